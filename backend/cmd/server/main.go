@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/api"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/database"
+	"github.com/huanhq99/H-Cloud/internal/jobs"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/mail"
+	"github.com/huanhq99/H-Cloud/internal/metrics"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"github.com/huanhq99/H-Cloud/internal/storage"
+	"github.com/huanhq99/H-Cloud/internal/tlscert"
+	"gorm.io/gorm"
+)
+
+func main() {
+	// 加载配置
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Fatal("加载配置失败: %v", err)
+	}
+
+	// 初始化日志
+	if err := logger.Init(logger.Options{
+		Level:      cfg.Log.Level,
+		Format:     cfg.Log.Format,
+		File:       cfg.Log.File,
+		MaxSize:    cfg.Log.MaxSize,
+		MaxBackups: cfg.Log.MaxBackups,
+		MaxAge:     cfg.Log.MaxAge,
+	}); err != nil {
+		logger.Fatal("初始化日志失败: %v", err)
+	}
+
+	// 校验配置，拒绝使用默认密钥等不安全的配置启动
+	warnings, err := cfg.Validate()
+	for _, w := range warnings {
+		logger.Warn("配置警告: %s", w)
+	}
+	if err != nil {
+		logger.Fatal("配置有误:\n%v", err)
+	}
+	config.OnReload(func(c *config.Config) {
+		logger.SetLevel(c.Log.Level)
+	})
+
+	// 初始化数据库和存储
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		logger.Fatal("初始化数据库失败: %v", err)
+	}
+	if err := storage.InitStorage(cfg); err != nil {
+		logger.Fatal("初始化存储失败: %v", err)
+	}
+
+	// 路由
+	r := gin.New()
+	r.Use(logger.RequestID())
+	if cfg.Log.AccessLog {
+		r.Use(logger.AccessLog("/livez", "/readyz", "/health"))
+	}
+	if cfg.Metrics.Enabled {
+		r.Use(metrics.Middleware())
+	}
+	r.Use(logger.Recovery(func(c *gin.Context) {
+		response.Error(c, response.ErrInternalServer)
+	}))
+	services := api.SetupRouter(r, db, cfg)
+	config.Watch()
+
+	// 定时任务，会话和 LDAP 使用路由中的同一实例
+	scheduler := jobs.NewScheduler()
+	scheduler.Every("清理过期会话", time.Hour, services.Sessions.PurgeExpired)
+	scheduler.Every("清理过期回收站", time.Hour, api.NewRecycleController(db).CleanExpiredItems)
+	scheduler.Every("清理图床流量统计", 24*time.Hour, api.NewImageGuard(db, cfg.ImageHost).PurgeTraffic)
+	scheduler.Every("清理缩略图缓存", 24*time.Hour, func(ctx context.Context) error {
+		return storage.PurgeThumbnails(ctx, api.ThumbnailCacheTTL)
+	})
+	if ldap := services.LDAP; ldap != nil {
+		scheduler.Every("LDAP 用户同步", time.Duration(cfg.Auth.LDAP.SyncInterval)*time.Minute, func(ctx context.Context) error {
+			_, err := ldap.Sync(ctx)
+			return err
+		})
+	}
+
+	// HTTPS 证书，文件更新后自动重新加载
+	var certs *tlscert.Manager
+	if cfg.Server.TLS.Enabled {
+		if certs, err = tlscert.NewManager(cfg.Server.TLS); err != nil {
+			logger.Fatal("初始化 TLS 失败: %v", err)
+		}
+		scheduler.Every("重新加载 TLS 证书", time.Duration(cfg.Server.TLS.ReloadInterval)*time.Second, certs.Reload)
+	}
+	scheduler.Start()
+
+	// TLS 握手失败等连接错误多由扫描器引起，按调试级别记录
+	errorLog := slog.NewLogLogger(logger.Slog().Handler(), slog.LevelDebug)
+	srv := &http.Server{
+		Addr:     fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:  r,
+		ErrorLog: errorLog,
+	}
+	servers := []*http.Server{srv}
+	if certs != nil {
+		if srv.TLSConfig, err = certs.TLSConfig(); err != nil {
+			logger.Fatal("初始化 TLS 失败: %v", err)
+		}
+		if !cfg.Server.TLS.HTTP2 {
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		logger.Info("H-Cloud 服务启动，监听 %s（HTTPS）", srv.Addr)
+		if cfg.Server.TLS.RedirectHTTP {
+			redirect := &http.Server{
+				Addr:              fmt.Sprintf(":%d", cfg.Server.TLS.HTTPPort),
+				Handler:           tlscert.RedirectHandler(cfg.Server.Port),
+				ReadHeaderTimeout: 10 * time.Second,
+				ErrorLog:          errorLog,
+			}
+			servers = append(servers, redirect)
+			logger.Info("HTTP 请求重定向到 HTTPS，监听 %s", redirect.Addr)
+		}
+	} else {
+		logger.Info("H-Cloud 服务启动，监听 %s", srv.Addr)
+	}
+	// 每个服务都能写入一个结果，停止时返回的 ErrServerClosed 不会阻塞 goroutine
+	serveErr := make(chan error, len(servers))
+	for _, s := range servers {
+		go func() {
+			if s.TLSConfig != nil {
+				serveErr <- s.ListenAndServeTLS("", "")
+			} else {
+				serveErr <- s.ListenAndServe()
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serveErr:
+		logger.Fatal("服务启动失败: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	shutdown(servers, scheduler, db, cfg)
+}
+
+// shutdown 优雅停止：先让就绪检查失败，等待负载均衡器摘除实例后停止接受新连接，
+// 再等待进行中的上传、下载完成，最后停止定时任务、发完邮件并关闭数据库
+func shutdown(servers []*http.Server, scheduler *jobs.Scheduler, db *gorm.DB, cfg *config.Config) {
+	api.SetDraining()
+	logger.Info("收到停止信号，开始停止服务")
+	if delay := time.Duration(cfg.Server.ShutdownDelay) * time.Second; delay > 0 {
+		logger.Info("等待 %s 后停止接受新连接", delay)
+		time.Sleep(delay)
+	}
+
+	// 关闭监听后等待进行中的请求完成，空闲连接立即关闭
+	drainTimeout := time.Duration(cfg.Server.DrainTimeout) * time.Second
+	if n := metrics.ActiveTransfers(); n > 0 {
+		logger.Info("等待 %d 个进行中的传输完成，最长 %s", n, drainTimeout)
+	}
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(drainCtx); err != nil {
+			logger.Warn("等待请求完成超时，强制断开 %d 个进行中的传输: %v", metrics.ActiveTransfers(), err)
+			srv.Close()
+		}
+	}
+
+	// 定时任务在处理完当前项目后中止，剩余部分下次启动后继续
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelStop()
+	if err := scheduler.Stop(stopCtx); err != nil {
+		logger.Warn("等待定时任务结束超时: %v", err)
+	}
+	if err := mail.Wait(stopCtx); err != nil {
+		logger.Warn("等待邮件发送完成超时: %v", err)
+	}
+	if err := database.Close(db); err != nil {
+		logger.Warn("关闭数据库失败: %v", err)
+	}
+	logger.Info("服务已停止")
+	logger.Close()
+}
@@ -1,151 +1,428 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
 	"github.com/huanhq99/H-Cloud/internal/config"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/ratelimit"
+	"github.com/huanhq99/H-Cloud/internal/rbac"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"github.com/huanhq99/H-Cloud/internal/storage"
+	"gorm.io/gorm"
 )
 
 // AdminController 管理员控制器
 type AdminController struct {
-	Config *config.Config
+	DB      *gorm.DB
+	Config  *config.Config
+	Auth    *AuthController
+	Authz   *rbac.Authorizer
+	LDAP    *LDAPProvider      // 未启用 LDAP 时为 nil
+	Limiter *ratelimit.Limiter // 未启用防暴力破解时为 nil
 }
 
 // NewAdminController 创建管理员控制器
-func NewAdminController(cfg *config.Config) *AdminController {
+func NewAdminController(db *gorm.DB, cfg *config.Config, auth *AuthController, authz *rbac.Authorizer, ldap *LDAPProvider, limiter *ratelimit.Limiter) *AdminController {
 	return &AdminController{
-		Config: cfg,
+		DB:      db,
+		Config:  cfg,
+		Auth:    auth,
+		Authz:   authz,
+		LDAP:    ldap,
+		Limiter: limiter,
 	}
 }
 
-// AdminClaims 管理员JWT声明
-type AdminClaims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	jwt.RegisteredClaims
-}
-
 // LoginRequest 登录请求
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse 登录响应
-type LoginResponse struct {
-	Token     string `json:"token"`
-	ExpiresAt int64  `json:"expires_at"`
-	Username  string `json:"username"`
-}
-
-// Login 管理员登录
+// Login 管理员登录：使用普通账户登录，要求角色拥有管理后台权限；
+// 除登录接口的通用字段外，额外返回 username 和 expires_at 供管理页面使用
 func (ac *AdminController) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		response.Error(c, response.ErrInvalidRequest, "请求参数错误")
 		return
 	}
 
-	// 验证用户名和密码
-	if req.Username != ac.Config.Admin.Username || req.Password != ac.Config.Admin.Password {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+	audit.SetActor(c, 0, req.Username)
+	u, _, _ := ac.Auth.checkCredentials(req.Username, "", req.Password)
+	if u == nil {
+		response.Error(c, response.ErrInvalidCredentials, "用户名或密码错误")
 		return
 	}
-
-	// 生成JWT token
-	expiresAt := time.Now().Add(time.Duration(ac.Config.JWT.ExpiresIn) * time.Hour)
-	claims := AdminClaims{
-		Username: req.Username,
-		Role:     "admin",
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "hqyun-admin",
-		},
+	if !ac.Authz.Has(u.Role, rbac.PermAdminAccess) {
+		audit.SetActor(c, u.ID, u.Username)
+		response.Error(c, response.ErrPermissionDenied, "权限不足")
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(ac.Config.JWT.Secret))
+	result, err := ac.Auth.loginResult(c, u)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成token失败"})
+		respondLoginError(c, err)
 		return
 	}
+	result["username"] = u.Username
+	if _, ok := result["token"]; ok {
+		result["expires_at"] = time.Now().Add(ac.Auth.Sessions.RefreshTTL).Unix()
+	}
+	response.Success(c, result)
+}
 
-	c.JSON(http.StatusOK, LoginResponse{
-		Token:     tokenString,
-		ExpiresAt: expiresAt.Unix(),
-		Username:  req.Username,
+// Me 获取当前管理员信息及其权限
+func (ac *AdminController) Me(c *gin.Context) {
+	var u model.User
+	if err := ac.DB.First(&u, c.GetUint("userID")).Error; err != nil {
+		response.Error(c, response.ErrUserNotFound, "用户不存在")
+		return
+	}
+	response.Success(c, gin.H{
+		"id":          u.ID,
+		"username":    u.Username,
+		"email":       u.Email,
+		"role":        u.Role,
+		"permissions": ac.Authz.Permissions(u.Role),
 	})
 }
 
-// Logout 管理员登出
-func (ac *AdminController) Logout(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "登出成功"})
+// ListPermissions 列出所有权限
+func (ac *AdminController) ListPermissions(c *gin.Context) {
+	var perms []model.Permission
+	if err := ac.DB.Order("code").Find(&perms).Error; err != nil {
+		logger.Request(c).Error("获取权限列表失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "获取权限列表失败")
+		return
+	}
+	items := make([]gin.H, 0, len(perms))
+	for _, p := range perms {
+		items = append(items, gin.H{"code": p.Code, "description": p.Description})
+	}
+	response.Success(c, gin.H{"permissions": items})
 }
 
-// Me 获取当前管理员信息
-func (ac *AdminController) Me(c *gin.Context) {
-	// 从中间件获取用户信息
-	username, exists := c.Get("admin_username")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+// roleJSON 角色的响应格式
+func (ac *AdminController) roleJSON(role *model.Role) gin.H {
+	codes := make([]string, 0, len(role.Permissions))
+	for _, p := range role.Permissions {
+		codes = append(codes, p.Code)
+	}
+	sort.Strings(codes)
+	var users int64
+	ac.DB.Model(&model.User{}).Where("role = ?", role.Name).Count(&users)
+	return gin.H{
+		"id":          role.ID,
+		"name":        role.Name,
+		"description": role.Description,
+		"system":      role.System,
+		"permissions": codes,
+		"userCount":   users,
+	}
+}
+
+// ListRoles 列出所有角色
+func (ac *AdminController) ListRoles(c *gin.Context) {
+	var roles []model.Role
+	if err := ac.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		logger.Request(c).Error("获取角色列表失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "获取角色列表失败")
 		return
 	}
+	items := make([]gin.H, 0, len(roles))
+	for i := range roles {
+		items = append(items, ac.roleJSON(&roles[i]))
+	}
+	response.Success(c, gin.H{"roles": items})
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"username": username,
-		"role":     "admin",
-	})
+// roleRequest 创建或修改角色的请求
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
 }
 
-// AdminAuthMiddleware 管理员认证中间件
-func AdminAuthMiddleware(jwtSecret string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 从Header获取token
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少认证token"})
-			c.Abort()
-			return
+// findPermissions 按权限代码查找权限，存在未知代码时返回错误
+func (ac *AdminController) findPermissions(codes []string) ([]model.Permission, error) {
+	perms := make([]model.Permission, 0, len(codes))
+	if len(codes) == 0 {
+		return perms, nil
+	}
+	if err := ac.DB.Where("code IN ?", codes).Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		found := false
+		for _, p := range perms {
+			if p.Code == code {
+				found = true
+				break
+			}
 		}
-
-		// 移除Bearer前缀
-		if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-			tokenString = tokenString[7:]
+		if !found {
+			return nil, fmt.Errorf("未知的权限: %s", code)
 		}
+	}
+	return perms, nil
+}
 
-		// 解析token
-		token, err := jwt.ParseWithClaims(tokenString, &AdminClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
+// CreateRole 创建角色
+func (ac *AdminController) CreateRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, response.ErrInvalidRequest, "请求参数错误")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 32 || strings.ContainsAny(req.Name, ", ") {
+		response.Error(c, response.ErrInvalidRequest, "角色名称不能为空、不超过32个字符且不能包含空格或逗号")
+		return
+	}
+	perms, err := ac.findPermissions(req.Permissions)
+	if err != nil {
+		response.Error(c, response.ErrInvalidRequest, err.Error())
+		return
+	}
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token"})
-			c.Abort()
-			return
+	var exist int64
+	ac.DB.Model(&model.Role{}).Where("name = ?", req.Name).Count(&exist)
+	if exist > 0 {
+		response.Error(c, response.ErrInvalidRequest, "角色已存在")
+		return
+	}
+	role := model.Role{Name: req.Name, Description: req.Description, Permissions: perms}
+	if err := ac.DB.Create(&role).Error; err != nil {
+		logger.Request(c).Error("创建角色失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "创建角色失败")
+		return
+	}
+	ac.Authz.Invalidate()
+	audit.SetTarget(c, audit.TargetRole, role.ID, role.Name)
+	audit.Describe(c, "权限: "+strings.Join(req.Permissions, ","))
+	response.SuccessWithStatus(c, http.StatusCreated, ac.roleJSON(&role))
+}
+
+// UpdateRole 修改角色说明和权限；内置管理员角色始终拥有全部权限，不能修改权限
+func (ac *AdminController) UpdateRole(c *gin.Context) {
+	var role model.Role
+	if err := ac.DB.Preload("Permissions").First(&role, c.Param("id")).Error; err != nil {
+		response.Error(c, response.ErrNotFound, "角色不存在")
+		return
+	}
+	audit.SetTarget(c, audit.TargetRole, role.ID, role.Name)
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, response.ErrInvalidRequest, "请求参数错误")
+		return
+	}
+	if req.Name != "" && req.Name != role.Name {
+		response.Error(c, response.ErrInvalidRequest, "不支持修改角色名称")
+		return
+	}
+	if role.Name == rbac.RoleAdmin && req.Permissions != nil {
+		response.Error(c, response.ErrInvalidRequest, "内置管理员角色拥有全部权限，不能修改")
+		return
+	}
+
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Update("description", req.Description).Error; err != nil {
+			return err
+		}
+		if req.Permissions == nil {
+			return nil
+		}
+		perms, err := ac.findPermissions(req.Permissions)
+		if err != nil {
+			return err
 		}
+		return tx.Model(&role).Association("Permissions").Replace(perms)
+	})
+	if err != nil {
+		response.Error(c, response.ErrInvalidRequest, "修改角色失败: " + err.Error())
+		return
+	}
+	ac.Authz.Invalidate()
+	if req.Permissions != nil {
+		audit.Describe(c, "权限: "+strings.Join(req.Permissions, ","))
+	}
+	ac.DB.Preload("Permissions").First(&role, role.ID)
+	response.Success(c, ac.roleJSON(&role))
+}
 
-		// 获取claims
-		if claims, ok := token.Claims.(*AdminClaims); ok {
-			// 检查是否为管理员角色
-			if claims.Role != "admin" {
-				c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
-				c.Abort()
-				return
-			}
+// DeleteRole 删除角色，内置角色和仍有用户使用的角色不能删除
+func (ac *AdminController) DeleteRole(c *gin.Context) {
+	var role model.Role
+	if err := ac.DB.First(&role, c.Param("id")).Error; err != nil {
+		response.Error(c, response.ErrNotFound, "角色不存在")
+		return
+	}
+	audit.SetTarget(c, audit.TargetRole, role.ID, role.Name)
+	if role.System {
+		response.Error(c, response.ErrInvalidRequest, "内置角色不能删除")
+		return
+	}
+	var users int64
+	ac.DB.Model(&model.User{}).Where("role = ?", role.Name).Count(&users)
+	if users > 0 {
+		response.Error(c, response.ErrInvalidRequest, fmt.Sprintf("仍有 %d 个用户使用该角色", users))
+		return
+	}
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&role).Error
+	})
+	if err != nil {
+		logger.Request(c).Error("删除角色失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "删除角色失败")
+		return
+	}
+	ac.Authz.Invalidate()
+		response.Success(c, gin.H{"message": "角色已删除"})
+}
 
-			// 将用户信息存储到上下文
-			c.Set("admin_username", claims.Username)
-			c.Set("admin_role", claims.Role)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token格式"})
-			c.Abort()
+// GetTwoFactorRoles 获取强制启用两步验证的角色列表
+func (ac *AdminController) GetTwoFactorRoles(c *gin.Context) {
+	response.Success(c, gin.H{"roles": getSettingList(ac.DB, settingRequire2FARoles)})
+}
+
+// UpdateTwoFactorRoles 设置强制启用两步验证的角色，这些角色的用户下次登录时必须先完成绑定
+func (ac *AdminController) UpdateTwoFactorRoles(c *gin.Context) {
+	var req struct {
+		Roles []string `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, response.ErrInvalidRequest, "请求参数错误")
+		return
+	}
+	roles := make([]string, 0, len(req.Roles))
+	for _, role := range req.Roles {
+		role = strings.TrimSpace(role)
+		if role == "" || strings.Contains(role, ",") {
+			response.Error(c, response.ErrInvalidRequest, "无效的角色: " + role)
+			return
+		}
+		if !hasScope(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) > 0 {
+		var known int64
+		ac.DB.Model(&model.Role{}).Where("name IN ?", roles).Count(&known)
+		if int(known) != len(roles) {
+			response.Error(c, response.ErrInvalidRequest, "包含不存在的角色")
 			return
 		}
+	}
+	if err := setSetting(ac.DB, settingRequire2FARoles, strings.Join(roles, ",")); err != nil {
+		logger.Request(c).Error("保存设置失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "保存设置失败")
+		return
+	}
+	response.Success(c, gin.H{"message": "设置已保存", "roles": roles})
+}
+
+// SyncLDAP 立即执行一次 LDAP 用户同步
+func (ac *AdminController) SyncLDAP(c *gin.Context) {
+	if ac.LDAP == nil {
+		response.Error(c, response.ErrNotFound, "未启用 LDAP")
+		return
+	}
+	result, err := ac.LDAP.Sync(c.Request.Context())
+	if err != nil {
+		response.Error(c, response.ErrBadGateway, "LDAP 同步失败: " + err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+// GetEncryption 查看静态加密状态：当前主密钥和各主密钥包装的文件数
+func (ac *AdminController) GetEncryption(c *gin.Context) {
+	stats, err := storage.RewrapKeys(c.Request.Context(), false)
+	if err != nil {
+		logger.Request(c).Error("统计加密文件失败: %v", err)
+		response.Error(c, response.ErrStorageFailure, "统计加密文件失败")
+		return
+	}
+	response.Success(c, stats)
+}
+
+// RewrapEncryptionKeys 轮换主密钥后，用当前主密钥重新包装所有文件的数据密钥，不重新加密文件内容
+func (ac *AdminController) RewrapEncryptionKeys(c *gin.Context) {
+	stats, err := storage.RewrapKeys(c.Request.Context(), true)
+	if errors.Is(err, storage.ErrNoMasterKey) {
+		response.Error(c, response.ErrNotFound, "未配置静态加密的主密钥")
+		return
+	}
+	if err != nil {
+		logger.Request(c).Error("重新包装数据密钥失败: %v", err)
+		response.Error(c, response.ErrStorageFailure, "重新包装数据密钥失败")
+		return
+	}
+	audit.Describe(c, fmt.Sprintf("主密钥 %s，重新包装 %d 个文件，失败 %d 个", stats.PrimaryKey, stats.Rewrapped, len(stats.Failed)))
+	response.Success(c, stats)
+}
 
-		c.Next()
+// ListLocks 列出当前因多次失败被锁定的 IP 和账户
+func (ac *AdminController) ListLocks(c *gin.Context) {
+	if ac.Limiter == nil {
+		response.Error(c, response.ErrNotFound, "未启用防暴力破解")
+		return
 	}
-}
\ No newline at end of file
+	locks, err := ac.Limiter.Locks()
+	if err != nil {
+		logger.Request(c).Error("获取锁定列表失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "获取锁定列表失败")
+		return
+	}
+	response.Success(c, gin.H{"locks": locks})
+}
+
+// ClearLocks 解除锁定，指定 key 时只解除该项，否则全部解除
+func (ac *AdminController) ClearLocks(c *gin.Context) {
+	if ac.Limiter == nil {
+		response.Error(c, response.ErrNotFound, "未启用防暴力破解")
+		return
+	}
+	key := c.Query("key")
+	var err error
+	if key != "" {
+		err = ac.Limiter.Reset(key)
+	} else {
+		err = ac.Limiter.ResetAll()
+		key = "全部"
+	}
+	if err != nil {
+		logger.Request(c).Error("解除锁定失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "解除锁定失败")
+		return
+	}
+	audit.Describe(c, "解除锁定 "+key)
+	response.Success(c, gin.H{"message": "已解除锁定"})
+}
+
+// GetConfig 查看当前生效的配置，密钥和密码已隐藏
+func (ac *AdminController) GetConfig(c *gin.Context) {
+	cfg := config.Current()
+	if cfg == nil {
+		cfg = ac.Config
+	}
+	response.Success(c, gin.H{
+		"config":     config.Flatten(cfg, true),
+		"file":       config.File(),
+		"loadedAt":   config.LoadedAt().Format(time.RFC3339),
+		"reloadable": config.Reloadable,
+	})
+}
@@ -0,0 +1,491 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/mail"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/rbac"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// 用户列表分页
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// errLastAdmin 操作会导致系统中没有可用的管理员
+var errLastAdmin = errors.New("不能移除最后一个管理员")
+
+// adminUserJSON 管理端的用户信息
+func adminUserJSON(u *model.User, fileCount int64) gin.H {
+	var lastLogin interface{}
+	if !u.LastLogin.IsZero() {
+		lastLogin = u.LastLogin.Format(time.RFC3339)
+	}
+	return gin.H{
+		"id":                 u.ID,
+		"username":           u.Username,
+		"email":              u.Email,
+		"role":               u.Role,
+		"authSource":         u.AuthSource,
+		"disabled":           u.Disabled,
+		"pendingApproval":    u.PendingApproval,
+		"storageQuota":       u.StorageQuota,
+		"storageUsed":        u.StorageUsed,
+		"fileCount":          fileCount,
+		"lastLogin":          lastLogin,
+		"createdAt":          u.CreatedAt.Format(time.RFC3339),
+		"twoFactorEnabled":   u.TOTPEnabled,
+		"mustChangePassword": u.MustChangePassword,
+	}
+}
+
+// fileCounts 统计用户的文件数量
+func (ac *AdminController) fileCounts(ids []uint) map[uint]int64 {
+	counts := make(map[uint]int64, len(ids))
+	if len(ids) == 0 {
+		return counts
+	}
+	var rows []struct {
+		UserID uint
+		Count  int64
+	}
+	ac.DB.Model(&model.File{}).Select("user_id, count(*) as count").
+		Where("user_id IN ?", ids).Group("user_id").Scan(&rows)
+	for _, r := range rows {
+		counts[r.UserID] = r.Count
+	}
+	return counts
+}
+
+// findUser 按路径参数 id 查找用户，找不到时直接返回错误响应
+func (ac *AdminController) findUser(c *gin.Context) (*model.User, bool) {
+	var u model.User
+	if err := ac.DB.First(&u, c.Param("id")).Error; err != nil {
+		response.Error(c, response.ErrUserNotFound, "用户不存在")
+		return nil, false
+	}
+	audit.SetTarget(c, audit.TargetUser, u.ID, u.Username)
+	return &u, true
+}
+
+// checkLastAdmin 禁用用户或取消其管理权限前检查是否还有其他管理员
+func (ac *AdminController) checkLastAdmin(u *model.User) error {
+	if u.Disabled || !ac.Authz.Has(u.Role, rbac.PermAdminAccess) {
+		return nil
+	}
+	count, err := rbac.HolderCount(ac.DB, rbac.PermAdminAccess)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+// roleExists 判断角色是否存在
+func (ac *AdminController) roleExists(name string) bool {
+	var count int64
+	ac.DB.Model(&model.Role{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// ListUsers 分页列出用户，支持按用户名或邮箱搜索，按角色和状态筛选
+func (ac *AdminController) ListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultUserPageSize)))
+	if pageSize < 1 || pageSize > maxUserPageSize {
+		pageSize = defaultUserPageSize
+	}
+
+	q := ac.DB.Model(&model.User{})
+	if keyword := strings.TrimSpace(c.Query("q")); keyword != "" {
+		like := "%" + keyword + "%"
+		q = q.Where("username LIKE ? OR email LIKE ?", like, like)
+	}
+	if role := c.Query("role"); role != "" {
+		q = q.Where("role = ?", role)
+	}
+	switch c.Query("status") {
+	case "active":
+		q = q.Where("disabled = ? AND pending_approval = ?", false, false)
+	case "disabled":
+		q = q.Where("disabled = ?", true)
+	case "pending":
+		q = q.Where("pending_approval = ?", true)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		logger.Request(c).Error("获取用户列表失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "获取用户列表失败")
+		return
+	}
+	var users []model.User
+	if err := q.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&users).Error; err != nil {
+		logger.Request(c).Error("获取用户列表失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "获取用户列表失败")
+		return
+	}
+
+	ids := make([]uint, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	counts := ac.fileCounts(ids)
+	items := make([]gin.H, 0, len(users))
+	for i := range users {
+		items = append(items, adminUserJSON(&users[i], counts[users[i].ID]))
+	}
+	response.Success(c, gin.H{"users": items, "total": total, "page": page, "pageSize": pageSize})
+}
+
+// GetUser 获取单个用户详情
+func (ac *AdminController) GetUser(c *gin.Context) {
+	u, ok := ac.findUser(c)
+	if !ok {
+		return
+	}
+	response.Success(c, adminUserJSON(u, ac.fileCounts([]uint{u.ID})[u.ID]))
+}
+
+// CreateUser 创建本地用户；未指定密码时生成临时密码，用户首次登录后必须修改
+func (ac *AdminController) CreateUser(c *gin.Context) {
+	var req struct {
+		Username     string `json:"username"`
+		Email        string `json:"email"`
+		Password     string `json:"password"`
+		Role         string `json:"role"`
+		StorageQuota *int64 `json:"storageQuota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, response.ErrInvalidRequest, "请求参数错误")
+		return
+	}
+	req.Username, req.Email = strings.TrimSpace(req.Username), strings.TrimSpace(req.Email)
+	if req.Username == "" || !mail.ValidAddress(req.Email) {
+		response.Error(c, response.ErrInvalidRequest, "用户名不能为空，邮箱格式必须正确")
+		return
+	}
+	if req.Role == "" {
+		req.Role = rbac.RoleUser
+	}
+	if !ac.roleExists(req.Role) {
+		response.Error(c, response.ErrInvalidRequest, "角色不存在")
+		return
+	}
+	if req.StorageQuota != nil && *req.StorageQuota < 0 {
+		response.Error(c, response.ErrInvalidRequest, "无效的存储配额")
+		return
+	}
+
+	var cnt int64
+	ac.DB.Model(&model.User{}).Where("username = ? OR email = ?", req.Username, req.Email).Count(&cnt)
+	if cnt > 0 {
+		response.Error(c, response.ErrUserExists, "用户名或邮箱已存在")
+		return
+	}
+
+	password, generated := req.Password, false
+	if password == "" {
+		var err error
+		if password, err = rbac.RandomPassword(16); err != nil {
+			logger.Request(c).Error("生成密码失败: %v", err)
+			response.Error(c, response.ErrInternalServer, "生成密码失败")
+			return
+		}
+		generated = true
+	} else if len(password) < minPasswordLength {
+		response.Error(c, response.ErrInvalidRequest, fmt.Sprintf("密码至少需要 %d 个字符", minPasswordLength))
+		return
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Request(c).Error("加密密码失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "加密密码失败")
+		return
+	}
+
+	// 管理员创建的账户视为邮箱已验证
+	now := time.Now()
+	u := model.User{
+		Username:           req.Username,
+		Email:              req.Email,
+		Password:           string(hashed),
+		Role:               req.Role,
+		MustChangePassword: true,
+		EmailVerifiedAt:    &now,
+	}
+	if req.StorageQuota != nil {
+		u.StorageQuota = *req.StorageQuota
+	}
+	if err := ac.DB.Create(&u).Error; err != nil {
+		logger.Request(c).Error("创建用户失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "创建用户失败")
+		return
+	}
+	// 配额为 0 时 gorm 会使用字段默认值，需要单独写入
+	if req.StorageQuota != nil && *req.StorageQuota == 0 {
+		ac.DB.Model(&u).Update("storage_quota", 0)
+	}
+	audit.SetTarget(c, audit.TargetUser, u.ID, u.Username)
+	audit.Describe(c, "角色 "+u.Role)
+
+	resp := adminUserJSON(&u, 0)
+	if generated {
+		resp["temporaryPassword"] = password
+	}
+	response.SuccessWithStatus(c, http.StatusCreated, resp)
+}
+
+// UpdateUser 修改用户的邮箱、角色和存储配额；角色变更后注销该用户的全部会话使其立即生效
+func (ac *AdminController) UpdateUser(c *gin.Context) {
+	u, ok := ac.findUser(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Email        *string `json:"email"`
+		Role         *string `json:"role"`
+		StorageQuota *int64  `json:"storageQuota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, response.ErrInvalidRequest, "请求参数错误")
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Email != nil && strings.TrimSpace(*req.Email) != u.Email {
+		email := strings.TrimSpace(*req.Email)
+		var cnt int64
+		ac.DB.Model(&model.User{}).Where("email = ? AND id <> ?", email, u.ID).Count(&cnt)
+		if !mail.ValidAddress(email) || cnt > 0 {
+			response.Error(c, response.ErrInvalidRequest, "邮箱格式不正确或已被使用")
+			return
+		}
+		updates["email"] = email
+	}
+	roleChanged := req.Role != nil && *req.Role != u.Role
+	if roleChanged {
+		if u.ID == c.GetUint("userID") {
+			response.Error(c, response.ErrInvalidRequest, "不能修改自己的角色")
+			return
+		}
+		if !ac.roleExists(*req.Role) {
+			response.Error(c, response.ErrInvalidRequest, "角色不存在")
+			return
+		}
+		if !ac.Authz.Has(*req.Role, rbac.PermAdminAccess) {
+			if err := ac.checkLastAdmin(u); err != nil {
+				response.Error(c, response.ErrInvalidRequest, err.Error())
+				return
+			}
+		}
+		updates["role"] = *req.Role
+	}
+	if req.StorageQuota != nil {
+		if *req.StorageQuota < 0 {
+			response.Error(c, response.ErrInvalidRequest, "无效的存储配额")
+			return
+		}
+		updates["storage_quota"] = *req.StorageQuota
+	}
+
+	if len(updates) > 0 {
+		if err := ac.DB.Model(u).Updates(updates).Error; err != nil {
+			logger.Request(c).Error("修改用户失败: %v", err)
+			response.Error(c, response.ErrInternalServer, "修改用户失败")
+			return
+		}
+	}
+	if roleChanged {
+		ac.Auth.Sessions.RevokeAll(u.ID)
+		audit.Describe(c, fmt.Sprintf("角色 %s -> %s", u.Role, *req.Role))
+	}
+	ac.DB.First(u, u.ID)
+	response.Success(c, adminUserJSON(u, ac.fileCounts([]uint{u.ID})[u.ID]))
+}
+
+// DisableUser 禁用用户并注销其全部会话
+func (ac *AdminController) DisableUser(c *gin.Context) {
+	u, ok := ac.findUser(c)
+	if !ok {
+		return
+	}
+	if u.ID == c.GetUint("userID") {
+		response.Error(c, response.ErrInvalidRequest, "不能禁用自己")
+		return
+	}
+	if err := ac.checkLastAdmin(u); err != nil {
+		response.Error(c, response.ErrInvalidRequest, err.Error())
+		return
+	}
+	if err := ac.DB.Model(u).Update("disabled", true).Error; err != nil {
+		logger.Request(c).Error("禁用用户失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "禁用用户失败")
+		return
+	}
+	ac.Auth.Sessions.RevokeAll(u.ID)
+	response.Success(c, gin.H{"message": "用户已禁用"})
+}
+
+// EnableUser 启用用户
+func (ac *AdminController) EnableUser(c *gin.Context) {
+	u, ok := ac.findUser(c)
+	if !ok {
+		return
+	}
+	if err := ac.DB.Model(u).Update("disabled", false).Error; err != nil {
+		logger.Request(c).Error("启用用户失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "启用用户失败")
+		return
+	}
+	response.Success(c, gin.H{"message": "用户已启用"})
+}
+
+// ResetUserPassword 重置本地用户的密码，未指定密码时生成临时密码；
+// 用户的全部会话被注销，下次登录后必须修改密码
+func (ac *AdminController) ResetUserPassword(c *gin.Context) {
+	u, ok := ac.findUser(c)
+	if !ok {
+		return
+	}
+	if u.AuthSource != authSourceLocal {
+		response.Error(c, response.ErrInvalidRequest, "外部账户的密码由身份提供方管理")
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+	}
+	_ = c.ShouldBindJSON(&req) // 请求体可以为空
+
+	password, generated := req.Password, false
+	if password == "" {
+		var err error
+		if password, err = rbac.RandomPassword(16); err != nil {
+			logger.Request(c).Error("生成密码失败: %v", err)
+			response.Error(c, response.ErrInternalServer, "生成密码失败")
+			return
+		}
+		generated = true
+	} else if len(password) < minPasswordLength {
+		response.Error(c, response.ErrInvalidRequest, fmt.Sprintf("密码至少需要 %d 个字符", minPasswordLength))
+		return
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Request(c).Error("加密密码失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "加密密码失败")
+		return
+	}
+	err = ac.DB.Model(u).Updates(map[string]interface{}{"password": string(hashed), "must_change_password": true}).Error
+	if err != nil {
+		logger.Request(c).Error("重置密码失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "重置密码失败")
+		return
+	}
+	ac.Auth.Sessions.RevokeAll(u.ID)
+
+	resp := gin.H{"message": "密码已重置，用户下次登录后必须修改密码"}
+	if generated {
+		resp["temporaryPassword"] = password
+	}
+	response.Success(c, resp)
+}
+
+// ApproveUser 通过待审核的注册申请，并通知用户
+func (ac *AdminController) ApproveUser(c *gin.Context) {
+	u, ok := ac.findUser(c)
+	if !ok {
+		return
+	}
+	if !u.PendingApproval {
+		response.Error(c, response.ErrInvalidRequest, "该用户不在待审核列表中")
+		return
+	}
+	if err := ac.DB.Model(u).Update("pending_approval", false).Error; err != nil {
+		logger.Request(c).Error("审核用户失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "审核用户失败")
+		return
+	}
+	ac.Auth.Mailer.SendAsync(u.Email, mail.TemplateAccountApproved, emailData{
+		Username: u.Username,
+		Email:    u.Email,
+		Link:     ac.Auth.Mailer.Link("/"),
+	})
+	response.Success(c, gin.H{"message": "已通过审核"})
+}
+
+// RejectUser 拒绝待审核的注册申请，删除该账户以便用户名和邮箱可以重新注册
+func (ac *AdminController) RejectUser(c *gin.Context) {
+	u, ok := ac.findUser(c)
+	if !ok {
+		return
+	}
+	if !u.PendingApproval {
+		response.Error(c, response.ErrInvalidRequest, "该用户不在待审核列表中")
+		return
+	}
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", u.ID).Delete(&model.EmailToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(u).Error
+	})
+	if err != nil {
+		logger.Request(c).Error("拒绝注册申请失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "拒绝注册申请失败")
+		return
+	}
+	response.Success(c, gin.H{"message": "已拒绝注册申请"})
+}
+
+// ImpersonateUser 以目标用户身份登录以便排查问题。
+// 代登录会话有效期较短、不能续期，也不能修改密码、两步验证和访问令牌；
+// 不能代登录管理员或已禁用的用户
+func (ac *AdminController) ImpersonateUser(c *gin.Context) {
+	u, ok := ac.findUser(c)
+	if !ok {
+		return
+	}
+	adminID := c.GetUint("userID")
+	if _, nested := c.Get("impersonatorID"); nested || u.ID == adminID {
+		response.Error(c, response.ErrInvalidRequest, "不能代登录该用户")
+		return
+	}
+	if u.Disabled {
+		response.Error(c, response.ErrInvalidRequest, errAccountDisabled.Error())
+		return
+	}
+	if ac.Authz.Has(u.Role, rbac.PermAdminAccess) {
+		response.Error(c, response.ErrForbidden, "不能代登录管理员")
+		return
+	}
+
+	tokens, err := ac.Auth.Sessions.Impersonate(c, u, adminID)
+	if err != nil {
+		logger.Request(c).Error("生成令牌失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "生成令牌失败")
+		return
+	}
+	audit.Describe(c, fmt.Sprintf("代登录会话 %d", tokens.SessionID))
+	resp := loginResponse(tokens, u)
+	resp["impersonatorId"] = adminID
+	response.Success(c, resp)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"gorm.io/gorm"
+)
+
+// 审计日志分页
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// AuditController 审计日志查询
+type AuditController struct {
+	DB *gorm.DB
+}
+
+func NewAuditController(db *gorm.DB) *AuditController {
+	return &AuditController{DB: db}
+}
+
+// parseAuditTime 解析时间参数，支持 RFC3339 和日期（按本地时区当天零点）
+func parseAuditTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// auditFilter 从查询参数构造筛选条件
+func auditFilter(c *gin.Context) (audit.Filter, error) {
+	f := audit.Filter{
+		Action:     c.Query("action"),
+		Result:     c.Query("result"),
+		Actor:      c.Query("actor"),
+		TargetType: c.Query("targetType"),
+		TargetID:   c.Query("targetId"),
+		IP:         c.Query("ip"),
+	}
+	if v := c.Query("actorId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return f, fmt.Errorf("无效的 actorId")
+		}
+		f.ActorID = uint(id)
+	}
+	var err error
+	if f.From, err = parseAuditTime(c.Query("from")); err != nil {
+		return f, fmt.Errorf("无效的开始时间")
+	}
+	if f.To, err = parseAuditTime(c.Query("to")); err != nil {
+		return f, fmt.Errorf("无效的结束时间")
+	}
+	return f, nil
+}
+
+// ListAuditLogs 分页查询审计日志，按时间倒序
+func (ac *AuditController) ListAuditLogs(c *gin.Context) {
+	f, err := auditFilter(c)
+	if err != nil {
+		response.Error(c, response.ErrInvalidRequest, err.Error())
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultAuditPageSize)))
+	if pageSize < 1 || pageSize > maxAuditPageSize {
+		pageSize = defaultAuditPageSize
+	}
+
+	var total int64
+	if err := audit.Query(ac.DB, f).Count(&total).Error; err != nil {
+		logger.Request(c).Error("查询审计日志失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "查询审计日志失败")
+		return
+	}
+	var logs []model.AuditLog
+	if err := audit.Query(ac.DB, f).Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		logger.Request(c).Error("查询审计日志失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "查询审计日志失败")
+		return
+	}
+	items := make([]map[string]interface{}, 0, len(logs))
+	for i := range logs {
+		items = append(items, audit.JSON(&logs[i]))
+	}
+	response.Success(c, gin.H{"logs": items, "total": total, "page": page, "pageSize": pageSize})
+}
+
+// ExportAuditLogs 以 JSON Lines 格式导出审计日志，筛选条件与查询接口相同
+func (ac *AuditController) ExportAuditLogs(c *gin.Context) {
+	f, err := auditFilter(c)
+	if err != nil {
+		response.Error(c, response.ErrInvalidRequest, err.Error())
+		return
+	}
+	filename := fmt.Sprintf("audit-%s.jsonl", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+	// 响应头已经发出，出错时只能记录日志
+	if err := audit.Export(ac.DB, f, c.Writer); err != nil {
+		logger.Request(c).Error("导出审计日志失败: %v", err)
+	}
+}
@@ -1,12 +1,22 @@
 package api
 
 import (
+    "errors"
+    "fmt"
     "net/http"
+    "strconv"
+    "strings"
     "time"
 
     "github.com/gin-gonic/gin"
+    "github.com/huanhq99/H-Cloud/internal/audit"
     "github.com/huanhq99/H-Cloud/internal/config"
+    "github.com/huanhq99/H-Cloud/internal/i18n"
+    "github.com/huanhq99/H-Cloud/internal/ldapauth"
+    "github.com/huanhq99/H-Cloud/internal/logger"
+    "github.com/huanhq99/H-Cloud/internal/mail"
     "github.com/huanhq99/H-Cloud/internal/model"
+    "github.com/huanhq99/H-Cloud/internal/response"
     "github.com/golang-jwt/jwt/v5"
     "golang.org/x/crypto/bcrypt"
     "gorm.io/gorm"
@@ -14,78 +24,152 @@ import (
 
 // Claims 自定义JWT声明
 type Claims struct {
-    UserID uint   `json:"uid"`
-    Role   string `json:"role"`
+    UserID             uint   `json:"uid"`
+    Role               string `json:"role"`
+    SessionID          uint   `json:"sid"`           // 所属会话，会话注销后令牌立即失效
+    MustChangePassword bool   `json:"pwc,omitempty"` // 必须先修改密码，期间只能访问少数接口
+    ImpersonatorID     *uint  `json:"imp,omitempty"` // 管理员代登录时为管理员的用户ID
+    Locale             string `json:"lng,omitempty"` // 用户首选语言，错误消息按该语言返回
     jwt.RegisteredClaims
 }
 
+// minPasswordLength 修改密码时的最小长度
+const minPasswordLength = 8
+
 // AuthController 账户控制器
 type AuthController struct {
-    DB        *gorm.DB
-    JWTSecret string
-    ExpiresIn int // 小时
+    DB           *gorm.DB
+    Sessions     *SessionManager
+    LDAP         *LDAPProvider // 未启用 LDAP 时为 nil
+    Mailer       *mail.Mailer
+    Registration config.RegistrationConfig
 }
 
-func NewAuthController(db *gorm.DB, cfg *config.Config) *AuthController {
-    return &AuthController{DB: db, JWTSecret: cfg.JWT.Secret, ExpiresIn: cfg.JWT.ExpiresIn}
+func NewAuthController(db *gorm.DB, sessions *SessionManager, ldap *LDAPProvider, mailer *mail.Mailer, cfg *config.Config) *AuthController {
+    return &AuthController{DB: db, Sessions: sessions, LDAP: ldap, Mailer: mailer, Registration: cfg.Auth.Registration}
 }
 
-// generateToken 生成JWT令牌
-func (a *AuthController) generateToken(uid uint, role string) (string, error) {
-    claims := &Claims{
-        UserID: uid,
-        Role:   role,
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(a.ExpiresIn) * time.Hour)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-        },
+// loginResponse 构造登录成功的响应
+func loginResponse(tokens *TokenPair, u *model.User) gin.H {
+    return gin.H{
+        "token":              tokens.AccessToken,
+        "refreshToken":       tokens.RefreshToken,
+        "expiresIn":          tokens.ExpiresIn,
+        "sessionId":          tokens.SessionID,
+        "user":               gin.H{"id": u.ID, "username": u.Username, "email": u.Email, "role": u.Role},
+        "mustChangePassword": u.MustChangePassword,
     }
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString([]byte(a.JWTSecret))
 }
 
-// Register 用户注册
+// Register 用户注册，按注册模式检查邀请码和邮箱域名；
+// 使用邀请码注册时按邀请码设置角色和存储配额，且无需管理员审核
 func (a *AuthController) Register(ctx *gin.Context) {
     var req struct {
-        Username string `json:"username"`
-        Password string `json:"password"`
-        Email    string `json:"email"`
+        Username   string `json:"username"`
+        Password   string `json:"password"`
+        Email      string `json:"email"`
+        InviteCode string `json:"inviteCode"`
     }
     if err := ctx.ShouldBindJSON(&req); err != nil || req.Username == "" || req.Password == "" || req.Email == "" {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的注册参数"})
+        response.Error(ctx, response.ErrInvalidRequest, "无效的注册参数")
+        return
+    }
+    if !mail.ValidAddress(req.Email) {
+        response.Error(ctx, response.ErrInvalidRequest, "邮箱格式不正确")
+        return
+    }
+
+    // 注册策略
+    mode, code := a.registrationMode(), strings.TrimSpace(req.InviteCode)
+    switch {
+    case mode == config.RegistrationDisabled:
+        response.Error(ctx, response.ErrRegistrationClosed, errRegistrationClosed.Error())
+        return
+    case mode == config.RegistrationInvite && code == "":
+        response.ErrorWithData(ctx, response.ErrRegistrationClosed, gin.H{"inviteRequired": true}, errInviteRequired.Error())
+        return
+    case code == "" && !a.emailDomainAllowed(req.Email):
+        response.Error(ctx, response.ErrRegistrationClosed, errEmailDomain.Error())
         return
     }
 
     // 唯一性检查
     var cnt int64
     if err := a.DB.Model(&model.User{}).Where("username = ? OR email = ?", req.Username, req.Email).Count(&cnt).Error; err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "检查用户失败"})
+        logger.Request(ctx).Error("检查用户失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "检查用户失败")
         return
     }
     if cnt > 0 {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "用户名或邮箱已存在"})
+        response.Error(ctx, response.ErrUserExists, "用户名或邮箱已存在")
         return
     }
 
     // 加密密码
     hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "加密密码失败"})
+        logger.Request(ctx).Error("加密密码失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "加密密码失败")
         return
     }
 
     u := &model.User{Username: req.Username, Password: string(hashed), Email: req.Email, Role: "user"}
-    if err := a.DB.Create(u).Error; err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建用户失败"})
+    err = a.DB.Transaction(func(tx *gorm.DB) error {
+        var invite *model.InviteCode
+        if code != "" {
+            var err error
+            if invite, err = redeemInvite(tx, code); err != nil {
+                return err
+            }
+            u.InviteCodeID = &invite.ID
+            if invite.Role != "" {
+                u.Role = invite.Role
+            }
+            if invite.StorageQuota != nil {
+                u.StorageQuota = *invite.StorageQuota
+            }
+        } else {
+            u.PendingApproval = mode == config.RegistrationApproval
+        }
+        if err := tx.Create(u).Error; err != nil {
+            return err
+        }
+        // 配额为 0 时 gorm 会使用字段默认值，需要单独写入
+        if invite != nil && invite.StorageQuota != nil && *invite.StorageQuota == 0 {
+            return tx.Model(u).Update("storage_quota", 0).Error
+        }
+        return nil
+    })
+    if errors.Is(err, errInviteInvalid) {
+        response.Error(ctx, response.ErrInvalidRequest, err.Error())
         return
     }
-
-    token, err := a.generateToken(u.ID, u.Role)
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+        logger.Request(ctx).Error("创建用户失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "创建用户失败")
+        return
+    }
+    if u.InviteCodeID != nil {
+        audit.Describe(ctx, fmt.Sprintf("使用邀请码 %d 注册，角色 %s", *u.InviteCodeID, u.Role))
+    }
+
+    // 发送验证邮件；要求验证邮箱时，验证后才能登录
+    if err := a.sendVerification(u, u.Email); err != nil {
+        logger.Request(ctx).Error("发送邮箱验证邮件失败: %v", err)
+    }
+    if u.PendingApproval || a.Registration.RequireEmailVerification {
+        msg := "注册成功，请查收验证邮件完成验证"
+        if u.PendingApproval {
+            msg = "注册成功，请等待管理员审核"
+        }
+        response.SuccessWithStatus(ctx, http.StatusCreated, gin.H{
+            "message":                   msg,
+            "approvalRequired":          u.PendingApproval,
+            "emailVerificationRequired": a.Registration.RequireEmailVerification,
+        })
         return
     }
-    ctx.JSON(http.StatusOK, gin.H{"token": token, "user": gin.H{"id": u.ID, "username": u.Username, "email": u.Email, "role": u.Role}})
+    a.completeLogin(ctx, u)
 }
 
 // Login 用户登录（支持用户名或邮箱）
@@ -96,70 +180,332 @@ func (a *AuthController) Login(ctx *gin.Context) {
         Password string `json:"password"`
     }
     if err := ctx.ShouldBindJSON(&req); err != nil || req.Password == "" || (req.Username == "" && req.Email == "") {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的登录参数"})
+        response.Error(ctx, response.ErrInvalidRequest, "无效的登录参数")
         return
     }
 
+    audit.SetActor(ctx, 0, req.Username+req.Email)
+    u, code, msg := a.checkCredentials(req.Username, req.Email, req.Password)
+    if u == nil {
+        response.Error(ctx, code, msg)
+        return
+    }
+    // 启用了两步验证的账户先返回部分令牌，提交验证码后才签发会话
+    a.completeLogin(ctx, u)
+}
+
+// checkCredentials 校验用户名（或邮箱）和密码，失败时返回错误代码和错误信息；
+// 本地账户使用 bcrypt 校验，LDAP 账户及本地不存在的用户交给 LDAP
+func (a *AuthController) checkCredentials(username, email, password string) (*model.User, response.ErrorCode, string) {
     var u model.User
     var q *gorm.DB
-    if req.Email != "" { q = a.DB.Where("email = ?", req.Email) } else { q = a.DB.Where("username = ?", req.Username) }
-    if err := q.First(&u).Error; err != nil {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+    if email != "" { q = a.DB.Where("email = ?", email) } else { q = a.DB.Where("username = ?", username) }
+    err := q.First(&u).Error
+
+    if a.LDAP != nil && (err != nil || u.AuthSource == authSourceLDAP) {
+        identifier := username
+        if identifier == "" { identifier = email }
+        user, err := a.LDAP.Login(identifier, password)
+        if err != nil {
+            if errors.Is(err, ldapauth.ErrInvalidCredentials) || errors.Is(err, errLDAPNotProvisioned) {
+                return nil, response.ErrInvalidCredentials, err.Error()
+            }
+            // 连接、搜索等错误可能包含目录结构和服务器信息，只记录日志，客户端只看到通用提示
+            logger.With("username", identifier).Warn("LDAP 登录失败: %v", err)
+            return nil, response.ErrInvalidCredentials, "目录认证失败"
+        }
+        return user, response.CodeSuccess, ""
+    }
+
+    if err != nil {
+        return nil, response.ErrInvalidCredentials, "用户不存在"
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+        return nil, response.ErrInvalidCredentials, "密码错误"
+    }
+    return &u, response.CodeSuccess, ""
+}
+
+// ChangePassword 修改密码，成功后注销全部会话并签发新会话
+func (a *AuthController) ChangePassword(ctx *gin.Context) {
+    uidVal, exists := ctx.Get("userID")
+    if !exists {
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
+        return
+    }
+    var req struct {
+        OldPassword string `json:"oldPassword"`
+        NewPassword string `json:"newPassword"`
+    }
+    if err := ctx.ShouldBindJSON(&req); err != nil || req.OldPassword == "" || req.NewPassword == "" {
+        response.Error(ctx, response.ErrInvalidRequest, "无效的参数")
+        return
+    }
+    if len(req.NewPassword) < minPasswordLength {
+        response.Error(ctx, response.ErrInvalidRequest, fmt.Sprintf("新密码至少需要 %d 个字符", minPasswordLength))
+        return
+    }
+    if req.NewPassword == req.OldPassword {
+        response.Error(ctx, response.ErrInvalidRequest, "新密码不能与原密码相同")
+        return
+    }
+
+    var u model.User
+    if err := a.DB.First(&u, uidVal.(uint)).Error; err != nil {
+        response.Error(ctx, response.ErrUserNotFound, "用户不存在")
+        return
+    }
+    if u.AuthSource != authSourceLocal {
+        response.Error(ctx, response.ErrInvalidRequest, "外部账户请在身份提供方修改密码")
+        return
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.OldPassword)); err != nil {
+        response.Error(ctx, response.ErrPasswordIncorrect, "原密码错误")
+        return
+    }
+
+    hashed, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+    if err != nil {
+        logger.Request(ctx).Error("加密密码失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "加密密码失败")
+        return
+    }
+    err = a.DB.Model(&u).Updates(map[string]interface{}{"password": string(hashed), "must_change_password": false}).Error
+    if err != nil {
+        logger.Request(ctx).Error("修改密码失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "修改密码失败")
+        return
+    }
+
+    // 其他设备上的会话全部失效，当前设备换发新会话
+    if _, err := a.Sessions.RevokeAll(u.ID); err != nil {
+        logger.Request(ctx).Error("注销会话失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "注销会话失败")
+        return
+    }
+    tokens, err := a.Sessions.Issue(ctx, &u)
+    if err != nil {
+        logger.Request(ctx).Error("生成令牌失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "生成令牌失败")
+        return
+    }
+    resp := loginResponse(tokens, &u)
+    resp["message"] = "密码已修改"
+    response.Success(ctx, resp)
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌（刷新令牌同时轮换）
+func (a *AuthController) Refresh(ctx *gin.Context) {
+    var req struct {
+        RefreshToken string `json:"refreshToken"`
+    }
+    if err := ctx.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+        response.Error(ctx, response.ErrInvalidRequest, "缺少刷新令牌")
+        return
+    }
+    tokens, err := a.Sessions.Refresh(ctx, req.RefreshToken)
+    if err != nil {
+        response.Error(ctx, response.ErrTokenInvalid, err.Error())
+        return
+    }
+    audit.SetTarget(ctx, audit.TargetSession, tokens.SessionID, "")
+    response.Success(ctx, tokens)
+}
+
+// Logout 注销当前会话
+func (a *AuthController) Logout(ctx *gin.Context) {
+    uidVal, exists := ctx.Get("userID")
+    if !exists {
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
+        return
+    }
+    if _, err := a.Sessions.Revoke(uidVal.(uint), ctx.GetUint("sessionID")); err != nil {
+        logger.Request(ctx).Error("登出失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "登出失败")
+        return
+    }
+    response.Success(ctx, gin.H{"message": "登出成功"})
+}
+
+// LogoutAll 注销当前用户在所有设备上的会话
+func (a *AuthController) LogoutAll(ctx *gin.Context) {
+    uidVal, exists := ctx.Get("userID")
+    if !exists {
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
+        return
+    }
+    count, err := a.Sessions.RevokeAll(uidVal.(uint))
+    if err != nil {
+        logger.Request(ctx).Error("登出失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "登出失败")
+        return
+    }
+    response.Success(ctx, gin.H{"message": "已在所有设备上登出", "revoked": count})
+}
+
+// ListSessions 列出当前用户的活跃会话
+func (a *AuthController) ListSessions(ctx *gin.Context) {
+    uidVal, exists := ctx.Get("userID")
+    if !exists {
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
+        return
+    }
+    var sessions []model.Session
+    err := a.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", uidVal.(uint), time.Now()).
+        Order("last_seen_at desc").Find(&sessions).Error
+    if err != nil {
+        logger.Request(ctx).Error("获取会话列表失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "获取会话列表失败")
+        return
+    }
+    current := ctx.GetUint("sessionID")
+    items := make([]gin.H, 0, len(sessions))
+    for _, s := range sessions {
+        items = append(items, gin.H{
+            "id":             s.ID,
+            "device":         s.Device,
+            "ip":             s.IP,
+            "createdAt":      s.CreatedAt.Format(time.RFC3339),
+            "lastSeenAt":     s.LastSeenAt.Format(time.RFC3339),
+            "expiresAt":      s.ExpiresAt.Format(time.RFC3339),
+            "current":        s.ID == current,
+            "impersonatorId": s.ImpersonatorID,
+        })
+    }
+    response.Success(ctx, gin.H{"sessions": items})
+}
+
+// RevokeSession 注销指定会话
+func (a *AuthController) RevokeSession(ctx *gin.Context) {
+    uidVal, exists := ctx.Get("userID")
+    if !exists {
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
         return
     }
-    if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.Password)); err != nil {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
+    sid, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+    if err != nil {
+        response.Error(ctx, response.ErrInvalidRequest, "无效的会话ID")
         return
     }
-    a.DB.Model(&u).Update("last_login", time.Now())
-    token, err := a.generateToken(u.ID, u.Role)
+    audit.SetTarget(ctx, audit.TargetSession, uint(sid), "")
+    ok, err := a.Sessions.Revoke(uidVal.(uint), uint(sid))
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+        logger.Request(ctx).Error("注销会话失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "注销会话失败")
         return
     }
-    ctx.JSON(http.StatusOK, gin.H{"token": token, "user": gin.H{"id": u.ID, "username": u.Username, "email": u.Email, "role": u.Role}})
+    if !ok {
+        response.Error(ctx, response.ErrNotFound, "会话不存在")
+        return
+    }
+    response.Success(ctx, gin.H{"message": "会话已注销"})
 }
 
 // Me 当前用户信息
 func (a *AuthController) Me(ctx *gin.Context) {
     uidVal, exists := ctx.Get("userID")
     if !exists {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
         return
     }
     var u model.User
     if err := a.DB.First(&u, uidVal.(uint)).Error; err != nil {
-        ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+        response.Error(ctx, response.ErrUserNotFound, "用户不存在")
         return
     }
-    ctx.JSON(http.StatusOK, gin.H{"user": gin.H{"id": u.ID, "username": u.Username, "email": u.Email, "role": u.Role}})
+    response.Success(ctx, gin.H{"user": gin.H{"id": u.ID, "username": u.Username, "email": u.Email, "role": u.Role, "twoFactorEnabled": u.TOTPEnabled, "mustChangePassword": u.MustChangePassword, "emailVerified": u.EmailVerifiedAt != nil, "locale": u.Locale}})
 }
 
-// UpdateEmail 更新邮箱
+// UpdateEmail 修改邮箱：向新邮箱发送验证链接，验证通过后才生效
 func (a *AuthController) UpdateEmail(ctx *gin.Context) {
     uidVal, exists := ctx.Get("userID")
     if !exists {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
         return
     }
     var req struct{ Email string `json:"email"` }
     if err := ctx.ShouldBindJSON(&req); err != nil || req.Email == "" {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的邮箱"})
+        response.Error(ctx, response.ErrInvalidRequest, "无效的邮箱")
+        return
+    }
+    req.Email = strings.TrimSpace(req.Email)
+    if !mail.ValidAddress(req.Email) {
+        response.Error(ctx, response.ErrInvalidRequest, "邮箱格式不正确")
+        return
+    }
+    var u model.User
+    if err := a.DB.First(&u, uidVal.(uint)).Error; err != nil {
+        response.Error(ctx, response.ErrUserNotFound, "用户不存在")
+        return
+    }
+    if u.AuthSource != authSourceLocal {
+        response.Error(ctx, response.ErrInvalidRequest, "外部账户的邮箱由身份提供方管理")
+        return
+    }
+    if req.Email == u.Email && u.EmailVerifiedAt != nil {
+        response.Error(ctx, response.ErrInvalidRequest, "邮箱未改变")
         return
     }
     // 唯一性检查
     var cnt int64
-    if err := a.DB.Model(&model.User{}).Where("email = ?", req.Email).Count(&cnt).Error; err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "检查邮箱失败"})
+    if err := a.DB.Model(&model.User{}).Where("email = ? AND id <> ?", req.Email, u.ID).Count(&cnt).Error; err != nil {
+        logger.Request(ctx).Error("检查邮箱失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "检查邮箱失败")
         return
     }
     if cnt > 0 {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "邮箱已被使用"})
+        response.Error(ctx, response.ErrUserExists, "邮箱已被使用")
         return
     }
-    if err := a.DB.Model(&model.User{}).Where("id = ?", uidVal.(uint)).Update("email", req.Email).Error; err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新邮箱失败"})
+    if err := a.sendVerification(&u, req.Email); err != nil {
+        if errors.Is(err, errEmailThrottled) {
+            response.Error(ctx, response.ErrTooManyRequests, err.Error())
+            return
+        }
+        logger.Request(ctx).Error("发送验证邮件失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "发送验证邮件失败")
         return
     }
-    ctx.JSON(http.StatusOK, gin.H{"message": "邮箱已更新"})
-}
\ No newline at end of file
+    response.SuccessWithStatus(ctx, http.StatusAccepted, gin.H{"message": "验证邮件已发送到新邮箱，验证后生效"})
+}
+
+// UpdateLocale 设置首选语言，为空表示按 Accept-Language 选择；
+// 同时返回携带新设置的访问令牌，客户端替换后立即生效
+func (a *AuthController) UpdateLocale(ctx *gin.Context) {
+    var req struct{ Locale string `json:"locale"` }
+    if err := ctx.ShouldBindJSON(&req); err != nil {
+        response.Error(ctx, response.ErrInvalidRequest, "无效的请求参数")
+        return
+    }
+    locale := ""
+    if req.Locale != "" {
+        if locale = i18n.Normalize(req.Locale); locale == "" {
+            response.ErrorWithData(ctx, response.ErrInvalidRequest, gin.H{"supported": i18n.Supported}, "不支持的语言")
+            return
+        }
+    }
+    var u model.User
+    if err := a.DB.First(&u, ctx.GetUint("userID")).Error; err != nil {
+        response.Error(ctx, response.ErrUserNotFound, "用户不存在")
+        return
+    }
+    var session model.Session
+    if err := a.DB.First(&session, ctx.GetUint("sessionID")).Error; err != nil {
+        response.Error(ctx, response.ErrTokenInvalid, "会话不存在")
+        return
+    }
+    if err := a.DB.Model(&u).Update("locale", locale).Error; err != nil {
+        logger.Request(ctx).Error("保存语言设置失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "保存语言设置失败")
+        return
+    }
+    token, err := a.Sessions.accessToken(&u, &session)
+    if err != nil {
+        logger.Request(ctx).Error("生成令牌失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "生成令牌失败")
+        return
+    }
+    // 本次响应即使用新的语言
+    ctx.Set(i18n.ContextKey, locale)
+    response.Success(ctx, gin.H{"message": "语言设置已保存", "locale": locale, "token": token, "expiresIn": int64(a.Sessions.AccessTTL.Seconds())})
+}
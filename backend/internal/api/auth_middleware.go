@@ -1,15 +1,57 @@
 package api
 
 import (
-    "net/http"
+    "crypto/subtle"
+    "errors"
     "strings"
+    "time"
 
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v5"
+    "github.com/huanhq99/H-Cloud/internal/i18n"
+    "github.com/huanhq99/H-Cloud/internal/metrics"
+    "github.com/huanhq99/H-Cloud/internal/model"
+    "github.com/huanhq99/H-Cloud/internal/rbac"
+    "github.com/huanhq99/H-Cloud/internal/response"
+    "gorm.io/gorm"
 )
 
-// AuthMiddleware 解析Authorization: Bearer <token> 并设置userID
-func AuthMiddleware(secret string) gin.HandlerFunc {
+// 访问令牌权限范围
+const (
+    ScopeFilesRead    = "files:read"
+    ScopeFilesWrite   = "files:write"
+    ScopeSharesManage = "shares:manage"
+    ScopeAdmin        = "admin"
+)
+
+// AllScopes 所有可授予访问令牌的权限范围
+var AllScopes = []string{ScopeFilesRead, ScopeFilesWrite, ScopeSharesManage, ScopeAdmin}
+
+// 认证方式，保存在上下文的 authMethod 中
+const (
+    authMethodSession = "session" // 登录获得的 JWT
+    authMethodToken   = "token"   // 个人访问令牌
+)
+
+// 个人访问令牌前缀，用于与 JWT 区分
+const accessTokenPrefix = "hcp_"
+
+// lastUsedInterval 令牌和图床密钥使用时间的记录间隔，避免每个请求都写数据库
+const lastUsedInterval = time.Minute
+
+// passwordChangeRoutes 必须修改密码的账户仍可访问的接口
+var passwordChangeRoutes = map[string]bool{
+    "/api/auth/password": true,
+    "/api/auth/me":       true,
+    "/api/auth/logout":   true,
+    "/api/admin/logout":  true,
+}
+
+// errMustChangePassword 账户需要先修改密码
+var errMustChangePassword = errors.New("请先修改密码")
+
+// AuthMiddleware 解析Authorization: Bearer <token> 并设置userID，支持 JWT 和个人访问令牌
+func AuthMiddleware(sessions *SessionManager) gin.HandlerFunc {
     return func(ctx *gin.Context) {
         // 如果已有 userID（例如开发模式注入），直接通过
         if _, ok := ctx.Get("userID"); ok {
@@ -17,29 +59,212 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
             return
         }
 
-        auth := ctx.GetHeader("Authorization")
-        if auth == "" || !strings.HasPrefix(strings.ToLower(auth), "bearer ") {
-            ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+        tokenStr, ok := bearerToken(ctx)
+        if !ok {
+            response.Error(ctx, response.ErrUnauthorized, "未授权")
             ctx.Abort()
             return
         }
-        tokenStr := strings.TrimSpace(auth[len("Bearer "):])
-        token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-            return []byte(secret), nil
-        })
-        if err != nil || !token.Valid {
-            ctx.JSON(http.StatusUnauthorized, gin.H{"error": "令牌无效"})
-            ctx.Abort()
+        if !authenticate(ctx, sessions, tokenStr) {
             return
         }
-        claims, ok := token.Claims.(*Claims)
+        ctx.Next()
+    }
+}
+
+// OptionalAuthMiddleware 可选认证：未携带凭据时匿名放行，携带时必须有效
+func OptionalAuthMiddleware(sessions *SessionManager) gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        if _, ok := ctx.Get("userID"); ok {
+            ctx.Next()
+            return
+        }
+        tokenStr, ok := bearerToken(ctx)
         if !ok {
-            ctx.JSON(http.StatusUnauthorized, gin.H{"error": "令牌解析失败"})
+            ctx.Next()
+            return
+        }
+        if !authenticate(ctx, sessions, tokenStr) {
+            return
+        }
+        ctx.Next()
+    }
+}
+
+// bearerToken 从 Authorization 头中取出令牌
+func bearerToken(ctx *gin.Context) (string, bool) {
+    auth := ctx.GetHeader("Authorization")
+    if auth == "" || !strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+        return "", false
+    }
+    return strings.TrimSpace(auth[len("Bearer "):]), true
+}
+
+// authenticate 校验令牌并写入上下文，失败时直接返回错误响应
+func authenticate(ctx *gin.Context, sessions *SessionManager, tokenStr string) bool {
+    if strings.HasPrefix(tokenStr, accessTokenPrefix) {
+        pat, user, err := lookupAccessToken(sessions.DB, tokenStr)
+        if err != nil {
+            response.Error(ctx, response.ErrTokenInvalid, "访问令牌无效或已过期")
+            ctx.Abort()
+            return false
+        }
+        i18n.SetLocale(ctx, user.Locale)
+        if user.MustChangePassword {
+            response.ErrorWithData(ctx, response.ErrMustChangePassword, gin.H{"mustChangePassword": true}, errMustChangePassword.Error())
+            ctx.Abort()
+            return false
+        }
+        ctx.Set("userID", user.ID)
+        ctx.Set("role", user.Role)
+        ctx.Set("authMethod", authMethodToken)
+        ctx.Set("scopes", strings.Fields(pat.Scopes))
+        return true
+    }
+
+    token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+        return []byte(sessions.Secret), nil
+    })
+    if err != nil || !token.Valid {
+        response.Error(ctx, response.ErrTokenInvalid, "令牌无效")
+        ctx.Abort()
+        return false
+    }
+    claims, ok := token.Claims.(*Claims)
+    if !ok {
+        response.Error(ctx, response.ErrTokenInvalid, "令牌解析失败")
+        ctx.Abort()
+        return false
+    }
+    i18n.SetLocale(ctx, claims.Locale)
+    // 会话被注销后，尚未过期的访问令牌也立即失效
+    if err := sessions.Validate(ctx, claims); err != nil {
+        response.Error(ctx, response.ErrTokenInvalid, err.Error())
+        ctx.Abort()
+        return false
+    }
+    // 初始管理员等账户修改密码前只能访问修改密码相关接口
+    if claims.MustChangePassword && !passwordChangeRoutes[ctx.FullPath()] {
+        response.ErrorWithData(ctx, response.ErrMustChangePassword, gin.H{"mustChangePassword": true}, errMustChangePassword.Error())
+        ctx.Abort()
+        return false
+    }
+    ctx.Set("userID", claims.UserID)
+    ctx.Set("role", claims.Role)
+    ctx.Set("sessionID", claims.SessionID)
+    ctx.Set("authMethod", authMethodSession)
+    if claims.ImpersonatorID != nil {
+        ctx.Set("impersonatorID", *claims.ImpersonatorID)
+    }
+    return true
+}
+
+// lookupAccessToken 根据明文令牌查找有效的个人访问令牌及其所属用户，并记录使用时间
+func lookupAccessToken(db *gorm.DB, plain string) (*model.PersonalAccessToken, *model.User, error) {
+    var pat model.PersonalAccessToken
+    if err := db.Where("token_hash = ?", hashSecret(plain)).First(&pat).Error; err != nil {
+        return nil, nil, err
+    }
+    if pat.ExpiresAt != nil && time.Now().After(*pat.ExpiresAt) {
+        return nil, nil, errors.New("访问令牌已过期")
+    }
+    var user model.User
+    if err := db.First(&user, pat.UserID).Error; err != nil {
+        return nil, nil, err
+    }
+    if user.Disabled {
+        return nil, nil, errAccountDisabled
+    }
+    touchLastUsed(db, &pat, pat.LastUsedAt)
+    return &pat, &user, nil
+}
+
+// touchLastUsed 距上次记录超过 lastUsedInterval 时更新 last_used_at
+func touchLastUsed(db *gorm.DB, record any, lastUsed *time.Time) {
+    now := time.Now()
+    if lastUsed != nil && now.Sub(*lastUsed) < lastUsedInterval {
+        return
+    }
+    db.Model(record).UpdateColumn("last_used_at", now)
+}
+
+// hasScope 判断权限范围列表中是否包含指定范围
+func hasScope(scopes []string, scope string) bool {
+    for _, s := range scopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// RequireScope 要求个人访问令牌具备指定权限范围；登录会话和匿名请求不受限制。
+// 它只限制令牌能做什么，不要求登录：文件、目录、回收站、搜索和分享管理接口设计为允许匿名访问，
+// 未携带凭据的请求直接放行，由各接口按请求参数处理；需要登录的路由组须另外使用 AuthMiddleware
+func RequireScope(scope string) gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        if ctx.GetString("authMethod") != authMethodToken {
+            ctx.Next()
+            return
+        }
+        if !hasScope(ctx.GetStringSlice("scopes"), scope) {
+            response.Error(ctx, response.ErrInsufficientScope, "访问令牌缺少权限: " + scope)
+            ctx.Abort()
+            return
+        }
+        ctx.Next()
+    }
+}
+
+// RequirePermission 要求当前用户的角色拥有指定权限，需在 AuthMiddleware 之后使用
+func RequirePermission(authz *rbac.Authorizer, perm string) gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        if !authz.Has(ctx.GetString("role"), perm) {
+            response.Error(ctx, response.ErrPermissionDenied, "权限不足: " + perm)
+            ctx.Abort()
+            return
+        }
+        ctx.Next()
+    }
+}
+
+// MetricsToken 携带正确采集令牌的请求直接输出指标，其余请求交给后续的管理员认证；令牌为空时不启用
+func MetricsToken(token string) gin.HandlerFunc {
+    handler := metrics.Handler()
+    return func(ctx *gin.Context) {
+        if token == "" {
+            ctx.Next()
+            return
+        }
+        if got, ok := bearerToken(ctx); ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+            handler(ctx)
+            ctx.Abort()
+            return
+        }
+        ctx.Next()
+    }
+}
+
+// NoImpersonation 禁止管理员在代登录会话中执行的操作（修改密码、两步验证、访问令牌等）
+func NoImpersonation() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        if _, ok := ctx.Get("impersonatorID"); ok {
+            response.Error(ctx, response.ErrImpersonationDenied, "代登录会话不能执行该操作")
+            ctx.Abort()
+            return
+        }
+        ctx.Next()
+    }
+}
+
+// SessionOnly 仅允许登录会话访问，禁止使用个人访问令牌（如管理令牌本身）
+func SessionOnly() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        if ctx.GetString("authMethod") == authMethodToken {
+            response.Error(ctx, response.ErrInsufficientScope, "该操作不支持使用访问令牌")
             ctx.Abort()
             return
         }
-        ctx.Set("userID", claims.UserID)
-        ctx.Set("role", claims.Role)
         ctx.Next()
     }
-}
\ No newline at end of file
+}
@@ -2,7 +2,6 @@ package api
 
 import (
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -10,7 +9,10 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/logger"
 	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
 	"github.com/huanhq99/H-Cloud/internal/storage"
 	"github.com/huanhq99/H-Cloud/internal/security"
 	"gorm.io/gorm"
@@ -38,13 +40,13 @@ func (c *DirectoryController) CreateDirectory(ctx *gin.Context) {
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的请求参数")
 		return
 	}
 
 	// 验证目录名安全性
 	if err := security.ValidateFileName(req.Name); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "目录名不合法: " + err.Error()})
+		response.Error(ctx, response.ErrFileNameInvalid, "目录名不合法: " + err.Error())
 		return
 	}
 
@@ -56,7 +58,7 @@ func (c *DirectoryController) CreateDirectory(ctx *gin.Context) {
 	// 验证父路径安全性
 	if req.ParentPath != "/" {
 		if err := security.ValidateFilePath(req.ParentPath); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "父路径不合法: " + err.Error()})
+			response.Error(ctx, response.ErrPathInvalid, "父路径不合法: " + err.Error())
 			return
 		}
 		req.ParentPath = security.SanitizePath(req.ParentPath)
@@ -72,14 +74,15 @@ func (c *DirectoryController) CreateDirectory(ctx *gin.Context) {
 
     var exist model.Directory
     if err := c.DB.Where("user_id = ? AND path = ?", userID, expectedPath).First(&exist).Error; err == nil {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "目录已存在"})
+        response.Error(ctx, response.ErrDirExists, "目录已存在")
         return
     }
 
     // 创建目录（幂等，已存在则 ensureDir 不报错）
     dirPath, err := storage.CreateDirectory(userID, req.ParentPath, req.Name)
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建目录失败: " + err.Error()})
+        logger.Request(ctx).Error("创建目录失败: %v", err)
+        response.Error(ctx, response.ErrStorageFailure, "创建目录失败")
         return
     }
 
@@ -102,17 +105,19 @@ func (c *DirectoryController) CreateDirectory(ctx *gin.Context) {
 		ParentID: parentID,
 	}
 
+    audit.SetTarget(ctx, audit.TargetDirectory, nil, directory.Path)
     if err := c.DB.Create(&directory).Error; err != nil {
         // 复合唯一约束命中（sqlite / mysql 通用匹配）
         if strings.Contains(strings.ToLower(err.Error()), "unique") || strings.Contains(strings.ToLower(err.Error()), "duplicate") {
-            ctx.JSON(http.StatusBadRequest, gin.H{"error": "目录已存在"})
+            response.Error(ctx, response.ErrDirExists, "目录已存在")
             return
         }
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "保存目录记录失败"})
+        logger.Request(ctx).Error("保存目录记录失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "保存目录记录失败")
         return
     }
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Success(ctx, gin.H{
 		"message": "目录创建成功",
 		"directory": gin.H{
 			"id":   directory.ID,
@@ -127,7 +132,7 @@ func (c *DirectoryController) MapDirectory(ctx *gin.Context) {
 	// 获取当前用户ID
 	userID, exists := ctx.Get("userID")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
 		return
 	}
 
@@ -138,21 +143,22 @@ func (c *DirectoryController) MapDirectory(ctx *gin.Context) {
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的请求参数")
 		return
 	}
 
     // 先检查目标路径是否重复
     var exist model.Directory
     if err := c.DB.Where("user_id = ? AND path = ?", userID, req.TargetPath).First(&exist).Error; err == nil {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "目录已存在"})
+        response.Error(ctx, response.ErrDirExists, "目录已存在")
         return
     }
 
     // 映射目录
     err := storage.MapDirectory(userID.(uint), req.SourcePath, req.TargetPath)
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "映射目录失败: " + err.Error()})
+        logger.Request(ctx).Error("映射目录失败: %v", err)
+        response.Error(ctx, response.ErrStorageFailure, "映射目录失败")
         return
     }
 
@@ -166,16 +172,18 @@ func (c *DirectoryController) MapDirectory(ctx *gin.Context) {
 		MappingPath: req.SourcePath,
 	}
 
+    audit.SetTarget(ctx, audit.TargetDirectory, nil, directory.Path)
     if err := c.DB.Create(&directory).Error; err != nil {
         if strings.Contains(strings.ToLower(err.Error()), "unique") || strings.Contains(strings.ToLower(err.Error()), "duplicate") {
-            ctx.JSON(http.StatusBadRequest, gin.H{"error": "目录已存在"})
+            response.Error(ctx, response.ErrDirExists, "目录已存在")
             return
         }
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "保存映射目录记录失败"})
+        logger.Request(ctx).Error("保存映射目录记录失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "保存映射目录记录失败")
         return
     }
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Success(ctx, gin.H{
 		"message": "目录映射成功",
 		"directory": gin.H{
 			"id":          directory.ID,
@@ -192,7 +200,7 @@ func (c *DirectoryController) ListDirectories(ctx *gin.Context) {
 	// 获取当前用户ID
 	userID, exists := ctx.Get("userID")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
 		return
 	}
 
@@ -200,7 +208,7 @@ func (c *DirectoryController) ListDirectories(ctx *gin.Context) {
 	parentIDStr := ctx.DefaultQuery("parentId", "0")
 	parentID, err := strconv.ParseUint(parentIDStr, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的父目录ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的父目录ID")
 		return
 	}
 
@@ -213,7 +221,8 @@ func (c *DirectoryController) ListDirectories(ctx *gin.Context) {
         queryErr = c.DB.Where("user_id = ? AND parent_id = ?", userID, parentID).Find(&directories).Error
     }
     if queryErr != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取目录列表失败"})
+        logger.Request(ctx).Error("获取目录列表失败: %v", queryErr)
+        response.Error(ctx, response.ErrInternalServer, "获取目录列表失败")
         return
     }
 
@@ -231,7 +240,7 @@ func (c *DirectoryController) ListDirectories(ctx *gin.Context) {
 		})
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Success(ctx, gin.H{
 		"directories": dirList,
 	})
 }
@@ -241,34 +250,36 @@ func (c *DirectoryController) DeleteDirectory(ctx *gin.Context) {
 	// 获取当前用户ID
 	userID, exists := ctx.Get("userID")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
 		return
 	}
 
 	// 获取目录ID
 	dirID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的目录ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的目录ID")
 		return
 	}
 
 	// 查询目录记录
 	var directory model.Directory
 	if err := c.DB.First(&directory, dirID).Error; err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "目录不存在"})
+		response.Error(ctx, response.ErrDirNotFound, "目录不存在")
 		return
 	}
+	audit.SetTarget(ctx, audit.TargetDirectory, directory.ID, directory.Path)
 
 	// 检查目录所有权
 	if directory.UserID != userID.(uint) {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限删除此目录"})
+		response.Error(ctx, response.ErrForbidden, "没有权限删除此目录")
 		return
 	}
 
 	// 创建回收站目录
 	recycleDir := filepath.Join("./storage", "recycle", fmt.Sprintf("user_%d", userID))
 	if err := os.MkdirAll(recycleDir, 0755); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建回收站目录失败"})
+		logger.Request(ctx).Error("创建回收站目录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "创建回收站目录失败")
 		return
 	}
 
@@ -280,7 +291,8 @@ func (c *DirectoryController) DeleteDirectory(ctx *gin.Context) {
 	// 移动目录到回收站
 	originalPath := filepath.Join("./storage", directory.Path)
 	if err := os.Rename(originalPath, recyclePath); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "移动目录到回收站失败"})
+		logger.Request(ctx).Error("移动目录到回收站失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "移动目录到回收站失败")
 		return
 	}
 
@@ -300,17 +312,19 @@ func (c *DirectoryController) DeleteDirectory(ctx *gin.Context) {
 	if err := c.DB.Create(&recycleBin).Error; err != nil {
 		// 如果创建回收站记录失败，恢复目录
 		os.Rename(recyclePath, originalPath)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建回收站记录失败"})
+		logger.Request(ctx).Error("创建回收站记录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "创建回收站记录失败")
 		return
 	}
 
 	// 删除原始数据库记录
 	if err := c.DB.Delete(&directory).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除目录记录失败"})
+		logger.Request(ctx).Error("删除目录记录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "删除目录记录失败")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "目录已移至回收站"})
+	response.Success(ctx, gin.H{"message": "目录已移至回收站"})
 }
 
 // RenameDirectory 重命名目录 - H-Yun盘版本
@@ -318,7 +332,7 @@ func (c *DirectoryController) RenameDirectory(ctx *gin.Context) {
 	// 获取目录路径参数
 	dirPath := ctx.Query("path")
 	if dirPath == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请提供目录路径"})
+		response.Error(ctx, response.ErrInvalidRequest, "请提供目录路径")
 		return
 	}
 
@@ -327,19 +341,21 @@ func (c *DirectoryController) RenameDirectory(ctx *gin.Context) {
 		NewName string `json:"newName" binding:"required"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请提供新目录名"})
+		response.Error(ctx, response.ErrInvalidRequest, "请提供新目录名")
 		return
 	}
 
 	// 验证新目录名安全性
 	if err := security.ValidateFileName(req.NewName); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "目录名不合法: " + err.Error()})
+		response.Error(ctx, response.ErrFileNameInvalid, "目录名不合法: " + err.Error())
 		return
 	}
 
 	// 构建新的目录路径
 	parentDir := filepath.Dir(dirPath)
 	newPath := filepath.Join(parentDir, req.NewName)
+	audit.SetTarget(ctx, audit.TargetDirectory, nil, dirPath)
+	audit.Describe(ctx, "重命名为 "+newPath)
 
 	// 直接使用存储路径，不再使用user_目录
 	oldFullPath := filepath.Join(storage.StoragePath, dirPath)
@@ -347,22 +363,23 @@ func (c *DirectoryController) RenameDirectory(ctx *gin.Context) {
 
 	// 检查原目录是否存在
 	if stat, err := os.Stat(oldFullPath); os.IsNotExist(err) {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "原目录不存在"})
+		response.Error(ctx, response.ErrDirNotFound, "原目录不存在")
 		return
 	} else if !stat.IsDir() {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "指定路径不是目录"})
+		response.Error(ctx, response.ErrDirInvalid, "指定路径不是目录")
 		return
 	}
 
 	// 检查新目录名是否已存在
 	if _, err := os.Stat(newFullPath); err == nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "目标目录名已存在"})
+		response.Error(ctx, response.ErrDirExists, "目标目录名已存在")
 		return
 	}
 
 	// 重命名目录
 	if err := os.Rename(oldFullPath, newFullPath); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "重命名失败: " + err.Error()})
+		logger.Request(ctx).Error("重命名失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "重命名失败")
 		return
 	}
 
@@ -375,7 +392,7 @@ func (c *DirectoryController) RenameDirectory(ctx *gin.Context) {
 		c.DB.Save(&dirRecord)
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Success(ctx, gin.H{
 		"message": "目录重命名成功",
 		"directory": gin.H{
 			"name": req.NewName,
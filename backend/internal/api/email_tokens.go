@@ -0,0 +1,253 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/mail"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 邮件令牌用途
+const (
+	emailPurposeVerify = "verify"
+	emailPurposeReset  = "reset"
+)
+
+// 同一用户同一用途的邮件最短发送间隔
+const emailResendInterval = time.Minute
+
+var (
+	errEmailNotVerified = errors.New("请先验证邮箱")
+	errEmailThrottled   = errors.New("邮件发送过于频繁，请稍后再试")
+	errEmailTokenBad    = errors.New("链接无效或已过期")
+)
+
+// emailData 邮件模板数据
+type emailData struct {
+	Username  string
+	Email     string
+	Link      string
+	ExpiresIn string
+	FileName  string
+	Signature string
+}
+
+// humanDuration 以中文描述时长，用于邮件正文
+func humanDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d 小时", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d 分钟", int(d/time.Minute))
+}
+
+// verifyTTL 邮箱验证链接有效期
+func (a *AuthController) verifyTTL() time.Duration {
+	return time.Duration(a.Registration.VerifyTTL) * time.Hour
+}
+
+// resetTTL 密码重置链接有效期
+func (a *AuthController) resetTTL() time.Duration {
+	return time.Duration(a.Registration.ResetTTL) * time.Minute
+}
+
+// issueEmailToken 生成一次性邮件令牌，同一用途之前未使用的令牌随之作废
+func (a *AuthController) issueEmailToken(u *model.User, purpose, email string, ttl time.Duration) (string, error) {
+	var recent int64
+	a.DB.Model(&model.EmailToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", u.ID, purpose, time.Now().Add(-emailResendInterval)).
+		Count(&recent)
+	if recent > 0 {
+		return "", errEmailThrottled
+	}
+
+	plain, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err := a.DB.Model(&model.EmailToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", u.ID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+	token := model.EmailToken{
+		UserID:    u.ID,
+		Purpose:   purpose,
+		Email:     email,
+		TokenHash: hashSecret(plain),
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := a.DB.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// consumeEmailToken 校验并消耗一次性邮件令牌
+func (a *AuthController) consumeEmailToken(plain, purpose string) (*model.EmailToken, *model.User, error) {
+	var token model.EmailToken
+	if err := a.DB.Where("token_hash = ? AND purpose = ?", hashSecret(plain), purpose).First(&token).Error; err != nil {
+		return nil, nil, errEmailTokenBad
+	}
+	// 条件更新保证并发提交时只有一个成功
+	result := a.DB.Model(&model.EmailToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, nil, errEmailTokenBad
+	}
+	var u model.User
+	if err := a.DB.First(&u, token.UserID).Error; err != nil {
+		return nil, nil, errEmailTokenBad
+	}
+	return &token, &u, nil
+}
+
+// sendVerification 向指定邮箱发送验证链接（注册时为当前邮箱，修改邮箱时为新邮箱）
+func (a *AuthController) sendVerification(u *model.User, email string) error {
+	plain, err := a.issueEmailToken(u, emailPurposeVerify, email, a.verifyTTL())
+	if err != nil {
+		return err
+	}
+	a.Mailer.SendAsync(email, mail.TemplateVerifyEmail, emailData{
+		Username:  u.Username,
+		Email:     email,
+		Link:      a.Mailer.Link("/account.html?action=verify&token=" + url.QueryEscape(plain)),
+		ExpiresIn: humanDuration(a.verifyTTL()),
+	})
+	return nil
+}
+
+// VerifyEmail 使用邮件中的令牌验证邮箱；修改邮箱时验证通过后才更新为新邮箱
+func (a *AuthController) VerifyEmail(ctx *gin.Context) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "缺少验证令牌")
+		return
+	}
+	token, u, err := a.consumeEmailToken(req.Token, emailPurposeVerify)
+	if err != nil {
+		response.Error(ctx, response.ErrInvalidRequest, err.Error())
+		return
+	}
+
+	updates := map[string]interface{}{"email_verified_at": time.Now()}
+	if token.Email != "" && token.Email != u.Email {
+		var cnt int64
+		a.DB.Model(&model.User{}).Where("email = ? AND id <> ?", token.Email, u.ID).Count(&cnt)
+		if cnt > 0 {
+			response.Error(ctx, response.ErrUserExists, "邮箱已被使用")
+			return
+		}
+		updates["email"] = token.Email
+	}
+	if err := a.DB.Model(u).Updates(updates).Error; err != nil {
+		logger.Request(ctx).Error("验证邮箱失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "验证邮箱失败")
+		return
+	}
+	response.Success(ctx, gin.H{"message": "邮箱已验证", "email": u.Email})
+}
+
+// ResendVerification 重新发送注册邮箱的验证邮件；无论邮箱是否存在都返回相同结果
+func (a *AuthController) ResendVerification(ctx *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "无效的邮箱")
+		return
+	}
+	var u model.User
+	err := a.DB.Where("email = ? AND auth_source = ? AND disabled = ?", strings.TrimSpace(req.Email), authSourceLocal, false).First(&u).Error
+	if err == nil && u.EmailVerifiedAt == nil {
+		if err := a.sendVerification(&u, u.Email); err != nil && !errors.Is(err, errEmailThrottled) {
+			logger.Request(ctx).Error("生成邮箱验证令牌失败: %v", err)
+		}
+	}
+	response.Success(ctx, gin.H{"message": "如果该邮箱已注册且尚未验证，验证邮件已发送"})
+}
+
+// ForgotPassword 发送密码重置邮件；无论邮箱是否存在都返回相同结果，避免暴露账户信息
+func (a *AuthController) ForgotPassword(ctx *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "无效的邮箱")
+		return
+	}
+	var u model.User
+	err := a.DB.Where("email = ? AND auth_source = ? AND disabled = ?", strings.TrimSpace(req.Email), authSourceLocal, false).First(&u).Error
+	if err == nil {
+		plain, err := a.issueEmailToken(&u, emailPurposeReset, u.Email, a.resetTTL())
+		switch {
+		case err == nil:
+			a.Mailer.SendAsync(u.Email, mail.TemplatePasswordReset, emailData{
+				Username:  u.Username,
+				Email:     u.Email,
+				Link:      a.Mailer.Link("/account.html?action=reset&token=" + url.QueryEscape(plain)),
+				ExpiresIn: humanDuration(a.resetTTL()),
+			})
+			audit.SetTarget(ctx, audit.TargetUser, u.ID, u.Username)
+		case !errors.Is(err, errEmailThrottled):
+			logger.Request(ctx).Error("生成密码重置令牌失败: %v", err)
+		}
+	}
+	response.Success(ctx, gin.H{"message": "如果该邮箱已注册，重置密码的邮件已发送"})
+}
+
+// ResetPassword 使用邮件中的令牌设置新密码，成功后注销该用户的全部会话
+func (a *AuthController) ResetPassword(ctx *gin.Context) {
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Token == "" || req.NewPassword == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "无效的参数")
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		response.Error(ctx, response.ErrInvalidRequest, fmt.Sprintf("新密码至少需要 %d 个字符", minPasswordLength))
+		return
+	}
+	token, u, err := a.consumeEmailToken(req.Token, emailPurposeReset)
+	if err != nil {
+		response.Error(ctx, response.ErrInvalidRequest, err.Error())
+		return
+	}
+	if u.AuthSource != authSourceLocal || u.Disabled {
+		response.Error(ctx, response.ErrInvalidRequest, errEmailTokenBad.Error())
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Request(ctx).Error("加密密码失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "加密密码失败")
+		return
+	}
+	updates := map[string]interface{}{"password": string(hashed), "must_change_password": false}
+	// 能收到重置邮件说明邮箱属于本人
+	if u.EmailVerifiedAt == nil && token.Email == u.Email {
+		updates["email_verified_at"] = time.Now()
+	}
+	if err := a.DB.Model(u).Updates(updates).Error; err != nil {
+		logger.Request(ctx).Error("重置密码失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "重置密码失败")
+		return
+	}
+	a.Sessions.RevokeAll(u.ID)
+	response.Success(ctx, gin.H{"message": "密码已重置，请使用新密码登录"})
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,7 +11,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
 	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
 	"github.com/huanhq99/H-Cloud/internal/security"
 	"github.com/huanhq99/H-Cloud/internal/storage"
 	"gorm.io/gorm"
@@ -18,33 +23,44 @@ import (
 
 // FileController 文件控制器
 type FileController struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Guard   *ImageGuard    // 图床防盗链与流量限制
+	Scanner *UploadScanner // 上传文件病毒扫描
 }
 
 // NewFileController 创建文件控制器
-func NewFileController(db *gorm.DB) *FileController {
-	return &FileController{DB: db}
+func NewFileController(db *gorm.DB, cfg *config.Config, scanner *UploadScanner) *FileController {
+	return &FileController{DB: db, Guard: NewImageGuard(db, cfg.ImageHost), Scanner: scanner}
 }
 
 // UploadFile 上传文件 - H-Yun盘版本
 func (c *FileController) UploadFile(ctx *gin.Context) {
+	policy := requestUploadPolicy(ctx, c.DB)
+	limitUploadBody(ctx, policy)
+
 	// 获取上传的文件
 	file, err := ctx.FormFile("file")
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件"})
+		if isBodyTooLarge(err) {
+			response.Error(ctx, response.ErrFileTooBig, fmt.Sprintf("文件大小超过限制，最大允许 %d MB", policy.Largest()/1024/1024))
+			return
+		}
+		response.Error(ctx, response.ErrFileInvalid, "无效的文件")
 		return
 	}
 
+	audit.SetTarget(ctx, audit.TargetFile, nil, file.Filename)
+
 	// 验证文件名安全性
 	if err := security.ValidateFileName(file.Filename); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "文件名不合法: " + err.Error()})
+		response.Error(ctx, response.ErrFileNameInvalid, "文件名不合法: " + err.Error())
 		return
 	}
 
-	// 验证文件类型和大小
-	validation := security.ValidateFileType(file.Filename, file.Size)
+	// 按上传策略验证文件类型和大小
+	validation := policy.Check(file.Filename, file.Size)
 	if !validation.IsValid {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": validation.Error.Error()})
+		response.Error(ctx, uploadErrorCode(validation.Error), validation.Error.Error())
 		return
 	}
 
@@ -53,7 +69,7 @@ func (c *FileController) UploadFile(ctx *gin.Context) {
 	
 	// 验证路径安全性
 	if err := security.ValidateFilePath(dirPath); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "路径不合法: " + err.Error()})
+		response.Error(ctx, response.ErrPathInvalid, "路径不合法: " + err.Error())
 		return
 	}
 
@@ -63,19 +79,33 @@ func (c *FileController) UploadFile(ctx *gin.Context) {
 	// 打开文件
 	src, err := file.Open()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "打开文件失败"})
+		logger.Request(ctx).Error("打开文件失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "打开文件失败")
 		return
 	}
 	defer src.Close()
 
+	// 按文件头部识别实际类型，不信任扩展名
+	detected, body, err := security.SniffContentType(src)
+	if err != nil {
+		logger.Request(ctx).Error("读取文件失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "读取文件失败")
+		return
+	}
+	if err := policy.CheckContent(&validation, detected); err != nil {
+		response.Error(ctx, uploadErrorCode(err), err.Error())
+		return
+	}
+
 	// 保存文件到存储
-	savedPath, err := storage.SaveFile(1, dirPath, file.Filename, file.Size, src)
+	savedPath, err := storage.SaveFile(1, dirPath, file.Filename, file.Size, body)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败: " + err.Error()})
+		logger.Request(ctx).Error("保存文件失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "保存文件失败")
 		return
 	}
 
-	// 使用验证结果中的Content-Type
+	// 使用识别出的Content-Type
 	contentType := validation.ContentType
 
 	// 保存文件信息到数据库
@@ -87,12 +117,34 @@ func (c *FileController) UploadFile(ctx *gin.Context) {
 		UserID:      1, // 当前用户ID
 	}
 
+	// 病毒扫描，检出恶意内容时文件已移入隔离区
+	if err := c.Scanner.Scan(ctx, &fileModel); err != nil {
+		storage.DeleteFile(1, savedPath)
+		logger.Request(ctx).Error("扫描文件失败: %v", err)
+		response.Error(ctx, response.ErrBadGateway, "病毒扫描失败，请稍后重试")
+		return
+	}
+
 	if err := c.DB.Create(&fileModel).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件信息失败: " + err.Error()})
+		// 没有记录的文件无法再被访问或清理，删除已保存的文件（被隔离的删除隔离区中的副本）
+		if fileModel.Quarantined {
+			storage.DeleteQuarantined(fileModel.QuarantinePath)
+		} else {
+			storage.DeleteFile(1, savedPath)
+		}
+		logger.Request(ctx).Error("保存文件信息失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "保存文件信息失败")
 		return
 	}
+	audit.SetTarget(ctx, audit.TargetFile, fileModel.ID, savedPath)
+	if fileModel.Quarantined {
+		c.Scanner.Notify(&fileModel)
+		response.Error(ctx, response.ErrFileInfected, fmt.Sprintf("文件包含恶意内容（%s），已被隔离", fileModel.Signature))
+		return
+	}
+	audit.Describe(ctx, fmt.Sprintf("%d 字节", file.Size))
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Success(ctx, gin.H{
 		"message": "文件上传成功",
 		"file": gin.H{
 			"id":          fileModel.ID,
@@ -110,21 +162,25 @@ func (c *FileController) DownloadFile(ctx *gin.Context) {
 	// 获取当前用户ID
 	userID, exists := ctx.Get("userID")
 	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
 		return
 	}
 
 	// 获取文件ID
 	fileID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的文件ID")
 		return
 	}
 
 	// 查询文件记录
 	var fileRecord model.File
 	if err := c.DB.First(&fileRecord, fileID).Error; err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		response.Error(ctx, response.ErrFileNotFound, "文件不存在")
+		return
+	}
+	audit.SetTarget(ctx, audit.TargetFile, fileRecord.ID, fileRecord.Path)
+	if rejectQuarantined(ctx, &fileRecord) {
 		return
 	}
 
@@ -134,7 +190,7 @@ func (c *FileController) DownloadFile(ctx *gin.Context) {
 		var share model.Share
 		result := c.DB.Where("file_id = ? AND shared_with = ?", fileID, userID).First(&share)
 		if result.Error != nil {
-			ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限访问此文件"})
+			response.Error(ctx, response.ErrForbidden, "没有权限访问此文件")
 			return
 		}
 	}
@@ -142,7 +198,8 @@ func (c *FileController) DownloadFile(ctx *gin.Context) {
 	// 获取文件
 	file, err := storage.GetFile(userID.(uint), fileRecord.Path)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件失败: " + err.Error()})
+		logger.Request(ctx).Error("获取文件失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "获取文件失败")
 		return
 	}
 	defer file.Close()
@@ -152,8 +209,8 @@ func (c *FileController) DownloadFile(ctx *gin.Context) {
 	ctx.Header("Content-Type", "application/octet-stream")
 	ctx.Header("Content-Length", strconv.FormatInt(fileRecord.Size, 10))
 
-	// 发送文件
-	ctx.File(file.Name())
+	// 发送文件，支持 Range 请求；加密文件只解密请求范围涉及的块
+	http.ServeContent(ctx.Writer, ctx.Request, fileRecord.Name, file.ModTime(), file)
 }
 
 // DownloadFileByPath 基于路径下载文件 - H-Yun盘版本
@@ -161,34 +218,40 @@ func (c *FileController) DownloadFileByPath(ctx *gin.Context) {
 	// 获取文件路径参数
 	filePath := ctx.Query("path")
 	if filePath == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请提供文件路径"})
+		response.Error(ctx, response.ErrInvalidRequest, "请提供文件路径")
 		return
 	}
 
 	// 获取用户ID参数（H-Yun盘版本）
 	userIDStr := ctx.Query("userId")
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请提供用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "请提供用户ID")
 		return
 	}
 
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 
 	// 查询文件记录
 	var fileRecord model.File
+	audit.SetTarget(ctx, audit.TargetFile, nil, filePath)
 	if err := c.DB.Where("path = ? AND user_id = ?", filePath, userID).First(&fileRecord).Error; err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		response.Error(ctx, response.ErrFileNotFound, "文件不存在")
+		return
+	}
+	audit.SetTarget(ctx, audit.TargetFile, fileRecord.ID, fileRecord.Path)
+	if rejectQuarantined(ctx, &fileRecord) {
 		return
 	}
 
 	// 获取文件
 	file, err := storage.GetFile(uint(userID), fileRecord.Path)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件失败: " + err.Error()})
+		logger.Request(ctx).Error("获取文件失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "获取文件失败")
 		return
 	}
 	defer file.Close()
@@ -198,8 +261,8 @@ func (c *FileController) DownloadFileByPath(ctx *gin.Context) {
 	ctx.Header("Content-Type", "application/octet-stream")
 	ctx.Header("Content-Length", strconv.FormatInt(fileRecord.Size, 10))
 
-	// 发送文件
-	ctx.File(file.Name())
+	// 发送文件，支持 Range 请求；加密文件只解密请求范围涉及的块
+	http.ServeContent(ctx.Writer, ctx.Request, fileRecord.Name, file.ModTime(), file)
 }
 
 // ListFiles 列出文件 - H-Yun盘版本，直接读取映射路径
@@ -210,13 +273,13 @@ func (c *FileController) ListFiles(ctx *gin.Context) {
 	// 获取用户ID
 	userIDStr := ctx.Query("userId")
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "缺少用户ID")
 		return
 	}
 	
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 	
@@ -276,7 +339,7 @@ func (c *FileController) ListFiles(ctx *gin.Context) {
 		}
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Success(ctx, gin.H{
 		"files":       files,
 		"directories": directories,
 	})
@@ -292,7 +355,8 @@ func (c *FileController) listFromDatabase(ctx *gin.Context, userID int, dirPath
 		// 根目录，parent_id 为 NULL
 		err := c.DB.Where("user_id = ? AND parent_id IS NULL", userID).Find(&dbDirectories).Error
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取目录列表失败: " + err.Error()})
+			logger.Request(ctx).Error("获取目录列表失败: %v", err)
+			response.Error(ctx, response.ErrInternalServer, "获取目录列表失败")
 			return
 		}
 	} else {
@@ -300,14 +364,16 @@ func (c *FileController) listFromDatabase(ctx *gin.Context, userID int, dirPath
 		var parentDir model.Directory
 		err := c.DB.Where("user_id = ? AND path = ?", userID, strings.TrimPrefix(dirPath, "/")).First(&parentDir).Error
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "父目录不存在"})
+			logger.Request(ctx).Error("父目录不存在: %v", err)
+			response.Error(ctx, response.ErrInternalServer, "父目录不存在")
 			return
 		}
 		parentID = &parentDir.ID
 		
 		err = c.DB.Where("user_id = ? AND parent_id = ?", userID, parentID).Find(&dbDirectories).Error
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取子目录列表失败: " + err.Error()})
+			logger.Request(ctx).Error("获取子目录列表失败: %v", err)
+			response.Error(ctx, response.ErrInternalServer, "获取子目录列表失败")
 			return
 		}
 	}
@@ -318,7 +384,8 @@ func (c *FileController) listFromDatabase(ctx *gin.Context, userID int, dirPath
 		// 根目录文件
 		err := c.DB.Where("user_id = ? AND directory_id = 0", userID).Find(&dbFiles).Error
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件列表失败: " + err.Error()})
+			logger.Request(ctx).Error("获取文件列表失败: %v", err)
+			response.Error(ctx, response.ErrInternalServer, "获取文件列表失败")
 			return
 		}
 	} else {
@@ -326,7 +393,8 @@ func (c *FileController) listFromDatabase(ctx *gin.Context, userID int, dirPath
 		if parentID != nil {
 			err := c.DB.Where("user_id = ? AND directory_id = ?", userID, *parentID).Find(&dbFiles).Error
 			if err != nil {
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件列表失败: " + err.Error()})
+				logger.Request(ctx).Error("获取文件列表失败: %v", err)
+				response.Error(ctx, response.ErrInternalServer, "获取文件列表失败")
 				return
 			}
 		}
@@ -350,13 +418,23 @@ func (c *FileController) listFromDatabase(ctx *gin.Context, userID int, dirPath
 	
 	// 添加数据库中的文件
 	for _, file := range dbFiles {
+		// 被隔离的文件不在存储目录中，不列出也不删除记录
+		if file.Quarantined {
+			continue
+		}
 		// 验证物理文件是否存在
-		_, err := storage.GetFile(uint(userID), file.Path)
-		if err != nil {
+		f, err := storage.GetFile(uint(userID), file.Path)
+		if errors.Is(err, storage.ErrNotExist) {
 			// 物理文件不存在，从数据库中硬删除记录
 			c.DB.Unscoped().Delete(&file)
 			continue
 		}
+		if err != nil {
+			// 无法解密等错误不删除记录，下载时会返回错误
+			logger.Request(ctx).Warn("打开文件 %s 失败: %v", file.Path, err)
+		} else {
+			f.Close()
+		}
 		
 		files = append(files, gin.H{
 			"id":          file.ID,
@@ -370,7 +448,7 @@ func (c *FileController) listFromDatabase(ctx *gin.Context, userID int, dirPath
 		})
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Success(ctx, gin.H{
 		"files":       files,
 		"directories": directories,
 	})
@@ -381,34 +459,37 @@ func (c *FileController) DeleteFile(ctx *gin.Context) {
 	// 获取文件路径参数
 	filePath := ctx.Query("path")
 	if filePath == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请提供文件路径"})
+		response.Error(ctx, response.ErrInvalidRequest, "请提供文件路径")
 		return
 	}
 
 	// 获取用户ID参数
 	userIDStr := ctx.Query("userId")
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请提供用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "请提供用户ID")
 		return
 	}
 
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 
 	// 查询文件记录
 	var fileRecord model.File
+	audit.SetTarget(ctx, audit.TargetFile, nil, filePath)
 	if err := c.DB.Where("path = ? AND user_id = ?", filePath, userID).First(&fileRecord).Error; err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		response.Error(ctx, response.ErrFileNotFound, "文件不存在")
 		return
 	}
+	audit.SetTarget(ctx, audit.TargetFile, fileRecord.ID, fileRecord.Path)
 
 	// 创建回收站目录
 	recycleDir := filepath.Join(storage.StoragePath, ".recycle")
 	if err := os.MkdirAll(recycleDir, 0755); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建回收站目录失败: " + err.Error()})
+		logger.Request(ctx).Error("创建回收站目录失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "创建回收站目录失败")
 		return
 	}
 
@@ -419,7 +500,8 @@ func (c *FileController) DeleteFile(ctx *gin.Context) {
 	// 移动文件到回收站
 	originalFilePath := filepath.Join(storage.StoragePath, filePath)
 	if err := os.Rename(originalFilePath, recycleFilePath); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "移动文件到回收站失败: " + err.Error()})
+		logger.Request(ctx).Error("移动文件到回收站失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "移动文件到回收站失败")
 		return
 	}
 
@@ -439,17 +521,19 @@ func (c *FileController) DeleteFile(ctx *gin.Context) {
 	if err := c.DB.Create(&recycleBinItem).Error; err != nil {
 		// 如果创建回收站记录失败，恢复文件
 		os.Rename(recycleFilePath, originalFilePath)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建回收站记录失败: " + err.Error()})
+		logger.Request(ctx).Error("创建回收站记录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "创建回收站记录失败")
 		return
 	}
 
 	// 删除原始数据库记录
 	if err := c.DB.Delete(&fileRecord).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除数据库记录失败: " + err.Error()})
+		logger.Request(ctx).Error("删除数据库记录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "删除数据库记录失败")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "文件已移至回收站"})
+	response.Success(ctx, gin.H{"message": "文件已移至回收站"})
 }
 
 // RenameFile 重命名文件 - H-Yun盘版本
@@ -457,7 +541,7 @@ func (c *FileController) RenameFile(ctx *gin.Context) {
 	// 获取文件路径参数
 	filePath := ctx.Query("path")
 	if filePath == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请提供文件路径"})
+		response.Error(ctx, response.ErrInvalidRequest, "请提供文件路径")
 		return
 	}
 
@@ -466,19 +550,21 @@ func (c *FileController) RenameFile(ctx *gin.Context) {
 		NewName string `json:"newName" binding:"required"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请提供新文件名"})
+		response.Error(ctx, response.ErrInvalidRequest, "请提供新文件名")
 		return
 	}
 
 	// 验证新文件名安全性
 	if err := security.ValidateFileName(req.NewName); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "文件名不合法: " + err.Error()})
+		response.Error(ctx, response.ErrFileNameInvalid, "文件名不合法: " + err.Error())
 		return
 	}
 
 	// 构建新的文件路径
 	dir := filepath.Dir(filePath)
 	newPath := filepath.Join(dir, req.NewName)
+	audit.SetTarget(ctx, audit.TargetFile, nil, filePath)
+	audit.Describe(ctx, "重命名为 "+newPath)
 
 	// 直接使用存储路径，不再使用user_目录
 	oldFullPath := filepath.Join(storage.StoragePath, filePath)
@@ -486,19 +572,20 @@ func (c *FileController) RenameFile(ctx *gin.Context) {
 
 	// 检查原文件是否存在
 	if _, err := os.Stat(oldFullPath); os.IsNotExist(err) {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "原文件不存在"})
+		response.Error(ctx, response.ErrFileNotFound, "原文件不存在")
 		return
 	}
 
 	// 检查新文件名是否已存在
 	if _, err := os.Stat(newFullPath); err == nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "目标文件名已存在"})
+		response.Error(ctx, response.ErrFileExists, "目标文件名已存在")
 		return
 	}
 
 	// 重命名文件
 	if err := os.Rename(oldFullPath, newFullPath); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "重命名失败: " + err.Error()})
+		logger.Request(ctx).Error("重命名失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "重命名失败")
 		return
 	}
 
@@ -509,9 +596,10 @@ func (c *FileController) RenameFile(ctx *gin.Context) {
 		fileRecord.Path = newPath
 		fileRecord.Name = req.NewName
 		c.DB.Save(&fileRecord)
+		audit.SetTarget(ctx, audit.TargetFile, fileRecord.ID, filePath)
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Success(ctx, gin.H{
 		"message": "文件重命名成功",
 		"file": gin.H{
 			"name": req.NewName,
@@ -525,44 +613,24 @@ func (c *FileController) GetImageDirect(ctx *gin.Context) {
 	fileID := ctx.Param("id")
 	id, err := strconv.ParseUint(fileID, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的文件ID")
 		return
 	}
 
 	// 查找文件记录
 	var fileRecord model.File
 	if err := c.DB.First(&fileRecord, uint(id)).Error; err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		response.Error(ctx, response.ErrFileNotFound, "文件不存在")
 		return
 	}
 
 	// 检查是否为图片文件
 	if !strings.HasPrefix(fileRecord.ContentType, "image/") {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "该文件不是图片"})
-		return
-	}
-
-	// 获取文件
-	f, err := storage.GetFile(fileRecord.UserID, fileRecord.Path)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件失败: " + err.Error()})
+		response.Error(ctx, response.ErrFileTypeInvalid, "该文件不是图片")
 		return
 	}
-	defer f.Close()
 
-	// 设置响应头
-	ctx.Header("Content-Type", fileRecord.ContentType)
-	ctx.Header("Content-Length", fmt.Sprintf("%d", fileRecord.Size))
-	ctx.Header("Cache-Control", "public, max-age=31536000") // 缓存1年
-	ctx.Header("ETag", fmt.Sprintf(`"%d-%d"`, fileRecord.ID, fileRecord.UpdatedAt.Unix()))
-	
-	// 支持跨域访问（图床功能）
-	ctx.Header("Access-Control-Allow-Origin", "*")
-	ctx.Header("Access-Control-Allow-Methods", "GET")
-	ctx.Header("Access-Control-Allow-Headers", "Content-Type")
-
-	// 返回文件内容
-	ctx.DataFromReader(http.StatusOK, fileRecord.Size, fileRecord.ContentType, f, nil)
+	c.serveImage(ctx, &fileRecord)
 }
 
 // GetImageByPath 图床功能：基于路径直接访问图片（无需认证）
@@ -571,44 +639,64 @@ func (c *FileController) GetImageByPath(ctx *gin.Context) {
 	filePath := ctx.Query("path")
 	
 	if userID == "" || filePath == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少必要参数 userId 或 path"})
+		response.Error(ctx, response.ErrInvalidRequest, "缺少必要参数 userId 或 path")
 		return
 	}
 
 	// 转换用户ID
 	uid, err := strconv.Atoi(userID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 
 	// 查找文件记录
 	var fileRecord model.File
 	if err := c.DB.Where("user_id = ? AND path = ?", uid, filePath).First(&fileRecord).Error; err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		response.Error(ctx, response.ErrFileNotFound, "文件不存在")
 		return
 	}
 
 	// 检查是否为图片文件
 	if !strings.HasPrefix(fileRecord.ContentType, "image/") {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "该文件不是图片"})
+		response.Error(ctx, response.ErrFileTypeInvalid, "该文件不是图片")
+		return
+	}
+
+	c.serveImage(ctx, &fileRecord)
+}
+
+// serveImage 输出图片内容，超出当日流量限制时返回占位图片
+func (c *FileController) serveImage(ctx *gin.Context, fileRecord *model.File) {
+	if rejectQuarantined(ctx, fileRecord) {
 		return
 	}
 
 	// 获取文件
 	f, err := storage.GetFile(fileRecord.UserID, fileRecord.Path)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件失败: " + err.Error()})
+		logger.Request(ctx).Error("获取文件失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "获取文件失败")
 		return
 	}
 	defer f.Close()
 
+	if !c.Guard.ReserveTraffic(fileRecord, fileRecord.Size) {
+		c.Guard.servePlaceholder(ctx, http.StatusTooManyRequests, "bandwidth")
+		return
+	}
+
 	// 设置响应头
 	ctx.Header("Content-Type", fileRecord.ContentType)
 	ctx.Header("Content-Length", fmt.Sprintf("%d", fileRecord.Size))
 	ctx.Header("Cache-Control", "public, max-age=31536000") // 缓存1年
 	ctx.Header("ETag", fmt.Sprintf(`"%d-%d"`, fileRecord.ID, fileRecord.UpdatedAt.Unix()))
-	
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	if isActiveFile(fileRecord) {
+		// SVG 仍可通过 <img> 引用，直接打开时下载而不是渲染
+		setAttachment(ctx, fileRecord.Name)
+	}
+
 	// 支持跨域访问（图床功能）
 	ctx.Header("Access-Control-Allow-Origin", "*")
 	ctx.Header("Access-Control-Allow-Methods", "GET")
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/storage"
+	"gorm.io/gorm"
+)
+
+// HealthController 存活和就绪检查。
+// 探针直接输出 JSON，不使用统一响应结构，便于负载均衡器和编排系统按状态码判断
+type HealthController struct {
+	DB             *gorm.DB
+	MinFreeBytes   int64
+	MinFreePercent float64
+	Timeout        time.Duration
+}
+
+// NewHealthController 创建健康检查控制器
+func NewHealthController(db *gorm.DB, cfg *config.Config) *HealthController {
+	timeout := time.Duration(cfg.Health.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return &HealthController{
+		DB:             db,
+		MinFreeBytes:   cfg.Health.MinFreeMB * 1024 * 1024,
+		MinFreePercent: cfg.Health.MinFreePercent,
+		Timeout:        timeout,
+	}
+}
+
+// draining 服务正在停止，就绪检查返回 503，让负载均衡器不再转发新请求
+var draining atomic.Bool
+
+// SetDraining 标记服务开始停止
+func SetDraining() {
+	draining.Store(true)
+}
+
+// checkResult 单项检查结果
+type checkResult struct {
+	Status    string  `json:"status"` // ok 或 fail
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Livez 存活检查：进程能处理请求即返回 200
+func (h *HealthController) Livez(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz 就绪检查：数据库可连接、存储可写且可用空间充足时返回 200，否则返回 503 和失败的检查项；
+// 服务停止过程中始终返回 503
+func (h *HealthController) Readyz(ctx *gin.Context) {
+	if draining.Load() {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+	checks := map[string]checkResult{
+		"database": h.run(ctx, h.checkDatabase),
+		"storage":  h.run(ctx, func(context.Context) error { return storage.CheckWritable() }),
+		"disk":     h.run(ctx, func(context.Context) error { return h.checkDiskSpace() }),
+	}
+	status, code := "ok", http.StatusOK
+	for name, c := range checks {
+		if c.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			logger.Request(ctx).Warn("就绪检查 %s 失败: %s", name, c.Error)
+		}
+	}
+	ctx.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+// run 在超时时间内执行一项检查；存储挂载异常时系统调用可能长时间阻塞，超时后不再等待
+func (h *HealthController) run(ctx *gin.Context, check func(context.Context) error) checkResult {
+	c, cancel := context.WithTimeout(ctx.Request.Context(), h.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(c) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-c.Done():
+		err = fmt.Errorf("检查超时（%s）", h.Timeout)
+	}
+	result := checkResult{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status, result.Error = "fail", err.Error()
+	}
+	return result
+}
+
+// checkDatabase 检查数据库连接
+func (h *HealthController) checkDatabase(ctx context.Context) error {
+	sqlDB, err := h.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkDiskSpace 检查存储可用空间是否低于配置的阈值
+func (h *HealthController) checkDiskSpace() error {
+	total, _, free, err := storage.GetSystemStorageInfo()
+	if err != nil {
+		return err
+	}
+	if h.MinFreeBytes > 0 && free < h.MinFreeBytes {
+		return fmt.Errorf("可用空间 %d MB 低于阈值 %d MB", free/1024/1024, h.MinFreeBytes/1024/1024)
+	}
+	if h.MinFreePercent > 0 && total > 0 {
+		if percent := float64(free) / float64(total) * 100; percent < h.MinFreePercent {
+			return fmt.Errorf("可用空间占比 %.1f%% 低于阈值 %.1f%%", percent, h.MinFreePercent)
+		}
+	}
+	return nil
+}
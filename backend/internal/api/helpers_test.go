@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestDB 在临时目录中创建迁移好的 SQLite 数据库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	err = db.AutoMigrate(
+		&model.User{},
+		&model.File{},
+		&model.Directory{},
+		&model.Share{},
+		&model.Session{},
+		&model.Setting{},
+		&model.AuditLog{},
+		&model.RecoveryCode{},
+	)
+	if err != nil {
+		t.Fatalf("迁移数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// newTestSessions 创建使用测试密钥的会话管理器
+func newTestSessions(db *gorm.DB) *SessionManager {
+	return &SessionManager{DB: db, Secret: "test-secret-0123456789abcdefghijkl", AccessTTL: 15 * time.Minute, RefreshTTL: 24 * time.Hour}
+}
+
+// decodeResponse 解析统一响应结构
+func decodeResponse(t *testing.T, body []byte) response.Response {
+	t.Helper()
+	var resp response.Response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("解析响应失败: %v: %s", err, body)
+	}
+	return resp
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 流量统计维度
+const (
+	trafficScopeFile  = "file"
+	trafficScopeOwner = "owner"
+)
+
+// defaultPlaceholder 内置的 1x1 透明 PNG，未配置占位图片时使用
+var defaultPlaceholder = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d,
+	0x49, 0x48, 0x44, 0x52, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4, 0x89, 0x00, 0x00, 0x00,
+	0x0b, 0x49, 0x44, 0x41, 0x54, 0x78, 0xda, 0x63, 0x60, 0x00, 0x02, 0x00,
+	0x00, 0x05, 0x00, 0x01, 0xe9, 0xfa, 0xdc, 0xd8, 0x00, 0x00, 0x00, 0x00,
+	0x49, 0x45, 0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
+}
+
+// ImageGuard 图床防盗链与流量限制
+type ImageGuard struct {
+	DB     *gorm.DB
+	Config config.ImageHostConfig
+}
+
+// NewImageGuard 创建图床防护
+func NewImageGuard(db *gorm.DB, cfg config.ImageHostConfig) *ImageGuard {
+	return &ImageGuard{DB: db, Config: cfg}
+}
+
+// HotlinkMiddleware 防盗链中间件，按 Referer 的允许/拒绝列表拦截外站引用
+func (g *ImageGuard) HotlinkMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !g.Config.Hotlink.Enabled || g.refererAllowed(ctx) {
+			ctx.Next()
+			return
+		}
+		logger.Request(ctx).With("referer", ctx.GetHeader("Referer")).Debug("图床防盗链拦截: %s", ctx.Request.URL.Path)
+		g.servePlaceholder(ctx, http.StatusForbidden, "hotlink")
+		ctx.Abort()
+	}
+}
+
+// refererAllowed 判断当前请求的来源是否允许访问
+func (g *ImageGuard) refererAllowed(ctx *gin.Context) bool {
+	hotlink := g.Config.Hotlink
+
+	referer := ctx.GetHeader("Referer")
+	if referer == "" {
+		return hotlink.AllowEmptyReferer
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+
+	// 拒绝列表优先
+	if matchHost(host, hotlink.DeniedReferers) {
+		return false
+	}
+
+	// 本站页面始终允许
+	if host == requestHost(ctx) {
+		return true
+	}
+
+	// 允许列表为空时只允许本站引用
+	return matchHost(host, hotlink.AllowedReferers)
+}
+
+// requestHost 获取请求的主机名（不含端口）
+func requestHost(ctx *gin.Context) string {
+	host := ctx.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// matchHost 判断主机名是否匹配列表中的任一规则，*.example.com 匹配所有子域名
+func matchHost(host string, patterns []string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if p == "*" || p == host {
+			return true
+		}
+		if strings.HasPrefix(p, "*.") && strings.HasSuffix(host, p[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// trafficRetention 流量统计保留的天数，更早的记录由定时任务清理
+const trafficRetention = 7
+
+// ReserveTraffic 检查文件及其所有者当日流量是否超限，未超限时在同一事务中计入本次输出的 bytes 字节。
+// 每个维度以带条件的 UPDATE 原子地检查并累加，并发请求不会同时通过检查而超出上限
+func (g *ImageGuard) ReserveTraffic(file *model.File, bytes int64) bool {
+	limits := g.Config.Bandwidth
+	if limits.PerFileDaily <= 0 && limits.PerOwnerDaily <= 0 {
+		return true
+	}
+	day := time.Now().Format("2006-01-02")
+	err := g.DB.Transaction(func(tx *gorm.DB) error {
+		if err := addUsage(tx, day, trafficScopeFile, file.ID, bytes, limits.PerFileDaily); err != nil {
+			return err
+		}
+		return addUsage(tx, day, trafficScopeOwner, file.UserID, bytes, limits.PerOwnerDaily)
+	})
+	if errors.Is(err, errTrafficExceeded) {
+		return false
+	}
+	if err != nil {
+		// 统计失败不影响访问
+		logger.Error("记录图床流量失败: %v", err)
+	}
+	return true
+}
+
+// errTrafficExceeded 当日流量已达上限
+var errTrafficExceeded = errors.New("图床流量超出当日限制")
+
+// addUsage 在 limit 内累加某维度当日流量，limit 不大于 0 时不限制
+func addUsage(tx *gorm.DB, day, scope string, targetID uint, bytes, limit int64) error {
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.ImageTraffic{Day: day, Scope: scope, TargetID: targetID}).Error
+	if err != nil {
+		return err
+	}
+	q := tx.Model(&model.ImageTraffic{}).Where("day = ? AND scope = ? AND target_id = ?", day, scope, targetID)
+	if limit > 0 {
+		q = q.Where("bytes + ? <= ?", bytes, limit)
+	}
+	result := q.UpdateColumn("bytes", gorm.Expr("bytes + ?", bytes))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errTrafficExceeded
+	}
+	return nil
+}
+
+// PurgeTraffic 清理超过保留天数的流量统计（定时任务调用）
+func (g *ImageGuard) PurgeTraffic(ctx context.Context) error {
+	before := time.Now().AddDate(0, 0, -trafficRetention).Format("2006-01-02")
+	return g.DB.WithContext(ctx).Unscoped().Where("day < ?", before).Delete(&model.ImageTraffic{}).Error
+}
+
+// servePlaceholder 返回占位图片，并通过响应头说明拦截原因
+func (g *ImageGuard) servePlaceholder(ctx *gin.Context, status int, reason string) {
+	data, contentType := defaultPlaceholder, "image/png"
+	if p := g.Config.PlaceholderImage; p != "" {
+		if b, err := os.ReadFile(p); err == nil {
+			data = b
+			if ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(p))); ct != "" {
+				contentType = ct
+			}
+		} else {
+			logger.Request(ctx).Warn("读取占位图片失败: %v", err)
+		}
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Image-Blocked", reason)
+	ctx.Data(status, contentType, data)
+}
@@ -0,0 +1,729 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "image/gif"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"github.com/huanhq99/H-Cloud/internal/security"
+	"github.com/huanhq99/H-Cloud/internal/storage"
+	"gorm.io/gorm"
+)
+
+// 图床上传密钥前缀
+const imageKeyPrefix = "hci_"
+
+// 缩略图最大边长（像素）
+const thumbnailSize = 256
+
+// 生成缩略图允许的最大像素数，更大的图片解码占用内存过多，直接重定向到原图
+const maxThumbnailPixels = 25_000_000
+
+// ThumbnailCacheTTL 缓存的缩略图保留时间，过期后由定时任务清理，再次访问时重新生成
+const ThumbnailCacheTTL = 30 * 24 * time.Hour
+
+// ImageHostController 图床上传控制器，兼容 Chevereto API（PicGo、ShareX 均内置支持）
+type ImageHostController struct {
+	DB             *gorm.DB
+	Config         config.ImageHostConfig
+	Guard          *ImageGuard // 缩略图同样计入图床流量
+	Scanner        *UploadScanner
+	TrustedProxies []*net.IPNet // 只信任来自这些地址的 X-Forwarded-Proto（server.trusted_proxies）
+}
+
+// NewImageHostController 创建图床上传控制器
+func NewImageHostController(db *gorm.DB, cfg *config.Config, scanner *UploadScanner) *ImageHostController {
+	return &ImageHostController{
+		DB:             db,
+		Config:         cfg.ImageHost,
+		Guard:          NewImageGuard(db, cfg.ImageHost),
+		Scanner:        scanner,
+		TrustedProxies: parseTrustedProxies(cfg.Server.TrustedProxies),
+	}
+}
+
+// parseTrustedProxies 解析 IP 地址或网段列表，单个地址按只包含该地址的网段处理；配置校验已排除无效的项
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		ip := net.ParseIP(p)
+		if ip == nil {
+			continue
+		}
+		bits := 8 * net.IPv6len
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits = v4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets
+}
+
+// fromTrustedProxy 判断请求是否由受信任的反向代理转发
+func (c *ImageHostController) fromTrustedProxy(ctx *gin.Context) bool {
+	ip := net.ParseIP(ctx.RemoteIP())
+	if ip == nil {
+		return false
+	}
+	for _, n := range c.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashSecret 计算密钥或令牌的哈希，数据库中只保存哈希值
+func hashSecret(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex 生成 n 字节的随机十六进制字符串
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetAPIKey 查看当前用户的图床密钥信息（不返回明文）
+func (c *ImageHostController) GetAPIKey(ctx *gin.Context) {
+	uidVal, exists := ctx.Get("userID")
+	if !exists {
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
+		return
+	}
+	var key model.ImageAPIKey
+	if err := c.DB.Where("user_id = ?", uidVal.(uint)).First(&key).Error; err != nil {
+		response.Success(ctx, gin.H{"exists": false})
+		return
+	}
+	response.Success(ctx, gin.H{
+		"exists":     true,
+		"prefix":     key.KeyPrefix,
+		"createdAt":  key.CreatedAt.Format(time.RFC3339),
+		"lastUsedAt": key.LastUsedAt,
+	})
+}
+
+// RegenerateAPIKey 生成（或重新生成）图床密钥，明文仅返回一次
+func (c *ImageHostController) RegenerateAPIKey(ctx *gin.Context) {
+	uidVal, exists := ctx.Get("userID")
+	if !exists {
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
+		return
+	}
+	userID := uidVal.(uint)
+
+	secret, err := randomHex(24)
+	if err != nil {
+		logger.Request(ctx).Error("生成密钥失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "生成密钥失败")
+		return
+	}
+	plain := imageKeyPrefix + secret
+	key := model.ImageAPIKey{
+		UserID:    userID,
+		KeyHash:   hashSecret(plain),
+		KeyPrefix: plain[:len(imageKeyPrefix)+6],
+	}
+
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		// 每个用户只保留一个密钥，旧密钥立即失效
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.ImageAPIKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&key).Error
+	})
+	if err != nil {
+		logger.Request(ctx).Error("保存密钥失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "保存密钥失败")
+		return
+	}
+
+	response.Success(ctx, gin.H{
+		"message": "密钥已生成，请妥善保存，之后将无法再次查看",
+		"key":     plain,
+		"prefix":  key.KeyPrefix,
+	})
+}
+
+// RevokeAPIKey 吊销当前用户的图床密钥
+func (c *ImageHostController) RevokeAPIKey(ctx *gin.Context) {
+	uidVal, exists := ctx.Get("userID")
+	if !exists {
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
+		return
+	}
+	if err := c.DB.Unscoped().Where("user_id = ?", uidVal.(uint)).Delete(&model.ImageAPIKey{}).Error; err != nil {
+		logger.Request(ctx).Error("吊销密钥失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "吊销密钥失败")
+		return
+	}
+	response.Success(ctx, gin.H{"message": "密钥已吊销"})
+}
+
+// authenticateKey 根据请求中的密钥查找用户，支持 X-API-Key 头、key 表单字段和查询参数，
+// 也接受具备 files:write 权限的个人访问令牌（Authorization: Bearer）
+func (c *ImageHostController) authenticateKey(ctx *gin.Context) (*model.User, bool) {
+	if tokenStr, ok := bearerToken(ctx); ok && strings.HasPrefix(tokenStr, accessTokenPrefix) {
+		pat, user, err := lookupAccessToken(c.DB, tokenStr)
+		if err != nil || !hasScope(strings.Fields(pat.Scopes), ScopeFilesWrite) {
+			return nil, false
+		}
+		audit.SetActor(ctx, user.ID, user.Username)
+		return user, true
+	}
+
+	plain := ctx.GetHeader("X-API-Key")
+	if plain == "" {
+		plain = ctx.PostForm("key")
+	}
+	if plain == "" {
+		plain = ctx.Query("key")
+	}
+	if !strings.HasPrefix(plain, imageKeyPrefix) {
+		return nil, false
+	}
+
+	var key model.ImageAPIKey
+	if err := c.DB.Where("key_hash = ?", hashSecret(plain)).First(&key).Error; err != nil {
+		return nil, false
+	}
+	var user model.User
+	if err := c.DB.First(&user, key.UserID).Error; err != nil || user.Disabled {
+		return nil, false
+	}
+	touchLastUsed(c.DB, &key, key.LastUsedAt)
+	audit.SetActor(ctx, user.ID, user.Username)
+	return &user, true
+}
+
+// cheveretoError 以 Chevereto 格式返回错误
+func cheveretoError(ctx *gin.Context, status int, message string) {
+	ctx.JSON(status, gin.H{
+		"status_code": status,
+		"error": gin.H{
+			"message": message,
+			"code":    status,
+		},
+		"status_txt": http.StatusText(status),
+	})
+}
+
+// baseURL 获取生成链接使用的站点地址
+func (c *ImageHostController) baseURL(ctx *gin.Context) string {
+	if c.Config.BaseURL != "" {
+		return strings.TrimRight(c.Config.BaseURL, "/")
+	}
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	// 客户端可以直接发送 X-Forwarded-Proto，只有受信任的反向代理转发的请求才采用
+	if c.fromTrustedProxy(ctx) {
+		if proto := strings.ToLower(ctx.GetHeader("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+	}
+	return scheme + "://" + ctx.Request.Host
+}
+
+// Upload 图床上传（Chevereto 兼容）
+// 参数：source/file/image 文件字段，key 上传密钥，album/path 目标目录，naming=random|original
+func (c *ImageHostController) Upload(ctx *gin.Context) {
+	user, ok := c.authenticateKey(ctx)
+	if !ok {
+		cheveretoError(ctx, http.StatusUnauthorized, "无效的上传密钥")
+		return
+	}
+	if user.Disabled {
+		cheveretoError(ctx, http.StatusForbidden, "账户已被禁用")
+		return
+	}
+
+	policy := security.NewUploadPolicy(config.Current().Upload, user.Role, user.Username)
+	limitUploadBody(ctx, policy)
+
+	// Chevereto 使用 source 字段，同时兼容常见的 file、image 字段名
+	fileHeader, err := ctx.FormFile("source")
+	if err != nil {
+		fileHeader, err = ctx.FormFile("file")
+	}
+	if err != nil {
+		fileHeader, err = ctx.FormFile("image")
+	}
+	if err != nil && isBodyTooLarge(err) {
+		cheveretoError(ctx, http.StatusRequestEntityTooLarge, fmt.Sprintf("文件大小超过限制，最大允许 %d MB", policy.Largest()/1024/1024))
+		return
+	}
+	if err != nil {
+		cheveretoError(ctx, http.StatusBadRequest, "未找到上传的图片")
+		return
+	}
+
+	// 验证文件名和类型
+	if err := security.ValidateFileName(fileHeader.Filename); err != nil {
+		cheveretoError(ctx, http.StatusBadRequest, "文件名不合法: "+err.Error())
+		return
+	}
+	validation := policy.Check(fileHeader.Filename, fileHeader.Size)
+	if !validation.IsValid {
+		status := http.StatusBadRequest
+		if errors.Is(validation.Error, security.ErrFileTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		cheveretoError(ctx, status, validation.Error.Error())
+		return
+	}
+	if validation.FileType != "image" {
+		cheveretoError(ctx, http.StatusBadRequest, "仅支持上传图片")
+		return
+	}
+
+	// 先按认证时读到的用量粗略检查配额，避免保存注定超额的文件；保存记录时再在事务中精确检查
+	if user.StorageQuota > 0 && user.StorageUsed+fileHeader.Size > user.StorageQuota {
+		cheveretoError(ctx, http.StatusForbidden, "存储空间不足")
+		return
+	}
+
+	// 目标相册目录
+	album := ctx.PostForm("album")
+	if album == "" {
+		album = ctx.PostForm("path")
+	}
+	if album == "" {
+		album = c.Config.UploadDir
+	}
+	if err := security.ValidateFilePath(album); err != nil {
+		cheveretoError(ctx, http.StatusBadRequest, "相册路径不合法: "+err.Error())
+		return
+	}
+	album = security.SanitizePath(album)
+
+	// 命名方式
+	naming := ctx.DefaultPostForm("naming", c.Config.Naming)
+	filename := fileHeader.Filename
+	if naming != "original" {
+		random, err := randomHex(8)
+		if err != nil {
+			logger.Request(ctx).Error("生成文件名失败: %v", err)
+			cheveretoError(ctx, http.StatusInternalServerError, "生成文件名失败")
+			return
+		}
+		filename = random + validation.Extension
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		logger.Request(ctx).Error("打开文件失败: %v", err)
+		cheveretoError(ctx, http.StatusInternalServerError, "打开文件失败")
+		return
+	}
+	defer src.Close()
+
+	// 按文件头部识别实际类型，内容不是图片时拒绝
+	detected, body, err := security.SniffContentType(src)
+	if err != nil {
+		logger.Request(ctx).Error("读取文件失败: %v", err)
+		cheveretoError(ctx, http.StatusInternalServerError, "读取文件失败")
+		return
+	}
+	if err := policy.CheckContent(&validation, detected); err != nil {
+		cheveretoError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !strings.HasPrefix(validation.ContentType, "image/") {
+		cheveretoError(ctx, http.StatusBadRequest, "仅支持上传图片")
+		return
+	}
+
+	savedPath, err := storage.SaveFile(user.ID, album, filename, fileHeader.Size, body)
+	if err != nil {
+		logger.Request(ctx).Error("保存文件失败: %v", err)
+		cheveretoError(ctx, http.StatusInternalServerError, "保存文件失败: "+err.Error())
+		return
+	}
+
+	deleteToken, err := randomHex(16)
+	if err != nil {
+		storage.DeleteFile(user.ID, savedPath)
+		logger.Request(ctx).Error("生成删除令牌失败: %v", err)
+		cheveretoError(ctx, http.StatusInternalServerError, "生成删除令牌失败")
+		return
+	}
+
+	fileModel := model.File{
+		Name:        filepath.Base(savedPath),
+		Path:        savedPath,
+		Size:        fileHeader.Size,
+		ContentType: validation.ContentType,
+		UserID:      user.ID,
+		DeleteToken: deleteToken,
+	}
+	if err := c.Scanner.Scan(ctx, &fileModel); err != nil {
+		storage.DeleteFile(user.ID, savedPath)
+		logger.Request(ctx).Error("扫描文件失败: %v", err)
+		cheveretoError(ctx, http.StatusBadGateway, "病毒扫描失败，请稍后重试")
+		return
+	}
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		return c.saveImage(tx, user.ID, album, &fileModel)
+	})
+	if err != nil {
+		if fileModel.Quarantined {
+			storage.DeleteQuarantined(fileModel.QuarantinePath)
+		} else {
+			storage.DeleteFile(user.ID, savedPath)
+		}
+		switch {
+		case errors.Is(err, errAccountDisabled):
+			cheveretoError(ctx, http.StatusForbidden, "账户已被禁用")
+		case errors.Is(err, errQuotaExceeded):
+			cheveretoError(ctx, http.StatusForbidden, "存储空间不足")
+		default:
+			logger.Request(ctx).Error("保存文件信息失败: %v", err)
+			cheveretoError(ctx, http.StatusInternalServerError, "保存文件信息失败")
+		}
+		return
+	}
+	audit.SetTarget(ctx, audit.TargetFile, fileModel.ID, savedPath)
+	if fileModel.Quarantined {
+		c.Scanner.Notify(&fileModel)
+		cheveretoError(ctx, http.StatusUnprocessableEntity, fmt.Sprintf("文件包含恶意内容（%s），已被隔离", fileModel.Signature))
+		return
+	}
+
+	// 读取图片尺寸（SVG 等无法解析的格式返回 0）
+	var width, height int
+	if f, err := storage.GetFile(user.ID, savedPath); err == nil {
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			width, height = cfg.Width, cfg.Height
+		}
+		f.Close()
+	}
+
+	base := c.baseURL(ctx)
+	url := fmt.Sprintf("%s/api/image/%d", base, fileModel.ID)
+	name := strings.TrimSuffix(fileModel.Name, validation.Extension)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status_code": http.StatusOK,
+		"success": gin.H{
+			"message": "image uploaded",
+			"code":    http.StatusOK,
+		},
+		"image": gin.H{
+			"id":          fileModel.ID,
+			"name":        name,
+			"filename":    fileModel.Name,
+			"extension":   strings.TrimPrefix(validation.Extension, "."),
+			"path":        savedPath,
+			"album":       album,
+			"size":        fileModel.Size,
+			"width":       width,
+			"height":      height,
+			"mime":        fileModel.ContentType,
+			"date":        fileModel.CreatedAt.Format("2006-01-02 15:04:05"),
+			"url":         url,
+			"display_url": url,
+			"url_viewer":  url,
+			"thumb":       gin.H{"url": url + "/thumb"},
+			"delete_url":  fmt.Sprintf("%s/api/image/delete/%s", base, deleteToken),
+			"markdown":    fmt.Sprintf("![%s](%s)", name, url),
+			"html":        fmt.Sprintf(`<img src="%s" alt="%s">`, url, html.EscapeString(name)),
+		},
+		"status_txt": "OK",
+	})
+}
+
+// errQuotaExceeded 保存后将超出存储配额
+var errQuotaExceeded = errors.New("存储空间不足")
+
+// saveImage 在事务中保存图片记录：以带条件的 UPDATE 原子地确认账户未被禁用、检查配额并累加用量，
+// 再为相册路径创建目录记录。先执行写操作，SQLite 下并发上传不会因读锁升级失败。隔离的文件不计入用量
+func (c *ImageHostController) saveImage(tx *gorm.DB, userID uint, album string, file *model.File) error {
+	if !file.Quarantined {
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND disabled = ? AND (storage_quota <= 0 OR storage_used + ? <= storage_quota)", userID, false, file.Size).
+			UpdateColumn("storage_used", gorm.Expr("storage_used + ?", file.Size))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var user model.User
+			if err := tx.First(&user, userID).Error; err != nil {
+				return err
+			}
+			if user.Disabled {
+				return errAccountDisabled
+			}
+			return errQuotaExceeded
+		}
+	}
+	if err := ensureDirectories(tx, userID, album); err != nil {
+		return err
+	}
+	return tx.Create(file).Error
+}
+
+// ensureDirectories 为目录路径的每一级创建目录记录，使上传的图片出现在网盘的目录树中；已删除的同名记录会被恢复
+func ensureDirectories(tx *gorm.DB, userID uint, dirPath string) error {
+	var parentID *uint
+	current := ""
+	for _, name := range strings.Split(filepath.ToSlash(dirPath), "/") {
+		if name == "" {
+			continue
+		}
+		current = filepath.Join(current, name)
+		var dir model.Directory
+		err := tx.Unscoped().Where("user_id = ? AND path = ?", userID, current).
+			Attrs(model.Directory{Name: name, Path: current, UserID: userID, ParentID: parentID}).
+			FirstOrCreate(&dir).Error
+		if err != nil {
+			return err
+		}
+		if dir.DeletedAt.Valid {
+			if err := tx.Unscoped().Model(&dir).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		id := dir.ID
+		parentID = &id
+	}
+	return nil
+}
+
+// DeleteByToken 通过删除链接删除图床图片。ShareX 等客户端在浏览器中打开该链接（GET），
+// 此时只返回确认页面，确认后以 POST 提交才删除，避免链接预览、预取等自动访问误删图片；客户端也可直接以 DELETE 调用
+func (c *ImageHostController) DeleteByToken(ctx *gin.Context) {
+	token := ctx.Param("token")
+	if token == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "无效的删除链接")
+		return
+	}
+
+	var fileRecord model.File
+	if err := c.DB.Where("delete_token = ?", token).First(&fileRecord).Error; err != nil {
+		response.Error(ctx, response.ErrFileNotFound, "图片不存在或已删除")
+		return
+	}
+	if ctx.Request.Method == http.MethodGet {
+		confirmDeletePage(ctx, &fileRecord)
+		return
+	}
+	audit.SetTarget(ctx, audit.TargetFile, fileRecord.ID, fileRecord.Path)
+
+	if err := storage.DeleteFile(fileRecord.UserID, fileRecord.Path); err != nil {
+		logger.Request(ctx).Error("删除文件失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "删除文件失败")
+		return
+	}
+	if err := c.DB.Unscoped().Delete(&fileRecord).Error; err != nil {
+		logger.Request(ctx).Error("删除文件记录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "删除文件记录失败")
+		return
+	}
+	c.DB.Model(&model.User{}).Where("id = ? AND storage_used >= ?", fileRecord.UserID, fileRecord.Size).
+		UpdateColumn("storage_used", gorm.Expr("storage_used - ?", fileRecord.Size))
+	if err := storage.DeleteThumbnails(thumbnailPrefix(fileRecord.ID)); err != nil {
+		logger.Request(ctx).Warn("删除缓存的缩略图失败: %v", err)
+	}
+
+	if ctx.Request.Method == http.MethodPost && ctx.ContentType() == "application/x-www-form-urlencoded" {
+		// 确认页面提交的表单，直接在浏览器中显示结果
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(deletePageHead+"<p>图片已删除。</p></body></html>"))
+		return
+	}
+	response.Success(ctx, gin.H{"message": "图片已删除"})
+}
+
+const deletePageHead = `<!DOCTYPE html><html lang="zh-CN"><head><meta charset="utf-8">` +
+	`<meta name="viewport" content="width=device-width, initial-scale=1"><title>删除图片</title></head><body>`
+
+// confirmDeletePage 删除图片前的确认页面，表单提交到当前地址
+func confirmDeletePage(ctx *gin.Context, file *model.File) {
+	page := deletePageHead +
+		fmt.Sprintf("<p>确定要删除图片 <strong>%s</strong> 吗？删除后无法恢复。</p>", html.EscapeString(file.Name)) +
+		`<form method="post"><button type="submit">删除</button></form></body></html>`
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Referrer-Policy", "no-referrer")
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
+
+// GetThumbnail 获取图片缩略图，生成后缓存在磁盘上；无法解码的格式（SVG、WebP 等）和像素过多的图片重定向到原图
+func (c *ImageHostController) GetThumbnail(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, response.ErrInvalidRequest, "无效的文件ID")
+		return
+	}
+
+	var fileRecord model.File
+	if err := c.DB.First(&fileRecord, uint(id)).Error; err != nil {
+		response.Error(ctx, response.ErrFileNotFound, "文件不存在")
+		return
+	}
+	if rejectQuarantined(ctx, &fileRecord) {
+		return
+	}
+	if !strings.HasPrefix(fileRecord.ContentType, "image/") {
+		response.Error(ctx, response.ErrFileTypeInvalid, "该文件不是图片")
+		return
+	}
+
+	// PNG、GIF 缩略图保留透明度，其余格式输出 JPEG
+	asPNG := fileRecord.ContentType == "image/png" || fileRecord.ContentType == "image/gif"
+	contentType, ext := "image/jpeg", ".jpg"
+	if asPNG {
+		contentType, ext = "image/png", ".png"
+	}
+	// 文件内容更新后 UpdatedAt 随之变化，旧的缓存不再命中
+	name := fmt.Sprintf("%s%d%s", thumbnailPrefix(fileRecord.ID), fileRecord.UpdatedAt.UnixNano(), ext)
+
+	data, err := cachedThumbnail(name)
+	if err != nil {
+		data, err = renderThumbnail(&fileRecord, asPNG)
+		if errors.Is(err, errNoThumbnail) {
+			ctx.Redirect(http.StatusFound, path.Join("/api/image", ctx.Param("id")))
+			return
+		}
+		if err != nil {
+			logger.Request(ctx).Error("生成缩略图失败: %v", err)
+			response.Error(ctx, response.ErrInternalServer, "获取文件失败")
+			return
+		}
+		if err := storage.SaveThumbnail(name, data); err != nil {
+			logger.Request(ctx).Warn("缓存缩略图失败: %v", err)
+		}
+	}
+
+	if !c.Guard.ReserveTraffic(&fileRecord, int64(len(data))) {
+		c.Guard.servePlaceholder(ctx, http.StatusTooManyRequests, "bandwidth")
+		return
+	}
+	ctx.Header("Cache-Control", "public, max-age=31536000")
+	ctx.Header("Access-Control-Allow-Origin", "*")
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// thumbnailPrefix 文件缓存缩略图的文件名前缀
+func thumbnailPrefix(fileID uint) string {
+	return fmt.Sprintf("%d_", fileID)
+}
+
+// cachedThumbnail 读取缓存的缩略图；缓存无法读取（如主密钥已移除）时按未缓存处理，重新生成后覆盖
+func cachedThumbnail(name string) ([]byte, error) {
+	f, err := storage.GetThumbnail(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// errNoThumbnail 图片无法解码或像素过多，不生成缩略图
+var errNoThumbnail = errors.New("无法生成缩略图")
+
+// renderThumbnail 解码原图并生成缩略图。先只读取文件头中的尺寸，像素数超过 maxThumbnailPixels 时不解码，
+// 防止小文件声明巨大尺寸耗尽内存
+func renderThumbnail(file *model.File, asPNG bool) ([]byte, error) {
+	f, err := storage.GetFile(file.UserID, file.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil || int64(cfg.Width)*int64(cfg.Height) > maxThumbnailPixels {
+		return nil, errNoThumbnail
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, errNoThumbnail
+	}
+	thumb := resizeImage(src, thumbnailSize)
+
+	var buf bytes.Buffer
+	if asPNG {
+		err = png.Encode(&buf, thumb)
+	} else {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resizeImage 按比例缩小图片使最长边不超过 max，采用区域平均采样
+func resizeImage(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return src
+	}
+	nw, nh := max, h*max/w
+	if h > w {
+		nw, nh = w*max/h, max
+	}
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		y0, y1 := b.Min.Y+y*h/nh, b.Min.Y+(y+1)*h/nh
+		for x := 0; x < nw; x++ {
+			x0, x1 := b.Min.X+x*w/nw, b.Min.X+(x+1)*w/nw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			// RGBA() 返回预乘 alpha 的 16 位分量
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
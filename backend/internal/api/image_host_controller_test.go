@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/config"
+)
+
+func TestImageHostBaseURL(t *testing.T) {
+	c := &ImageHostController{TrustedProxies: parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})}
+	tests := []struct {
+		name   string
+		remote string
+		proto  string
+		want   string
+	}{
+		{name: "直接访问", remote: "203.0.113.5:1234", want: "http://pan.example.com"},
+		{name: "客户端伪造 X-Forwarded-Proto", remote: "203.0.113.5:1234", proto: "https", want: "http://pan.example.com"},
+		{name: "受信任网段中的代理", remote: "10.1.2.3:1234", proto: "https", want: "https://pan.example.com"},
+		{name: "受信任的单个地址", remote: "192.0.2.1:1234", proto: "HTTPS", want: "https://pan.example.com"},
+		{name: "未受信任的相邻地址", remote: "192.0.2.2:1234", proto: "https", want: "http://pan.example.com"},
+		{name: "代理传入无效的协议", remote: "10.1.2.3:1234", proto: "javascript", want: "http://pan.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, "http://pan.example.com/api/image/1", nil)
+			ctx.Request.RemoteAddr = tt.remote
+			if tt.proto != "" {
+				ctx.Request.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if got := c.baseURL(ctx); got != tt.want {
+				t.Errorf("站点地址应为 %s，实际为 %s", tt.want, got)
+			}
+		})
+	}
+
+	// 配置了 base_url 时不根据请求推断
+	c.Config = config.ImageHostConfig{BaseURL: "https://img.example.com/"}
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "http://pan.example.com/", nil)
+	if got := c.baseURL(ctx); got != "https://img.example.com" {
+		t.Errorf("应使用配置的 base_url，实际为 %s", got)
+	}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/ldapauth"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// 账户来源
+const (
+	authSourceLocal = "local"
+	authSourceOIDC  = "oidc"
+	authSourceLDAP  = "ldap"
+)
+
+var (
+	errAccountDisabled    = errors.New("账户已被禁用")
+	errLDAPNotProvisioned = errors.New("账户不存在，请联系管理员开通")
+)
+
+// LDAPSyncResult 一次同步的统计
+type LDAPSyncResult struct {
+	Created  int      `json:"created"`
+	Updated  int      `json:"updated"`
+	Disabled int      `json:"disabled"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors"`
+	// DisableSkipped 因目录结果为空或待禁用用户过多而未禁用的用户名
+	DisableSkipped []string `json:"disableSkipped,omitempty"`
+}
+
+// LDAPProvider LDAP 用户的登录与定时同步
+type LDAPProvider struct {
+	DB       *gorm.DB
+	Client   *ldapauth.Client
+	Sessions *SessionManager
+}
+
+// NewLDAPProvider 创建 LDAP 认证提供者，未启用时返回 nil
+func NewLDAPProvider(db *gorm.DB, cfg *config.Config, sessions *SessionManager) *LDAPProvider {
+	if !cfg.Auth.LDAP.Enabled {
+		return nil
+	}
+	return &LDAPProvider{DB: db, Client: ldapauth.NewClient(cfg.Auth.LDAP), Sessions: sessions}
+}
+
+// Login 通过 LDAP 绑定验证密码，并同步本地用户的邮箱、角色和配额
+func (p *LDAPProvider) Login(username, password string) (*model.User, error) {
+	entry, err := p.Client.Authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+	user, _, err := p.provision(entry, p.Client.Config.AutoCreate)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errLDAPNotProvisioned
+	}
+	return user, nil
+}
+
+// provision 按 DN 查找本地用户并更新属性，不存在时按需创建；返回是否为新建用户
+func (p *LDAPProvider) provision(entry *ldapauth.Entry, create bool) (*model.User, bool, error) {
+	role, quota := p.Client.MapGroups(entry.Groups)
+
+	var user model.User
+	err := p.DB.Where("ldap_dn = ?", entry.DN).First(&user).Error
+	if err == nil {
+		updates := map[string]interface{}{"role": role, "username": entry.Username}
+		if entry.Email != "" {
+			updates["email"] = entry.Email
+		}
+		if quota > 0 {
+			updates["storage_quota"] = quota
+		}
+		if err := p.DB.Model(&user).Updates(updates).Error; err != nil {
+			return nil, false, fmt.Errorf("更新用户 %s 失败: %w", entry.Username, err)
+		}
+		return &user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	if !create {
+		return nil, false, nil
+	}
+
+	var cnt int64
+	p.DB.Model(&model.User{}).Where("username = ?", entry.Username).Count(&cnt)
+	if cnt > 0 {
+		return nil, false, fmt.Errorf("用户名 %s 已被本地账户占用", entry.Username)
+	}
+	email := entry.Email
+	if email == "" {
+		// 邮箱字段唯一且必填，目录中没有邮箱时使用占位地址
+		email = entry.Username + "@ldap.invalid"
+	}
+	p.DB.Model(&model.User{}).Where("email = ?", email).Count(&cnt)
+	if cnt > 0 {
+		return nil, false, fmt.Errorf("邮箱 %s 已被其他账户使用", email)
+	}
+
+	// 密码始终由 LDAP 校验，本地保存不可用的随机哈希
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, false, err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, false, err
+	}
+	user = model.User{
+		Username:   entry.Username,
+		Password:   string(hashed),
+		Email:      email,
+		Role:       role,
+		AuthSource: authSourceLDAP,
+		LDAPDN:     entry.DN,
+	}
+	if quota > 0 {
+		user.StorageQuota = quota
+	}
+	if err := p.DB.Create(&user).Error; err != nil {
+		return nil, false, fmt.Errorf("创建用户 %s 失败: %w", entry.Username, err)
+	}
+	return &user, true, nil
+}
+
+// Sync 同步目录中的用户：导入新用户、更新角色和配额，禁用已从目录中移除的用户；
+// 被禁用的用户重新出现在目录中时不会自动启用，需要管理员手动处理。
+// ctx 取消时在当前用户处理完后中止，且不执行禁用步骤，避免把尚未处理到的用户误判为已移除；
+// 目录返回空结果或待禁用用户超过 sync_max_disable_ratio 时同样跳过禁用，只记录日志
+func (p *LDAPProvider) Sync(ctx context.Context) (*LDAPSyncResult, error) {
+	entries, err := p.Client.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LDAPSyncResult{Errors: []string{}}
+	seen := make(map[string]bool, len(entries))
+	for i := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		seen[entries[i].DN] = true
+		user, created, err := p.provision(&entries[i], p.Client.Config.AutoCreate)
+		switch {
+		case err != nil:
+			result.Errors = append(result.Errors, err.Error())
+		case user == nil:
+			result.Skipped++
+		case created:
+			result.Created++
+		default:
+			result.Updated++
+		}
+	}
+
+	var users []model.User
+	if err := p.DB.Where("auth_source = ? AND disabled = ?", authSourceLDAP, false).Find(&users).Error; err != nil {
+		return result, err
+	}
+	var missing []model.User
+	for _, u := range users {
+		if !seen[u.LDAPDN] {
+			missing = append(missing, u)
+		}
+	}
+	// 目录返回空结果或一次要禁用过多用户，多半是过滤器、基准 DN 或服务账号权限配置有误，跳过禁用等待管理员确认
+	if limit := max(int(p.Client.Config.SyncMaxDisable*float64(len(users))), 1); len(missing) > 0 && (len(entries) == 0 || len(missing) > limit) {
+		for _, u := range missing {
+			result.DisableSkipped = append(result.DisableSkipped, u.Username)
+		}
+		logger.Warn("LDAP 同步跳过禁用: 目录返回 %d 个用户, 待禁用 %d 个 (上限 %d), 未禁用的用户: %s",
+			len(entries), len(missing), limit, strings.Join(result.DisableSkipped, ", "))
+		missing = nil
+	}
+	for _, u := range missing {
+		if err := p.DB.Model(&u).Update("disabled", true).Error; err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		p.Sessions.RevokeAll(u.ID)
+		result.Disabled++
+	}
+
+	logger.Info("LDAP 同步完成: 新建 %d, 更新 %d, 禁用 %d, 跳过 %d, 失败 %d",
+		result.Created, result.Updated, result.Disabled, result.Skipped, len(result.Errors))
+	return result, nil
+}
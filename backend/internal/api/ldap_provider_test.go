@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/ldapauth"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"gorm.io/gorm"
+)
+
+// fakeLDAPConn 内存目录：服务账号绑定总是成功，用户按 uid 查找
+type fakeLDAPConn struct {
+	entries   []*ldap.Entry
+	passwords map[string]string // DN -> 密码
+}
+
+func (c *fakeLDAPConn) Bind(username, password string) error {
+	if username == "cn=svc" {
+		return nil
+	}
+	if p, ok := c.passwords[username]; ok && p == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *fakeLDAPConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := &ldap.SearchResult{}
+	for _, e := range c.entries {
+		if strings.Contains(req.Filter, "(uid=*)") || strings.Contains(req.Filter, "(uid="+e.GetAttributeValue("uid")+")") {
+			result.Entries = append(result.Entries, e)
+		}
+	}
+	return result, nil
+}
+
+func (c *fakeLDAPConn) SearchWithPaging(req *ldap.SearchRequest, _ uint32) (*ldap.SearchResult, error) {
+	return c.Search(req)
+}
+
+func (c *fakeLDAPConn) Close() error { return nil }
+
+// ldapHarness 使用内存目录的 LDAP 认证提供者
+type ldapHarness struct {
+	db       *gorm.DB
+	provider *LDAPProvider
+	conn     *fakeLDAPConn
+	dialErr  error
+}
+
+func newLDAPHarness(t *testing.T, ratio float64) *ldapHarness {
+	t.Helper()
+	db := newTestDB(t)
+	h := &ldapHarness{db: db, conn: &fakeLDAPConn{passwords: map[string]string{}}}
+	client := ldapauth.NewClient(config.LDAPConfig{
+		Enabled:        true,
+		BindDN:         "cn=svc",
+		BaseDN:         "dc=example,dc=com",
+		UserFilter:     "(&(objectClass=person)(uid=%s))",
+		UsernameAttr:   "uid",
+		EmailAttr:      "mail",
+		GroupAttr:      "memberOf",
+		GroupMappings:  []config.GroupMapping{{Group: "admins", Role: "admin"}},
+		DefaultRole:    "user",
+		AutoCreate:     true,
+		SyncMaxDisable: ratio,
+	})
+	client.Dial = func() (ldapauth.Conn, error) {
+		if h.dialErr != nil {
+			return nil, h.dialErr
+		}
+		return h.conn, nil
+	}
+	h.provider = &LDAPProvider{DB: db, Client: client, Sessions: newTestSessions(db)}
+	return h
+}
+
+// addEntry 在目录中添加用户
+func (h *ldapHarness) addEntry(uid string, groups ...string) {
+	dn := "uid=" + uid + ",ou=people,dc=example,dc=com"
+	h.conn.entries = append(h.conn.entries, ldap.NewEntry(dn, map[string][]string{
+		"uid":      {uid},
+		"mail":     {uid + "@example.com"},
+		"memberOf": groups,
+	}))
+	h.conn.passwords[dn] = uid + "-pass"
+}
+
+// sync 执行同步并返回结果
+func (h *ldapHarness) sync(t *testing.T) *LDAPSyncResult {
+	t.Helper()
+	result, err := h.provider.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	return result
+}
+
+// disabled 返回已禁用的用户名
+func (h *ldapHarness) disabled(t *testing.T) []string {
+	t.Helper()
+	var names []string
+	if err := h.db.Model(&model.User{}).Where("disabled = ?", true).Order("username").Pluck("username", &names).Error; err != nil {
+		t.Fatalf("查询用户失败: %v", err)
+	}
+	return names
+}
+
+func TestLDAPSyncCreatesAndUpdatesUsers(t *testing.T) {
+	h := newLDAPHarness(t, 0.5)
+	h.addEntry("alice", "cn=admins,ou=groups,dc=example,dc=com")
+	h.addEntry("bob")
+
+	result := h.sync(t)
+	if result.Created != 2 || result.Updated != 0 || result.Disabled != 0 {
+		t.Fatalf("首次同步结果不正确: %+v", result)
+	}
+	var alice model.User
+	h.db.Where("username = ?", "alice").First(&alice)
+	if alice.Role != "admin" || alice.AuthSource != authSourceLDAP || alice.Email != "alice@example.com" {
+		t.Errorf("属性映射不正确: %+v", alice)
+	}
+
+	result = h.sync(t)
+	if result.Created != 0 || result.Updated != 2 {
+		t.Errorf("再次同步应更新已有用户: %+v", result)
+	}
+}
+
+func TestLDAPSyncDisablesRemovedUsers(t *testing.T) {
+	h := newLDAPHarness(t, 0.5)
+	for _, uid := range []string{"alice", "bob", "carol", "dave"} {
+		h.addEntry(uid)
+	}
+	h.sync(t)
+
+	var bob model.User
+	h.db.Where("username = ?", "bob").First(&bob)
+	session := model.Session{UserID: bob.ID, RefreshTokenHash: "bob-session", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := h.db.Create(&session).Error; err != nil {
+		t.Fatalf("创建会话失败: %v", err)
+	}
+
+	all := h.conn.entries
+	h.conn.entries = []*ldap.Entry{all[0], all[2], all[3]}
+	result := h.sync(t)
+	if result.Disabled != 1 || len(result.DisableSkipped) != 0 {
+		t.Fatalf("应禁用 1 个用户: %+v", result)
+	}
+	if got := h.disabled(t); len(got) != 1 || got[0] != "bob" {
+		t.Errorf("已禁用的用户不正确: %v", got)
+	}
+	var active int64
+	h.db.Model(&model.Session{}).Where("user_id = ? AND revoked_at IS NULL", bob.ID).Count(&active)
+	if active != 0 {
+		t.Errorf("被禁用用户的会话应被注销，仍有 %d 个", active)
+	}
+}
+
+func TestLDAPSyncSkipsDisableOnEmptyResult(t *testing.T) {
+	h := newLDAPHarness(t, 1)
+	h.addEntry("alice")
+	h.addEntry("bob")
+	h.sync(t)
+
+	// 过滤器或权限配置错误时目录可能返回空结果，即使比例允许全部禁用也不能执行
+	h.conn.entries = nil
+	result := h.sync(t)
+	if result.Disabled != 0 || len(result.DisableSkipped) != 2 {
+		t.Fatalf("空结果时应跳过禁用: %+v", result)
+	}
+	if got := h.disabled(t); len(got) != 0 {
+		t.Errorf("不应禁用任何用户: %v", got)
+	}
+}
+
+func TestLDAPSyncSkipsDisableOverRatio(t *testing.T) {
+	h := newLDAPHarness(t, 0.2)
+	for i := 0; i < 10; i++ {
+		h.addEntry(fmt.Sprintf("user%02d", i))
+	}
+	h.sync(t)
+
+	// 10 个用户按 0.2 最多禁用 2 个，移除 3 个时全部跳过
+	all := h.conn.entries
+	h.conn.entries = all[:7]
+	result := h.sync(t)
+	if result.Disabled != 0 || len(result.DisableSkipped) != 3 {
+		t.Fatalf("超过比例时应跳过禁用: %+v", result)
+	}
+	if got := h.disabled(t); len(got) != 0 {
+		t.Errorf("不应禁用任何用户: %v", got)
+	}
+
+	// 移除 2 个时在上限之内
+	h.conn.entries = all[:8]
+	result = h.sync(t)
+	if result.Disabled != 2 || len(result.DisableSkipped) != 0 {
+		t.Fatalf("未超过比例时应禁用: %+v", result)
+	}
+	if got := h.disabled(t); strings.Join(got, ",") != "user08,user09" {
+		t.Errorf("已禁用的用户不正确: %v", got)
+	}
+}
+
+func TestLDAPLoginHidesDirectoryErrors(t *testing.T) {
+	h := newLDAPHarness(t, 0.2)
+	h.addEntry("alice")
+	auth := &AuthController{DB: h.db, Sessions: h.provider.Sessions, LDAP: h.provider}
+
+	u, code, _ := auth.checkCredentials("alice", "", "alice-pass")
+	if u == nil || code != response.CodeSuccess || u.Username != "alice" {
+		t.Fatalf("登录失败: %v, %d", u, code)
+	}
+
+	u, code, msg := auth.checkCredentials("alice", "", "wrong")
+	if u != nil || code != response.ErrInvalidCredentials || msg != "用户名或密码错误" {
+		t.Errorf("密码错误时结果不正确: %v, %d, %q", u, code, msg)
+	}
+
+	h.dialErr = errors.New("连接 LDAP 服务器失败: dial tcp 10.0.0.5:389: connection refused")
+	u, code, msg = auth.checkCredentials("alice", "", "alice-pass")
+	if u != nil || code != response.ErrInvalidCredentials {
+		t.Fatalf("目录不可用时应登录失败: %v, %d", u, code)
+	}
+	if msg != "目录认证失败" {
+		t.Errorf("不应向客户端返回目录错误详情: %q", msg)
+	}
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/oidc"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// OIDC 登录流程的临时状态保存在签名 Cookie 中，无需服务端存储
+const (
+	oidcStateCookie   = "hc_oidc_state"
+	oidcStateTTL      = 10 * time.Minute
+	oidcStateAudience = "oidc-state"
+)
+
+// oidcStateClaims 授权请求的 state、nonce 和 PKCE verifier
+type oidcStateClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// OIDCController OpenID Connect 单点登录控制器
+type OIDCController struct {
+	DB       *gorm.DB
+	Auth     *AuthController
+	Config   config.OIDCConfig
+	Provider *oidc.Provider
+}
+
+// NewOIDCController 创建单点登录控制器，未启用时 Provider 为 nil
+func NewOIDCController(db *gorm.DB, auth *AuthController, cfg *config.Config) *OIDCController {
+	c := &OIDCController{DB: db, Auth: auth, Config: cfg.Auth.OIDC}
+	if cfg.Auth.OIDC.Enabled {
+		c.Provider = oidc.NewProvider(cfg.Auth.OIDC, nil)
+	}
+	return c
+}
+
+// Login 跳转到身份提供方登录页
+func (c *OIDCController) Login(ctx *gin.Context) {
+	if c.Provider == nil {
+		response.Error(ctx, response.ErrNotFound, "未启用单点登录")
+		return
+	}
+
+	redirect := ctx.Query("redirect")
+	if redirect != "" && !isLocalRedirect(redirect) {
+		response.Error(ctx, response.ErrInvalidRequest, "无效的跳转地址")
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := oidc.RandomString(32)
+		if err != nil {
+			logger.Request(ctx).Error("生成登录状态失败: %v", err)
+			response.Error(ctx, response.ErrInternalServer, "生成登录状态失败")
+			return
+		}
+		values[i] = v
+	}
+	state := oidcStateClaims{
+		State:    values[0],
+		Nonce:    values[1],
+		Verifier: values[2],
+		Redirect: redirect,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oidcStateAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcStateTTL)),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, state).SignedString([]byte(c.Auth.Sessions.Secret))
+	if err != nil {
+		logger.Request(ctx).Error("生成登录状态失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "生成登录状态失败")
+		return
+	}
+
+	authURL, err := c.Provider.AuthCodeURL(ctx.Request.Context(), state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		logger.Request(ctx).Error("连接身份提供方失败: %v", err)
+		response.Error(ctx, response.ErrBadGateway, "连接身份提供方失败")
+		return
+	}
+
+	c.setStateCookie(ctx, signed, int(oidcStateTTL.Seconds()))
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 身份提供方回调：校验 state，换取并校验 ID Token，然后登录或创建本地用户
+func (c *OIDCController) Callback(ctx *gin.Context) {
+	if c.Provider == nil {
+		response.Error(ctx, response.ErrNotFound, "未启用单点登录")
+		return
+	}
+	if errCode := ctx.Query("error"); errCode != "" {
+		response.ErrorWithData(ctx, response.ErrUnauthorized, gin.H{"description": ctx.Query("error_description")}, "身份提供方拒绝登录: "+errCode)
+		return
+	}
+
+	cookie, err := ctx.Cookie(oidcStateCookie)
+	if err != nil {
+		response.Error(ctx, response.ErrInvalidRequest, "登录状态已过期，请重新登录")
+		return
+	}
+	// state 只能使用一次
+	c.setStateCookie(ctx, "", -1)
+
+	state := &oidcStateClaims{}
+	token, err := jwt.ParseWithClaims(cookie, state, func(t *jwt.Token) (interface{}, error) {
+		return []byte(c.Auth.Sessions.Secret), nil
+	}, jwt.WithAudience(oidcStateAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid || state.State == "" || ctx.Query("state") != state.State {
+		response.Error(ctx, response.ErrInvalidRequest, "登录状态无效，请重新登录")
+		return
+	}
+	code := ctx.Query("code")
+	if code == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "缺少授权码")
+		return
+	}
+
+	tokens, err := c.Provider.Exchange(ctx.Request.Context(), code, state.Verifier)
+	if err != nil {
+		// 令牌端点的响应可能包含身份提供方的内部信息，只写入日志
+		logger.Request(ctx).Error("换取令牌失败: %v", err)
+		response.Error(ctx, response.ErrBadGateway, "换取令牌失败")
+		return
+	}
+	claims, err := c.Provider.VerifyIDToken(ctx.Request.Context(), tokens.IDToken, state.Nonce)
+	if err != nil {
+		response.Error(ctx, response.ErrUnauthorized, err.Error())
+		return
+	}
+	// ID Token 中没有邮箱时从 userinfo 补充
+	if claimString(claims, c.Config.EmailClaim) == "" && tokens.AccessToken != "" {
+		if info, err := c.Provider.UserInfo(ctx.Request.Context(), tokens.AccessToken); err == nil && info["sub"] == claims["sub"] {
+			for k, v := range info {
+				if _, ok := claims[k]; !ok {
+					claims[k] = v
+				}
+			}
+		}
+	}
+
+	user, reason, err := c.resolveUser(claims)
+	if err != nil {
+		if reason == response.ErrInternalServer {
+			logger.Request(ctx).Error("单点登录失败: %v", err)
+		}
+		response.Error(ctx, reason, err.Error())
+		return
+	}
+
+	result, err := c.Auth.loginResult(ctx, user)
+	if err != nil {
+		respondLoginError(ctx, err)
+		return
+	}
+
+	target := state.Redirect
+	if target == "" {
+		target = c.Config.SuccessRedirect
+	}
+	if target == "" {
+		response.Success(ctx, result)
+		return
+	}
+	// 令牌放在 URL 片段中，不会出现在服务器日志和 Referer 里
+	fragment := url.Values{}
+	for k, v := range result {
+		if _, nested := v.(gin.H); !nested {
+			fragment.Set(k, fmt.Sprint(v))
+		}
+	}
+	ctx.Redirect(http.StatusFound, target+"#"+fragment.Encode())
+}
+
+// resolveUser 按 sub 查找已关联的用户，其次按邮箱关联已有账户，最后自动创建
+func (c *OIDCController) resolveUser(claims map[string]interface{}) (*model.User, response.ErrorCode, error) {
+	sub := claimString(claims, "sub")
+	if sub == "" {
+		return nil, response.ErrUnauthorized, errors.New("ID Token 缺少 sub")
+	}
+	subject := c.Config.Issuer + "|" + sub
+	email := strings.ToLower(claimString(claims, c.Config.EmailClaim))
+	role, syncRole := c.mapRole(claims)
+
+	var user model.User
+	err := c.DB.Where("oidc_subject = ?", subject).First(&user).Error
+	switch {
+	case err == nil:
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		logger.Error("查询单点登录用户失败: %v", err)
+		return nil, response.ErrInternalServer, errors.New("查询用户失败")
+	case email != "" && c.Config.LinkByEmail && c.DB.Where("LOWER(email) = ?", email).First(&user).Error == nil:
+		if c.Config.RequireVerifiedEmail && !claimBool(claims, "email_verified") {
+			return nil, response.ErrForbidden, errors.New("邮箱未经身份提供方验证，无法关联已有账户")
+		}
+		if user.OIDCSubject != "" {
+			return nil, response.ErrConflict, errors.New("该邮箱的账户已关联其他单点登录身份")
+		}
+		if err := c.DB.Model(&user).Update("oidc_subject", subject).Error; err != nil {
+			logger.Error("关联单点登录账户失败: %v", err)
+			return nil, response.ErrInternalServer, errors.New("关联账户失败")
+		}
+	case !c.Config.AutoCreate:
+		return nil, response.ErrForbidden, errors.New("账户不存在，请联系管理员开通")
+	default:
+		created, err := c.createUser(claims, subject, email, role)
+		if err != nil {
+			return nil, response.ErrInternalServer, err
+		}
+		return created, response.CodeSuccess, nil
+	}
+
+	// 配置了角色声明时以身份提供方为准，每次登录同步。按邮箱关联的已有账户（如本地管理员）默认不同步，
+	// 避免身份提供方中同邮箱的账户改变其角色，需要时通过 sync_linked_roles 开启
+	if syncRole && (user.AuthSource == authSourceOIDC || c.Config.SyncLinkedRoles) && user.Role != role {
+		if err := c.DB.Model(&user).Update("role", role).Error; err != nil {
+			logger.Error("同步单点登录角色失败: %v", err)
+			return nil, response.ErrInternalServer, errors.New("同步角色失败")
+		}
+	}
+	return &user, response.CodeSuccess, nil
+}
+
+// createUser 首次登录时创建本地用户，密码为不可用的随机值
+func (c *OIDCController) createUser(claims map[string]interface{}, subject, email, role string) (*model.User, error) {
+	if email == "" {
+		return nil, errors.New("身份提供方未返回邮箱，无法创建账户")
+	}
+	var cnt int64
+	c.DB.Model(&model.User{}).Where("LOWER(email) = ?", email).Count(&cnt)
+	if cnt > 0 {
+		return nil, errors.New("邮箱已被其他账户使用")
+	}
+
+	base := claimString(claims, c.Config.UsernameClaim)
+	if base == "" {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	username := base
+	for i := 2; ; i++ {
+		c.DB.Model(&model.User{}).Where("username = ?", username).Count(&cnt)
+		if cnt == 0 {
+			break
+		}
+		if i > 100 {
+			return nil, errors.New("无法生成唯一的用户名")
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, errors.New("创建用户失败")
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.New("创建用户失败")
+	}
+
+	u := &model.User{
+		Username:    username,
+		Password:    string(hashed),
+		Email:       email,
+		Role:        role,
+		AuthSource:  authSourceOIDC,
+		OIDCSubject: subject,
+	}
+	if err := c.DB.Create(u).Error; err != nil {
+		return nil, errors.New("创建用户失败")
+	}
+	return u, nil
+}
+
+// mapRole 根据角色声明映射本地角色；未配置角色声明时返回默认角色且不同步
+func (c *OIDCController) mapRole(claims map[string]interface{}) (string, bool) {
+	role := c.Config.DefaultRole
+	if role == "" {
+		role = "user"
+	}
+	if c.Config.RoleClaim == "" {
+		return role, false
+	}
+	values := claimStrings(claims, c.Config.RoleClaim)
+	for _, m := range c.Config.RoleMappings {
+		for _, v := range values {
+			if v == m.Value {
+				return m.Role, true
+			}
+		}
+	}
+	return role, true
+}
+
+// setStateCookie 写入或清除登录状态 Cookie
+func (c *OIDCController) setStateCookie(ctx *gin.Context, value string, maxAge int) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, value, maxAge, "/api/auth/oidc", "", ctx.Request.TLS != nil, true)
+}
+
+// isLocalRedirect 只允许站内相对路径，防止开放重定向
+func isLocalRedirect(target string) bool {
+	return strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") && !strings.Contains(target, "\\")
+}
+
+// claimString 读取字符串声明
+func claimString(claims map[string]interface{}, name string) string {
+	s, _ := claims[name].(string)
+	return strings.TrimSpace(s)
+}
+
+// claimBool 读取布尔声明，兼容字符串形式
+func claimBool(claims map[string]interface{}, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// claimStrings 读取字符串或字符串数组声明
+func claimStrings(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
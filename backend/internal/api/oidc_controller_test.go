@@ -0,0 +1,373 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/oidc"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"gorm.io/gorm"
+)
+
+const (
+	testClientID    = "drive"
+	testRedirectURL = "http://drive.test/api/auth/oidc/callback"
+)
+
+// fakeIdP 测试用的身份提供方：发现文档、授权端点、带 PKCE 校验的令牌端点和 JWKS
+type fakeIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims jwt.MapClaims        // 下一次授权签发的 ID Token 声明，未设置 nonce 时使用授权请求中的 nonce
+	grants map[string]fakeGrant // 授权码 → 授权请求
+	issuer string               // 发现文档中的 issuer，为空时使用服务地址
+}
+
+type fakeGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key, grants: map[string]fakeGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := idp.issuer
+	if issuer == "" {
+		issuer = idp.URL
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": idp.URL + "/authorize",
+		"token_endpoint":         idp.URL + "/token",
+		"jwks_uri":               idp.URL + "/jwks",
+	})
+}
+
+// authorize 直接同意授权，带授权码跳回客户端
+func (idp *fakeIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != testClientID || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+	idp.mu.Lock()
+	claims := jwt.MapClaims{}
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = q.Get("nonce")
+	}
+	code := fmt.Sprintf("code-%d", len(idp.grants)+1)
+	idp.grants[code] = fakeGrant{challenge: q.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+
+	back := url.Values{"code": {code}, "state": {q.Get("state")}}
+	http.Redirect(w, r, q.Get("redirect_uri")+"?"+back.Encode(), http.StatusFound)
+}
+
+// token 校验授权码和 PKCE verifier 后签发 ID Token，授权码只能使用一次
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	idp.mu.Lock()
+	grant, ok := idp.grants[r.PostForm.Get("code")]
+	delete(idp.grants, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+	if !ok || r.PostForm.Get("redirect_uri") != testRedirectURL ||
+		oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"internal trace id 42 at db-7"}`))
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{"iss": idp.URL, "aud": testClientID, "iat": now.Unix(), "exp": now.Add(time.Minute).Unix()}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": signed})
+}
+
+func (idp *fakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	enc := base64.RawURLEncoding
+	json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"use": "sig",
+		"n":   enc.EncodeToString(idp.key.N.Bytes()),
+		"e":   enc.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+	}}})
+}
+
+// oidcHarness 接入假身份提供方的单点登录控制器
+type oidcHarness struct {
+	db     *gorm.DB
+	idp    *fakeIdP
+	router *gin.Engine
+}
+
+func newOIDCHarness(t *testing.T, configure func(*config.OIDCConfig)) *oidcHarness {
+	t.Helper()
+	idp := newFakeIdP(t)
+	db := newTestDB(t)
+
+	cfg := &config.Config{}
+	cfg.Auth.OIDC = config.OIDCConfig{
+		Enabled:              true,
+		Issuer:               idp.URL,
+		ClientID:             testClientID,
+		ClientSecret:         "secret",
+		RedirectURL:          testRedirectURL,
+		Scopes:               []string{"openid", "email"},
+		UsernameClaim:        "preferred_username",
+		EmailClaim:           "email",
+		RoleClaim:            "groups",
+		RoleMappings:         []config.RoleMapping{{Value: "drive-admins", Role: "admin"}},
+		DefaultRole:          "user",
+		AutoCreate:           true,
+		LinkByEmail:          true,
+		RequireVerifiedEmail: true,
+	}
+	if configure != nil {
+		configure(&cfg.Auth.OIDC)
+	}
+	auth := &AuthController{DB: db, Sessions: newTestSessions(db)}
+	c := NewOIDCController(db, auth, cfg)
+	c.Provider.HTTPClient = idp.Client()
+
+	r := gin.New()
+	r.GET("/api/auth/oidc/login", c.Login)
+	r.GET("/api/auth/oidc/callback", c.Callback)
+	return &oidcHarness{db: db, idp: idp, router: r}
+}
+
+// login 走完整的授权码流程，tamper 可在回调前修改回调参数，返回回调的响应
+func (h *oidcHarness) login(t *testing.T, claims jwt.MapClaims, tamper func(url.Values)) *httptest.ResponseRecorder {
+	t.Helper()
+	h.idp.mu.Lock()
+	h.idp.claims = claims
+	h.idp.mu.Unlock()
+
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("登录应跳转到身份提供方，实际 %d: %s", rec.Code, rec.Body)
+	}
+	cookies := rec.Result().Cookies()
+
+	client := h.idp.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("身份提供方未跳回: %d %v", resp.StatusCode, err)
+	}
+	query := back.Query()
+	if tamper != nil {
+		tamper(query)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?"+query.Encode(), nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func idpClaims(sub, email string, groups ...string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":                sub,
+		"email":              email,
+		"email_verified":     true,
+		"preferred_username": strings.SplitN(email, "@", 2)[0],
+		"groups":             groups,
+	}
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	h := newOIDCHarness(t, nil)
+
+	rec := h.login(t, idpClaims("alice-sub", "alice@example.com", "drive-admins"), nil)
+	resp := decodeResponse(t, rec.Body.Bytes())
+	if rec.Code != http.StatusOK || !resp.Success {
+		t.Fatalf("登录失败: %d %s", rec.Code, rec.Body)
+	}
+	if data, _ := resp.Data.(map[string]any); data["token"] == "" || data["token"] == nil {
+		t.Fatalf("响应中没有令牌: %s", rec.Body)
+	}
+
+	var u model.User
+	if err := h.db.Where("email = ?", "alice@example.com").First(&u).Error; err != nil {
+		t.Fatalf("未创建用户: %v", err)
+	}
+	if u.AuthSource != authSourceOIDC || u.OIDCSubject != h.idp.URL+"|alice-sub" || u.Role != "admin" {
+		t.Fatalf("创建的用户不正确: source=%s subject=%s role=%s", u.AuthSource, u.OIDCSubject, u.Role)
+	}
+
+	// 身份提供方移除管理员组后，单点登录创建的账户随之降级
+	if rec := h.login(t, idpClaims("alice-sub", "alice@example.com"), nil); rec.Code != http.StatusOK {
+		t.Fatalf("再次登录失败: %d %s", rec.Code, rec.Body)
+	}
+	h.db.First(&u, u.ID)
+	if u.Role != "user" {
+		t.Fatalf("角色未同步: %s", u.Role)
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	h := newOIDCHarness(t, nil)
+	h.idp.issuer = "https://other.example.com"
+
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if resp := decodeResponse(t, rec.Body.Bytes()); resp.Code != response.ErrBadGateway {
+		t.Fatalf("issuer 不匹配时应拒绝，实际 %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	h := newOIDCHarness(t, nil)
+
+	rec := h.login(t, idpClaims("bob-sub", "bob@example.com"), func(q url.Values) {
+		q.Set("state", "forged")
+	})
+	if resp := decodeResponse(t, rec.Body.Bytes()); resp.Code != response.ErrInvalidRequest {
+		t.Fatalf("state 不匹配时应拒绝，实际 %d: %s", rec.Code, rec.Body)
+	}
+	var count int64
+	h.db.Model(&model.User{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("不应创建用户")
+	}
+}
+
+func TestOIDCCallbackRejectsNonceMismatch(t *testing.T) {
+	h := newOIDCHarness(t, nil)
+
+	claims := idpClaims("bob-sub", "bob@example.com")
+	claims["nonce"] = "replayed-nonce"
+	rec := h.login(t, claims, nil)
+	if resp := decodeResponse(t, rec.Body.Bytes()); resp.Code != response.ErrUnauthorized {
+		t.Fatalf("nonce 不匹配时应拒绝，实际 %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOIDCCallbackRejectsWrongVerifier(t *testing.T) {
+	h := newOIDCHarness(t, nil)
+
+	// 授权码被换成另一次授权的授权码时，PKCE verifier 与 code_challenge 不匹配
+	h.idp.mu.Lock()
+	h.idp.grants["stolen"] = fakeGrant{challenge: oidc.CodeChallenge("attacker-verifier"), claims: idpClaims("bob-sub", "bob@example.com")}
+	h.idp.mu.Unlock()
+	rec := h.login(t, idpClaims("bob-sub", "bob@example.com"), func(q url.Values) {
+		q.Set("code", "stolen")
+	})
+	resp := decodeResponse(t, rec.Body.Bytes())
+	if resp.Code != response.ErrBadGateway {
+		t.Fatalf("PKCE 校验失败时应拒绝，实际 %d: %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "internal trace") {
+		t.Fatalf("响应不应包含令牌端点返回的内容: %s", rec.Body)
+	}
+}
+
+func TestOIDCLinksExistingAccount(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		sync     bool
+		wantRole string
+	}{
+		{"keeps local role", false, "admin"},
+		{"sync_linked_roles", true, "user"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newOIDCHarness(t, func(c *config.OIDCConfig) { c.SyncLinkedRoles = tc.sync })
+			local := model.User{Username: "carol", Password: "x", Email: "carol@example.com", Role: "admin", AuthSource: authSourceLocal}
+			if err := h.db.Create(&local).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			rec := h.login(t, idpClaims("carol-sub", "Carol@example.com"), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("关联登录失败: %d %s", rec.Code, rec.Body)
+			}
+			var u model.User
+			h.db.First(&u, local.ID)
+			if u.OIDCSubject != h.idp.URL+"|carol-sub" {
+				t.Fatalf("未关联单点登录身份: %q", u.OIDCSubject)
+			}
+			if u.Role != tc.wantRole {
+				t.Fatalf("角色为 %s，应为 %s", u.Role, tc.wantRole)
+			}
+			var count int64
+			h.db.Model(&model.User{}).Count(&count)
+			if count != 1 {
+				t.Fatalf("不应创建新用户，现有 %d 个", count)
+			}
+		})
+	}
+}
+
+func TestOIDCLinkRequiresVerifiedEmail(t *testing.T) {
+	h := newOIDCHarness(t, nil)
+	if err := h.db.Create(&model.User{Username: "dave", Password: "x", Email: "dave@example.com", Role: "user"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	claims := idpClaims("dave-sub", "dave@example.com")
+	claims["email_verified"] = false
+	rec := h.login(t, claims, nil)
+	if resp := decodeResponse(t, rec.Body.Bytes()); resp.Code != response.ErrForbidden {
+		t.Fatalf("邮箱未验证时不应关联，实际 %d: %s", rec.Code, rec.Body)
+	}
+	var u model.User
+	h.db.Where("username = ?", "dave").First(&u)
+	if u.OIDCSubject != "" {
+		t.Fatalf("不应关联单点登录身份")
+	}
+}
@@ -1,14 +1,17 @@
 package api
 
 import (
-	"net/http"
+	"context"
 	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/logger"
 	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
 	"github.com/huanhq99/H-Cloud/internal/storage"
 	"gorm.io/gorm"
 )
@@ -28,20 +31,21 @@ func (c *RecycleController) ListRecycleBin(ctx *gin.Context) {
 	// 获取用户ID
 	userIDStr := ctx.Query("userId")
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "缺少用户ID")
 		return
 	}
 
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 
 	// 查询回收站中的文件
 	var recycleBinItems []model.RecycleBin
 	if err := c.DB.Where("user_id = ?", userID).Order("deleted_at DESC").Find(&recycleBinItems).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站列表失败: " + err.Error()})
+		logger.Request(ctx).Error("获取回收站列表失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "获取回收站列表失败")
 		return
 	}
 
@@ -60,7 +64,7 @@ func (c *RecycleController) ListRecycleBin(ctx *gin.Context) {
 		})
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"items": items})
+	response.Success(ctx, gin.H{"items": items})
 }
 
 // RestoreFromRecycleBin 从回收站恢复文件
@@ -68,29 +72,30 @@ func (c *RecycleController) RestoreFromRecycleBin(ctx *gin.Context) {
 	// 获取回收站项目ID
 	itemID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的项目ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的项目ID")
 		return
 	}
 
 	// 获取用户ID
 	userIDStr := ctx.Query("userId")
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "缺少用户ID")
 		return
 	}
 
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 
 	// 查询回收站项目
 	var recycleBinItem model.RecycleBin
 	if err := c.DB.Where("id = ? AND user_id = ?", itemID, userID).First(&recycleBinItem).Error; err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "回收站项目不存在"})
+		response.Error(ctx, response.ErrNotFound, "回收站项目不存在")
 		return
 	}
+	audit.SetTarget(ctx, recycleBinItem.ItemType, recycleBinItem.ID, recycleBinItem.OriginalPath)
 
 	// 检查原始位置是否已存在同名文件
 	originalFullPath := filepath.Join(storage.StoragePath, recycleBinItem.OriginalPath)
@@ -115,7 +120,8 @@ func (c *RecycleController) RestoreFromRecycleBin(ctx *gin.Context) {
 	// 移动文件从回收站存储位置到原始位置
 	recycleStoragePath := filepath.Join(storage.StoragePath, ".recycle", recycleBinItem.StoragePath)
 	if err := os.Rename(recycleStoragePath, originalFullPath); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "恢复文件失败: " + err.Error()})
+		logger.Request(ctx).Error("恢复文件失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "恢复文件失败")
 		return
 	}
 
@@ -131,18 +137,20 @@ func (c *RecycleController) RestoreFromRecycleBin(ctx *gin.Context) {
 		if err := c.DB.Create(&fileModel).Error; err != nil {
 			// 如果数据库恢复失败，回滚文件移动
 			os.Rename(originalFullPath, recycleStoragePath)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "恢复文件记录失败: " + err.Error()})
+			logger.Request(ctx).Error("恢复文件记录失败: %v", err)
+			response.Error(ctx, response.ErrInternalServer, "恢复文件记录失败")
 			return
 		}
 	}
 
 	// 从回收站中删除记录
 	if err := c.DB.Delete(&recycleBinItem).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除回收站记录失败: " + err.Error()})
+		logger.Request(ctx).Error("删除回收站记录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "删除回收站记录失败")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "文件恢复成功"})
+	response.Success(ctx, gin.H{"message": "文件恢复成功"})
 }
 
 // PermanentDelete 永久删除回收站中的文件
@@ -150,44 +158,47 @@ func (c *RecycleController) PermanentDelete(ctx *gin.Context) {
 	// 获取回收站项目ID
 	itemID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的项目ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的项目ID")
 		return
 	}
 
 	// 获取用户ID
 	userIDStr := ctx.Query("userId")
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "缺少用户ID")
 		return
 	}
 
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 
 	// 查询回收站项目
 	var recycleBinItem model.RecycleBin
 	if err := c.DB.Where("id = ? AND user_id = ?", itemID, userID).First(&recycleBinItem).Error; err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "回收站项目不存在"})
+		response.Error(ctx, response.ErrNotFound, "回收站项目不存在")
 		return
 	}
+	audit.SetTarget(ctx, recycleBinItem.ItemType, recycleBinItem.ID, recycleBinItem.OriginalPath)
 
 	// 删除物理文件
 	recycleStoragePath := filepath.Join(storage.StoragePath, ".recycle", recycleBinItem.StoragePath)
 	if err := os.RemoveAll(recycleStoragePath); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除物理文件失败: " + err.Error()})
+		logger.Request(ctx).Error("删除物理文件失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "删除物理文件失败")
 		return
 	}
 
 	// 从回收站中删除记录
 	if err := c.DB.Delete(&recycleBinItem).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除回收站记录失败: " + err.Error()})
+		logger.Request(ctx).Error("删除回收站记录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "删除回收站记录失败")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "文件已永久删除"})
+	response.Success(ctx, gin.H{"message": "文件已永久删除"})
 }
 
 // EmptyRecycleBin 清空回收站
@@ -195,20 +206,21 @@ func (c *RecycleController) EmptyRecycleBin(ctx *gin.Context) {
 	// 获取用户ID
 	userIDStr := ctx.Query("userId")
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "缺少用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "缺少用户ID")
 		return
 	}
 
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(ctx, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 
 	// 查询用户的所有回收站项目
 	var recycleBinItems []model.RecycleBin
 	if err := c.DB.Where("user_id = ?", userID).Find(&recycleBinItems).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站列表失败: " + err.Error()})
+		logger.Request(ctx).Error("获取回收站列表失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "获取回收站列表失败")
 		return
 	}
 
@@ -220,27 +232,32 @@ func (c *RecycleController) EmptyRecycleBin(ctx *gin.Context) {
 
 	// 删除所有回收站记录
 	if err := c.DB.Where("user_id = ?", userID).Delete(&model.RecycleBin{}).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "清空回收站记录失败: " + err.Error()})
+		logger.Request(ctx).Error("清空回收站记录失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "清空回收站记录失败")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "回收站已清空"})
+	response.Success(ctx, gin.H{"message": "回收站已清空"})
 }
 
-// CleanExpiredItems 清理过期的回收站项目（定时任务调用）
-func (c *RecycleController) CleanExpiredItems() error {
+// CleanExpiredItems 清理过期的回收站项目（定时任务调用）。
+// 逐项删除物理文件和记录，中途停止时已清理的项目不会重复处理
+func (c *RecycleController) CleanExpiredItems(ctx context.Context) error {
 	// 查询所有过期的项目
 	var expiredItems []model.RecycleBin
-	if err := c.DB.Where("expire_at < ?", time.Now()).Find(&expiredItems).Error; err != nil {
+	if err := c.DB.WithContext(ctx).Where("expire_at < ?", time.Now()).Find(&expiredItems).Error; err != nil {
 		return err
 	}
 
-	// 删除过期的物理文件
 	for _, item := range expiredItems {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		recycleStoragePath := filepath.Join(storage.StoragePath, ".recycle", item.StoragePath)
 		os.RemoveAll(recycleStoragePath) // 忽略错误
+		if err := c.DB.Delete(&model.RecycleBin{}, item.ID).Error; err != nil {
+			return err
+		}
 	}
-
-	// 删除过期的数据库记录
-	return c.DB.Where("expire_at < ?", time.Now()).Delete(&model.RecycleBin{}).Error
-}
\ No newline at end of file
+	return nil
+}
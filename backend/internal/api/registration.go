@@ -0,0 +1,206 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/rbac"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"gorm.io/gorm"
+)
+
+var (
+	errRegistrationClosed = errors.New("系统已关闭注册")
+	errInviteRequired     = errors.New("需要邀请码才能注册")
+	errInviteInvalid      = errors.New("邀请码无效、已用完或已过期")
+	errEmailDomain        = errors.New("该邮箱域名不允许注册")
+	errPendingApproval    = errors.New("账户正在等待管理员审核")
+)
+
+// registrationMode 当前注册模式，未知的配置值按关闭注册处理
+func (a *AuthController) registrationMode() string {
+	switch mode := strings.ToLower(a.Registration.Mode); mode {
+	case config.RegistrationOpen, config.RegistrationInvite, config.RegistrationApproval:
+		return mode
+	case "":
+		return config.RegistrationOpen
+	default:
+		return config.RegistrationDisabled
+	}
+}
+
+// emailDomainAllowed 检查邮箱域名是否在允许注册的列表中，列表为空时不限制
+func (a *AuthController) emailDomainAllowed(email string) bool {
+	if len(a.Registration.AllowedDomains) == 0 {
+		return true
+	}
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[i+1:])
+	for _, d := range a.Registration.AllowedDomains {
+		if strings.ToLower(strings.TrimSpace(d)) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// redeemInvite 在事务中使用一次邀请码；条件更新保证并发注册不会超过使用次数
+func redeemInvite(tx *gorm.DB, code string) (*model.InviteCode, error) {
+	var invite model.InviteCode
+	if err := tx.Where("code = ?", code).First(&invite).Error; err != nil {
+		return nil, errInviteInvalid
+	}
+	result := tx.Model(&model.InviteCode{}).
+		Where("id = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at IS NULL OR expires_at > ?)", invite.ID, time.Now()).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInviteInvalid
+	}
+	return &invite, nil
+}
+
+// RegistrationPolicy 返回注册策略，供注册页面决定是否显示邀请码输入框等
+func (a *AuthController) RegistrationPolicy(ctx *gin.Context) {
+	domains := a.Registration.AllowedDomains
+	if domains == nil {
+		domains = []string{}
+	}
+	response.Success(ctx, gin.H{
+		"mode":                      a.registrationMode(),
+		"allowedDomains":            domains,
+		"emailVerificationRequired": a.Registration.RequireEmailVerification,
+	})
+}
+
+// inviteJSON 管理端的邀请码信息
+func inviteJSON(inv *model.InviteCode) gin.H {
+	var expiresAt interface{}
+	if inv.ExpiresAt != nil {
+		expiresAt = inv.ExpiresAt.Format(time.RFC3339)
+	}
+	role := inv.Role
+	if role == "" {
+		role = rbac.RoleUser
+	}
+	return gin.H{
+		"id":           inv.ID,
+		"code":         inv.Code,
+		"note":         inv.Note,
+		"role":         role,
+		"storageQuota": inv.StorageQuota,
+		"maxUses":      inv.MaxUses,
+		"uses":         inv.Uses,
+		"expiresAt":    expiresAt,
+		"expired":      inv.ExpiresAt != nil && inv.ExpiresAt.Before(time.Now()),
+		"exhausted":    inv.MaxUses > 0 && inv.Uses >= inv.MaxUses,
+		"createdBy":    inv.CreatedBy,
+		"createdAt":    inv.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// ListInvites 列出邀请码
+func (ac *AdminController) ListInvites(c *gin.Context) {
+	var invites []model.InviteCode
+	if err := ac.DB.Order("id DESC").Find(&invites).Error; err != nil {
+		logger.Request(c).Error("获取邀请码失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "获取邀请码失败")
+		return
+	}
+	items := make([]gin.H, 0, len(invites))
+	for i := range invites {
+		items = append(items, inviteJSON(&invites[i]))
+	}
+	response.Success(c, gin.H{"invites": items})
+}
+
+// CreateInvite 生成邀请码，可限制使用次数和有效期，并预设注册用户的角色和存储配额
+func (ac *AdminController) CreateInvite(c *gin.Context) {
+	var req struct {
+		Note         string `json:"note"`
+		Role         string `json:"role"`
+		StorageQuota *int64 `json:"storageQuota"`
+		MaxUses      *int   `json:"maxUses"`   // 默认 1，0 表示不限
+		ExpiresIn    int    `json:"expiresIn"` // 有效期（小时），0 表示永不过期
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, response.ErrInvalidRequest, "请求参数错误")
+		return
+	}
+	if req.Role != "" && !ac.roleExists(req.Role) {
+		response.Error(c, response.ErrInvalidRequest, "角色不存在")
+		return
+	}
+	if req.StorageQuota != nil && *req.StorageQuota < 0 {
+		response.Error(c, response.ErrInvalidRequest, "无效的存储配额")
+		return
+	}
+	maxUses := 1
+	if req.MaxUses != nil {
+		maxUses = *req.MaxUses
+	}
+	if maxUses < 0 || req.ExpiresIn < 0 {
+		response.Error(c, response.ErrInvalidRequest, "无效的使用次数或有效期")
+		return
+	}
+
+	code, err := rbac.RandomPassword(12)
+	if err != nil {
+		logger.Request(c).Error("生成邀请码失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "生成邀请码失败")
+		return
+	}
+	invite := model.InviteCode{
+		Code:         code,
+		Note:         strings.TrimSpace(req.Note),
+		CreatedBy:    c.GetUint("userID"),
+		Role:         req.Role,
+		StorageQuota: req.StorageQuota,
+		MaxUses:      maxUses,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Hour)
+		invite.ExpiresAt = &expiresAt
+	}
+	if err := ac.DB.Create(&invite).Error; err != nil {
+		logger.Request(c).Error("生成邀请码失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "生成邀请码失败")
+		return
+	}
+	// 不限次数时 gorm 会使用字段默认值，需要单独写入
+	if maxUses == 0 {
+		ac.DB.Model(&invite).Update("max_uses", 0)
+	}
+	audit.SetTarget(c, audit.TargetInvite, invite.ID, invite.Note)
+	audit.Describe(c, fmt.Sprintf("角色 %s，可用 %d 次", inviteJSON(&invite)["role"], maxUses))
+	response.SuccessWithStatus(c, http.StatusCreated, inviteJSON(&invite))
+}
+
+// DeleteInvite 作废邀请码，已注册的用户不受影响
+func (ac *AdminController) DeleteInvite(c *gin.Context) {
+	audit.SetTarget(c, audit.TargetInvite, c.Param("id"), "")
+	result := ac.DB.Delete(&model.InviteCode{}, c.Param("id"))
+	if result.Error != nil {
+		logger.Request(c).Error("删除邀请码失败: %v", result.Error)
+		response.Error(c, response.ErrInternalServer, "删除邀请码失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(c, response.ErrNotFound, "邀请码不存在")
+		return
+	}
+	response.Success(c, gin.H{"message": "邀请码已作废"})
+}
@@ -1,10 +1,19 @@
 package api
 
 import (
+    "fmt"
     "strings"
     "github.com/gin-gonic/gin"
+    "github.com/huanhq99/H-Cloud/internal/audit"
     "github.com/huanhq99/H-Cloud/internal/config"
+    "github.com/huanhq99/H-Cloud/internal/i18n"
+    "github.com/huanhq99/H-Cloud/internal/logger"
+    "github.com/huanhq99/H-Cloud/internal/mail"
+    "github.com/huanhq99/H-Cloud/internal/metrics"
     "github.com/huanhq99/H-Cloud/internal/model"
+    "github.com/huanhq99/H-Cloud/internal/ratelimit"
+    "github.com/huanhq99/H-Cloud/internal/rbac"
+    "github.com/huanhq99/H-Cloud/internal/response"
     "github.com/huanhq99/H-Cloud/internal/storage"
     "gorm.io/gorm"
 )
@@ -18,12 +27,28 @@ func isImageFile(filename string) bool {
            strings.HasSuffix(ext, ".svg")
 }
 
-// SetupRouter 设置路由
-func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
-    // 健康检查
-    r.GET("/health", func(ctx *gin.Context) {
-        ctx.JSON(200, gin.H{"status": "ok"})
-    })
+// Services 路由使用的共享服务，定时任务需要使用同一实例
+type Services struct {
+    Sessions *SessionManager
+    LDAP     *LDAPProvider // 未启用 LDAP 时为 nil
+}
+
+// SetupRouter 设置路由，返回路由使用的共享服务
+func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config) *Services {
+    // 只信任配置的反向代理转发的客户端 IP，否则任何人都能通过 X-Forwarded-For 伪造 IP 绕过按 IP 的限制
+    if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+        logger.Warn("设置可信代理失败: %v", err)
+    }
+    response.SetLegacy(cfg.Server.LegacyResponse)
+    if !i18n.SetDefault(cfg.Server.Locale) {
+        logger.Warn("不支持的默认语言 %q，使用 %s", cfg.Server.Locale, i18n.Default())
+    }
+
+    // 健康检查：/livez 存活检查，/readyz 就绪检查，/health 为兼容旧版本保留的存活检查
+    health := NewHealthController(db, cfg)
+    r.GET("/livez", health.Livez)
+    r.GET("/readyz", health.Readyz)
+    r.GET("/health", health.Livez)
 
     // 管理界面 - 根路径、/api 和 /api.html 都可以访问
     r.Use(func(c *gin.Context) {
@@ -37,13 +62,11 @@ func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
     r.StaticFile("/api", "./public/api.html")
     r.StaticFile("/api.html", "./public/api.html")
     r.StaticFile("/login.html", "./public/login.html")  // 添加登录页面路由
+    r.StaticFile("/account.html", "./public/account.html") // 邮箱验证、重置密码页面
     
     // 加载HTML模板
     r.LoadHTMLGlob("public/*.html")
     
-    // 静态文件服务 - 提供storage目录的直接访问
-    r.Static("/storage", "./storage")
-    
     // 分享路由 - 智能处理图片和其他文件
     r.GET("/share/:uuid", func(ctx *gin.Context) {
         uuid := ctx.Param("uuid")
@@ -72,51 +95,139 @@ func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
     })
 
     // 创建控制器实例
-    authController := NewAuthController(db, cfg)
-    fileController := NewFileController(db)
+    sessions := NewSessionManager(db, cfg)
+    ldapProvider := NewLDAPProvider(db, cfg, sessions)
+    mailer := newMailer(cfg)
+    authController := NewAuthController(db, sessions, ldapProvider, mailer, cfg)
+    uploadScanner := NewUploadScanner(db, cfg, mailer)
+    fileController := NewFileController(db, cfg, uploadScanner)
     dirController := NewDirectoryController(db)
     shareController := NewShareController(db)
-    adminController := NewAdminController(cfg)
+    limiter := ratelimit.New(cfg.Security.RateLimit, db)
+    config.OnReload(func(c *config.Config) {
+        limiter.Configure(c.Security.RateLimit)
+    })
+    authz := rbac.NewAuthorizer(db)
+    adminController := NewAdminController(db, cfg, authController, authz, ldapProvider, limiter)
     systemController := NewSystemController(db)
     recycleController := NewRecycleController(db)
     searchController := NewSearchController(db)
+    imageHostController := NewImageHostController(db, cfg, uploadScanner)
+    quarantineController := NewQuarantineController(db)
+    auditController := NewAuditController(db)
+    tokenController := NewTokenController(db, authz)
+    oidcController := NewOIDCController(db, authController, cfg)
+
+    // 文件类接口未登录时保持匿名访问，携带令牌时校验令牌及其权限范围
+    optionalAuth := OptionalAuthMiddleware(sessions)
+    canRead := RequireScope(ScopeFilesRead)
+    canWrite := RequireScope(ScopeFilesWrite)
+    canShare := RequireScope(ScopeSharesManage)
+
+    // 统计文件传输字节数
+    upload := metrics.Transfer(metrics.Upload)
+    download := metrics.Transfer(metrics.Download)
+
+    // Prometheus 指标 - 管理员或携带采集令牌访问
+    if cfg.Metrics.Enabled {
+        if err := metrics.RegisterDB(db); err != nil {
+            logger.Warn("注册数据库指标失败: %v", err)
+        }
+        r.GET("/metrics", MetricsToken(cfg.Metrics.Token), AuthMiddleware(sessions), RequireScope(ScopeAdmin), RequirePermission(authz, rbac.PermAdminAccess), metrics.Handler())
+    }
 
     // API 路由组
     api := r.Group("/api")
+    api.Use(audit.Middleware(db, auditActions))
     {
         // 账户相关路由
-        api.POST("/auth/register", authController.Register)
-        api.POST("/auth/login", authController.Login)
+        api.POST("/auth/register", limiter.Throttle("register", nil), authController.Register)
+        api.POST("/auth/login", limiter.Middleware("login", loginAccount), authController.Login)
+        api.POST("/auth/refresh", authController.Refresh)
+
+        // 邮箱验证和找回密码
+        api.GET("/auth/registration", authController.RegistrationPolicy)
+        api.POST("/auth/email/verify", authController.VerifyEmail)
+        api.POST("/auth/email/resend", limiter.Throttle("mail", loginAccount), authController.ResendVerification)
+        api.POST("/auth/password/forgot", limiter.Throttle("mail", loginAccount), authController.ForgotPassword)
+        api.POST("/auth/password/reset", authController.ResetPassword)
+        api.POST("/auth/login/2fa", limiter.Middleware("2fa", mfaAccount(sessions)), authController.LoginTwoFactor)
+
+        // OIDC 单点登录
+        api.GET("/auth/oidc/login", oidcController.Login)
+        api.GET("/auth/oidc/callback", oidcController.Callback)
+
+        // 两步验证绑定 - 登录会话或登录时返回的绑定令牌均可操作
+        api.POST("/auth/2fa/setup", optionalAuth, NoImpersonation(), authController.SetupTwoFactor)
+        api.POST("/auth/2fa/activate", optionalAuth, NoImpersonation(), authController.ActivateTwoFactor)
         auth := api.Group("/auth")
-        auth.Use(AuthMiddleware(cfg.JWT.Secret))
+        auth.Use(AuthMiddleware(sessions))
         {
             auth.GET("/me", authController.Me)
-            auth.PUT("/email", authController.UpdateEmail)
+            auth.POST("/password", SessionOnly(), NoImpersonation(), limiter.Middleware("password", nil), authController.ChangePassword)
+            auth.PUT("/email", SessionOnly(), NoImpersonation(), authController.UpdateEmail)
+            auth.PUT("/locale", SessionOnly(), NoImpersonation(), authController.UpdateLocale)
+
+            // 会话管理
+            auth.POST("/logout", SessionOnly(), authController.Logout)
+            auth.POST("/logout-all", SessionOnly(), authController.LogoutAll)
+            auth.GET("/sessions", SessionOnly(), authController.ListSessions)
+            auth.DELETE("/sessions/:id", SessionOnly(), authController.RevokeSession)
+
+            // 两步验证管理
+            auth.GET("/2fa", SessionOnly(), authController.TwoFactorStatus)
+            auth.POST("/2fa/disable", SessionOnly(), NoImpersonation(), authController.DisableTwoFactor)
+            auth.POST("/2fa/recovery-codes", SessionOnly(), NoImpersonation(), authController.RegenerateRecoveryCodes)
+
+            // 图床上传密钥管理
+            auth.GET("/image-key", SessionOnly(), imageHostController.GetAPIKey)
+            auth.POST("/image-key", SessionOnly(), NoImpersonation(), imageHostController.RegenerateAPIKey)
+            auth.DELETE("/image-key", SessionOnly(), imageHostController.RevokeAPIKey)
+
+            // 个人访问令牌管理（只能通过登录会话操作）
+            auth.GET("/tokens", SessionOnly(), NoImpersonation(), tokenController.ListTokens)
+            auth.POST("/tokens", SessionOnly(), NoImpersonation(), tokenController.CreateToken)
+            auth.DELETE("/tokens/:id", SessionOnly(), NoImpersonation(), tokenController.RevokeToken)
         }
 
         // 文件相关路由 - 移除认证中间件，实现H-Yun盘
         files := api.Group("/files")
+        files.Use(optionalAuth)
         {
-            files.POST("/upload", fileController.UploadFile)
-            files.GET("/download/:id", fileController.DownloadFile)
-            files.GET("/download", fileController.DownloadFileByPath)  // 新增：基于路径的下载
-            files.GET("/list", fileController.ListFiles)
-            files.DELETE("/delete", fileController.DeleteFile)
-		files.PUT("/rename", fileController.RenameFile)
+            files.POST("/upload", canWrite, upload, fileController.UploadFile)
+            files.GET("/upload-policy", canWrite, fileController.GetUploadPolicy)
+            files.GET("/download/:id", canRead, download, fileController.DownloadFile)
+            files.GET("/download", canRead, download, fileController.DownloadFileByPath)  // 新增：基于路径的下载
+            files.GET("/list", canRead, fileController.ListFiles)
+            files.DELETE("/delete", canWrite, fileController.DeleteFile)
+            files.PUT("/rename", canWrite, fileController.RenameFile)
         }
 
-        // 图床功能路由 - 无需认证的图片直链访问
-        api.GET("/image", fileController.GetImageByPath)  // 基于路径的图片直链访问
-        api.GET("/image/:id", fileController.GetImageDirect)  // 基于ID的图片直链访问
+        // 图床功能路由 - 无需认证的图片直链访问，受防盗链和流量限制保护
+        image := api.Group("/image")
+        image.Use(fileController.Guard.HotlinkMiddleware())
+        {
+            image.GET("", download, fileController.GetImageByPath)  // 基于路径的图片直链访问
+            image.GET("/:id", download, fileController.GetImageDirect)  // 基于ID的图片直链访问
+            image.GET("/:id/thumb", download, imageHostController.GetThumbnail)  // 缩略图
+        }
+
+        // 图床上传接口 - 使用图床密钥认证，兼容 Chevereto API（PicGo、ShareX）
+        api.POST("/image/upload", upload, imageHostController.Upload)
+        api.POST("/1/upload", upload, imageHostController.Upload)
+        api.GET("/image/delete/:token", imageHostController.DeleteByToken)  // 删除确认页面
+        api.POST("/image/delete/:token", imageHostController.DeleteByToken)
+        api.DELETE("/image/delete/:token", imageHostController.DeleteByToken)
 
         // 目录相关路由
         dirs := api.Group("/directories")
+        dirs.Use(optionalAuth)
         {
-            dirs.POST("/create", dirController.CreateDirectory)
-            dirs.POST("/map", dirController.MapDirectory)
-            dirs.GET("/list", dirController.ListDirectories)
-            dirs.DELETE("/:id", dirController.DeleteDirectory)
-            dirs.PUT("/rename", dirController.RenameDirectory)
+            dirs.POST("/create", canWrite, dirController.CreateDirectory)
+            dirs.POST("/map", canWrite, dirController.MapDirectory)
+            dirs.GET("/list", canRead, dirController.ListDirectories)
+            dirs.DELETE("/:id", canWrite, dirController.DeleteDirectory)
+            dirs.PUT("/rename", canWrite, dirController.RenameDirectory)
         }
 
         // 分享相关路由 - 移除认证，实现H-Yun盘
@@ -124,26 +235,28 @@ func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
         {
             // 公开访问的接口
             shares.GET("/check/:uuid", shareController.CheckShare)
-            shares.GET("/verify/:uuid", shareController.VerifyShare)
-            shares.GET("/access/:uuid", shareController.AccessShare)
+            shares.GET("/verify/:uuid", limiter.Middleware("share", shareAccount), shareController.VerifyShare)
+            shares.GET("/access/:uuid", limiter.Middleware("share", shareAccount), download, shareController.AccessShare)
 
             // 分享管理接口 - 移除认证
-            shares.POST("/create", shareController.CreateShare)
-            shares.GET("/list", shareController.ListShares)
-            shares.DELETE("/:uuid", shareController.RevokeShare)
+            shares.POST("/create", optionalAuth, canShare, shareController.CreateShare)
+            shares.GET("/list", optionalAuth, canShare, shareController.ListShares)
+            shares.DELETE("/:uuid", optionalAuth, canShare, shareController.RevokeShare)
         }
 
         // 回收站相关路由
         recycle := api.Group("/recycle")
+        recycle.Use(optionalAuth)
         {
-            recycle.GET("/list", recycleController.ListRecycleBin)
-            recycle.POST("/restore", recycleController.RestoreFromRecycleBin)
-            recycle.DELETE("/permanent", recycleController.PermanentDelete)
-            recycle.DELETE("/empty", recycleController.EmptyRecycleBin)
+            recycle.GET("/list", canRead, recycleController.ListRecycleBin)
+            recycle.POST("/restore", canWrite, recycleController.RestoreFromRecycleBin)
+            recycle.DELETE("/permanent", canWrite, recycleController.PermanentDelete)
+            recycle.DELETE("/empty", canWrite, recycleController.EmptyRecycleBin)
         }
 
         // 搜索相关路由
         search := api.Group("/search")
+        search.Use(optionalAuth, canRead)
         {
             search.GET("/files", searchController.SearchFiles)
             search.GET("/type", searchController.SearchByType)
@@ -155,25 +268,161 @@ func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
         api.GET("/system/info", systemController.GetSystemInfo)
 
         // 管理员相关路由
-        api.POST("/admin/login", adminController.Login)
+        api.POST("/admin/login", limiter.Middleware("admin", loginAccount), adminController.Login)
         admin := api.Group("/admin")
-        admin.Use(AdminAuthMiddleware(cfg.JWT.Secret))
+        admin.Use(AuthMiddleware(sessions), RequireScope(ScopeAdmin), RequirePermission(authz, rbac.PermAdminAccess))
         {
-            admin.POST("/logout", adminController.Logout)
+            admin.POST("/logout", SessionOnly(), authController.Logout)
             admin.GET("/me", adminController.Me)
+
+            // 用户管理
+            canManageUsers := RequirePermission(authz, rbac.PermUsersManage)
+            admin.GET("/users", canManageUsers, adminController.ListUsers)
+            admin.POST("/users", canManageUsers, adminController.CreateUser)
+            admin.GET("/users/:id", canManageUsers, adminController.GetUser)
+            admin.PUT("/users/:id", canManageUsers, adminController.UpdateUser)
+            admin.POST("/users/:id/disable", canManageUsers, adminController.DisableUser)
+            admin.POST("/users/:id/enable", canManageUsers, adminController.EnableUser)
+            admin.POST("/users/:id/reset-password", canManageUsers, adminController.ResetUserPassword)
+            admin.POST("/users/:id/impersonate", SessionOnly(), canManageUsers, adminController.ImpersonateUser)
+            admin.POST("/users/:id/approve", canManageUsers, adminController.ApproveUser)
+            admin.POST("/users/:id/reject", canManageUsers, adminController.RejectUser)
+            admin.GET("/invites", canManageUsers, adminController.ListInvites)
+            admin.POST("/invites", canManageUsers, adminController.CreateInvite)
+            admin.DELETE("/invites/:id", canManageUsers, adminController.DeleteInvite)
+
+            // 角色和权限
+            canManageRoles := RequirePermission(authz, rbac.PermRolesManage)
+            admin.GET("/permissions", canManageRoles, adminController.ListPermissions)
+            admin.GET("/roles", canManageRoles, adminController.ListRoles)
+            admin.POST("/roles", canManageRoles, adminController.CreateRole)
+            admin.PUT("/roles/:id", canManageRoles, adminController.UpdateRole)
+            admin.DELETE("/roles/:id", canManageRoles, adminController.DeleteRole)
+
+            // 安全设置
+            canManageSecurity := RequirePermission(authz, rbac.PermSecurityManage)
+            admin.GET("/security/2fa-roles", canManageSecurity, adminController.GetTwoFactorRoles)
+            admin.PUT("/security/2fa-roles", canManageSecurity, adminController.UpdateTwoFactorRoles)
+            admin.GET("/security/locks", canManageSecurity, adminController.ListLocks)
+            admin.DELETE("/security/locks", canManageSecurity, adminController.ClearLocks)
+            admin.GET("/quarantine", canManageSecurity, quarantineController.List)
+            admin.POST("/quarantine/:id/restore", canManageSecurity, quarantineController.Restore)
+            admin.DELETE("/quarantine/:id", canManageSecurity, quarantineController.Delete)
+
+            canManageSystem := RequirePermission(authz, rbac.PermSystemManage)
+            admin.POST("/ldap/sync", canManageSystem, adminController.SyncLDAP)
+            admin.GET("/config", canManageSystem, adminController.GetConfig)
+            admin.GET("/encryption", canManageSystem, adminController.GetEncryption)
+            admin.POST("/encryption/rewrap", canManageSystem, adminController.RewrapEncryptionKeys)
+
+            // 审计日志
+            canReadAudit := RequirePermission(authz, rbac.PermAuditRead)
+            admin.GET("/audit", canReadAudit, auditController.ListAuditLogs)
+            admin.GET("/audit/export", canReadAudit, auditController.ExportAuditLogs)
         }
     }
+    return &Services{Sessions: sessions, LDAP: ldapProvider}
+}
+
+// auditActions 审计日志中各接口的操作名称；写操作都会记录，读操作只记录这里列出的
+var auditActions = map[string]string{
+    "POST /api/auth/register":           "auth.register",
+    "POST /api/auth/login":              "auth.login",
+    "POST /api/auth/login/2fa":          "auth.login_2fa",
+    "POST /api/auth/refresh":            "auth.refresh",
+    "GET /api/auth/oidc/callback":       "auth.login_oidc",
+    "POST /api/auth/logout":             "auth.logout",
+    "POST /api/auth/logout-all":         "auth.logout_all",
+    "DELETE /api/auth/sessions/:id":     "auth.session_revoke",
+    "POST /api/auth/password":           "auth.password_change",
+    "POST /api/auth/password/forgot":    "auth.password_forgot",
+    "POST /api/auth/password/reset":     "auth.password_reset",
+    "PUT /api/auth/email":               "auth.email_change",
+    "POST /api/auth/email/verify":       "auth.email_verify",
+    "POST /api/auth/email/resend":       "auth.email_resend",
+    "PUT /api/auth/locale":              "auth.locale_change",
+    "POST /api/auth/2fa/setup":          "auth.2fa_setup",
+    "POST /api/auth/2fa/activate":       "auth.2fa_enable",
+    "POST /api/auth/2fa/disable":        "auth.2fa_disable",
+    "POST /api/auth/2fa/recovery-codes": "auth.2fa_recovery_codes",
+    "POST /api/auth/image-key":          "auth.image_key_create",
+    "DELETE /api/auth/image-key":        "auth.image_key_revoke",
+    "POST /api/auth/tokens":             "auth.token_create",
+    "DELETE /api/auth/tokens/:id":       "auth.token_revoke",
+
+    "POST /api/files/upload":        "file.upload",
+    "GET /api/files/download/:id":   "file.download",
+    "GET /api/files/download":       "file.download",
+    "DELETE /api/files/delete":      "file.delete",
+    "PUT /api/files/rename":         "file.rename",
+    "POST /api/image/upload":        "file.upload_image",
+    "POST /api/1/upload":            "file.upload_image",
+    "POST /api/image/delete/:token": "file.delete_image",
+    "DELETE /api/image/delete/:token": "file.delete_image",
+    "POST /api/directories/create":  "directory.create",
+    "POST /api/directories/map":     "directory.map",
+    "DELETE /api/directories/:id":   "directory.delete",
+    "PUT /api/directories/rename":   "directory.rename",
+    "POST /api/recycle/restore":     "recycle.restore",
+    "DELETE /api/recycle/permanent": "recycle.delete",
+    "DELETE /api/recycle/empty":     "recycle.empty",
+
+    "POST /api/shares/create":      "share.create",
+    "DELETE /api/shares/:uuid":     "share.revoke",
+    "GET /api/shares/verify/:uuid": "share.verify_password",
+    "GET /api/shares/access/:uuid": "share.access",
+
+    "POST /api/admin/login":                    "admin.login",
+    "POST /api/admin/logout":                   "admin.logout",
+    "POST /api/admin/users":                    "admin.user_create",
+    "PUT /api/admin/users/:id":                 "admin.user_update",
+    "POST /api/admin/users/:id/disable":        "admin.user_disable",
+    "POST /api/admin/users/:id/enable":         "admin.user_enable",
+    "POST /api/admin/users/:id/reset-password": "admin.user_reset_password",
+    "POST /api/admin/users/:id/impersonate":    "admin.user_impersonate",
+    "POST /api/admin/users/:id/approve":        "admin.user_approve",
+    "POST /api/admin/users/:id/reject":         "admin.user_reject",
+    "POST /api/admin/invites":                  "admin.invite_create",
+    "DELETE /api/admin/invites/:id":            "admin.invite_delete",
+    "POST /api/admin/roles":                    "admin.role_create",
+    "PUT /api/admin/roles/:id":                 "admin.role_update",
+    "DELETE /api/admin/roles/:id":              "admin.role_delete",
+    "PUT /api/admin/security/2fa-roles":        "admin.2fa_roles_update",
+    "DELETE /api/admin/security/locks":         "admin.locks_clear",
+    "POST /api/admin/quarantine/:id/restore":   "admin.quarantine_restore",
+    "DELETE /api/admin/quarantine/:id":         "admin.quarantine_delete",
+    "POST /api/admin/ldap/sync":                "admin.ldap_sync",
+    "GET /api/admin/config":                    "admin.config_view",
+    "POST /api/admin/encryption/rewrap":        "admin.encryption_rewrap",
+    "GET /api/admin/audit/export":              "admin.audit_export",
+}
+
+// newMailer 创建邮件发送器，配置有误时退回到只写日志，避免影响启动
+func newMailer(cfg *config.Config) *mail.Mailer {
+    fallback := fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
+    if cfg.Mail.BaseURL == "" && cfg.Mail.Transport == "smtp" {
+        logger.Warn("未配置 mail.base_url，邮件中的链接将使用 %s", fallback)
+    }
+    mailer, err := mail.New(cfg.Mail, fallback)
+    if err != nil {
+        logger.Error("初始化邮件发送失败，邮件将只写入日志: %v", err)
+        logOnly := cfg.Mail
+        logOnly.Transport = "log"
+        mailer, _ = mail.New(logOnly, fallback)
+    }
+    return mailer
 }
 
 // GetSystemStorageInfo 获取系统存储信息
 func GetSystemStorageInfo(ctx *gin.Context) {
 	total, used, free, err := storage.GetSystemStorageInfo()
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": "获取存储信息失败: " + err.Error()})
+		logger.Request(ctx).Error("获取存储信息失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "获取存储信息失败")
 		return
 	}
 
-	ctx.JSON(200, gin.H{
+	response.Success(ctx, gin.H{
 		"total": total,
 		"used":  used,
 		"free":  free,
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/mail"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"github.com/huanhq99/H-Cloud/internal/scanner"
+	"github.com/huanhq99/H-Cloud/internal/storage"
+	"gorm.io/gorm"
+)
+
+// UploadScanner 上传完成后扫描文件，检出恶意内容时移入隔离区并通知文件所有者
+type UploadScanner struct {
+	DB      *gorm.DB
+	Scanner scanner.Scanner
+	Mailer  *mail.Mailer
+	MaxSize int64  // 超过该大小的文件不扫描，0 表示都扫描
+	OnError string // 扫描失败时的处理方式：reject 或 allow
+}
+
+// NewUploadScanner 创建上传扫描器，扫描后端配置有误时拒绝启动
+func NewUploadScanner(db *gorm.DB, cfg *config.Config, mailer *mail.Mailer) *UploadScanner {
+	s, err := scanner.New(cfg.Scan)
+	if err != nil {
+		logger.Fatal("初始化病毒扫描失败: %v", err)
+	}
+	if clamd, ok := s.(*scanner.Clamd); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := clamd.Ping(ctx); err != nil {
+			logger.Warn("无法连接 clamd，扫描失败时按 scan.on_error=%s 处理: %v", cfg.Scan.OnError, err)
+		} else {
+			logger.Info("上传的文件将由 clamd（%s）扫描", cfg.Scan.Clamd.Address)
+		}
+	}
+	return &UploadScanner{
+		DB:      db,
+		Scanner: s,
+		Mailer:  mailer,
+		MaxSize: cfg.Scan.MaxSizeMB * 1024 * 1024,
+		OnError: cfg.Scan.OnError,
+	}
+}
+
+// Scan 扫描刚保存到存储中的文件 f：检出恶意内容时将文件移入隔离区并在 f 上标记，调用方保存记录后应调用 Notify；
+// 扫描失败且 scan.on_error 为 reject 时返回错误，调用方应删除已保存的文件并拒绝上传
+func (s *UploadScanner) Scan(ctx *gin.Context, f *model.File) error {
+	if _, ok := s.Scanner.(scanner.Noop); ok {
+		return nil
+	}
+	if s.MaxSize > 0 && f.Size > s.MaxSize {
+		logger.Request(ctx).Warn("文件 %s 超过 scan.max_size_mb，未扫描", f.Path)
+		return nil
+	}
+
+	src, err := storage.GetFile(f.UserID, f.Path)
+	if err != nil {
+		return err
+	}
+	result, err := s.Scanner.Scan(ctx.Request.Context(), src)
+	src.Close()
+	if err != nil {
+		if s.OnError == "allow" {
+			logger.Request(ctx).Error("扫描文件 %s 失败，按配置允许上传: %v", f.Path, err)
+			return nil
+		}
+		return err
+	}
+	now := time.Now()
+	f.ScannedAt = &now
+	if !result.Infected {
+		return nil
+	}
+
+	name, err := storage.QuarantineFile(f.Path)
+	if err != nil {
+		return fmt.Errorf("移入隔离区失败: %w", err)
+	}
+	f.Quarantined, f.QuarantinePath, f.Signature = true, name, result.Signature
+	logger.Request(ctx).Warn("文件 %s 检出 %s，已移入隔离区 %s", f.Path, result.Signature, name)
+	audit.Describe(ctx, "检出 "+result.Signature+"，已隔离")
+	return nil
+}
+
+// Notify 通知文件所有者文件已被隔离，所有者没有邮箱时只记录日志
+func (s *UploadScanner) Notify(f *model.File) {
+	var owner model.User
+	if err := s.DB.First(&owner, f.UserID).Error; err != nil || owner.Email == "" {
+		logger.Warn("文件 %d 已被隔离，但无法通知所有者（用户 %d 不存在或没有邮箱）", f.ID, f.UserID)
+		return
+	}
+	s.Mailer.SendAsync(owner.Email, mail.TemplateFileQuarantined, emailData{
+		Username:  owner.Username,
+		Email:     owner.Email,
+		FileName:  f.Name,
+		Signature: f.Signature,
+	})
+}
+
+// rejectQuarantined 文件已被隔离时返回错误响应，用于下载、预览和分享
+func rejectQuarantined(ctx *gin.Context, f *model.File) bool {
+	if !f.Quarantined {
+		return false
+	}
+	response.Error(ctx, response.ErrFileQuarantined, "文件已被隔离")
+	return true
+}
+
+// QuarantineController 隔离区管理，只有拥有安全管理权限的管理员可以访问
+type QuarantineController struct {
+	DB *gorm.DB
+}
+
+// NewQuarantineController 创建隔离区控制器
+func NewQuarantineController(db *gorm.DB) *QuarantineController {
+	return &QuarantineController{DB: db}
+}
+
+// List 列出被隔离的文件
+func (qc *QuarantineController) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultUserPageSize)))
+	if pageSize < 1 || pageSize > maxUserPageSize {
+		pageSize = defaultUserPageSize
+	}
+
+	q := qc.DB.Model(&model.File{}).Where("quarantined = ?", true)
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		logger.Request(c).Error("获取隔离文件列表失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "获取隔离文件列表失败")
+		return
+	}
+	var files []model.File
+	if err := q.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&files).Error; err != nil {
+		logger.Request(c).Error("获取隔离文件列表失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "获取隔离文件列表失败")
+		return
+	}
+
+	owners := map[uint]string{}
+	for _, f := range files {
+		owners[f.UserID] = ""
+	}
+	var users []model.User
+	ids := make([]uint, 0, len(owners))
+	for id := range owners {
+		ids = append(ids, id)
+	}
+	qc.DB.Select("id", "username").Where("id IN ?", ids).Find(&users)
+	for _, u := range users {
+		owners[u.ID] = u.Username
+	}
+
+	items := make([]gin.H, 0, len(files))
+	for _, f := range files {
+		items = append(items, gin.H{
+			"id":          f.ID,
+			"name":        f.Name,
+			"path":        f.Path,
+			"size":        f.Size,
+			"contentType": f.ContentType,
+			"userId":      f.UserID,
+			"username":    owners[f.UserID],
+			"signature":   f.Signature,
+			"scannedAt":   f.ScannedAt,
+		})
+	}
+	response.Success(c, gin.H{"files": items, "total": total, "page": page, "pageSize": pageSize})
+}
+
+// findQuarantined 按路径参数 id 查找被隔离的文件
+func (qc *QuarantineController) findQuarantined(c *gin.Context) (*model.File, bool) {
+	var f model.File
+	if err := qc.DB.Where("quarantined = ?", true).First(&f, c.Param("id")).Error; err != nil {
+		response.Error(c, response.ErrFileNotFound, "文件不存在")
+		return nil, false
+	}
+	audit.SetTarget(c, audit.TargetFile, f.ID, f.Path)
+	return &f, true
+}
+
+// Restore 确认误报后将文件移回原位置并解除隔离
+func (qc *QuarantineController) Restore(c *gin.Context) {
+	f, ok := qc.findQuarantined(c)
+	if !ok {
+		return
+	}
+	if err := storage.RestoreQuarantined(f.QuarantinePath, f.Path); err != nil {
+		if errors.Is(err, storage.ErrRestoreConflict) {
+			response.Error(c, response.ErrFileExists, err.Error())
+			return
+		}
+		logger.Request(c).Error("恢复隔离文件失败: %v", err)
+		response.Error(c, response.ErrStorageFailure, "恢复隔离文件失败")
+		return
+	}
+	err := qc.DB.Model(f).Updates(map[string]interface{}{
+		"quarantined":     false,
+		"quarantine_path": "",
+	}).Error
+	if err != nil {
+		logger.Request(c).Error("更新文件记录失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "更新文件记录失败")
+		return
+	}
+	audit.Describe(c, f.Signature)
+	response.Success(c, gin.H{"message": "文件已恢复"})
+}
+
+// Delete 永久删除被隔离的文件
+func (qc *QuarantineController) Delete(c *gin.Context) {
+	f, ok := qc.findQuarantined(c)
+	if !ok {
+		return
+	}
+	if err := storage.DeleteQuarantined(f.QuarantinePath); err != nil {
+		logger.Request(c).Error("删除隔离文件失败: %v", err)
+		response.Error(c, response.ErrStorageFailure, "删除隔离文件失败")
+		return
+	}
+	if err := qc.DB.Unscoped().Delete(f).Error; err != nil {
+		logger.Request(c).Error("删除文件记录失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "删除文件记录失败")
+		return
+	}
+	audit.Describe(c, f.Signature)
+	response.Success(c, gin.H{"message": "文件已删除"})
+}
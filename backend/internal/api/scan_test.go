@@ -0,0 +1,341 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"github.com/huanhq99/H-Cloud/internal/scanner"
+	"github.com/huanhq99/H-Cloud/internal/storage"
+	"gorm.io/gorm"
+)
+
+// eicarMarker 文件内容包含该字符串时假 clamd 报告感染
+const eicarMarker = "EICAR-STANDARD-ANTIVIRUS-TEST-FILE"
+
+// fakeClamd 在回环地址上实现 INSTREAM 协议的假 clamd
+type fakeClamd struct {
+	ln      net.Listener
+	scanned chan []byte // 收到的文件内容
+}
+
+func newFakeClamd(t *testing.T) *fakeClamd {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	c := &fakeClamd{ln: ln, scanned: make(chan []byte, 10)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go c.serve(conn)
+		}
+	}()
+	return c
+}
+
+func (c *fakeClamd) address() string {
+	return "tcp://" + c.ln.Addr().String()
+}
+
+// serve 处理一次 zINSTREAM 或 zPING 会话
+func (c *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch cmd {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+		return
+	case "zINSTREAM\x00":
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+	var body bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&body, r, int64(size)); err != nil {
+			return
+		}
+	}
+	c.scanned <- body.Bytes()
+	if bytes.Contains(body.Bytes(), []byte(eicarMarker)) {
+		conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		return
+	}
+	conn.Write([]byte("stream: OK\x00"))
+}
+
+// closedAddress 返回一个没有服务监听的地址，模拟 clamd 不可用
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return "tcp://" + addr
+}
+
+// scanHarness 使用真实存储目录和 clamd 扫描器的文件与分享接口
+type scanHarness struct {
+	db     *gorm.DB
+	router *gin.Engine
+}
+
+func newScanHarness(t *testing.T, clamdAddress, onError string) *scanHarness {
+	t.Helper()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	dir := t.TempDir()
+	cfg.Storage.Path = filepath.Join(dir, "storage")
+	cfg.Storage.MappedPath = cfg.Storage.Path
+	cfg.Scan.QuarantinePath = filepath.Join(dir, "quarantine")
+	cfg.ImageHost.ThumbnailPath = ""
+	cfg.Encryption.Enabled = false
+	if err := storage.InitStorage(cfg); err != nil {
+		t.Fatalf("初始化存储失败: %v", err)
+	}
+
+	clamd, err := scanner.NewClamd(clamdAddress, 2*time.Second)
+	if err != nil {
+		t.Fatalf("创建扫描器失败: %v", err)
+	}
+	db := newTestDB(t)
+	if err := db.Create(&model.User{Username: "alice", Password: "x", Email: "alice@example.com"}).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	uploadScanner := &UploadScanner{DB: db, Scanner: clamd, Mailer: newMailer(cfg), OnError: onError}
+	files := NewFileController(db, cfg, uploadScanner)
+	shares := NewShareController(db)
+
+	r := gin.New()
+	r.POST("/upload", files.UploadFile)
+	r.GET("/download", files.DownloadFileByPath)
+	r.POST("/shares", shares.CreateShare)
+	r.GET("/shares/access/:uuid", shares.AccessShare)
+	return &scanHarness{db: db, router: r}
+}
+
+// do 发送请求并返回响应
+func (h *scanHarness) do(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.router.ServeHTTP(w, req)
+	return w
+}
+
+// upload 以 multipart 表单上传文件
+func (h *scanHarness) upload(t *testing.T, name, content string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return h.do(req)
+}
+
+// download 按路径下载用户 1 的文件
+func (h *scanHarness) download(path string) *httptest.ResponseRecorder {
+	return h.do(httptest.NewRequest(http.MethodGet, "/download?userId=1&path="+path, nil))
+}
+
+// share 为文件创建分享
+func (h *scanHarness) share(fileID uint) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(gin.H{"userId": 1, "fileId": fileID, "expireDays": 1})
+	req := httptest.NewRequest(http.MethodPost, "/shares", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return h.do(req)
+}
+
+// file 按名称查找文件记录
+func (h *scanHarness) file(t *testing.T, name string) *model.File {
+	t.Helper()
+	var f model.File
+	if err := h.db.Where("name = ?", name).First(&f).Error; err != nil {
+		return nil
+	}
+	return &f
+}
+
+// expectCode 检查响应的错误代码
+func expectCode(t *testing.T, w *httptest.ResponseRecorder, code response.ErrorCode) {
+	t.Helper()
+	if resp := decodeResponse(t, w.Body.Bytes()); resp.Code != code {
+		t.Fatalf("错误代码应为 %d，实际为 %d: %s", code, resp.Code, w.Body.String())
+	}
+}
+
+func TestUploadScanClean(t *testing.T) {
+	clamd := newFakeClamd(t)
+	h := newScanHarness(t, clamd.address(), "reject")
+
+	w := h.upload(t, "clean.txt", "hello world")
+	if w.Code != http.StatusOK {
+		t.Fatalf("上传失败: %d %s", w.Code, w.Body.String())
+	}
+	select {
+	case got := <-clamd.scanned:
+		if string(got) != "hello world" {
+			t.Errorf("clamd 收到的内容不正确: %q", got)
+		}
+	default:
+		t.Fatal("文件未经过扫描")
+	}
+
+	f := h.file(t, "clean.txt")
+	if f == nil || f.Quarantined || f.ScannedAt == nil {
+		t.Fatalf("文件记录不正确: %+v", f)
+	}
+	if w := h.download(f.Path); w.Code != http.StatusOK || w.Body.String() != "hello world" {
+		t.Errorf("下载失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := h.share(f.ID); w.Code != http.StatusOK {
+		t.Errorf("创建分享失败: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUploadScanInfected(t *testing.T) {
+	clamd := newFakeClamd(t)
+	h := newScanHarness(t, clamd.address(), "reject")
+
+	w := h.upload(t, "eicar.txt", "X5O!P%@AP "+eicarMarker)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("应返回 422，实际为 %d %s", w.Code, w.Body.String())
+	}
+	expectCode(t, w, response.ErrFileInfected)
+	if !strings.Contains(decodeResponse(t, w.Body.Bytes()).Message, "Eicar-Test-Signature") {
+		t.Errorf("响应应包含病毒名称: %s", w.Body.String())
+	}
+
+	f := h.file(t, "eicar.txt")
+	if f == nil || !f.Quarantined || f.Signature != "Eicar-Test-Signature" || f.QuarantinePath == "" {
+		t.Fatalf("文件应被标记为已隔离: %+v", f)
+	}
+	if _, err := os.Stat(filepath.Join(storage.StoragePath, f.Path)); !os.IsNotExist(err) {
+		t.Errorf("原文件应已移出存储目录: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storage.QuarantinePath, f.QuarantinePath)); err != nil {
+		t.Errorf("文件应在隔离区中: %v", err)
+	}
+
+	// 下载、创建分享和访问已有分享都被拒绝
+	w = h.download(f.Path)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("下载应返回 403，实际为 %d", w.Code)
+	}
+	expectCode(t, w, response.ErrFileQuarantined)
+	expectCode(t, h.share(f.ID), response.ErrFileQuarantined)
+
+	share := model.Share{UUID: "quarantined-share", FileID: &f.ID, UserID: f.UserID, ExpireAt: time.Now().Add(time.Hour), IsPublic: true}
+	if err := h.db.Create(&share).Error; err != nil {
+		t.Fatalf("创建分享失败: %v", err)
+	}
+	w = h.do(httptest.NewRequest(http.MethodGet, "/shares/access/"+share.UUID, nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("访问分享应返回 403，实际为 %d", w.Code)
+	}
+	expectCode(t, w, response.ErrFileQuarantined)
+}
+
+func TestUploadScanUnavailable(t *testing.T) {
+	t.Run("reject", func(t *testing.T) {
+		h := newScanHarness(t, closedAddress(t), "reject")
+
+		w := h.upload(t, "a.txt", "hello")
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("应返回 502，实际为 %d %s", w.Code, w.Body.String())
+		}
+		expectCode(t, w, response.ErrBadGateway)
+		if f := h.file(t, "a.txt"); f != nil {
+			t.Errorf("不应保存文件记录: %+v", f)
+		}
+		if _, err := os.Stat(filepath.Join(storage.StoragePath, "a.txt")); !os.IsNotExist(err) {
+			t.Errorf("已保存的文件应被删除: %v", err)
+		}
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		h := newScanHarness(t, closedAddress(t), "allow")
+
+		w := h.upload(t, "a.txt", "hello")
+		if w.Code != http.StatusOK {
+			t.Fatalf("应允许上传，实际为 %d %s", w.Code, w.Body.String())
+		}
+		f := h.file(t, "a.txt")
+		if f == nil || f.Quarantined || f.ScannedAt != nil {
+			t.Fatalf("文件应保存且标记为未扫描: %+v", f)
+		}
+		if w := h.download(f.Path); w.Code != http.StatusOK {
+			t.Errorf("下载失败: %d", w.Code)
+		}
+	})
+}
+
+func TestUploadScanRecordFailure(t *testing.T) {
+	clamd := newFakeClamd(t)
+
+	for _, tt := range []struct{ name, content string }{
+		{"clean", "hello"},
+		{"infected", "X5O!P%@AP " + eicarMarker},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newScanHarness(t, clamd.address(), "reject")
+			// 删除文件表使保存文件记录失败
+			if err := h.db.Migrator().DropTable(&model.File{}); err != nil {
+				t.Fatal(err)
+			}
+
+			w := h.upload(t, "a.txt", tt.content)
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("应返回 500，实际为 %d %s", w.Code, w.Body.String())
+			}
+			for _, dir := range []string{storage.StoragePath, storage.QuarantinePath} {
+				entries, _ := os.ReadDir(dir)
+				for _, e := range entries {
+					if !e.IsDir() {
+						t.Errorf("没有文件记录时应删除已保存的文件: %s", filepath.Join(dir, e.Name()))
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,14 @@
 package api
 
 import (
-	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"github.com/huanhq99/H-Cloud/internal/logger"
 	. "github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
 )
 
 type SearchController struct {
@@ -24,13 +25,13 @@ func (sc *SearchController) SearchFiles(c *gin.Context) {
 	query := c.Query("query")
 	
 	if userIDStr == "" || query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "用户ID和搜索关键词不能为空"})
+		response.Error(c, response.ErrInvalidRequest, "用户ID和搜索关键词不能为空")
 		return
 	}
 	
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(c, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 	
@@ -38,7 +39,8 @@ func (sc *SearchController) SearchFiles(c *gin.Context) {
 	var files []File
 	err = sc.DB.Where("user_id = ? AND name LIKE ?", userID, "%"+query+"%").Find(&files).Error
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索文件失败"})
+		logger.Request(c).Error("搜索文件失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "搜索文件失败")
 		return
 	}
 	
@@ -46,7 +48,8 @@ func (sc *SearchController) SearchFiles(c *gin.Context) {
 	var directories []Directory
 	err = sc.DB.Where("user_id = ? AND name LIKE ?", userID, "%"+query+"%").Find(&directories).Error
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索目录失败"})
+		logger.Request(c).Error("搜索目录失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "搜索目录失败")
 		return
 	}
 	
@@ -79,7 +82,7 @@ func (sc *SearchController) SearchFiles(c *gin.Context) {
 		})
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
+	response.Success(c, gin.H{
 		"results": results,
 		"total":   len(results),
 		"query":   query,
@@ -92,13 +95,13 @@ func (sc *SearchController) SearchByType(c *gin.Context) {
 	fileType := c.Query("type")
 	
 	if userIDStr == "" || fileType == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "用户ID和文件类型不能为空"})
+		response.Error(c, response.ErrInvalidRequest, "用户ID和文件类型不能为空")
 		return
 	}
 	
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		response.Error(c, response.ErrInvalidRequest, "无效的用户ID")
 		return
 	}
 	
@@ -117,13 +120,14 @@ func (sc *SearchController) SearchByType(c *gin.Context) {
 	case "archive":
 		whereClause = "user_id = ? AND (content_type LIKE 'application/zip' OR content_type LIKE 'application/x-rar%' OR content_type LIKE 'application/x-tar%')"
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的文件类型"})
+		response.Error(c, response.ErrFileTypeInvalid, "不支持的文件类型")
 		return
 	}
 	
 	err = sc.DB.Where(whereClause, userID).Find(&files).Error
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索文件失败"})
+		logger.Request(c).Error("搜索文件失败: %v", err)
+		response.Error(c, response.ErrInternalServer, "搜索文件失败")
 		return
 	}
 	
@@ -142,7 +146,7 @@ func (sc *SearchController) SearchByType(c *gin.Context) {
 		})
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
+	response.Success(c, gin.H{
 		"results": results,
 		"total":   len(results),
 		"type":    fileType,
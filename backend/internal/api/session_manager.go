@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/i18n"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"gorm.io/gorm"
+)
+
+// 刷新令牌前缀
+const refreshTokenPrefix = "hcr_"
+
+// 会话最近活跃时间的更新间隔，避免每个请求都写数据库
+const sessionTouchInterval = time.Minute
+
+// 管理员代登录会话的有效期，到期后不能续期
+const impersonationTTL = time.Hour
+
+var (
+	errSessionRevoked = errors.New("会话已失效，请重新登录")
+	errRefreshInvalid = errors.New("刷新令牌无效或已过期")
+	errRefreshReused  = errors.New("刷新令牌已被使用，会话已被注销")
+)
+
+// TokenPair 登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // 访问令牌有效期（秒）
+	SessionID    uint   `json:"sessionId"`
+}
+
+// SessionManager 会话管理：签发短期访问令牌和可轮换的刷新令牌，并负责吊销校验
+type SessionManager struct {
+	DB         *gorm.DB
+	Secret     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// NewSessionManager 创建会话管理器
+func NewSessionManager(db *gorm.DB, cfg *config.Config) *SessionManager {
+	return &SessionManager{
+		DB:         db,
+		Secret:     cfg.JWT.Secret,
+		AccessTTL:  time.Duration(cfg.JWT.AccessExpiresIn) * time.Minute,
+		RefreshTTL: time.Duration(cfg.JWT.RefreshExpiresIn) * time.Hour,
+	}
+}
+
+// accessToken 生成绑定会话的访问令牌
+func (m *SessionManager) accessToken(user *model.User, session *model.Session) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:             user.ID,
+		Role:               user.Role,
+		SessionID:          session.ID,
+		MustChangePassword: user.MustChangePassword,
+		ImpersonatorID:     session.ImpersonatorID,
+		Locale:             user.Locale,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.AccessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(m.Secret))
+}
+
+// Issue 为用户创建新会话并签发令牌
+func (m *SessionManager) Issue(ctx *gin.Context, user *model.User) (*TokenPair, error) {
+	return m.issue(ctx, user, nil, m.RefreshTTL)
+}
+
+// Impersonate 为管理员创建代登录目标用户的短期会话，会话记录发起代登录的管理员
+func (m *SessionManager) Impersonate(ctx *gin.Context, user *model.User, impersonatorID uint) (*TokenPair, error) {
+	return m.issue(ctx, user, &impersonatorID, impersonationTTL)
+}
+
+// issue 创建会话并签发令牌，ttl 为会话（刷新令牌）有效期
+func (m *SessionManager) issue(ctx *gin.Context, user *model.User, impersonatorID *uint, ttl time.Duration) (*TokenPair, error) {
+	refresh, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	refresh = refreshTokenPrefix + refresh
+
+	now := time.Now()
+	session := model.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashSecret(refresh),
+		Device:           truncate(ctx.GetHeader("User-Agent"), 255),
+		IP:               ctx.ClientIP(),
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(ttl),
+		ImpersonatorID:   impersonatorID,
+	}
+	if err := m.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	// 登录等接口的响应也使用用户的首选语言
+	i18n.SetLocale(ctx, user.Locale)
+
+	access, err := m.accessToken(user, &session)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(m.AccessTTL.Seconds()),
+		SessionID:    session.ID,
+	}, nil
+}
+
+// Refresh 使用刷新令牌换取新令牌，刷新令牌每次使用后轮换；
+// 已轮换掉的旧令牌再次出现视为泄露，直接注销整个会话
+func (m *SessionManager) Refresh(ctx *gin.Context, refresh string) (*TokenPair, error) {
+	hash := hashSecret(refresh)
+
+	var session model.Session
+	if err := m.DB.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		if err := m.DB.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&session).Error; err == nil {
+			m.Revoke(session.UserID, session.ID)
+			return nil, errRefreshReused
+		}
+		return nil, errRefreshInvalid
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, errRefreshInvalid
+	}
+
+	var user model.User
+	if err := m.DB.First(&user, session.UserID).Error; err != nil || user.Disabled {
+		return nil, errRefreshInvalid
+	}
+
+	next, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	next = refreshTokenPrefix + next
+
+	now := time.Now()
+	// 代登录会话不随刷新续期
+	expiresAt := now.Add(m.RefreshTTL)
+	if session.ImpersonatorID != nil {
+		expiresAt = session.ExpiresAt
+	}
+	result := m.DB.Model(&model.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  hashSecret(next),
+			"previous_token_hash": hash,
+			"ip":                  ctx.ClientIP(),
+			"last_seen_at":        now,
+			"expires_at":          expiresAt,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	// 并发刷新时只有一个请求能成功轮换
+	if result.RowsAffected == 0 {
+		return nil, errRefreshInvalid
+	}
+
+	access, err := m.accessToken(&user, &session)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: next,
+		ExpiresIn:    int64(m.AccessTTL.Seconds()),
+		SessionID:    session.ID,
+	}, nil
+}
+
+// Validate 校验访问令牌所属会话仍然有效、用户未被禁用，并按间隔更新最近活跃时间
+func (m *SessionManager) Validate(ctx *gin.Context, claims *Claims) error {
+	if claims.SessionID == 0 {
+		return errSessionRevoked
+	}
+	var session model.Session
+	if err := m.DB.First(&session, claims.SessionID).Error; err != nil {
+		return errSessionRevoked
+	}
+	if session.UserID != claims.UserID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return errSessionRevoked
+	}
+	// 禁用账户时会注销其会话，这里再检查一次，直接修改数据库等途径禁用的账户也立即失效
+	var user model.User
+	if err := m.DB.Select("id", "disabled").First(&user, session.UserID).Error; err != nil {
+		return errSessionRevoked
+	}
+	if user.Disabled {
+		return errAccountDisabled
+	}
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		m.DB.Model(&session).UpdateColumns(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip":           ctx.ClientIP(),
+		})
+	}
+	return nil
+}
+
+// Revoke 注销用户的指定会话
+func (m *SessionManager) Revoke(userID, sessionID uint) (bool, error) {
+	result := m.DB.Model(&model.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAll 注销用户的全部会话
+func (m *SessionManager) RevokeAll(userID uint) (int64, error) {
+	result := m.DB.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// PurgeExpired 清理已过期的会话。已注销的会话保留到刷新令牌过期，
+// 以便泄露的旧刷新令牌在有效期内再次出现时仍能被识别
+func (m *SessionManager) PurgeExpired(ctx context.Context) error {
+	return m.DB.WithContext(ctx).Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.Session{}).Error
+}
+
+// truncate 截断字符串到指定字节数
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
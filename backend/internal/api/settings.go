@@ -0,0 +1,42 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 系统设置键
+const (
+	settingRequire2FARoles = "security.require_2fa_roles" // 强制启用两步验证的角色，逗号分隔
+)
+
+// getSetting 读取系统设置，不存在时返回空字符串
+func getSetting(db *gorm.DB, key string) string {
+	var setting model.Setting
+	if err := db.Where("`key` = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
+
+// setSetting 写入系统设置
+func setSetting(db *gorm.DB, key, value string) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&model.Setting{Key: key, Value: value}).Error
+}
+
+// getSettingList 读取逗号分隔的列表设置
+func getSettingList(db *gorm.DB, key string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(getSetting(db, key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
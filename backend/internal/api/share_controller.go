@@ -8,7 +8,11 @@ import (
     "fmt"
 
     "github.com/gin-gonic/gin"
+    "github.com/huanhq99/H-Cloud/internal/audit"
+    "github.com/huanhq99/H-Cloud/internal/config"
+    "github.com/huanhq99/H-Cloud/internal/logger"
     "github.com/huanhq99/H-Cloud/internal/model"
+    "github.com/huanhq99/H-Cloud/internal/response"
     "github.com/huanhq99/H-Cloud/internal/storage"
     "gorm.io/gorm"
 )
@@ -36,19 +40,19 @@ func (c *ShareController) CreateShare(ctx *gin.Context) {
         IsPublic    *bool  `json:"isPublic"`
     }
     if err := ctx.ShouldBindJSON(&req); err != nil {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+        response.Error(ctx, response.ErrInvalidRequest, "无效的请求参数")
         return
     }
 
     // 使用请求中的用户ID
     userID := req.UserID
     if userID == 0 {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "用户ID不能为空"})
+        response.Error(ctx, response.ErrInvalidRequest, "用户ID不能为空")
         return
     }
 
     if (req.FileID == nil && req.DirectoryID == nil) || (req.FileID != nil && req.DirectoryID != nil) {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "必须且仅选择文件或目录其中之一"})
+        response.Error(ctx, response.ErrInvalidRequest, "必须且仅选择文件或目录其中之一")
         return
     }
 
@@ -56,22 +60,26 @@ func (c *ShareController) CreateShare(ctx *gin.Context) {
     if req.FileID != nil {
         var file model.File
         if err := c.DB.First(&file, *req.FileID).Error; err != nil {
-            ctx.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+            response.Error(ctx, response.ErrFileNotFound, "文件不存在")
             return
         }
         if file.UserID != userID {
-            ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限分享该文件"})
+            response.Error(ctx, response.ErrForbidden, "没有权限分享该文件")
+            return
+        }
+        if file.Quarantined {
+            response.Error(ctx, response.ErrFileQuarantined, "文件已被隔离，不能分享")
             return
         }
     }
     if req.DirectoryID != nil {
         var dir model.Directory
         if err := c.DB.First(&dir, *req.DirectoryID).Error; err != nil {
-            ctx.JSON(http.StatusNotFound, gin.H{"error": "目录不存在"})
+            response.Error(ctx, response.ErrDirNotFound, "目录不存在")
             return
         }
         if dir.UserID != userID {
-            ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限分享该目录"})
+            response.Error(ctx, response.ErrForbidden, "没有权限分享该目录")
             return
         }
     }
@@ -79,23 +87,39 @@ func (c *ShareController) CreateShare(ctx *gin.Context) {
     // 生成UUID（安全随机16字节hex）
     b := make([]byte, 16)
     if _, err := rand.Read(b); err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成分享ID失败"})
+        logger.Request(ctx).Error("生成分享ID失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "生成分享ID失败")
         return
     }
     uuid := hex.EncodeToString(b)
 
-    // 过期时间与公开性
+    // 过期时间与公开性，按当前的分享策略检查
+    policy := config.Current().Share
+    if policy.RequirePassword && req.Password == "" {
+        response.Error(ctx, response.ErrInvalidRequest, "分享必须设置访问密码")
+        return
+    }
     var expireAt time.Time
     noExpire := false
     if req.Forever {
+        if !policy.AllowPermanent {
+            response.Error(ctx, response.ErrInvalidRequest, "不允许创建永久分享")
+            return
+        }
         noExpire = true
         expireAt = time.Now().AddDate(100, 0, 0) // 约定永久，设置一个很远的时间
-    } else if req.ExpireDays > 0 {
-        expireAt = time.Now().Add(time.Duration(req.ExpireDays) * 24 * time.Hour)
     } else {
-        expireHours := req.ExpireHours
-        if expireHours <= 0 { expireHours = 24 }
-        expireAt = time.Now().Add(time.Duration(expireHours) * time.Hour)
+        ttl := time.Duration(req.ExpireHours) * time.Hour
+        if req.ExpireDays > 0 {
+            ttl = time.Duration(req.ExpireDays) * 24 * time.Hour
+        } else if ttl <= 0 {
+            ttl = time.Duration(policy.DefaultExpireHours) * time.Hour
+        }
+        if policy.MaxExpireDays > 0 && ttl > time.Duration(policy.MaxExpireDays)*24*time.Hour {
+            response.Error(ctx, response.ErrInvalidRequest, fmt.Sprintf("分享有效期不能超过 %d 天", policy.MaxExpireDays))
+            return
+        }
+        expireAt = time.Now().Add(ttl)
     }
     isPublic := true
     if req.IsPublic != nil { isPublic = *req.IsPublic }
@@ -111,23 +135,23 @@ func (c *ShareController) CreateShare(ctx *gin.Context) {
         IsPublic:    isPublic,
     }
     if err := c.DB.Create(&share).Error; err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建分享失败"})
+        logger.Request(ctx).Error("创建分享失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "创建分享失败")
         return
     }
-
-    // 获取文件信息，返回直接的storage路径
-    var directLink string
+    audit.SetTarget(ctx, audit.TargetShare, uuid, "")
     if req.FileID != nil {
-        var file model.File
-        if err := c.DB.First(&file, *req.FileID).Error; err == nil {
-            // 返回直接的storage路径访问链接
-            directLink = fmt.Sprintf("/storage%s", file.Path)
-        }
+        audit.Describe(ctx, fmt.Sprintf("文件 %d", *req.FileID))
+    } else {
+        audit.Describe(ctx, fmt.Sprintf("目录 %d", *req.DirectoryID))
+    }
+    if req.Password != "" {
+        audit.Describe(ctx, "设置了访问密码")
     }
 
     // 返回相对链接，由前端拼接域名
     link := fmt.Sprintf("/api/shares/access/%s", uuid)
-    response := gin.H{
+    result := gin.H{
         "message": "分享创建成功",
         "uuid": uuid,
         "link": link,
@@ -136,24 +160,25 @@ func (c *ShareController) CreateShare(ctx *gin.Context) {
         "isPermanent": share.NoExpire,
     }
     
-    // 如果是文件分享，添加直接访问链接
-    if directLink != "" {
-        response["directLink"] = directLink
+    // 文件分享的直接访问链接同样经过分享接口，以便检查有效期、密码和隔离状态并解密文件
+    if req.FileID != nil {
+        result["directLink"] = link
     }
     
-    ctx.JSON(http.StatusOK, response)
+    response.Success(ctx, result)
 }
 
 // ListShares 列出我创建的分享
 func (c *ShareController) ListShares(ctx *gin.Context) {
     userID, exists := ctx.Get("userID")
     if !exists {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
         return
     }
     var shares []model.Share
     if err := c.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&shares).Error; err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取分享列表失败"})
+        logger.Request(ctx).Error("获取分享列表失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "获取分享列表失败")
         return
     }
     // 补充文件或目录名称
@@ -196,7 +221,7 @@ func (c *ShareController) ListShares(ctx *gin.Context) {
         resp = append(resp, item)
     }
 
-    ctx.JSON(http.StatusOK, gin.H{"shares": resp})
+    response.Success(ctx, gin.H{"shares": resp})
 }
 
 // CheckShare 校验分享信息（用于前端判断是否需要密码）
@@ -204,11 +229,11 @@ func (c *ShareController) CheckShare(ctx *gin.Context) {
     uuid := ctx.Param("uuid")
     var share model.Share
     if err := c.DB.Where("uuid = ?", uuid).First(&share).Error; err != nil {
-        ctx.JSON(http.StatusNotFound, gin.H{"error": "分享不存在"})
+        response.Error(ctx, response.ErrShareNotFound, "分享不存在")
         return
     }
     if !share.NoExpire && time.Now().After(share.ExpireAt) {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "分享已过期"})
+        response.Error(ctx, response.ErrShareExpired, "分享已过期")
         return
     }
     resp := gin.H{
@@ -240,91 +265,99 @@ func (c *ShareController) CheckShare(ctx *gin.Context) {
             resp["name"] = "(目录不存在)"
         }
     }
-    ctx.JSON(http.StatusOK, resp)
+    response.Success(ctx, resp)
 }
 
 // VerifyShare 验证分享密码（不返回文件，仅校验密码是否正确）
 func (c *ShareController) VerifyShare(ctx *gin.Context) {
     uuid := ctx.Param("uuid")
     password := ctx.Query("password")
+    audit.SetTarget(ctx, audit.TargetShare, uuid, "")
 
     var share model.Share
     if err := c.DB.Where("uuid = ?", uuid).First(&share).Error; err != nil {
-        ctx.JSON(http.StatusNotFound, gin.H{"error": "分享不存在"})
+        response.Error(ctx, response.ErrShareNotFound, "分享不存在")
         return
     }
     if !share.NoExpire && time.Now().After(share.ExpireAt) {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "分享已过期"})
+        response.Error(ctx, response.ErrShareExpired, "分享已过期")
         return
     }
     if share.Password != "" && share.Password != password {
-        ctx.JSON(http.StatusForbidden, gin.H{"error": "密码错误"})
+        response.Error(ctx, response.ErrPasswordIncorrect, "密码错误")
         return
     }
 
-    ctx.JSON(http.StatusOK, gin.H{"ok": true})
+    response.Success(ctx, gin.H{"ok": true})
 }
 
 // RevokeShare 取消分享
 func (c *ShareController) RevokeShare(ctx *gin.Context) {
     userID, exists := ctx.Get("userID")
     if !exists {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+        response.Error(ctx, response.ErrUnauthorized, "未授权")
         return
     }
     uuid := ctx.Param("uuid")
+    audit.SetTarget(ctx, audit.TargetShare, uuid, "")
     var share model.Share
     if err := c.DB.Where("uuid = ?", uuid).First(&share).Error; err != nil {
-        ctx.JSON(http.StatusNotFound, gin.H{"error": "分享不存在"})
+        response.Error(ctx, response.ErrShareNotFound, "分享不存在")
         return
     }
     if share.UserID != userID.(uint) {
-        ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限取消该分享"})
+        response.Error(ctx, response.ErrForbidden, "没有权限取消该分享")
         return
     }
     if err := c.DB.Delete(&share).Error; err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "取消分享失败"})
+        logger.Request(ctx).Error("取消分享失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "取消分享失败")
         return
     }
-    ctx.JSON(http.StatusOK, gin.H{"message": "分享已取消"})
+    response.Success(ctx, gin.H{"message": "分享已取消"})
 }
 
 // AccessShare 访问分享（下载或内联预览）
 func (c *ShareController) AccessShare(ctx *gin.Context) {
     uuid := ctx.Param("uuid")
     password := ctx.Query("password")
+    audit.SetTarget(ctx, audit.TargetShare, uuid, "")
     inline := ctx.Query("inline") == "1"
 
     var share model.Share
     if err := c.DB.Where("uuid = ?", uuid).First(&share).Error; err != nil {
-        ctx.JSON(http.StatusNotFound, gin.H{"error": "分享不存在"})
+        response.Error(ctx, response.ErrShareNotFound, "分享不存在")
         return
     }
     if !share.NoExpire && time.Now().After(share.ExpireAt) {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "分享已过期"})
+        response.Error(ctx, response.ErrShareExpired, "分享已过期")
         return
     }
     if share.Password != "" && share.Password != password {
-        ctx.JSON(http.StatusForbidden, gin.H{"error": "密码错误"})
+        response.Error(ctx, response.ErrPasswordIncorrect, "密码错误")
         return
     }
 
     // 目前仅支持文件
     if share.FileID == nil {
-        ctx.JSON(http.StatusNotImplemented, gin.H{"error": "暂未支持目录分享下载"})
+        response.Error(ctx, response.ErrNotImplemented, "暂未支持目录分享下载")
         return
     }
 
     var fileRecord model.File
     if err := c.DB.First(&fileRecord, *share.FileID).Error; err != nil {
-        ctx.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+        response.Error(ctx, response.ErrFileNotFound, "文件不存在")
+        return
+    }
+    if rejectQuarantined(ctx, &fileRecord) {
         return
     }
 
     // 获取文件（使用文件所有者ID）
     f, err := storage.GetFile(fileRecord.UserID, fileRecord.Path)
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取文件失败: " + err.Error()})
+        logger.Request(ctx).Error("获取文件失败: %v", err)
+        response.Error(ctx, response.ErrInternalServer, "获取文件失败")
         return
     }
     defer f.Close()
@@ -332,19 +365,21 @@ func (c *ShareController) AccessShare(ctx *gin.Context) {
     // 增加查看次数
     c.DB.Model(&share).UpdateColumn("view_count", gorm.Expr("view_count + 1"))
 
-    if inline {
+    // HTML、SVG 等可能执行脚本的文件不在线预览
+    if inline && !isActiveFile(&fileRecord) {
         ct := fileRecord.ContentType
         if ct == "" { ct = "application/octet-stream" }
         ctx.Header("Content-Type", ct)
         ctx.Header("Content-Length", fmt.Sprintf("%d", fileRecord.Size))
-        
-        // 直接复制文件内容而不是使用ctx.File，避免自动设置Content-Disposition
-        http.ServeFile(ctx.Writer, ctx.Request, f.Name())
+        ctx.Header("X-Content-Type-Options", "nosniff")
+
+        // 直接输出文件内容而不是使用ctx.File，避免自动设置Content-Disposition
+        http.ServeContent(ctx.Writer, ctx.Request, fileRecord.Name, f.ModTime(), f)
         return
     }
 
     ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileRecord.Name))
     ctx.Header("Content-Type", "application/octet-stream")
     ctx.Header("Content-Length", fmt.Sprintf("%d", fileRecord.Size))
-    ctx.File(f.Name())
+    http.ServeContent(ctx.Writer, ctx.Request, fileRecord.Name, f.ModTime(), f)
 }
\ No newline at end of file
@@ -1,12 +1,13 @@
 package api
 
 import (
-	"net/http"
-	"os"
-	"runtime"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/buildinfo"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
 	"github.com/huanhq99/H-Cloud/internal/storage"
 	"gorm.io/gorm"
 )
@@ -23,25 +24,9 @@ func NewSystemController(db *gorm.DB) *SystemController {
 	}
 }
 
-// GetVersion 获取版本信息
+// GetVersion 获取版本信息，版本号、提交和构建时间在构建时注入
 func (sc *SystemController) GetVersion(c *gin.Context) {
-	buildTime := time.Now().Format("2006-01-02")
-	
-	// 尝试从环境变量获取版本号
-	version := os.Getenv("APP_VERSION")
-	if version == "" {
-		version = "v1.2.4" // 默认版本号
-	}
-	
-	versionInfo := gin.H{
-		"version":    version,
-		"build_time": buildTime,
-		"go_version": runtime.Version(),
-		"os":         runtime.GOOS,
-		"arch":       runtime.GOARCH,
-	}
-	
-	c.JSON(http.StatusOK, versionInfo)
+	response.Success(c, buildinfo.Get())
 }
 
 // GetSystemInfo 获取系统信息
@@ -49,35 +34,42 @@ func (sc *SystemController) GetSystemInfo(ctx *gin.Context) {
 	// 获取存储信息
 	total, used, free, err := storage.GetSystemStorageInfo()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取存储信息失败: " + err.Error(),
-		})
+		logger.Request(ctx).Error("获取存储信息失败: %v", err)
+		response.Error(ctx, response.ErrStorageFailure, "获取存储信息失败")
 		return
 	}
 
-	// 获取用户数量
-	var userCount int64
-	sc.DB.Model(&struct {
-		ID uint `gorm:"primarykey"`
-	}{}).Count(&userCount)
-
-	// 获取文件数量
-	var fileCount int64
-	sc.DB.Table("files").Count(&fileCount)
+	// 获取用户和文件数量（不含已删除的记录）
+	var userCount, fileCount int64
+	if err := sc.DB.Model(&model.User{}).Count(&userCount).Error; err != nil {
+		logger.Request(ctx).Error("统计用户数量失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "获取系统信息失败")
+		return
+	}
+	if err := sc.DB.Model(&model.File{}).Count(&fileCount).Error; err != nil {
+		logger.Request(ctx).Error("统计文件数量失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "获取系统信息失败")
+		return
+	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"version":     "v1.2.3",
-		"build_time":  "2025-01-18",
-		"go_version":  runtime.Version(),
-		"os":          runtime.GOOS,
-		"arch":        runtime.GOARCH,
-		"uptime":      time.Since(time.Now().Add(-time.Hour * 24)).String(), // 示例运行时间
-		"users_count": userCount,
-		"files_count": fileCount,
+	info := buildinfo.Get()
+	uptime := buildinfo.Uptime()
+	response.Success(ctx, gin.H{
+		"version":        info.Version,
+		"commit":         info.Commit,
+		"build_time":     info.BuildTime,
+		"go_version":     info.GoVersion,
+		"os":             info.OS,
+		"arch":           info.Arch,
+		"started_at":     buildinfo.StartTime().Format(time.RFC3339),
+		"uptime":         uptime.Truncate(time.Second).String(),
+		"uptime_seconds": int64(uptime.Seconds()),
+		"users_count":    userCount,
+		"files_count":    fileCount,
 		"storage": gin.H{
 			"total": total,
 			"used":  used,
 			"free":  free,
 		},
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 防暴力破解计数时读取请求体的上限
+const throttleBodyLimit = 64 << 10
+
+// peekJSON 读取 JSON 请求体到 out，并恢复请求体供后续处理函数使用
+func peekJSON(ctx *gin.Context, out interface{}) {
+	if ctx.Request.Body == nil {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, throttleBodyLimit))
+	ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(body, out)
+}
+
+// loginAccount 取出登录请求中的用户名或邮箱，用于按账户计数
+func loginAccount(ctx *gin.Context) string {
+	var req struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	peekJSON(ctx, &req)
+	if req.Username != "" {
+		return strings.ToLower(strings.TrimSpace(req.Username))
+	}
+	return strings.ToLower(strings.TrimSpace(req.Email))
+}
+
+// mfaAccount 按两步登录部分令牌中的用户计数；只接受本服务签发的有效令牌，
+// 否则攻击者可以伪造任意用户 ID 的令牌来锁定他人账户
+func mfaAccount(sessions *SessionManager) func(ctx *gin.Context) string {
+	return func(ctx *gin.Context) string {
+		var req struct {
+			MFAToken string `json:"mfaToken"`
+		}
+		peekJSON(ctx, &req)
+		claims, ok := verifyMFAToken(req.MFAToken, sessions.Secret)
+		if !ok || claims.UserID == 0 {
+			return ""
+		}
+		return fmt.Sprintf("uid:%d", claims.UserID)
+	}
+}
+
+// shareAccount 按分享链接计数，防止猜测分享密码
+func shareAccount(ctx *gin.Context) string {
+	return ctx.Param("uuid")
+}
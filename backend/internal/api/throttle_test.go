@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/huanhq99/H-Cloud/internal/ratelimit"
+	"github.com/huanhq99/H-Cloud/internal/response"
+)
+
+// jsonContext 创建带 JSON 请求体的 gin 上下文
+func jsonContext(body string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	return ctx
+}
+
+func TestLoginAccount(t *testing.T) {
+	tests := []struct {
+		body, want string
+	}{
+		{`{"username":" Alice ","password":"x"}`, "alice"},
+		{`{"email":"Bob@Example.com"}`, "bob@example.com"},
+		{`{"username":"alice","email":"bob@example.com"}`, "alice"},
+		{`{"password":"x"}`, ""},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		ctx := jsonContext(tt.body)
+		if got := loginAccount(ctx); got != tt.want {
+			t.Errorf("%s: 账户应为 %q，实际为 %q", tt.body, tt.want, got)
+		}
+		// 请求体恢复后处理函数仍能读取
+		var req struct {
+			Password string `json:"password"`
+		}
+		ctx.ShouldBindJSON(&req)
+		if strings.Contains(tt.body, `"password":"x"`) && req.Password != "x" {
+			t.Errorf("%s: 请求体未恢复", tt.body)
+		}
+	}
+}
+
+func TestMFAAccount(t *testing.T) {
+	db := newTestDB(t)
+	sessions := newTestSessions(db)
+	auth := &AuthController{DB: db, Sessions: sessions}
+	account := mfaAccount(sessions)
+
+	token, err := auth.mfaToken(42, mfaPurposeLogin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := account(jsonContext(`{"mfaToken":"` + token + `"}`)); got != "uid:42" {
+		t.Errorf("有效令牌应按用户计数，实际为 %q", got)
+	}
+
+	// 伪造的令牌不能用于锁定他人账户
+	claims := &mfaClaims{UserID: 42, Purpose: mfaPurposeLogin, RegisteredClaims: jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{mfaTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}}
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	otherKey, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("another-secret"))
+	for name, forged := range map[string]string{"未签名": unsigned, "其他密钥签名": otherKey, "格式错误": "x.y.z"} {
+		if got := account(jsonContext(`{"mfaToken":"` + forged + `"}`)); got != "" {
+			t.Errorf("%s的令牌不应计入账户，实际为 %q", name, got)
+		}
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	limiter := &ratelimit.Limiter{
+		Store:      ratelimit.NewMemoryStore(),
+		IP:         ratelimit.Policy{Threshold: 100, BaseLockout: time.Minute},
+		Account:    ratelimit.Policy{Threshold: 3, BaseLockout: time.Minute},
+		ResetAfter: time.Hour,
+	}
+	r := gin.New()
+	r.POST("/login", limiter.Middleware("login", loginAccount), func(ctx *gin.Context) {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil || req.Password != "secret" {
+			response.Error(ctx, response.ErrInvalidCredentials, "用户名或密码错误")
+			return
+		}
+		response.Success(ctx, nil)
+	})
+	login := func(username, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"username":"`+username+`","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		expectCode(t, login("alice", "wrong"), response.ErrInvalidCredentials)
+	}
+	// 用户名大小写不同也计入同一账户，锁定后正确的密码同样被拒绝
+	expectCode(t, login("ALICE", "secret"), response.ErrTooManyRequests)
+	expectCode(t, login("bob", "secret"), response.CodeSuccess)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/rbac"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"gorm.io/gorm"
+)
+
+// TokenController 个人访问令牌控制器
+type TokenController struct {
+	DB    *gorm.DB
+	Authz *rbac.Authorizer
+}
+
+// NewTokenController 创建个人访问令牌控制器
+func NewTokenController(db *gorm.DB, authz *rbac.Authorizer) *TokenController {
+	return &TokenController{DB: db, Authz: authz}
+}
+
+// ListTokens 列出当前用户的访问令牌
+func (c *TokenController) ListTokens(ctx *gin.Context) {
+	uidVal, exists := ctx.Get("userID")
+	if !exists {
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
+		return
+	}
+
+	var tokens []model.PersonalAccessToken
+	if err := c.DB.Where("user_id = ?", uidVal.(uint)).Order("created_at desc").Find(&tokens).Error; err != nil {
+		logger.Request(ctx).Error("获取访问令牌失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "获取访问令牌失败")
+		return
+	}
+
+	items := make([]gin.H, 0, len(tokens))
+	for _, t := range tokens {
+		items = append(items, gin.H{
+			"id":         t.ID,
+			"name":       t.Name,
+			"prefix":     t.TokenPrefix,
+			"scopes":     strings.Fields(t.Scopes),
+			"expiresAt":  t.ExpiresAt,
+			"lastUsedAt": t.LastUsedAt,
+			"createdAt":  t.CreatedAt.Format(time.RFC3339),
+			"expired":    t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt),
+		})
+	}
+	response.Success(ctx, gin.H{"tokens": items})
+}
+
+// CreateToken 创建访问令牌，明文仅在创建时返回一次
+func (c *TokenController) CreateToken(ctx *gin.Context) {
+	uidVal, exists := ctx.Get("userID")
+	if !exists {
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
+		return
+	}
+
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expiresInDays"` // 0 表示永不过期
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, response.ErrInvalidRequest, "无效的请求参数")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		response.Error(ctx, response.ErrInvalidRequest, "令牌名称不能为空且不超过64个字符")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		response.Error(ctx, response.ErrInvalidRequest, "至少需要选择一个权限范围")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !hasScope(AllScopes, scope) {
+			response.Error(ctx, response.ErrInvalidRequest, "未知的权限范围: "+scope)
+			return
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		response.Error(ctx, response.ErrInvalidRequest, "无效的有效期")
+		return
+	}
+
+	// 令牌权限不能超过用户自身权限
+	if hasScope(req.Scopes, ScopeAdmin) && !c.Authz.Has(ctx.GetString("role"), rbac.PermAdminAccess) {
+		response.Error(ctx, response.ErrForbidden, "没有权限创建管理员范围的令牌")
+		return
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		logger.Request(ctx).Error("生成令牌失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "生成令牌失败")
+		return
+	}
+	plain := accessTokenPrefix + secret
+
+	token := model.PersonalAccessToken{
+		UserID:      uidVal.(uint),
+		Name:        req.Name,
+		TokenHash:   hashSecret(plain),
+		TokenPrefix: plain[:len(accessTokenPrefix)+6],
+		Scopes:      strings.Join(req.Scopes, " "),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := c.DB.Create(&token).Error; err != nil {
+		logger.Request(ctx).Error("保存令牌失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "保存令牌失败")
+		return
+	}
+	audit.SetTarget(ctx, audit.TargetToken, token.ID, token.Name)
+	audit.Describe(ctx, "权限: "+token.Scopes)
+
+	response.Success(ctx, gin.H{
+		"message":   "令牌已创建，请妥善保存，之后将无法再次查看",
+		"token":     plain,
+		"id":        token.ID,
+		"name":      token.Name,
+		"scopes":    req.Scopes,
+		"expiresAt": token.ExpiresAt,
+	})
+}
+
+// RevokeToken 吊销访问令牌
+func (c *TokenController) RevokeToken(ctx *gin.Context) {
+	uidVal, exists := ctx.Get("userID")
+	if !exists {
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
+		return
+	}
+
+	audit.SetTarget(ctx, audit.TargetToken, ctx.Param("id"), "")
+	result := c.DB.Unscoped().Where("id = ? AND user_id = ?", ctx.Param("id"), uidVal.(uint)).Delete(&model.PersonalAccessToken{})
+	if result.Error != nil {
+		logger.Request(ctx).Error("吊销令牌失败: %v", result.Error)
+		response.Error(ctx, response.ErrInternalServer, "吊销令牌失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(ctx, response.ErrNotFound, "令牌不存在")
+		return
+	}
+	response.Success(ctx, gin.H{"message": "令牌已吊销"})
+}
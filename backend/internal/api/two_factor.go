@@ -0,0 +1,472 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"github.com/huanhq99/H-Cloud/internal/security"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// 两步验证相关常量
+const (
+	totpIssuer        = "H-Cloud"
+	mfaTokenAudience  = "mfa"
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+// 部分令牌用途
+const (
+	mfaPurposeLogin  = "login"  // 已通过密码验证，等待提交验证码
+	mfaPurposeEnroll = "enroll" // 角色要求两步验证但尚未启用，只能用于完成绑定
+)
+
+// mfaClaims 两步登录中的部分令牌，不绑定会话，无法通过 AuthMiddleware
+type mfaClaims struct {
+	UserID  uint   `json:"uid"`
+	Purpose string `json:"mfa"`
+	jwt.RegisteredClaims
+}
+
+// roleRequires2FA 判断角色是否被管理员要求启用两步验证
+func (a *AuthController) roleRequires2FA(role string) bool {
+	return hasScope(getSettingList(a.DB, settingRequire2FARoles), role)
+}
+
+// mfaToken 签发部分令牌
+func (a *AuthController) mfaToken(uid uint, purpose string) (string, error) {
+	now := time.Now()
+	claims := &mfaClaims{
+		UserID:  uid,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{mfaTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(a.Sessions.Secret))
+}
+
+// verifyMFAToken 校验部分令牌的签名、有效期和受众
+func verifyMFAToken(tokenStr, secret string) (*mfaClaims, bool) {
+	token, err := jwt.ParseWithClaims(tokenStr, &mfaClaims{}, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithAudience(mfaTokenAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims, ok := token.Claims.(*mfaClaims)
+	return claims, ok
+}
+
+// parseMFAToken 校验部分令牌及其用途，返回对应用户
+func (a *AuthController) parseMFAToken(tokenStr, purpose string) (*model.User, error) {
+	claims, ok := verifyMFAToken(tokenStr, a.Sessions.Secret)
+	if !ok || claims.Purpose != purpose {
+		return nil, errors.New("验证令牌无效或已过期，请重新登录")
+	}
+	var user model.User
+	if err := a.DB.First(&user, claims.UserID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	return &user, nil
+}
+
+// completeLogin 密码验证通过后的处理：已启用两步验证时返回部分令牌，否则直接签发会话
+func (a *AuthController) completeLogin(ctx *gin.Context, u *model.User) {
+	result, err := a.loginResult(ctx, u)
+	if err != nil {
+		respondLoginError(ctx, err)
+		return
+	}
+	response.Success(ctx, result)
+}
+
+// respondLoginError 输出 loginResult 失败时的响应
+func respondLoginError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errAccountDisabled):
+		response.Error(ctx, response.ErrAccountDisabled, err.Error())
+	case errors.Is(err, errPendingApproval):
+		response.ErrorWithData(ctx, response.ErrPendingApproval, gin.H{"approvalRequired": true}, err.Error())
+	case errors.Is(err, errEmailNotVerified):
+		response.ErrorWithData(ctx, response.ErrEmailNotVerified, gin.H{"emailVerificationRequired": true}, err.Error())
+	default:
+		logger.Request(ctx).Error("生成令牌失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "生成令牌失败")
+	}
+}
+
+// loginResult 生成身份验证通过后的登录结果（正式令牌或两步验证的部分令牌）
+func (a *AuthController) loginResult(ctx *gin.Context, u *model.User) (gin.H, error) {
+	audit.SetActor(ctx, u.ID, u.Username)
+	if u.Disabled {
+		return nil, errAccountDisabled
+	}
+	if u.PendingApproval {
+		return nil, errPendingApproval
+	}
+	if a.Registration.RequireEmailVerification && u.AuthSource == authSourceLocal && u.EmailVerifiedAt == nil {
+		return nil, errEmailNotVerified
+	}
+	if u.TOTPEnabled || a.roleRequires2FA(u.Role) {
+		purpose, key := mfaPurposeLogin, "mfaRequired"
+		if !u.TOTPEnabled {
+			purpose, key = mfaPurposeEnroll, "mfaEnrollRequired"
+		}
+		token, err := a.mfaToken(u.ID, purpose)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{
+			key:         true,
+			"mfaToken":  token,
+			"expiresIn": int64(mfaTokenTTL.Seconds()),
+		}, nil
+	}
+
+	a.DB.Model(u).Update("last_login", time.Now())
+	tokens, err := a.Sessions.Issue(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return loginResponse(tokens, u), nil
+}
+
+// verifyTOTP 校验验证码并记录时间步，同一验证码只能使用一次
+func (a *AuthController) verifyTOTP(u *model.User, code string) bool {
+	step, ok := security.ValidateTOTP(u.TOTPSecret, code, time.Now(), u.TOTPLastStep)
+	if !ok {
+		return false
+	}
+	// 条件更新保证并发提交同一验证码时只有一个成功
+	result := a.DB.Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", u.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	u.TOTPLastStep = step
+	return true
+}
+
+// useRecoveryCode 消耗一个未使用的恢复码
+func (a *AuthController) useRecoveryCode(u *model.User, code string) bool {
+	hash := hashSecret(normalizeRecoveryCode(code))
+	result := a.DB.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", u.ID, hash).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// normalizeRecoveryCode 忽略恢复码中的大小写、空格和分隔符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// generateRecoveryCodes 重新生成恢复码，旧恢复码全部作废，明文只返回一次
+func (a *AuthController) generateRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]model.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomHex(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		records = append(records, model.RecoveryCode{UserID: userID, CodeHash: hashSecret(raw)})
+	}
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// twoFactorUser 获取两步验证设置的操作用户：登录会话，或登录时返回的绑定令牌
+func (a *AuthController) twoFactorUser(ctx *gin.Context, mfaToken string) (*model.User, bool) {
+	if uidVal, exists := ctx.Get("userID"); exists {
+		if ctx.GetString("authMethod") == authMethodToken {
+			response.Error(ctx, response.ErrInsufficientScope, "该操作不支持使用访问令牌")
+			return nil, false
+		}
+		var u model.User
+		if err := a.DB.First(&u, uidVal.(uint)).Error; err != nil {
+			response.Error(ctx, response.ErrUserNotFound, "用户不存在")
+			return nil, false
+		}
+		return &u, true
+	}
+	if mfaToken == "" {
+		response.Error(ctx, response.ErrUnauthorized, "未授权")
+		return nil, false
+	}
+	u, err := a.parseMFAToken(mfaToken, mfaPurposeEnroll)
+	if err != nil {
+		response.Error(ctx, response.ErrTokenInvalid, err.Error())
+		return nil, false
+	}
+	audit.SetActor(ctx, u.ID, u.Username)
+	return u, true
+}
+
+// LoginTwoFactor 两步登录第二步：用部分令牌和验证码（或恢复码）换取正式令牌
+func (a *AuthController) LoginTwoFactor(ctx *gin.Context) {
+	var req struct {
+		MFAToken     string `json:"mfaToken"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		response.Error(ctx, response.ErrInvalidRequest, "缺少验证令牌或验证码")
+		return
+	}
+	u, err := a.parseMFAToken(req.MFAToken, mfaPurposeLogin)
+	if err != nil {
+		response.Error(ctx, response.ErrTokenInvalid, err.Error())
+		return
+	}
+	audit.SetActor(ctx, u.ID, u.Username)
+	if u.Disabled {
+		response.Error(ctx, response.ErrAccountDisabled, errAccountDisabled.Error())
+		return
+	}
+	if !u.TOTPEnabled {
+		response.Error(ctx, response.ErrInvalidRequest, "该账户未启用两步验证")
+		return
+	}
+
+	if req.RecoveryCode != "" {
+		if !a.useRecoveryCode(u, req.RecoveryCode) {
+			response.Error(ctx, response.ErrVerificationFailed, "恢复码无效或已使用")
+			return
+		}
+	} else if !a.verifyTOTP(u, req.Code) {
+		response.Error(ctx, response.ErrVerificationFailed, "验证码错误")
+		return
+	}
+
+	a.DB.Model(u).Update("last_login", time.Now())
+	tokens, err := a.Sessions.Issue(ctx, u)
+	if err != nil {
+		logger.Request(ctx).Error("生成令牌失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "生成令牌失败")
+		return
+	}
+	response.Success(ctx, loginResponse(tokens, u))
+}
+
+// TwoFactorStatus 查询当前用户的两步验证状态
+func (a *AuthController) TwoFactorStatus(ctx *gin.Context) {
+	u, ok := a.twoFactorUser(ctx, "")
+	if !ok {
+		return
+	}
+	var remaining int64
+	a.DB.Model(&model.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", u.ID).Count(&remaining)
+	response.Success(ctx, gin.H{
+		"enabled":                u.TOTPEnabled,
+		"required":               a.roleRequires2FA(u.Role),
+		"recoveryCodesRemaining": remaining,
+	})
+}
+
+// SetupTwoFactor 生成待激活的 TOTP 密钥及二维码地址，提交验证码激活前不生效
+func (a *AuthController) SetupTwoFactor(ctx *gin.Context) {
+	var req struct {
+		MFAToken string `json:"mfaToken"`
+	}
+	_ = ctx.ShouldBindJSON(&req)
+	u, ok := a.twoFactorUser(ctx, req.MFAToken)
+	if !ok {
+		return
+	}
+	if u.TOTPEnabled {
+		response.Error(ctx, response.ErrInvalidRequest, "两步验证已启用")
+		return
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		logger.Request(ctx).Error("生成密钥失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "生成密钥失败")
+		return
+	}
+	if err := a.DB.Model(u).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		logger.Request(ctx).Error("保存密钥失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "保存密钥失败")
+		return
+	}
+	response.Success(ctx, gin.H{
+		"secret":     secret,
+		"otpauthUri": security.TOTPProvisioningURI(totpIssuer, u.Username, secret),
+		"digits":     security.TOTPDigits,
+		"period":     security.TOTPPeriod,
+	})
+}
+
+// ActivateTwoFactor 提交验证码激活两步验证，返回一次性恢复码；
+// 通过绑定令牌激活时同时完成登录
+func (a *AuthController) ActivateTwoFactor(ctx *gin.Context) {
+	var req struct {
+		MFAToken string `json:"mfaToken"`
+		Code     string `json:"code"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "缺少验证码")
+		return
+	}
+	_, viaSession := ctx.Get("userID")
+	u, ok := a.twoFactorUser(ctx, req.MFAToken)
+	if !ok {
+		return
+	}
+	if u.TOTPEnabled {
+		response.Error(ctx, response.ErrInvalidRequest, "两步验证已启用")
+		return
+	}
+	if u.TOTPSecret == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "请先生成两步验证密钥")
+		return
+	}
+	if !a.verifyTOTP(u, req.Code) {
+		response.Error(ctx, response.ErrVerificationFailed, "验证码错误")
+		return
+	}
+
+	if err := a.DB.Model(u).Update("totp_enabled", true).Error; err != nil {
+		logger.Request(ctx).Error("启用两步验证失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "启用两步验证失败")
+		return
+	}
+	codes, err := a.generateRecoveryCodes(u.ID)
+	if err != nil {
+		logger.Request(ctx).Error("生成恢复码失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "生成恢复码失败")
+		return
+	}
+
+	resp := gin.H{
+		"message":       "两步验证已启用，请妥善保存恢复码，之后将无法再次查看",
+		"recoveryCodes": codes,
+	}
+	if !viaSession {
+		if u.Disabled {
+			response.Error(ctx, response.ErrAccountDisabled, errAccountDisabled.Error())
+			return
+		}
+		a.DB.Model(u).Update("last_login", time.Now())
+		tokens, err := a.Sessions.Issue(ctx, u)
+		if err != nil {
+			logger.Request(ctx).Error("生成令牌失败: %v", err)
+			response.Error(ctx, response.ErrInternalServer, "生成令牌失败")
+			return
+		}
+		for k, v := range loginResponse(tokens, u) {
+			resp[k] = v
+		}
+	}
+	response.Success(ctx, resp)
+}
+
+// DisableTwoFactor 关闭两步验证，本地账户需要同时验证密码和验证码；
+// LDAP、OIDC 账户没有本地密码，只验证当前的验证码
+func (a *AuthController) DisableTwoFactor(ctx *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "缺少验证码")
+		return
+	}
+	u, ok := a.twoFactorUser(ctx, "")
+	if !ok {
+		return
+	}
+	local := u.AuthSource == authSourceLocal
+	if local && req.Password == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "需要提供密码和验证码")
+		return
+	}
+	if !u.TOTPEnabled {
+		response.Error(ctx, response.ErrInvalidRequest, "两步验证未启用")
+		return
+	}
+	if a.roleRequires2FA(u.Role) {
+		response.Error(ctx, response.ErrTwoFactorRequired, "管理员要求当前角色必须启用两步验证")
+		return
+	}
+	if local {
+		if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.Password)); err != nil {
+			response.Error(ctx, response.ErrUnauthorized, "密码错误")
+			return
+		}
+	}
+	if !a.verifyTOTP(u, req.Code) {
+		response.Error(ctx, response.ErrVerificationFailed, "验证码错误")
+		return
+	}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(u).Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": ""}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", u.ID).Delete(&model.RecoveryCode{}).Error
+	})
+	if err != nil {
+		logger.Request(ctx).Error("关闭两步验证失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "关闭两步验证失败")
+		return
+	}
+	response.Success(ctx, gin.H{"message": "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，需要验证码确认
+func (a *AuthController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		response.Error(ctx, response.ErrInvalidRequest, "缺少验证码")
+		return
+	}
+	u, ok := a.twoFactorUser(ctx, "")
+	if !ok {
+		return
+	}
+	if !u.TOTPEnabled {
+		response.Error(ctx, response.ErrInvalidRequest, "两步验证未启用")
+		return
+	}
+	if !a.verifyTOTP(u, req.Code) {
+		response.Error(ctx, response.ErrVerificationFailed, "验证码错误")
+		return
+	}
+	codes, err := a.generateRecoveryCodes(u.ID)
+	if err != nil {
+		logger.Request(ctx).Error("生成恢复码失败: %v", err)
+		response.Error(ctx, response.ErrInternalServer, "生成恢复码失败")
+		return
+	}
+	response.Success(ctx, gin.H{
+		"message":       "恢复码已重新生成，旧恢复码全部失效",
+		"recoveryCodes": codes,
+	})
+}
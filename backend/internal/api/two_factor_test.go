@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"github.com/huanhq99/H-Cloud/internal/security"
+)
+
+// twoFactorHarness 两步登录和绑定接口
+type twoFactorHarness struct {
+	auth   *AuthController
+	router *gin.Engine
+	user   model.User
+}
+
+// newTwoFactorHarness 创建已启用两步验证的用户 alice
+func newTwoFactorHarness(t *testing.T) *twoFactorHarness {
+	t.Helper()
+	db := newTestDB(t)
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &twoFactorHarness{
+		auth: &AuthController{DB: db, Sessions: newTestSessions(db)},
+		user: model.User{Username: "alice", Password: "x", Email: "alice@example.com", TOTPSecret: secret, TOTPEnabled: true},
+	}
+	if err := db.Create(&h.user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	h.router = gin.New()
+	h.router.POST("/login/2fa", h.auth.LoginTwoFactor)
+	h.router.POST("/2fa/setup", h.auth.SetupTwoFactor)
+	return h
+}
+
+// post 以 JSON 提交请求
+func (h *twoFactorHarness) post(path string, body gin.H) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.router.ServeHTTP(w, req)
+	return w
+}
+
+// mfaToken 签发 alice 的部分令牌
+func (h *twoFactorHarness) mfaToken(t *testing.T, purpose string) string {
+	t.Helper()
+	token, err := h.auth.mfaToken(h.user.ID, purpose)
+	if err != nil {
+		t.Fatalf("签发部分令牌失败: %v", err)
+	}
+	return token
+}
+
+// login 用验证码或恢复码完成两步登录
+func (h *twoFactorHarness) login(t *testing.T, field, value string) *httptest.ResponseRecorder {
+	t.Helper()
+	return h.post("/login/2fa", gin.H{"mfaToken": h.mfaToken(t, mfaPurposeLogin), field: value})
+}
+
+func TestLoginTwoFactorRejectsReusedCode(t *testing.T) {
+	h := newTwoFactorHarness(t)
+	code, err := security.TOTPCode(h.user.TOTPSecret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := h.login(t, "code", code)
+	expectCode(t, w, response.CodeSuccess)
+	var u model.User
+	h.auth.DB.First(&u, h.user.ID)
+	if u.TOTPLastStep == 0 {
+		t.Fatal("应记录通过验证的时间步")
+	}
+
+	// 同一时间窗口内重放验证码，即使换了新的部分令牌也被拒绝
+	expectCode(t, h.login(t, "code", code), response.ErrVerificationFailed)
+
+	// 时间步早于上次使用的验证码同样被拒绝
+	h.auth.DB.Model(&u).Update("totp_last_step", u.TOTPLastStep+1)
+	next, _ := security.TOTPCode(h.user.TOTPSecret, time.Now().Add(security.TOTPPeriod*time.Second))
+	expectCode(t, h.login(t, "code", next), response.ErrVerificationFailed)
+}
+
+func TestLoginTwoFactorRecoveryCodeSingleUse(t *testing.T) {
+	h := newTwoFactorHarness(t)
+	codes, err := h.auth.generateRecoveryCodes(h.user.ID)
+	if err != nil {
+		t.Fatalf("生成恢复码失败: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("应生成 %d 个恢复码，实际为 %d", recoveryCodeCount, len(codes))
+	}
+
+	// 忽略大小写和分隔符
+	expectCode(t, h.login(t, "recoveryCode", strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))), response.CodeSuccess)
+	expectCode(t, h.login(t, "recoveryCode", codes[0]), response.ErrVerificationFailed)
+	expectCode(t, h.login(t, "recoveryCode", "00000-00000"), response.ErrVerificationFailed)
+
+	var remaining int64
+	h.auth.DB.Model(&model.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", h.user.ID).Count(&remaining)
+	if remaining != recoveryCodeCount-1 {
+		t.Errorf("应剩余 %d 个恢复码，实际为 %d", recoveryCodeCount-1, remaining)
+	}
+
+	// 重新生成后旧恢复码作废
+	if _, err := h.auth.generateRecoveryCodes(h.user.ID); err != nil {
+		t.Fatalf("重新生成恢复码失败: %v", err)
+	}
+	expectCode(t, h.login(t, "recoveryCode", codes[1]), response.ErrVerificationFailed)
+}
+
+func TestMFATokenExpiryAndPurpose(t *testing.T) {
+	h := newTwoFactorHarness(t)
+	h.auth.DB.Model(&h.user).Updates(map[string]interface{}{"totp_enabled": false})
+
+	sign := func(purpose string, expiresAt time.Time) string {
+		claims := &mfaClaims{
+			UserID:  h.user.ID,
+			Purpose: purpose,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{mfaTokenAudience},
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+				IssuedAt:  jwt.NewNumericDate(expiresAt.Add(-mfaTokenTTL)),
+			},
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.auth.Sessions.Secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	// 过期的绑定令牌不能用于绑定
+	w := h.post("/2fa/setup", gin.H{"mfaToken": sign(mfaPurposeEnroll, time.Now().Add(-time.Second))})
+	expectCode(t, w, response.ErrTokenInvalid)
+
+	// 登录用途的令牌不能用于绑定，绑定令牌也不能用于两步登录
+	expectCode(t, h.post("/2fa/setup", gin.H{"mfaToken": h.mfaToken(t, mfaPurposeLogin)}), response.ErrTokenInvalid)
+	w = h.post("/login/2fa", gin.H{"mfaToken": h.mfaToken(t, mfaPurposeEnroll), "code": "123456"})
+	expectCode(t, w, response.ErrTokenInvalid)
+
+	// 有效期内的绑定令牌可以生成密钥
+	w = h.post("/2fa/setup", gin.H{"mfaToken": sign(mfaPurposeEnroll, time.Now().Add(time.Minute))})
+	expectCode(t, w, response.CodeSuccess)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "otpauth://totp/") {
+		t.Errorf("应返回二维码地址: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"github.com/huanhq99/H-Cloud/internal/security"
+	"gorm.io/gorm"
+)
+
+// multipartOverhead 上传请求中 multipart 边界、表单字段等的额外字节数上限
+const multipartOverhead = 1024 * 1024
+
+// requestUploadPolicy 返回当前登录用户适用的上传策略；只有配置了按用户调整时才查询用户名
+func requestUploadPolicy(ctx *gin.Context, db *gorm.DB) *security.UploadPolicy {
+	cfg := config.Current().Upload
+	role, _ := ctx.Get("role")
+	roleName, _ := role.(string)
+	username := ""
+	if userID, ok := ctx.Get("userID"); ok && hasUserOverrides(cfg) {
+		var u model.User
+		if err := db.Select("username").First(&u, userID).Error; err == nil {
+			username = u.Username
+		}
+	}
+	return security.NewUploadPolicy(cfg, roleName, username)
+}
+
+func hasUserOverrides(cfg config.UploadConfig) bool {
+	for _, o := range cfg.Overrides {
+		if len(o.Users) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// limitUploadBody 按策略允许的最大文件限制请求体大小，避免过大的文件先被完整写入临时目录
+func limitUploadBody(ctx *gin.Context, policy *security.UploadPolicy) {
+	if largest := policy.Largest(); largest > 0 {
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, largest+multipartOverhead)
+	}
+}
+
+// isBodyTooLarge 判断读取请求体失败是否因为超过了 limitUploadBody 设置的上限
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// uploadErrorCode 违反上传策略时返回的错误码
+func uploadErrorCode(err error) response.ErrorCode {
+	switch {
+	case errors.Is(err, security.ErrFileTooLarge):
+		return response.ErrFileTooBig
+	case errors.Is(err, security.ErrFileTypeDenied):
+		return response.ErrFileTypeInvalid
+	default:
+		return response.ErrFileInvalid
+	}
+}
+
+// GetUploadPolicy 返回当前用户适用的上传策略，客户端可据此在上传前检查文件
+func (c *FileController) GetUploadPolicy(ctx *gin.Context) {
+	response.Success(ctx, requestUploadPolicy(ctx, c.DB))
+}
+
+// isActiveFile 判断文件是否属于浏览器可能执行的类型（HTML、SVG 等）
+func isActiveFile(f *model.File) bool {
+	return security.IsActiveContent(f.ContentType, config.Current().Upload.ActiveContentTypes)
+}
+
+// setAttachment 以附件形式下载，并禁止浏览器猜测内容类型，避免上传的文件在站点域名下执行脚本
+func setAttachment(ctx *gin.Context, name string) {
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	ctx.Header("X-Content-Type-Options", "nosniff")
+}
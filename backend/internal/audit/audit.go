@@ -0,0 +1,224 @@
+// Package audit 记录安全审计日志。
+//
+// 审计日志只追加、不修改：每个请求结束后由 Middleware 统一写入数据库，
+// 处理函数只需通过 SetActor、SetTarget、Describe 补充操作者、对象和说明；
+// 不在同一请求内完成的事件（如登录锁定）使用 Record 追加额外的记录。
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"gorm.io/gorm"
+)
+
+// 操作结果
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultDenied  = "denied" // 未登录、无权限、被锁定等
+)
+
+// 对象类型
+const (
+	TargetUser      = "user"
+	TargetFile      = "file"
+	TargetDirectory = "directory"
+	TargetShare     = "share"
+	TargetRole      = "role"
+	TargetInvite    = "invite"
+	TargetSession   = "session"
+	TargetToken     = "token"
+)
+
+// 上下文中保存审计信息的键
+const stateKey = "audit"
+
+// Entry 额外追加的审计记录
+type Entry struct {
+	Action  string
+	Result  string
+	Target  Target
+	Details string
+}
+
+// Target 操作对象
+type Target struct {
+	Type string
+	ID   string
+	Name string
+}
+
+// state 当前请求的审计信息
+type state struct {
+	actorID   *uint
+	actorName string
+	target    Target
+	details   []string
+	extra     []Entry
+}
+
+func current(c *gin.Context) *state {
+	if v, ok := c.Get(stateKey); ok {
+		return v.(*state)
+	}
+	s := &state{}
+	c.Set(stateKey, s)
+	return s
+}
+
+// SetActor 设置操作者，用于登录等尚未通过身份验证的请求
+func SetActor(c *gin.Context, id uint, name string) {
+	s := current(c)
+	if id != 0 {
+		s.actorID = &id
+	}
+	s.actorName = name
+}
+
+// SetTarget 设置操作对象
+func SetTarget(c *gin.Context, typ string, id interface{}, name string) {
+	current(c).target = Target{Type: typ, ID: formatID(id), Name: name}
+}
+
+// Describe 补充操作说明，可多次调用
+func Describe(c *gin.Context, details string) {
+	s := current(c)
+	s.details = append(s.details, details)
+}
+
+// Record 追加一条额外的审计记录，随请求一起写入
+func Record(c *gin.Context, e Entry) {
+	s := current(c)
+	s.extra = append(s.extra, e)
+}
+
+// Middleware 在请求结束后写入审计日志。
+// actions 把 "方法 路由" 映射为操作名称：写操作全部记录，未映射的使用方法和路由作为操作名称；
+// 读操作只记录 actions 中列出的（如下载）
+func Middleware(db *gorm.DB, actions map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		action, mapped := actions[c.Request.Method+" "+c.FullPath()]
+		s, _ := c.Get(stateKey)
+		st, _ := s.(*state)
+		if st == nil {
+			st = &state{}
+		}
+
+		var logs []model.AuditLog
+		if (mapped || isMutating(c.Request.Method)) && c.FullPath() != "" {
+			if action == "" {
+				action = c.Request.Method + " " + c.FullPath()
+			}
+			logs = append(logs, newLog(c, st, action, resultOf(c.Writer.Status()), st.target, strings.Join(st.details, "；")))
+		}
+		for _, e := range st.extra {
+			result := e.Result
+			if result == "" {
+				result = ResultSuccess
+			}
+			logs = append(logs, newLog(c, st, e.Action, result, e.Target, e.Details))
+		}
+		if len(logs) == 0 {
+			return
+		}
+		fillActorNames(db, logs)
+		if err := db.Create(&logs).Error; err != nil {
+			logger.Error("写入审计日志失败: %v", err)
+		}
+		for _, l := range logs {
+			entry := logger.With("request_id", l.RequestID, "audit_action", l.Action, "audit_result", l.Result, "actor", l.ActorName)
+			if l.TargetType != "" {
+				entry = entry.With("target", l.TargetType+":"+l.TargetID)
+			}
+			entry.Info("[AUDIT] %s %s %s", l.Action, l.Result, l.Details)
+		}
+	}
+}
+
+// fillActorNames 为只知道用户 ID 的记录补充用户名，便于日后用户被删除后仍能辨认
+func fillActorNames(db *gorm.DB, logs []model.AuditLog) {
+	for i := range logs {
+		if logs[i].ActorName != "" || logs[i].ActorID == nil {
+			continue
+		}
+		var u model.User
+		if err := db.Unscoped().Select("id", "username").First(&u, *logs[i].ActorID).Error; err == nil {
+			for j := i; j < len(logs); j++ {
+				if logs[j].ActorName == "" && logs[j].ActorID != nil && *logs[j].ActorID == u.ID {
+					logs[j].ActorName = u.Username
+				}
+			}
+		}
+	}
+}
+
+// newLog 根据请求上下文生成审计记录
+func newLog(c *gin.Context, st *state, action, result string, target Target, details string) model.AuditLog {
+	l := model.AuditLog{
+		CreatedAt:  time.Now(),
+		RequestID:  logger.GetRequestID(c),
+		Action:     action,
+		Result:     result,
+		Status:     c.Writer.Status(),
+		Method:     c.Request.Method,
+		Path:       c.FullPath(), // 记录路由而不是实际路径，避免删除链接等令牌进入日志
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		TargetType: target.Type,
+		TargetID:   target.ID,
+		TargetName: target.Name,
+		Details:    details,
+		ActorName:  st.actorName,
+	}
+	if id := c.GetUint("userID"); id != 0 {
+		l.ActorID = &id
+	} else {
+		l.ActorID = st.actorID
+	}
+	if id := c.GetUint("impersonatorID"); id != 0 {
+		l.ImpersonatorID = &id
+	}
+	return l
+}
+
+// formatID 把对象 ID 统一转为字符串，零值视为空
+func formatID(id interface{}) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case uint:
+		if v == 0 {
+			return ""
+		}
+	case string:
+		return v
+	}
+	return fmt.Sprint(id)
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// resultOf 根据响应状态码判断操作结果
+func resultOf(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusTooManyRequests:
+		return ResultDenied
+	case status >= 400:
+		return ResultFailure
+	}
+	return ResultSuccess
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"gorm.io/gorm"
+)
+
+// Filter 审计日志查询条件，零值表示不限
+type Filter struct {
+	Action     string // 操作名称，以 . 结尾时按前缀匹配（如 file.）
+	Result     string
+	ActorID    uint
+	Actor      string // 操作者用户名
+	TargetType string
+	TargetID   string
+	IP         string
+	From       time.Time
+	To         time.Time
+}
+
+// Query 按条件筛选审计日志
+func Query(db *gorm.DB, f Filter) *gorm.DB {
+	q := db.Model(&model.AuditLog{})
+	if f.Action != "" {
+		if f.Action[len(f.Action)-1] == '.' {
+			q = q.Where("action LIKE ?", f.Action+"%")
+		} else {
+			q = q.Where("action = ?", f.Action)
+		}
+	}
+	if f.Result != "" {
+		q = q.Where("result = ?", f.Result)
+	}
+	if f.ActorID != 0 {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Actor != "" {
+		q = q.Where("actor_name = ?", f.Actor)
+	}
+	if f.TargetType != "" {
+		q = q.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		q = q.Where("target_id = ?", f.TargetID)
+	}
+	if f.IP != "" {
+		q = q.Where("ip = ?", f.IP)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("created_at < ?", f.To)
+	}
+	return q
+}
+
+// exportBatchSize 导出时每批读取的记录数
+const exportBatchSize = 500
+
+// Export 以 JSON Lines 格式按时间顺序写出符合条件的审计日志，便于导入 SIEM
+func Export(db *gorm.DB, f Filter, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	var batch []model.AuditLog
+	var writeErr error
+	result := Query(db, f).FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if writeErr = enc.Encode(JSON(&batch[i])); writeErr != nil {
+				return writeErr
+			}
+		}
+		return nil
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	return result.Error
+}
+
+// JSON 审计日志的对外格式，查询接口和导出共用
+func JSON(l *model.AuditLog) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             l.ID,
+		"time":           l.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"requestId":      l.RequestID,
+		"action":         l.Action,
+		"result":         l.Result,
+		"status":         l.Status,
+		"actorId":        l.ActorID,
+		"actor":          l.ActorName,
+		"impersonatorId": l.ImpersonatorID,
+		"ip":             l.IP,
+		"userAgent":      l.UserAgent,
+		"method":         l.Method,
+		"path":           l.Path,
+		"targetType":     l.TargetType,
+		"targetId":       l.TargetID,
+		"targetName":     l.TargetName,
+		"details":        l.Details,
+	}
+}
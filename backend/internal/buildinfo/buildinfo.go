@@ -0,0 +1,76 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// 构建信息，发布构建时通过链接参数注入：
+//
+//	go build -ldflags "-X github.com/huanhq99/H-Cloud/internal/buildinfo.Version=v1.3.0 \
+//	  -X github.com/huanhq99/H-Cloud/internal/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/huanhq99/H-Cloud/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 未注入 Commit 时取自 Go 工具链记录的版本控制信息（在 git 仓库中直接 go build 时可用）；
+// BuildTime 只能通过链接参数注入，未注入时为空
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// startTime 进程启动时间
+var startTime = time.Now()
+
+// Info 构建和运行信息
+type Info struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	BuildTime  string `json:"build_time"`
+	CommitTime string `json:"commit_time,omitempty"` // 提交时间，取自版本控制信息
+	Modified   bool   `json:"modified,omitempty"`    // 构建时工作区有未提交的修改
+	GoVersion  string `json:"go_version"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+}
+
+// Get 返回构建信息
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+					if len(info.Commit) > 12 {
+						info.Commit = info.Commit[:12]
+					}
+				}
+			case "vcs.time":
+				info.CommitTime = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// StartTime 返回进程启动时间
+func StartTime() time.Time {
+	return startTime
+}
+
+// Uptime 返回进程已运行的时长
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
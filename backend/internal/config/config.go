@@ -7,16 +7,48 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Storage  StorageConfig  `mapstructure:"storage"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Admin    AdminConfig    `mapstructure:"admin"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	JWT        JWTConfig        `mapstructure:"jwt"`
+	Admin      AdminConfig      `mapstructure:"admin"`
+	ImageHost  ImageHostConfig  `mapstructure:"image_host"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Security   SecurityConfig   `mapstructure:"security"`
+	Mail       MailConfig       `mapstructure:"mail"`
+	Log        LogConfig        `mapstructure:"log"`
+	Metrics    MetricsConfig    `mapstructure:"metrics"`
+	Health     HealthConfig     `mapstructure:"health"`
+	Share      ShareConfig      `mapstructure:"share"`
+	Upload     UploadConfig     `mapstructure:"upload"`
+	Scan       ScanConfig       `mapstructure:"scan"`
+	Encryption EncryptionConfig `mapstructure:"encryption"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port int `mapstructure:"port"`
+	Port           int       `mapstructure:"port"`
+	DevMode        bool      `mapstructure:"dev_mode"`        // 开发模式：不安全的默认配置（如示例 JWT 密钥）只警告，不拒绝启动
+	LegacyResponse bool      `mapstructure:"legacy_response"` // 默认使用旧的响应格式，供尚未迁移到统一响应结构的客户端使用
+	Locale         string    `mapstructure:"locale"`          // 默认语言（zh-CN 或 en-US），用户未设置且请求未携带 Accept-Language 时使用
+	ShutdownDelay  int       `mapstructure:"shutdown_delay"`  // 收到停止信号后、停止接受新连接前的等待时间（秒），留给负载均衡器摘除实例
+	DrainTimeout   int       `mapstructure:"drain_timeout"`   // 等待进行中的请求（上传、下载）完成的最长时间（秒），超时后强制断开
+	TrustedProxies []string  `mapstructure:"trusted_proxies"` // 信任其 X-Forwarded-For、X-Forwarded-Proto 的反向代理地址或网段，为空时使用连接的对端地址
+	TLS            TLSConfig `mapstructure:"tls"`
+}
+
+// TLSConfig HTTPS 配置，启用后 port 为 HTTPS 端口
+type TLSConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	CertFile       string `mapstructure:"cert_file"`       // 证书文件（PEM），可包含中间证书
+	KeyFile        string `mapstructure:"key_file"`        // 私钥文件（PEM）
+	MinVersion     string `mapstructure:"min_version"`     // 最低 TLS 版本：1.2 或 1.3
+	HTTP2          bool   `mapstructure:"http2"`           // 是否启用 HTTP/2
+	ClientAuth     string `mapstructure:"client_auth"`     // 客户端证书认证：none、optional 或 require
+	ClientCAFile   string `mapstructure:"client_ca_file"`  // 签发客户端证书的 CA（PEM）
+	ReloadInterval int    `mapstructure:"reload_interval"` // 检查证书文件是否更新的间隔（秒），0 表示不自动重新加载
+	RedirectHTTP   bool   `mapstructure:"redirect_http"`   // 是否在 http_port 上监听并将请求重定向到 HTTPS
+	HTTPPort       int    `mapstructure:"http_port"`       // HTTP 重定向端口
 }
 
 // DatabaseConfig 数据库配置
@@ -36,14 +68,247 @@ type StorageConfig struct {
 
 // JWTConfig JWT配置
 type JWTConfig struct {
-	Secret    string `mapstructure:"secret"`
-	ExpiresIn int    `mapstructure:"expires_in"` // 过期时间（小时）
+	Secret           string `mapstructure:"secret"`
+	AccessExpiresIn  int    `mapstructure:"access_expires_in"`  // 用户访问令牌过期时间（分钟）
+	RefreshExpiresIn int    `mapstructure:"refresh_expires_in"` // 刷新令牌（会话）过期时间（小时）
 }
 
-// AdminConfig 管理员配置
+// AdminConfig 初始管理员配置，仅在数据库中没有管理员时用于创建账户
 type AdminConfig struct {
 	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	Email    string `mapstructure:"email"`
+	Password string `mapstructure:"password"` // 初始密码，为空时随机生成并写入日志；首次登录后必须修改
+}
+
+// ImageHostConfig 图床配置
+type ImageHostConfig struct {
+	Hotlink          HotlinkConfig   `mapstructure:"hotlink"`
+	Bandwidth        BandwidthConfig `mapstructure:"bandwidth"`
+	PlaceholderImage string          `mapstructure:"placeholder_image"` // 被拦截时返回的占位图片，为空则使用内置透明图片
+	BaseURL          string          `mapstructure:"base_url"`          // 生成图片链接使用的站点地址，为空则根据请求推断
+	UploadDir        string          `mapstructure:"upload_dir"`        // 未指定相册时的默认上传目录
+	Naming           string          `mapstructure:"naming"`            // 默认命名方式：random 或 original
+	ThumbnailPath    string          `mapstructure:"thumbnail_path"`    // 缩略图缓存目录，必须位于存储目录之外
+}
+
+// HotlinkConfig 防盗链配置
+type HotlinkConfig struct {
+	Enabled           bool     `mapstructure:"enabled"`
+	AllowedReferers   []string `mapstructure:"allowed_referers"`    // 允许的来源域名，支持 *.example.com，为空表示只允许本站
+	DeniedReferers    []string `mapstructure:"denied_referers"`     // 拒绝的来源域名，优先于允许列表
+	AllowEmptyReferer bool     `mapstructure:"allow_empty_referer"` // 是否允许没有 Referer 的请求（浏览器直接打开、部分客户端）
+}
+
+// BandwidthConfig 图床流量限制配置（字节/天，0 表示不限制）
+type BandwidthConfig struct {
+	PerFileDaily  int64 `mapstructure:"per_file_daily"`
+	PerOwnerDaily int64 `mapstructure:"per_owner_daily"`
+}
+
+// AuthConfig 身份认证配置
+type AuthConfig struct {
+	OIDC         OIDCConfig         `mapstructure:"oidc"`
+	LDAP         LDAPConfig         `mapstructure:"ldap"`
+	Registration RegistrationConfig `mapstructure:"registration"`
+}
+
+// RegistrationConfig 用户注册配置
+type RegistrationConfig struct {
+	Mode                     string   `mapstructure:"mode"`                       // open、invite、approval 或 disabled
+	AllowedDomains           []string `mapstructure:"allowed_domains"`            // 允许注册的邮箱域名，为空时不限制
+	RequireEmailVerification bool     `mapstructure:"require_email_verification"` // 本地账户验证邮箱后才能登录
+	VerifyTTL                int      `mapstructure:"verify_ttl"`                 // 邮箱验证链接有效期（小时）
+	ResetTTL                 int      `mapstructure:"reset_ttl"`                  // 密码重置链接有效期（分钟）
+}
+
+// 注册模式
+const (
+	RegistrationOpen     = "open"     // 任何人都可以注册
+	RegistrationInvite   = "invite"   // 必须使用邀请码注册
+	RegistrationApproval = "approval" // 注册后需管理员审核，使用邀请码注册的无需审核
+	RegistrationDisabled = "disabled" // 关闭注册
+)
+
+// OIDCConfig OpenID Connect 单点登录配置
+type OIDCConfig struct {
+	Enabled              bool          `mapstructure:"enabled"`
+	Issuer               string        `mapstructure:"issuer"` // 身份提供方地址，用于自动发现 /.well-known/openid-configuration
+	ClientID             string        `mapstructure:"client_id"`
+	ClientSecret         string        `mapstructure:"client_secret"`
+	RedirectURL          string        `mapstructure:"redirect_url"` // 回调地址，需与身份提供方登记的一致，如 https://pan.example.com/api/auth/oidc/callback
+	Scopes               []string      `mapstructure:"scopes"`
+	UsernameClaim        string        `mapstructure:"username_claim"`
+	EmailClaim           string        `mapstructure:"email_claim"`
+	RoleClaim            string        `mapstructure:"role_claim"` // 用于映射角色的声明（字符串或数组），为空则不同步角色
+	RoleMappings         []RoleMapping `mapstructure:"role_mappings"`
+	DefaultRole          string        `mapstructure:"default_role"`           // 没有匹配的映射时使用的角色
+	AutoCreate           bool          `mapstructure:"auto_create"`            // 首次登录时自动创建用户
+	LinkByEmail          bool          `mapstructure:"link_by_email"`          // 按邮箱关联已有账户
+	RequireVerifiedEmail bool          `mapstructure:"require_verified_email"` // 关联已有账户时要求 email_verified 为 true
+	SyncLinkedRoles      bool          `mapstructure:"sync_linked_roles"`      // 按角色声明同步按邮箱关联的已有账户的角色；默认只同步单点登录创建的账户
+	SuccessRedirect      string        `mapstructure:"success_redirect"`       // 登录成功后跳转的前端页面，令牌放在 URL 片段中；为空则直接返回 JSON
+}
+
+// RoleMapping 身份提供方声明值到本地角色的映射
+type RoleMapping struct {
+	Value string `mapstructure:"value"`
+	Role  string `mapstructure:"role"`
+}
+
+// LDAPConfig LDAP 目录认证配置
+type LDAPConfig struct {
+	Enabled            bool           `mapstructure:"enabled"`
+	URL                string         `mapstructure:"url"` // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool           `mapstructure:"start_tls"`
+	InsecureSkipVerify bool           `mapstructure:"insecure_skip_verify"`
+	Timeout            int            `mapstructure:"timeout"` // 连接超时（秒）
+	BindDN             string         `mapstructure:"bind_dn"` // 用于搜索用户的服务账号，为空则匿名搜索
+	BindPassword       string         `mapstructure:"bind_password"`
+	BaseDN             string         `mapstructure:"base_dn"`
+	UserFilter         string         `mapstructure:"user_filter"` // 登录时查找用户的过滤器，%s 替换为转义后的用户名
+	SyncFilter         string         `mapstructure:"sync_filter"` // 同步时列出用户的过滤器，为空则由 user_filter 推导
+	UsernameAttr       string         `mapstructure:"username_attr"`
+	EmailAttr          string         `mapstructure:"email_attr"`
+	GroupAttr          string         `mapstructure:"group_attr"` // 用户所属组的属性，如 memberOf
+	GroupMappings      []GroupMapping `mapstructure:"group_mappings"`
+	DefaultRole        string         `mapstructure:"default_role"`
+	AutoCreate         bool           `mapstructure:"auto_create"`            // 首次登录或同步时自动创建用户
+	SyncInterval       int            `mapstructure:"sync_interval"`          // 同步间隔（分钟），0 表示不定时同步
+	SyncMaxDisable     float64        `mapstructure:"sync_max_disable_ratio"` // 一次同步最多禁用的 LDAP 用户比例，超过时跳过禁用
+}
+
+// GroupMapping LDAP 组到本地角色和存储配额的映射，按顺序取第一个匹配项
+type GroupMapping struct {
+	Group string `mapstructure:"group"` // 组的完整 DN 或 CN
+	Role  string `mapstructure:"role"`
+	Quota int64  `mapstructure:"quota"` // 存储配额（字节），0 表示不修改
+}
+
+// SecurityConfig 安全防护配置
+type SecurityConfig struct {
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+}
+
+// RateLimitConfig 登录及分享密码的防暴力破解配置
+type RateLimitConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Store      string        `mapstructure:"store"`       // 计数存储：memory 或 database（多实例部署时使用）
+	ResetAfter int           `mapstructure:"reset_after"` // 最后一次失败（或锁定结束）后多久清零计数（秒）
+	IP         LockoutPolicy `mapstructure:"ip"`          // 按客户端 IP 计数
+	Account    LockoutPolicy `mapstructure:"account"`     // 按账户（用户名、分享链接）计数
+}
+
+// LockoutPolicy 锁定策略：失败次数达到阈值后锁定，之后每次失败锁定时间翻倍，直到上限
+type LockoutPolicy struct {
+	Threshold   int `mapstructure:"threshold"`
+	BaseLockout int `mapstructure:"base_lockout"` // 首次锁定时长（秒）
+	MaxLockout  int `mapstructure:"max_lockout"`  // 最长锁定时长（秒）
+}
+
+// MailConfig 邮件发送配置
+type MailConfig struct {
+	Transport string     `mapstructure:"transport"` // smtp、file（写入目录，用于测试）或 log（只写日志）
+	From      string     `mapstructure:"from"`      // 发件人，如 "H-Cloud <noreply@example.com>"
+	BaseURL   string     `mapstructure:"base_url"`  // 邮件中链接使用的站点地址，如 https://pan.example.com
+	Dir       string     `mapstructure:"dir"`       // file 方式下保存邮件的目录
+	SMTP      SMTPConfig `mapstructure:"smtp"`
+}
+
+// SMTPConfig SMTP 服务器配置
+type SMTPConfig struct {
+	Host               string `mapstructure:"host"`
+	Port               int    `mapstructure:"port"`
+	Username           string `mapstructure:"username"`
+	Password           string `mapstructure:"password"`
+	Encryption         string `mapstructure:"encryption"` // none、starttls 或 tls（隐式 TLS，通常为 465 端口）
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	Timeout            int    `mapstructure:"timeout"` // 连接超时（秒）
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level      string `mapstructure:"level"`       // debug、info、warn 或 error
+	Format     string `mapstructure:"format"`      // json 或 text
+	File       string `mapstructure:"file"`        // 日志文件路径，为空时输出到标准输出
+	MaxSize    int    `mapstructure:"max_size"`    // 单个日志文件最大大小（MB），0 表示不轮转
+	MaxBackups int    `mapstructure:"max_backups"` // 保留的旧日志文件数量，0 表示不限
+	MaxAge     int    `mapstructure:"max_age"`     // 旧日志文件保留天数，0 表示不限
+	AccessLog  bool   `mapstructure:"access_log"`  // 是否记录访问日志
+}
+
+// MetricsConfig Prometheus 指标配置
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // 是否提供 /metrics 接口并统计请求指标
+	Token   string `mapstructure:"token"`   // 采集令牌，Prometheus 以 Bearer 方式携带；为空时只允许管理员访问
+}
+
+// HealthConfig 就绪检查配置，存储可用空间低于任一阈值时 /readyz 返回 503
+type HealthConfig struct {
+	MinFreeMB      int64   `mapstructure:"min_free_mb"`      // 最小可用空间（MB），0 表示不检查
+	MinFreePercent float64 `mapstructure:"min_free_percent"` // 最小可用空间占比（%），0 表示不检查
+	Timeout        int     `mapstructure:"timeout"`          // 单项检查超时时间（秒）
+}
+
+// ShareConfig 分享策略，修改后无需重启
+type ShareConfig struct {
+	DefaultExpireHours int  `mapstructure:"default_expire_hours"` // 未指定有效期时的默认有效期（小时）
+	MaxExpireDays      int  `mapstructure:"max_expire_days"`      // 最长有效期（天），0 表示不限制
+	AllowPermanent     bool `mapstructure:"allow_permanent"`      // 是否允许创建永久分享
+	RequirePassword    bool `mapstructure:"require_password"`     // 是否要求分享必须设置访问密码
+}
+
+// UploadConfig 上传策略，修改后无需重启。大小单位均为 MB，0 表示不限制
+type UploadConfig struct {
+	MaxSizeMB          int64                     `mapstructure:"max_size_mb"`          // 单个文件的全局上限，优先于分类上限
+	DefaultMaxSizeMB   int64                     `mapstructure:"default_max_size_mb"`  // 不属于任何分类的文件的上限
+	Categories         map[string]UploadCategory `mapstructure:"categories"`           // 文件分类，键为分类名
+	AllowedExtensions  []string                  `mapstructure:"allowed_extensions"`   // 只允许这些扩展名，为空表示不限制
+	DeniedExtensions   []string                  `mapstructure:"denied_extensions"`    // 禁止的扩展名，优先于允许列表和分类
+	AllowedMIMETypes   []string                  `mapstructure:"allowed_mime_types"`   // 只允许这些 MIME 类型，支持 image/* 形式，为空表示不限制
+	DeniedMIMETypes    []string                  `mapstructure:"denied_mime_types"`    // 禁止的 MIME 类型，支持 image/* 形式
+	ContentMismatch    string                    `mapstructure:"content_mismatch"`     // 文件内容与扩展名不符时：reject 拒绝、active 仅拒绝可执行内容、allow 允许
+	ActiveContentTypes []string                  `mapstructure:"active_content_types"` // 浏览器可能执行的内容类型，总是以附件形式下载
+	Overrides          []UploadOverride          `mapstructure:"overrides"`            // 按角色或用户调整策略，按顺序取第一个匹配项
+}
+
+// UploadCategory 文件分类及其大小上限
+type UploadCategory struct {
+	Extensions []string `mapstructure:"extensions"`
+	MaxSizeMB  int64    `mapstructure:"max_size_mb"`
+}
+
+// UploadOverride 针对部分角色或用户的上传策略调整
+type UploadOverride struct {
+	Roles           []string         `mapstructure:"roles"`            // 匹配的角色
+	Users           []string         `mapstructure:"users"`            // 匹配的用户名
+	MaxSizeMB       *int64           `mapstructure:"max_size_mb"`      // 设置时替换全局上限
+	Categories      map[string]int64 `mapstructure:"categories"`       // 替换对应分类的上限（MB）
+	AllowExtensions []string         `mapstructure:"allow_extensions"` // 额外允许的扩展名，同时从禁止列表中移除
+	DenyExtensions  []string         `mapstructure:"deny_extensions"`  // 额外禁止的扩展名
+}
+
+// ScanConfig 上传文件病毒扫描
+type ScanConfig struct {
+	Backend        string      `mapstructure:"backend"` // none 不扫描，clamd 使用 ClamAV
+	Clamd          ClamdConfig `mapstructure:"clamd"`
+	Timeout        int         `mapstructure:"timeout"`         // 单个文件的扫描超时（秒）
+	MaxSizeMB      int64       `mapstructure:"max_size_mb"`     // 超过该大小的文件不扫描，应不大于 clamd 的 StreamMaxLength；0 表示都扫描
+	OnError        string      `mapstructure:"on_error"`        // 扫描失败（如 clamd 不可用）时：reject 拒绝上传，allow 允许并记录日志
+	QuarantinePath string      `mapstructure:"quarantine_path"` // 隔离区目录，必须位于存储目录之外
+}
+
+// ClamdConfig ClamAV 守护进程配置
+type ClamdConfig struct {
+	Address string `mapstructure:"address"` // tcp://127.0.0.1:3310 或 unix:///run/clamav/clamd.ctl
+}
+
+// EncryptionConfig 存储文件的静态加密，每个文件使用单独的数据密钥，数据密钥由主密钥包装后保存在文件头中
+type EncryptionConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`       // 新保存的文件是否加密；关闭后只要保留主密钥，已加密的文件仍可读取
+	Key          string   `mapstructure:"key"`           // 主密钥，32 字节的 base64 或十六进制编码，与 key_file 二选一
+	KeyFile      string   `mapstructure:"key_file"`      // 主密钥文件，每行一个密钥，第一行为当前主密钥，其余为轮换前的旧主密钥
+	PreviousKeys []string `mapstructure:"previous_keys"` // 轮换前的旧主密钥，只用于读取尚未重新包装的文件
+	ChunkSize    int      `mapstructure:"chunk_size"`    // 分块大小（KB），每块单独加密，按范围读取时只解密涉及的块
 }
 
 // LoadConfig 加载配置
@@ -73,6 +338,8 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	setCurrent(&config)
+	lastSeen = Flatten(&config, false)
 	return &config, nil
 }
 
@@ -80,6 +347,22 @@ func LoadConfig() (*Config, error) {
 func setDefaults() {
 	// 服务器默认配置
 	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.legacy_response", false)
+	viper.SetDefault("server.locale", "zh-CN")
+	viper.SetDefault("server.shutdown_delay", 5)
+	viper.SetDefault("server.drain_timeout", 60)
+	viper.SetDefault("server.trusted_proxies", []string{})
+	viper.SetDefault("server.dev_mode", false)
+	viper.SetDefault("server.tls.enabled", false)
+	viper.SetDefault("server.tls.cert_file", "")
+	viper.SetDefault("server.tls.key_file", "")
+	viper.SetDefault("server.tls.min_version", "1.2")
+	viper.SetDefault("server.tls.http2", true)
+	viper.SetDefault("server.tls.client_auth", "none")
+	viper.SetDefault("server.tls.client_ca_file", "")
+	viper.SetDefault("server.tls.reload_interval", 60)
+	viper.SetDefault("server.tls.redirect_http", false)
+	viper.SetDefault("server.tls.http_port", 80)
 
 	// 数据库默认配置
 	viper.SetDefault("database.host", "mysql")
@@ -94,9 +377,144 @@ func setDefaults() {
 
 	// JWT默认配置
 	viper.SetDefault("jwt.secret", "hqyun_secret_key")
-	viper.SetDefault("jwt.expires_in", 24) // 24小时
+	viper.SetDefault("jwt.access_expires_in", 15)   // 15分钟
+	viper.SetDefault("jwt.refresh_expires_in", 720) // 30天
 
-	// 管理员默认配置
+	// 初始管理员默认配置（密码为空时随机生成）
 	viper.SetDefault("admin.username", "admin")
-	viper.SetDefault("admin.password", "password")
+	viper.SetDefault("admin.email", "admin@localhost")
+	viper.SetDefault("admin.password", "")
+
+	// 图床默认配置
+	viper.SetDefault("image_host.hotlink.enabled", false)
+	viper.SetDefault("image_host.hotlink.allowed_referers", []string{})
+	viper.SetDefault("image_host.hotlink.denied_referers", []string{})
+	viper.SetDefault("image_host.hotlink.allow_empty_referer", true)
+	viper.SetDefault("image_host.bandwidth.per_file_daily", 0)
+	viper.SetDefault("image_host.bandwidth.per_owner_daily", 0)
+	viper.SetDefault("image_host.placeholder_image", "")
+	viper.SetDefault("image_host.base_url", "")
+	viper.SetDefault("image_host.upload_dir", "images")
+	viper.SetDefault("image_host.naming", "random")
+	viper.SetDefault("image_host.thumbnail_path", "./cache/thumbnails")
+
+	// OIDC 单点登录默认配置
+	viper.SetDefault("auth.oidc.enabled", false)
+	viper.SetDefault("auth.oidc.scopes", []string{"openid", "profile", "email"})
+	viper.SetDefault("auth.oidc.username_claim", "preferred_username")
+	viper.SetDefault("auth.oidc.email_claim", "email")
+	viper.SetDefault("auth.oidc.role_claim", "")
+	viper.SetDefault("auth.oidc.default_role", "user")
+	viper.SetDefault("auth.oidc.auto_create", true)
+	viper.SetDefault("auth.oidc.link_by_email", true)
+	viper.SetDefault("auth.oidc.require_verified_email", true)
+	viper.SetDefault("auth.oidc.sync_linked_roles", false)
+	viper.SetDefault("auth.oidc.success_redirect", "")
+
+	// LDAP 默认配置
+	viper.SetDefault("auth.ldap.enabled", false)
+	viper.SetDefault("auth.ldap.timeout", 10)
+	viper.SetDefault("auth.ldap.user_filter", "(&(objectClass=person)(uid=%s))")
+	viper.SetDefault("auth.ldap.sync_filter", "")
+	viper.SetDefault("auth.ldap.username_attr", "uid")
+	viper.SetDefault("auth.ldap.email_attr", "mail")
+	viper.SetDefault("auth.ldap.group_attr", "memberOf")
+	viper.SetDefault("auth.ldap.default_role", "user")
+	viper.SetDefault("auth.ldap.auto_create", true)
+	viper.SetDefault("auth.ldap.sync_interval", 60)
+	viper.SetDefault("auth.ldap.sync_max_disable_ratio", 0.2)
+
+	// 注册默认配置
+	viper.SetDefault("auth.registration.mode", RegistrationOpen)
+	viper.SetDefault("auth.registration.allowed_domains", []string{})
+	viper.SetDefault("auth.registration.require_email_verification", false)
+	viper.SetDefault("auth.registration.verify_ttl", 24)
+	viper.SetDefault("auth.registration.reset_ttl", 60)
+
+	// 邮件默认配置
+	viper.SetDefault("mail.transport", "log")
+	viper.SetDefault("mail.from", "H-Cloud <noreply@localhost>")
+	viper.SetDefault("mail.base_url", "")
+	viper.SetDefault("mail.dir", "./mail")
+	viper.SetDefault("mail.smtp.host", "")
+	viper.SetDefault("mail.smtp.port", 587)
+	viper.SetDefault("mail.smtp.username", "")
+	viper.SetDefault("mail.smtp.password", "")
+	viper.SetDefault("mail.smtp.encryption", "starttls")
+	viper.SetDefault("mail.smtp.insecure_skip_verify", false)
+	viper.SetDefault("mail.smtp.timeout", 10)
+
+	// 日志默认配置
+	viper.SetDefault("log.level", "info")
+	viper.SetDefault("log.format", "json")
+	viper.SetDefault("log.file", "")
+	viper.SetDefault("log.max_size", 100)
+	viper.SetDefault("log.max_backups", 7)
+	viper.SetDefault("log.max_age", 30)
+	viper.SetDefault("log.access_log", true)
+
+	// 指标默认配置
+	viper.SetDefault("metrics.enabled", true)
+	viper.SetDefault("metrics.token", "")
+
+	// 就绪检查默认配置
+	viper.SetDefault("health.min_free_mb", 512)
+	viper.SetDefault("health.min_free_percent", 1)
+	viper.SetDefault("health.timeout", 3)
+
+	// 分享默认配置
+	viper.SetDefault("share.default_expire_hours", 24)
+	viper.SetDefault("share.max_expire_days", 0)
+	viper.SetDefault("share.allow_permanent", true)
+	viper.SetDefault("share.require_password", false)
+
+	// 上传策略默认配置
+	viper.SetDefault("upload.max_size_mb", 0)
+	viper.SetDefault("upload.default_max_size_mb", 20)
+	viper.SetDefault("upload.categories", map[string]any{
+		"image":    map[string]any{"max_size_mb": 10, "extensions": []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp", ".svg"}},
+		"document": map[string]any{"max_size_mb": 50, "extensions": []string{".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".txt", ".md", ".rtf"}},
+		"video":    map[string]any{"max_size_mb": 500, "extensions": []string{".mp4", ".avi", ".mkv", ".mov", ".wmv", ".flv", ".webm"}},
+		"audio":    map[string]any{"max_size_mb": 100, "extensions": []string{".mp3", ".wav", ".flac", ".aac", ".ogg", ".wma"}},
+		"archive":  map[string]any{"max_size_mb": 100, "extensions": []string{".zip", ".rar", ".7z", ".tar", ".gz", ".bz2"}},
+		"code":     map[string]any{"max_size_mb": 5, "extensions": []string{".go", ".html", ".css", ".json", ".xml", ".yaml", ".yml", ".sql"}},
+	})
+	viper.SetDefault("upload.allowed_extensions", []string{})
+	viper.SetDefault("upload.denied_extensions", []string{
+		".exe", ".bat", ".cmd", ".com", ".pif", ".scr", ".vbs", ".js", ".jar",
+		".sh", ".php", ".asp", ".aspx", ".jsp", ".py", ".rb", ".pl",
+	})
+	viper.SetDefault("upload.allowed_mime_types", []string{})
+	viper.SetDefault("upload.denied_mime_types", []string{})
+	viper.SetDefault("upload.content_mismatch", "reject")
+	viper.SetDefault("upload.active_content_types", []string{
+		"text/html", "application/xhtml+xml", "image/svg+xml", "text/xml", "application/xml",
+		"text/javascript", "application/javascript", "application/x-shockwave-flash",
+	})
+	viper.SetDefault("upload.overrides", []map[string]any{})
+
+	// 病毒扫描默认配置
+	viper.SetDefault("scan.backend", "none")
+	viper.SetDefault("scan.clamd.address", "tcp://127.0.0.1:3310")
+	viper.SetDefault("scan.timeout", 60)
+	viper.SetDefault("scan.max_size_mb", 25)
+	viper.SetDefault("scan.on_error", "reject")
+	viper.SetDefault("scan.quarantine_path", "./quarantine")
+
+	// 静态加密默认配置
+	viper.SetDefault("encryption.enabled", false)
+	viper.SetDefault("encryption.key", "")
+	viper.SetDefault("encryption.key_file", "")
+	viper.SetDefault("encryption.chunk_size", 64)
+
+	// 防暴力破解默认配置
+	viper.SetDefault("security.rate_limit.enabled", true)
+	viper.SetDefault("security.rate_limit.store", "memory")
+	viper.SetDefault("security.rate_limit.reset_after", 3600)
+	viper.SetDefault("security.rate_limit.ip.threshold", 20)
+	viper.SetDefault("security.rate_limit.ip.base_lockout", 60)
+	viper.SetDefault("security.rate_limit.ip.max_lockout", 3600)
+	viper.SetDefault("security.rate_limit.account.threshold", 5)
+	viper.SetDefault("security.rate_limit.account.base_lockout", 60)
+	viper.SetDefault("security.rate_limit.account.max_lockout", 3600)
 }
\ No newline at end of file
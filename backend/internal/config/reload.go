@@ -0,0 +1,215 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/spf13/viper"
+)
+
+// Reloadable 修改配置文件后无需重启即可生效的设置（键或键前缀），其余设置修改后需要重启
+var Reloadable = []string{
+	"log.level",
+	"security.rate_limit.reset_after",
+	"security.rate_limit.ip.",
+	"security.rate_limit.account.",
+	"share.",
+	"upload.",
+}
+
+// secretKeys 输出配置时需要隐藏的键（最后一级）
+var secretKeys = []string{"secret", "password", "bind_password", "client_secret", "token", "key", "previous_keys"}
+
+var (
+	current  atomic.Pointer[Config]
+	loadedAt atomic.Pointer[time.Time]
+
+	reloadMu    sync.Mutex
+	reloadHooks []func(*Config)
+	lastSeen    map[string]any // 最近一次读到的配置文件内容，用于忽略重复的修改事件
+)
+
+// setCurrent 设置当前生效的配置
+func setCurrent(cfg *Config) {
+	now := time.Now()
+	current.Store(cfg)
+	loadedAt.Store(&now)
+}
+
+// Current 返回当前生效的配置。配置文件修改后会被替换，因此只应在每次使用时读取支持热加载的设置
+func Current() *Config {
+	return current.Load()
+}
+
+// LoadedAt 返回当前配置的加载时间
+func LoadedAt() time.Time {
+	if t := loadedAt.Load(); t != nil {
+		return *t
+	}
+	return time.Time{}
+}
+
+// File 返回使用的配置文件路径，未找到配置文件时为空
+func File() string {
+	return viper.ConfigFileUsed()
+}
+
+// OnReload 注册配置重新加载后的回调，缓存了可热加载设置的组件通过它更新自身
+func OnReload(fn func(*Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloadHooks = append(reloadHooks, fn)
+}
+
+// Watch 监听配置文件，修改后重新加载：新配置校验失败时保留原配置；
+// 可热加载的设置立即生效，其余设置的修改记录警告，重启后生效
+func Watch() {
+	if viper.ConfigFileUsed() == "" {
+		logger.Info("未使用配置文件，不监听配置修改")
+		return
+	}
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		// viper 读取失败时只写入自己的日志并保留原内容，这里再读一次以记录错误
+		if err := viper.ReadInConfig(); err != nil {
+			logger.Error("读取配置文件失败，继续使用原配置: %v", err)
+			return
+		}
+		reload()
+	})
+	viper.WatchConfig()
+	logger.Info("监听配置文件 %s，修改后自动重新加载", viper.ConfigFileUsed())
+}
+
+// reload 从 viper 重新读取配置并应用
+func reload() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	var next Config
+	if err := viper.Unmarshal(&next); err != nil {
+		logger.Error("重新加载配置失败: %v", err)
+		return
+	}
+	// 编辑器保存时可能触发多次事件
+	seen := Flatten(&next, false)
+	if reflect.DeepEqual(seen, lastSeen) {
+		return
+	}
+	lastSeen = seen
+	prev := Current()
+	changed := diff(Flatten(prev, false), seen)
+	if len(changed) == 0 {
+		return
+	}
+	warnings, err := next.Validate()
+	if err != nil {
+		logger.Error("配置文件有误，继续使用原配置: %v", err)
+		return
+	}
+	for _, w := range warnings {
+		logger.Warn("配置警告: %s", w)
+	}
+
+	// 需要重启的设置保持原值，Current 返回的配置与实际运行状态一致
+	var applied, pending []string
+	merged := *prev
+	for _, key := range changed {
+		if isReloadable(key) {
+			applied = append(applied, key)
+		} else {
+			pending = append(pending, key)
+		}
+	}
+	copyReloadable(&merged, &next)
+
+	setCurrent(&merged)
+	for _, fn := range reloadHooks {
+		fn(&merged)
+	}
+	if len(applied) > 0 {
+		logger.Info("配置已重新加载，已生效: %s", strings.Join(applied, ", "))
+	}
+	if len(pending) > 0 {
+		logger.Warn("以下配置修改需要重启后生效: %s", strings.Join(pending, ", "))
+	}
+}
+
+// copyReloadable 将可热加载的设置从 src 复制到 dst
+func copyReloadable(dst, src *Config) {
+	dst.Log.Level = src.Log.Level
+	dst.Security.RateLimit.ResetAfter = src.Security.RateLimit.ResetAfter
+	dst.Security.RateLimit.IP = src.Security.RateLimit.IP
+	dst.Security.RateLimit.Account = src.Security.RateLimit.Account
+	dst.Share = src.Share
+	dst.Upload = src.Upload
+}
+
+// isReloadable 判断键是否支持热加载
+func isReloadable(key string) bool {
+	for _, r := range Reloadable {
+		if key == r || (strings.HasSuffix(r, ".") && strings.HasPrefix(key, r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// diff 返回两份扁平配置中值不同的键
+func diff(a, b map[string]any) []string {
+	var keys []string
+	for k, v := range b {
+		if !reflect.DeepEqual(a[k], v) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Flatten 将配置展开为以点分隔的键（与配置文件中的键一致）到值的映射；
+// redact 为 true 时隐藏密钥、密码等敏感值，未设置的敏感值保留为空字符串
+func Flatten(cfg *Config, redact bool) map[string]any {
+	out := map[string]any{}
+	flatten(out, "", reflect.ValueOf(cfg).Elem(), redact)
+	return out
+}
+
+func flatten(out map[string]any, prefix string, v reflect.Value, redact bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" {
+			continue
+		}
+		key := prefix + name
+		f := v.Field(i)
+		if f.Kind() == reflect.Struct {
+			flatten(out, key+".", f, redact)
+			continue
+		}
+		if redact && contains(secretKeys, name) && f.Kind() == reflect.String && f.String() != "" {
+			out[key] = "******"
+			continue
+		}
+		if redact && contains(secretKeys, name) && f.Kind() == reflect.Slice {
+			masked := make([]string, f.Len())
+			for i := range masked {
+				masked[i] = "******"
+			}
+			out[key] = masked
+			continue
+		}
+		out[key] = f.Interface()
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// minSecretLength JWT 密钥的最小长度
+const minSecretLength = 32
+
+// insecureSecrets 默认配置、示例配置和文档中出现过的 JWT 密钥
+var insecureSecrets = []string{
+	"hqyun_secret_key",
+	"hyun_disk_secret_key",
+	"your_jwt_secret_key",
+	"your_jwt_secret_key_here",
+	"your_jwt_secret_key_here_at_least_32_characters",
+	"your_jwt_secret_key_at_least_32_characters",
+	"your_super_secret_jwt_key_at_least_32_characters_long",
+	"your_super_secret_jwt_key_change_in_production",
+}
+
+// insecurePasswords 默认配置和示例配置中的密码以及常见弱密码
+var insecurePasswords = []string{
+	"password", "admin", "admin123", "123456", "12345678",
+	"your_secure_password", "your_secure_password_here", "hqyun_password",
+}
+
+// Validate 检查配置：端口、路径和枚举值有误时返回错误；
+// 使用默认或示例中的密钥、密码时非开发模式下返回错误，开发模式下作为警告返回
+func (c *Config) Validate() (warnings []string, err error) {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	insecure := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		if c.Server.DevMode {
+			warnings = append(warnings, msg)
+		} else {
+			errs = append(errs, fmt.Errorf("%s（开发环境可设置 server.dev_mode: true 跳过）", msg))
+		}
+	}
+
+	// 不安全的默认值
+	if secret := c.JWT.Secret; secret == "" {
+		insecure("未配置 jwt.secret")
+	} else if contains(insecureSecrets, secret) {
+		insecure("jwt.secret 使用了默认或示例中的密钥，请设置为随机字符串（如 openssl rand -hex 32）")
+	} else if len(secret) < minSecretLength {
+		insecure("jwt.secret 长度不能少于 %d 个字符", minSecretLength)
+	}
+	if p := c.Admin.Password; p != "" && (contains(insecurePasswords, strings.ToLower(p)) || len(p) < 8) {
+		insecure("admin.password 过于简单，请修改或留空以随机生成")
+	}
+	if c.Database.Host != "sqlite" && contains(insecurePasswords, c.Database.Password) {
+		insecure("database.password 使用了默认或示例中的密码")
+	}
+
+	// 端口
+	checkPort := func(key string, port int) {
+		if port < 1 || port > 65535 {
+			invalid("%s 必须在 1-65535 之间，当前为 %d", key, port)
+		}
+	}
+	checkPort("server.port", c.Server.Port)
+	if c.Server.TLS.Enabled && c.Server.TLS.RedirectHTTP {
+		checkPort("server.tls.http_port", c.Server.TLS.HTTPPort)
+		if c.Server.TLS.HTTPPort == c.Server.Port {
+			invalid("server.tls.http_port 不能与 server.port 相同")
+		}
+	}
+	if c.Server.ShutdownDelay < 0 || c.Server.DrainTimeout < 0 {
+		invalid("server.shutdown_delay 和 server.drain_timeout 不能为负数")
+	}
+
+	// 路径
+	checkDir := func(key, path string) {
+		if path == "" {
+			invalid("%s 不能为空", key)
+			return
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			invalid("%s（%s）不是目录", key, path)
+		}
+	}
+	checkFile := func(key, path string) {
+		if info, err := os.Stat(path); err != nil {
+			invalid("%s（%s）无法读取: %v", key, path, err)
+		} else if info.IsDir() {
+			invalid("%s（%s）是目录", key, path)
+		}
+	}
+	checkDir("storage.path", c.Storage.Path)
+	checkDir("storage.mapped_path", c.Storage.MappedPath)
+	if c.Log.File != "" {
+		if info, err := os.Stat(c.Log.File); err == nil && info.IsDir() {
+			invalid("log.file（%s）是目录", c.Log.File)
+		}
+		if info, err := os.Stat(filepath.Dir(c.Log.File)); err == nil && !info.IsDir() {
+			invalid("log.file 所在的 %s 不是目录", filepath.Dir(c.Log.File))
+		}
+	}
+	if c.Mail.Transport == "file" {
+		checkDir("mail.dir", c.Mail.Dir)
+	}
+	if c.ImageHost.PlaceholderImage != "" {
+		checkFile("image_host.placeholder_image", c.ImageHost.PlaceholderImage)
+	}
+	checkDir("image_host.thumbnail_path", c.ImageHost.ThumbnailPath)
+	if within(c.ImageHost.ThumbnailPath, c.Storage.Path) {
+		invalid("image_host.thumbnail_path 不能位于 storage.path 之内，否则缓存的缩略图会出现在文件列表中")
+	}
+	if c.Scan.Backend != "none" && c.Scan.Backend != "" {
+		checkDir("scan.quarantine_path", c.Scan.QuarantinePath)
+		if within(c.Scan.QuarantinePath, c.Storage.Path) {
+			invalid("scan.quarantine_path 不能位于 storage.path 之内，否则隔离的文件仍可被列出和访问")
+		}
+	}
+	if c.Encryption.KeyFile != "" {
+		checkFile("encryption.key_file", c.Encryption.KeyFile)
+	}
+	if tc := c.Server.TLS; tc.Enabled {
+		checkFile("server.tls.cert_file", tc.CertFile)
+		checkFile("server.tls.key_file", tc.KeyFile)
+		if tc.ClientCAFile != "" {
+			checkFile("server.tls.client_ca_file", tc.ClientCAFile)
+		}
+		if tc.ClientAuth != "none" && tc.ClientAuth != "" && tc.ClientCAFile == "" {
+			invalid("server.tls.client_auth 为 %s 时必须配置 client_ca_file", tc.ClientAuth)
+		}
+	}
+
+	// 枚举值
+	checkOneOf := func(key, value string, allowed ...string) {
+		if !contains(allowed, value) {
+			invalid("%s 不支持 %q，可选 %s", key, value, strings.Join(allowed, "、"))
+		}
+	}
+	checkOneOf("log.level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "warning", "error")
+	checkOneOf("log.format", strings.ToLower(c.Log.Format), "json", "text")
+	checkOneOf("auth.registration.mode", c.Auth.Registration.Mode, RegistrationOpen, RegistrationInvite, RegistrationApproval, RegistrationDisabled)
+	checkOneOf("security.rate_limit.store", c.Security.RateLimit.Store, "memory", "database")
+	checkOneOf("mail.transport", c.Mail.Transport, "smtp", "file", "log")
+	if c.Mail.Transport == "smtp" {
+		checkOneOf("mail.smtp.encryption", c.Mail.SMTP.Encryption, "none", "starttls", "tls")
+	}
+	checkOneOf("image_host.naming", c.ImageHost.Naming, "random", "original")
+	checkOneOf("upload.content_mismatch", c.Upload.ContentMismatch, "reject", "active", "allow")
+	checkOneOf("scan.backend", c.Scan.Backend, "none", "clamd")
+	checkOneOf("scan.on_error", c.Scan.OnError, "reject", "allow")
+	if c.Scan.Backend == "clamd" {
+		if addr := c.Scan.Clamd.Address; addr == "" {
+			invalid("scan.backend 为 clamd 时必须配置 scan.clamd.address")
+		} else if strings.Contains(addr, "://") && !strings.HasPrefix(addr, "tcp://") && !strings.HasPrefix(addr, "unix://") {
+			invalid("scan.clamd.address 只支持 tcp:// 和 unix:// 地址")
+		}
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			invalid("server.trusted_proxies 中的 %s 不是有效的 IP 地址或网段", proxy)
+		}
+	}
+	if c.Server.TLS.Enabled {
+		checkOneOf("server.tls.min_version", c.Server.TLS.MinVersion, "1.2", "1.3")
+		checkOneOf("server.tls.client_auth", c.Server.TLS.ClientAuth, "none", "optional", "require")
+	}
+
+	// 数值
+	if c.JWT.AccessExpiresIn <= 0 || c.JWT.RefreshExpiresIn <= 0 {
+		invalid("jwt.access_expires_in 和 jwt.refresh_expires_in 必须大于 0")
+	}
+	if c.Share.DefaultExpireHours <= 0 {
+		invalid("share.default_expire_hours 必须大于 0")
+	}
+	if c.Share.MaxExpireDays < 0 {
+		invalid("share.max_expire_days 不能为负数")
+	} else if c.Share.MaxExpireDays > 0 && c.Share.DefaultExpireHours > c.Share.MaxExpireDays*24 {
+		invalid("share.default_expire_hours 超过了 share.max_expire_days")
+	}
+	if c.Auth.LDAP.Enabled && (c.Auth.LDAP.SyncMaxDisable <= 0 || c.Auth.LDAP.SyncMaxDisable > 1) {
+		invalid("auth.ldap.sync_max_disable_ratio 必须在 (0, 1] 之间")
+	}
+	if c.Scan.Timeout <= 0 {
+		invalid("scan.timeout 必须大于 0")
+	}
+	if c.Scan.MaxSizeMB < 0 {
+		invalid("scan.max_size_mb 不能为负数")
+	}
+	if c.Encryption.Enabled && c.Encryption.Key == "" && c.Encryption.KeyFile == "" {
+		invalid("encryption.enabled 为 true 时必须配置 encryption.key 或 encryption.key_file")
+	}
+	if c.Encryption.Key != "" && c.Encryption.KeyFile != "" {
+		invalid("encryption.key 和 encryption.key_file 只能配置一个")
+	}
+	if c.Encryption.ChunkSize < 1 || c.Encryption.ChunkSize > 4096 {
+		invalid("encryption.chunk_size 必须在 1-4096（KB）之间")
+	}
+	checkUpload(&c.Upload, invalid)
+
+	return warnings, errors.Join(errs...)
+}
+
+// checkUpload 检查上传策略：大小不能为负数，分类中的扩展名不能同时出现在禁止列表中
+func checkUpload(u *UploadConfig, invalid func(string, ...any)) {
+	checkSize := func(key string, size int64) {
+		if size < 0 {
+			invalid("%s 不能为负数", key)
+		}
+	}
+	checkSize("upload.max_size_mb", u.MaxSizeMB)
+	checkSize("upload.default_max_size_mb", u.DefaultMaxSizeMB)
+
+	denied := map[string]bool{}
+	for _, ext := range u.DeniedExtensions {
+		denied[normalizeExtension(ext)] = true
+	}
+	names := make([]string, 0, len(u.Categories))
+	for name := range u.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		category := u.Categories[name]
+		checkSize("upload.categories."+name+".max_size_mb", category.MaxSizeMB)
+		for _, ext := range category.Extensions {
+			if denied[normalizeExtension(ext)] {
+				invalid("upload.categories.%s 中的 %s 同时出现在 upload.denied_extensions 中", name, ext)
+			}
+		}
+	}
+
+	for i, o := range u.Overrides {
+		if len(o.Roles) == 0 && len(o.Users) == 0 {
+			invalid("upload.overrides[%d] 必须指定 roles 或 users", i)
+		}
+		if o.MaxSizeMB != nil {
+			checkSize(fmt.Sprintf("upload.overrides[%d].max_size_mb", i), *o.MaxSizeMB)
+		}
+		for name, size := range o.Categories {
+			if _, ok := u.Categories[name]; !ok {
+				invalid("upload.overrides[%d].categories 中的分类 %s 不存在", i, name)
+			}
+			checkSize(fmt.Sprintf("upload.overrides[%d].categories.%s", i, name), size)
+		}
+	}
+}
+
+// normalizeExtension 扩展名统一为小写并以点开头
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// within 判断 path 是否为 dir 或位于 dir 之内
+func within(path, dir string) bool {
+	p, err1 := filepath.Abs(path)
+	d, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(d, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
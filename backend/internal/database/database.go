@@ -2,17 +2,36 @@ package database
 
 import (
     "fmt"
+    "time"
 
     "github.com/huanhq99/H-Cloud/internal/config"
+    "github.com/huanhq99/H-Cloud/internal/logger"
     "github.com/huanhq99/H-Cloud/internal/model"
+    "github.com/huanhq99/H-Cloud/internal/rbac"
     "gorm.io/driver/mysql"
     "gorm.io/driver/sqlite"
     "gorm.io/gorm"
-    "golang.org/x/crypto/bcrypt"
+    gormlogger "gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
+// gormWriter 把 gorm 的日志（慢查询、SQL 错误）写入统一日志
+type gormWriter struct{}
+
+func (gormWriter) Printf(format string, args ...interface{}) {
+    logger.With("component", "gorm").Warn(format, args...)
+}
+
+// newGormLogger 只记录慢查询和错误，查询不到记录属于正常情况，不记录
+func newGormLogger() gormlogger.Interface {
+    return gormlogger.New(gormWriter{}, gormlogger.Config{
+        SlowThreshold:             500 * time.Millisecond,
+        LogLevel:                  gormlogger.Warn,
+        IgnoreRecordNotFoundError: true,
+    })
+}
+
 // InitDB 初始化数据库连接
 func InitDB(cfg *config.Config) (*gorm.DB, error) {
     var err error
@@ -21,7 +40,7 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
         if dbPath == "" {
             dbPath = "hqyun.db"
         }
-        DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+        DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{Logger: newGormLogger()})
     } else {
         dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
             cfg.Database.User,
@@ -30,26 +49,47 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
             cfg.Database.Port,
             cfg.Database.DBName,
         )
-        DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+        DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: newGormLogger()})
     }
     if err != nil {
         return nil, err
     }
 
+	// 邮箱验证字段加入之前注册的账户视为已验证
+	grandfather := DB.Migrator().HasTable(&model.User{}) && !DB.Migrator().HasColumn(&model.User{}, "EmailVerifiedAt")
+
 	// 自动迁移数据库模型
 	err = migrateModels()
 	if err != nil {
 		return nil, err
 	}
+	if grandfather {
+		if err := DB.Model(&model.User{}).Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).Error; err != nil {
+			return nil, err
+		}
+	}
 
-	// 种子默认管理员账号（如不存在）
-	if err := seedDefaultAdmin(); err != nil {
+	// 初始化内置角色和权限，首次启动时创建初始管理员
+	if err := rbac.Seed(DB); err != nil {
+		return nil, err
+	}
+	if err := rbac.Bootstrap(DB, cfg); err != nil {
 		return nil, err
 	}
 	
 	return DB, nil
 }
 
+// Close 关闭数据库连接池，服务退出前调用
+func Close(db *gorm.DB) error {
+    sqlDB, err := db.DB()
+    if err != nil {
+        return err
+    }
+    return sqlDB.Close()
+}
+
 // migrateModels 自动迁移数据库模型
 func migrateModels() error {
     return DB.AutoMigrate(
@@ -58,38 +98,17 @@ func migrateModels() error {
         &model.Directory{},
         &model.Share{},
         &model.RecycleBin{},
+        &model.ImageTraffic{},
+        &model.ImageAPIKey{},
+        &model.PersonalAccessToken{},
+        &model.Session{},
+        &model.RecoveryCode{},
+        &model.Setting{},
+        &model.FailedAttempt{},
+        &model.Role{},
+        &model.Permission{},
+        &model.EmailToken{},
+        &model.InviteCode{},
+        &model.AuditLog{},
     )
 }
-
-// seedDefaultAdmin 在首次启动时初始化一个默认管理员账户
-func seedDefaultAdmin() error {
-    var count int64
-    if err := DB.Model(&model.User{}).Where("role = ?", "admin").Count(&count).Error; err != nil {
-        return err
-    }
-    if count > 0 {
-        return nil
-    }
-    // 没有管理员则创建默认管理员 admin / password
-    // 使用 bcrypt 哈希存储密码
-    hashed := []byte("password")
-    // 尝试生成哈希（失败时仍以明文作为退路，但记录错误）
-    if h, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost); err == nil {
-        hashed = h
-    }
-    admin := &model.User{
-        Username: "admin",
-        Password: string(hashed),
-        Email:    "admin@example.com",
-        Role:     "admin",
-    }
-    // 若用户名或邮箱已被占用，则跳过
-    var exist int64
-    if err := DB.Model(&model.User{}).Where("username = ? OR email = ?", admin.Username, admin.Email).Count(&exist).Error; err != nil {
-        return err
-    }
-    if exist > 0 {
-        return nil
-    }
-    return DB.Create(admin).Error
-}
\ No newline at end of file
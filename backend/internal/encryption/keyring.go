@@ -0,0 +1,130 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+)
+
+// KeySize 主密钥和数据密钥的长度（AES-256）
+const KeySize = 32
+
+// KeyID 主密钥标识，取密钥 SHA-256 的前 8 字节，写入文件头用于选择解密用的主密钥
+type KeyID [8]byte
+
+// String 以十六进制显示
+func (id KeyID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Keyring 主密钥集合：当前主密钥用于包装新文件的数据密钥，旧主密钥只用于解开轮换前包装的数据密钥
+type Keyring struct {
+	primary KeyID
+	keys    map[KeyID][]byte
+	order   []KeyID
+}
+
+// NewKeyring 从配置加载主密钥，未配置任何密钥时返回 nil
+func NewKeyring(cfg config.EncryptionConfig) (*Keyring, error) {
+	var encoded []string
+	if cfg.Key != "" {
+		encoded = append(encoded, cfg.Key)
+	}
+	if cfg.KeyFile != "" {
+		lines, err := readKeyFile(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, lines...)
+	}
+	encoded = append(encoded, cfg.PreviousKeys...)
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+
+	k := &Keyring{keys: map[KeyID][]byte{}}
+	for i, s := range encoded {
+		key, err := ParseKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个主密钥: %w", i+1, err)
+		}
+		id := keyID(key)
+		if _, ok := k.keys[id]; ok {
+			continue
+		}
+		if len(k.order) == 0 {
+			k.primary = id
+		}
+		k.keys[id] = key
+		k.order = append(k.order, id)
+	}
+	return k, nil
+}
+
+// Primary 当前主密钥的标识
+func (k *Keyring) Primary() KeyID {
+	return k.primary
+}
+
+// IDs 所有主密钥的标识，第一个为当前主密钥
+func (k *Keyring) IDs() []KeyID {
+	return append([]KeyID(nil), k.order...)
+}
+
+// ParseKey 解析 base64 或十六进制编码的 32 字节密钥
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == hex.EncodedLen(KeySize) {
+		if key, err := hex.DecodeString(s); err == nil {
+			return key, nil
+		}
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(s); err == nil {
+			if len(key) != KeySize {
+				return nil, fmt.Errorf("密钥长度为 %d 字节，应为 %d 字节", len(key), KeySize)
+			}
+			return key, nil
+		}
+	}
+	return nil, errors.New("密钥应为 base64 或十六进制编码，可用 openssl rand -base64 32 生成")
+}
+
+// readKeyFile 读取密钥文件，每行一个密钥，忽略空行和 # 开头的注释
+func readKeyFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	defer f.Close()
+	var keys []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("密钥文件 %s 中没有密钥", path)
+	}
+	return keys, nil
+}
+
+func keyID(key []byte) KeyID {
+	sum := sha256.Sum256(key)
+	var id KeyID
+	copy(id[:], sum[:len(id)])
+	return id
+}
@@ -0,0 +1,380 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// 加密文件的格式：
+//
+//	文件头（80 字节）: magic(8) | 主密钥标识(8) | 分块大小(4) | 包装 nonce(12) | 包装后的数据密钥(32+16)
+//	数据块: 每块明文单独以数据密钥做 AES-256-GCM 加密，密文比明文多 16 字节的认证标签
+//
+// 每个文件使用随机生成的数据密钥，数据块的 nonce 为块序号加最后一块标记，截断或调换数据块都无法通过认证。
+// 主密钥只用于包装数据密钥，轮换主密钥时只需重写文件头。明文大小由文件大小推算，无需额外记录
+const (
+	HeaderSize = 80
+	overhead   = 16 // GCM 认证标签长度
+
+	DefaultChunkSize = 64 * 1024
+	// MaxChunkSize 分块大小上限，与配置 encryption.chunk_size 的上限一致；
+	// 文件头中的分块大小决定读取时分配的缓冲区，必须限制以防损坏的文件头导致分配过大的内存
+	MaxChunkSize = 4 * 1024 * 1024
+)
+
+var magic = [8]byte{'H', 'C', 'L', 'D', 'E', 'N', 'C', '1'}
+
+var (
+	// ErrUnknownKey 文件头中的主密钥不在当前配置中
+	ErrUnknownKey = errors.New("文件使用的主密钥未配置")
+	// ErrCorrupted 文件头或数据块无法通过认证
+	ErrCorrupted = errors.New("加密文件已损坏或被篡改")
+
+	errClosed = errors.New("encryption: Writer 已关闭")
+)
+
+// IsEncrypted 根据文件开头的 magic 判断是否为加密文件
+func IsEncrypted(head []byte) bool {
+	return len(head) >= len(magic) && bytes.Equal(head[:len(magic)], magic[:])
+}
+
+// header 解析后的文件头
+type header struct {
+	keyID     KeyID
+	chunkSize int
+	nonce     [12]byte
+	wrapped   [KeySize + overhead]byte
+}
+
+func (h *header) marshal() []byte {
+	b := make([]byte, HeaderSize)
+	copy(b[0:8], magic[:])
+	copy(b[8:16], h.keyID[:])
+	binary.BigEndian.PutUint32(b[16:20], uint32(h.chunkSize))
+	copy(b[20:32], h.nonce[:])
+	copy(b[32:80], h.wrapped[:])
+	return b
+}
+
+func parseHeader(b []byte) (*header, error) {
+	if len(b) < HeaderSize || !IsEncrypted(b) {
+		return nil, ErrCorrupted
+	}
+	h := &header{chunkSize: int(binary.BigEndian.Uint32(b[16:20]))}
+	if h.chunkSize <= 0 || h.chunkSize > MaxChunkSize {
+		return nil, ErrCorrupted
+	}
+	copy(h.keyID[:], b[8:16])
+	copy(h.nonce[:], b[20:32])
+	copy(h.wrapped[:], b[32:80])
+	return h, nil
+}
+
+// aad 包装数据密钥时认证的文件头字段，防止修改分块大小或主密钥标识
+func (h *header) aad() []byte {
+	return h.marshal()[:20]
+}
+
+// wrap 以当前主密钥包装数据密钥
+func (k *Keyring) wrap(h *header, dek []byte) error {
+	h.keyID = k.primary
+	if _, err := rand.Read(h.nonce[:]); err != nil {
+		return err
+	}
+	aead, err := newGCM(k.keys[k.primary])
+	if err != nil {
+		return err
+	}
+	aead.Seal(h.wrapped[:0], h.nonce[:], dek, h.aad())
+	return nil
+}
+
+// unwrap 用文件头中标识的主密钥解开数据密钥
+func (k *Keyring) unwrap(h *header) ([]byte, error) {
+	key, ok := k.keys[h.keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, h.keyID)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := aead.Open(nil, h.nonce[:], h.wrapped[:], h.aad())
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return dek, nil
+}
+
+// Writer 分块加密写入，必须调用 Close 写入最后一块，否则文件无法通过认证
+type Writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	out   []byte
+	index uint64
+	err   error
+}
+
+// NewWriter 生成数据密钥并写入文件头，返回加密写入 w 的 Writer；chunkSize 不大于 0 时使用默认值
+func (k *Keyring) NewWriter(w io.Writer, chunkSize int) (*Writer, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("encryption: 分块大小不能超过 %d 字节", MaxChunkSize)
+	}
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	h := &header{chunkSize: chunkSize}
+	if err := k.wrap(h, dek); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(h.marshal()); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, chunkSize),
+		out:  make([]byte, 0, chunkSize+overhead),
+	}, nil
+}
+
+// Write 缓冲到一整块后加密写出；缓冲区满时要等到有后续数据才写出，以便最后一块带上结束标记
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			if w.err = w.flush(false); w.err != nil {
+				return n, w.err
+			}
+		}
+		c := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close 写出最后一块，不关闭底层的 io.Writer
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.flush(true); err != nil {
+		w.err = err
+		return err
+	}
+	w.err = errClosed
+	return nil
+}
+
+func (w *Writer) flush(last bool) error {
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.index, last), w.buf, nil)
+	w.buf = w.buf[:0]
+	w.index++
+	_, err := w.w.Write(w.out)
+	return err
+}
+
+// Reader 按需解密的只读视图，支持随机读取；缓存最近解密的一块，顺序读取时每块只解密一次
+type Reader struct {
+	r         io.ReaderAt
+	aead      cipher.AEAD
+	chunkSize int
+	chunks    int64
+	bodySize  int64
+	size      int64
+
+	mu     sync.Mutex
+	cached int64
+	plain  []byte
+	raw    []byte
+}
+
+// NewReader 解析文件头、解开数据密钥，size 为加密文件的总大小
+func (k *Keyring) NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	b := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return nil, ErrCorrupted
+	}
+	h, err := parseHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := k.unwrap(h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	body := size - HeaderSize
+	sealed := int64(h.chunkSize + overhead)
+	chunks := (body + sealed - 1) / sealed
+	if body < overhead || body-(chunks-1)*sealed < overhead {
+		return nil, ErrCorrupted
+	}
+	return &Reader{
+		r:         r,
+		aead:      aead,
+		chunkSize: h.chunkSize,
+		chunks:    chunks,
+		bodySize:  body,
+		size:      body - chunks*overhead,
+		cached:    -1,
+	}, nil
+}
+
+// Size 明文大小
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// ReadAt 读取明文中 off 开始的内容，只解密涉及的数据块
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("encryption: 偏移量为负数")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for len(p) > 0 && off < r.size {
+		index := off / int64(r.chunkSize)
+		plain, err := r.chunk(index)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p, plain[off-index*int64(r.chunkSize):])
+		p = p[c:]
+		off += int64(c)
+		n += c
+	}
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// chunk 解密第 index 块
+func (r *Reader) chunk(index int64) ([]byte, error) {
+	if index == r.cached {
+		return r.plain, nil
+	}
+	sealed := int64(r.chunkSize + overhead)
+	length := min(sealed, r.bodySize-index*sealed)
+	if cap(r.raw) < int(sealed) {
+		r.raw = make([]byte, sealed)
+	}
+	raw := r.raw[:length]
+	if _, err := r.r.ReadAt(raw, HeaderSize+index*sealed); err != nil && err != io.EOF {
+		return nil, err
+	}
+	plain, err := r.aead.Open(r.plain[:0], chunkNonce(uint64(index), index == r.chunks-1), raw, nil)
+	if err != nil {
+		r.cached = -1
+		return nil, ErrCorrupted
+	}
+	r.plain, r.cached = plain, index
+	return plain, nil
+}
+
+// Rewrap 用当前主密钥重新包装文件头中的数据密钥，文件已使用当前主密钥时返回 false。
+// 只重写文件头，不重新加密数据块
+func (k *Keyring) Rewrap(f interface {
+	io.ReaderAt
+	io.WriterAt
+}) (bool, error) {
+	b := make([]byte, HeaderSize)
+	if _, err := f.ReadAt(b, 0); err != nil {
+		return false, ErrCorrupted
+	}
+	h, err := parseHeader(b)
+	if err != nil {
+		return false, err
+	}
+	if h.keyID == k.primary {
+		return false, nil
+	}
+	dek, err := k.unwrap(h)
+	if err != nil {
+		return false, err
+	}
+	if err := k.wrap(h, dek); err != nil {
+		return false, err
+	}
+	if _, err := f.WriteAt(h.marshal(), 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// KeyOf 读取文件头中的主密钥标识
+func KeyOf(r io.ReaderAt) (KeyID, error) {
+	b := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return KeyID{}, ErrCorrupted
+	}
+	h, err := parseHeader(b)
+	if err != nil {
+		return KeyID{}, err
+	}
+	return h.keyID, nil
+}
+
+// PlainSize 根据文件头中的分块大小和加密文件大小推算明文大小，不解开数据密钥，只用于显示
+func PlainSize(r io.ReaderAt, size int64) (int64, error) {
+	b := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return 0, ErrCorrupted
+	}
+	h, err := parseHeader(b)
+	if err != nil {
+		return 0, err
+	}
+	body := size - HeaderSize
+	sealed := int64(h.chunkSize + overhead)
+	chunks := (body + sealed - 1) / sealed
+	if body < overhead {
+		return 0, ErrCorrupted
+	}
+	return body - chunks*overhead, nil
+}
+
+// chunkNonce 数据块的 nonce：8 字节块序号，最后一字节标记是否为最后一块
+func chunkNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,299 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+)
+
+const testChunkSize = 64
+
+// newTestKey 生成 base64 编码的随机主密钥
+func newTestKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newTestKeyring(t *testing.T, key string, previous ...string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(config.EncryptionConfig{Key: key, PreviousKeys: previous})
+	if err != nil {
+		t.Fatalf("加载主密钥失败: %v", err)
+	}
+	return k
+}
+
+// testData 生成 n 字节的随机明文
+func testData(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// encrypt 以 chunkSize 分块加密 plain
+func encrypt(t *testing.T, k *Keyring, plain []byte, chunkSize int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := k.NewWriter(&buf, chunkSize)
+	if err != nil {
+		t.Fatalf("创建 Writer 失败: %v", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("关闭 Writer 失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// decrypt 解密整个文件
+func decrypt(k *Keyring, sealed []byte) ([]byte, error) {
+	r, err := k.NewReader(bytes.NewReader(sealed), int64(len(sealed)))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+}
+
+func TestRoundTrip(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(t))
+	sizes := []int{0, 1, testChunkSize - 1, testChunkSize, testChunkSize + 1, 2 * testChunkSize, 5*testChunkSize + 7}
+	for _, n := range sizes {
+		plain := testData(t, n)
+		sealed := encrypt(t, k, plain, testChunkSize)
+		if !IsEncrypted(sealed) {
+			t.Fatalf("%d 字节: 缺少文件头", n)
+		}
+		chunks := max((n+testChunkSize-1)/testChunkSize, 1)
+		if want := HeaderSize + n + chunks*overhead; len(sealed) != want {
+			t.Errorf("%d 字节: 密文应为 %d 字节，实际为 %d", n, want, len(sealed))
+		}
+		got, err := decrypt(k, sealed)
+		if err != nil {
+			t.Fatalf("%d 字节: 解密失败: %v", n, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("%d 字节: 解密结果与明文不一致", n)
+		}
+	}
+}
+
+func TestRoundTripDefaultChunkSize(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(t))
+	plain := testData(t, 2*DefaultChunkSize+100)
+	got, err := decrypt(k, encrypt(t, k, plain, 0))
+	if err != nil {
+		t.Fatalf("解密失败: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Error("解密结果与明文不一致")
+	}
+}
+
+func TestReadAt(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(t))
+	plain := testData(t, 4*testChunkSize+10)
+	sealed := encrypt(t, k, plain, testChunkSize)
+	r, err := k.NewReader(bytes.NewReader(sealed), int64(len(sealed)))
+	if err != nil {
+		t.Fatalf("创建 Reader 失败: %v", err)
+	}
+	if r.Size() != int64(len(plain)) {
+		t.Fatalf("明文大小应为 %d，实际为 %d", len(plain), r.Size())
+	}
+
+	tests := []struct {
+		name     string
+		off, n   int
+		wantRead int
+		wantEOF  bool
+	}{
+		{name: "块内", off: 3, n: 10, wantRead: 10},
+		{name: "跨一个块边界", off: testChunkSize - 5, n: 10, wantRead: 10},
+		{name: "跨多个块", off: testChunkSize / 2, n: 3 * testChunkSize, wantRead: 3 * testChunkSize},
+		{name: "从块开头读整块", off: 2 * testChunkSize, n: testChunkSize, wantRead: testChunkSize},
+		{name: "读到末尾", off: len(plain) - 20, n: 20, wantRead: 20},
+		{name: "超出末尾", off: len(plain) - 5, n: 20, wantRead: 5, wantEOF: true},
+		{name: "从末尾开始", off: len(plain), n: 1, wantRead: 0, wantEOF: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := make([]byte, tt.n)
+			n, err := r.ReadAt(p, int64(tt.off))
+			if n != tt.wantRead {
+				t.Fatalf("应读取 %d 字节，实际为 %d", tt.wantRead, n)
+			}
+			if tt.wantEOF != (err == io.EOF) || (!tt.wantEOF && err != nil) {
+				t.Fatalf("错误不正确: %v", err)
+			}
+			if !bytes.Equal(p[:n], plain[tt.off:tt.off+n]) {
+				t.Error("读取的内容不正确")
+			}
+		})
+	}
+
+	if _, err := r.ReadAt(make([]byte, 1), -1); err == nil {
+		t.Error("负数偏移量应返回错误")
+	}
+}
+
+func TestTamperingRejected(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(t))
+	plain := testData(t, 3*testChunkSize+10)
+	sealed := encrypt(t, k, plain, testChunkSize)
+	chunk := testChunkSize + overhead
+
+	tests := []struct {
+		name   string
+		modify func(b []byte) []byte
+	}{
+		{name: "修改数据块中的一个字节", modify: func(b []byte) []byte {
+			b[HeaderSize+chunk+5] ^= 1
+			return b
+		}},
+		{name: "修改认证标签", modify: func(b []byte) []byte {
+			b[len(b)-1] ^= 1
+			return b
+		}},
+		{name: "截断最后一块", modify: func(b []byte) []byte {
+			return b[:len(b)-5]
+		}},
+		{name: "删除最后一块", modify: func(b []byte) []byte {
+			return b[:HeaderSize+3*chunk]
+		}},
+		{name: "调换数据块", modify: func(b []byte) []byte {
+			first := append([]byte(nil), b[HeaderSize:HeaderSize+chunk]...)
+			copy(b[HeaderSize:], b[HeaderSize+chunk:HeaderSize+2*chunk])
+			copy(b[HeaderSize+chunk:], first)
+			return b
+		}},
+		{name: "修改文件头中的分块大小", modify: func(b []byte) []byte {
+			binary.BigEndian.PutUint32(b[16:20], testChunkSize*2)
+			return b
+		}},
+		{name: "修改包装后的数据密钥", modify: func(b []byte) []byte {
+			b[40] ^= 1
+			return b
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.modify(append([]byte(nil), sealed...))
+			if _, err := decrypt(k, b); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("应返回 ErrCorrupted，实际为 %v", err)
+			}
+		})
+	}
+}
+
+func TestParseHeaderChunkSize(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(t))
+	sealed := encrypt(t, k, testData(t, 10), testChunkSize)
+
+	for _, size := range []uint32{0, MaxChunkSize + 1, 1<<32 - 1} {
+		b := append([]byte(nil), sealed...)
+		binary.BigEndian.PutUint32(b[16:20], size)
+		if _, err := KeyOf(bytes.NewReader(b)); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("分块大小 %d 应被拒绝，实际为 %v", size, err)
+		}
+		if _, err := PlainSize(bytes.NewReader(b), int64(len(b))); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("分块大小 %d 应被拒绝，实际为 %v", size, err)
+		}
+	}
+	if _, err := k.NewWriter(io.Discard, MaxChunkSize+1); err == nil {
+		t.Error("超过上限的分块大小应返回错误")
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	sealed := encrypt(t, newTestKeyring(t, newTestKey(t)), testData(t, 10), testChunkSize)
+	if _, err := decrypt(newTestKeyring(t, newTestKey(t)), sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("应返回 ErrUnknownKey，实际为 %v", err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	oldRing := newTestKeyring(t, oldKey)
+	plain := testData(t, 2*testChunkSize+3)
+	sealed := encrypt(t, oldRing, plain, testChunkSize)
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, sealed, 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// 轮换后新主密钥在前，旧主密钥仍可解开轮换前的文件
+	rotated := newTestKeyring(t, newKey, oldKey)
+	if ids := rotated.IDs(); len(ids) != 2 || ids[0] != rotated.Primary() || ids[1] != oldRing.Primary() {
+		t.Fatalf("主密钥顺序不正确: %v", ids)
+	}
+	changed, err := rotated.Rewrap(f)
+	if err != nil || !changed {
+		t.Fatalf("重新包装失败: %v, %v", changed, err)
+	}
+	if id, err := KeyOf(f); err != nil || id != rotated.Primary() {
+		t.Fatalf("文件头应改用新主密钥: %v, %v", id, err)
+	}
+	if changed, err := rotated.Rewrap(f); err != nil || changed {
+		t.Errorf("已使用当前主密钥的文件不应再次重写: %v, %v", changed, err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[HeaderSize:], sealed[HeaderSize:]) {
+		t.Error("只应重写文件头，不应修改数据块")
+	}
+	// 移除旧主密钥后仍可解密
+	got, err := decrypt(newTestKeyring(t, newKey), b)
+	if err != nil {
+		t.Fatalf("新主密钥解密失败: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Error("解密结果与明文不一致")
+	}
+	if _, err := decrypt(oldRing, b); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("旧主密钥不应再能解密，实际为 %v", err)
+	}
+}
+
+func TestPlainSize(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(t))
+	for _, n := range []int{0, testChunkSize - 1, testChunkSize, testChunkSize + 1, 4*testChunkSize + 9} {
+		sealed := encrypt(t, k, testData(t, n), testChunkSize)
+		size, err := PlainSize(bytes.NewReader(sealed), int64(len(sealed)))
+		if err != nil {
+			t.Fatalf("%d 字节: %v", n, err)
+		}
+		if size != int64(n) {
+			t.Errorf("明文大小应为 %d，实际为 %d", n, size)
+		}
+	}
+
+	if _, err := PlainSize(bytes.NewReader([]byte("plain text")), 10); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("未加密的文件应返回 ErrCorrupted，实际为 %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// pattern 带参数的消息模板，如 "密码至少需要 %d 个字符"
+type pattern struct {
+	re     *regexp.Regexp
+	format string
+}
+
+// catalog 一种语言的翻译：以源语言消息为键
+type catalog struct {
+	phrases  map[string]string
+	patterns []pattern
+}
+
+var catalogs = map[string]*catalog{
+	EnUS: newCatalog(enUS),
+}
+
+// verbPattern 匹配模板中的 %d 和 %s
+var verbPattern = regexp.MustCompile(`%[ds]`)
+
+// newCatalog 将翻译表中含 %d、%s 的条目编译为模板，其余作为固定短语
+func newCatalog(messages map[string]string) *catalog {
+	c := &catalog{phrases: make(map[string]string, len(messages))}
+	for src, dst := range messages {
+		if !verbPattern.MatchString(src) {
+			c.phrases[src] = dst
+			continue
+		}
+		expr := verbPattern.ReplaceAllStringFunc(regexp.QuoteMeta(src), func(verb string) string {
+			if verb == "%d" {
+				return `(-?\d+)`
+			}
+			return `(.+?)`
+		})
+		c.patterns = append(c.patterns, pattern{
+			re:     regexp.MustCompile("^" + expr + "$"),
+			format: verbPattern.ReplaceAllString(dst, "%s"),
+		})
+	}
+	return c
+}
+
+// Translate 将源语言消息翻译为指定语言。
+// 依次尝试固定短语、参数模板，以及 "前缀: 详情" 形式的组合消息；
+// 没有翻译时返回 false，由调用方决定回退方式
+func Translate(locale, message string) (string, bool) {
+	if locale == Source || !hasHan(message) {
+		return message, true
+	}
+	c, ok := catalogs[locale]
+	if !ok {
+		return "", false
+	}
+	return c.translate(message)
+}
+
+func (c *catalog) translate(message string) (string, bool) {
+	if s, ok := c.phrases[message]; ok {
+		return s, true
+	}
+	for _, p := range c.patterns {
+		if m := p.re.FindStringSubmatch(message); m != nil {
+			args := make([]any, len(m)-1)
+			for i, v := range m[1:] {
+				args[i] = v
+			}
+			return fmt.Sprintf(p.format, args...), true
+		}
+	}
+	// 组合消息的详情部分常来自下层错误，非中文的详情（如第三方库的错误）原样保留
+	if prefix, detail, ok := strings.Cut(message, ": "); ok {
+		p, ok := c.translate(prefix)
+		if !ok {
+			return "", false
+		}
+		if !hasHan(detail) {
+			return p + ": " + detail, true
+		}
+		if d, ok := c.translate(detail); ok {
+			return p + ": " + d, true
+		}
+	}
+	return "", false
+}
+
+// hasHan 判断字符串是否包含汉字，不含汉字的消息无需翻译
+func hasHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,346 @@
+package i18n
+
+// enUS 英文翻译，键为代码中的中文消息；%d、%s 按顺序对应
+var enUS = map[string]string{
+	// 通用
+	"无效的请求参数":           "Invalid request parameters",
+	"请求参数错误":            "Invalid request parameters",
+	"无效的参数":             "Invalid parameters",
+	"未授权":               "Unauthorized",
+	"权限不足":              "Permission denied",
+	"操作成功":              "Success",
+	"存储空间不足":            "Insufficient storage space",
+	"获取系统信息失败":          "Failed to get system information",
+	"获取存储信息失败":          "Failed to get storage information",
+	"保存设置失败":            "Failed to save settings",
+	"不支持的语言":            "Unsupported language",
+	"语言设置已保存":           "Language preference saved",
+	"保存语言设置失败":          "Failed to save language preference",
+	"设置已保存":             "Settings saved",
+	"尝试次数过多，请在 %d 秒后重试": "Too many attempts, please retry in %d seconds",
+
+	// 登录、会话与令牌
+	"无效的登录参数":               "Invalid login parameters",
+	"用户名或密码错误":              "Invalid username or password",
+	"账户已被禁用":                "Account is disabled",
+	"账户正在等待管理员审核":           "Account is pending administrator approval",
+	"账户不存在，请联系管理员开通":        "Account does not exist, please contact an administrator",
+	"登出成功":                  "Logged out",
+	"登出失败":                  "Failed to log out",
+	"已在所有设备上登出":             "Logged out on all devices",
+	"会话不存在":                 "Session not found",
+	"会话已注销":                 "Session revoked",
+	"会话已失效，请重新登录":           "Session has expired, please log in again",
+	"无效的会话ID":               "Invalid session ID",
+	"获取会话列表失败":              "Failed to list sessions",
+	"注销会话失败":                "Failed to revoke session",
+	"缺少刷新令牌":                "Missing refresh token",
+	"刷新令牌无效或已过期":            "Refresh token is invalid or expired",
+	"刷新令牌已被使用，会话已被注销":       "Refresh token was already used, the session has been revoked",
+	"令牌无效":                  "Invalid token",
+	"令牌解析失败":                "Failed to parse token",
+	"生成令牌失败":                "Failed to generate token",
+	"访问令牌已过期":               "Access token has expired",
+	"访问令牌无效或已过期":            "Access token is invalid or expired",
+	"访问令牌缺少权限":              "Access token is missing a required scope",
+	"该操作不支持使用访问令牌":          "This operation cannot be performed with an access token",
+	"登录状态已过期，请重新登录":         "Login has expired, please log in again",
+	"登录状态无效，请重新登录":          "Login state is invalid, please log in again",
+	"验证令牌无效或已过期，请重新登录":      "Verification token is invalid or expired, please log in again",
+	"请先修改密码":                "Please change your password first",
+	"代登录会话不能执行该操作":          "This operation is not allowed in an impersonation session",
+	"不能代登录管理员":              "Cannot impersonate an administrator",
+	"不能代登录该用户":              "Cannot impersonate this user",
+	"令牌名称不能为空且不超过64个字符":     "Token name must be 1 to 64 characters",
+	"令牌已创建，请妥善保存，之后将无法再次查看": "Token created. Store it safely, it will not be shown again",
+	"令牌不存在":                 "Token not found",
+	"令牌已吊销":                 "Token revoked",
+	"保存令牌失败":                "Failed to save token",
+	"吊销令牌失败":                "Failed to revoke token",
+	"获取访问令牌失败":              "Failed to list access tokens",
+	"至少需要选择一个权限范围":          "Select at least one scope",
+	"未知的权限范围":               "Unknown scope",
+	"没有权限创建管理员范围的令牌":        "You are not allowed to create tokens with admin scopes",
+
+	// 密码与两步验证
+	"密码错误":                "Incorrect password",
+	"原密码错误":               "Current password is incorrect",
+	"密码已修改":               "Password changed",
+	"修改密码失败":              "Failed to change password",
+	"加密密码失败":              "Failed to hash password",
+	"新密码不能与原密码相同":         "New password must differ from the current password",
+	"密码至少需要 %d 个字符":       "Password must be at least %d characters",
+	"新密码至少需要 %d 个字符":      "New password must be at least %d characters",
+	"密码已重置，请使用新密码登录":      "Password reset, please log in with the new password",
+	"密码已重置，用户下次登录后必须修改密码": "Password reset, the user must change it after the next login",
+	"重置密码失败":              "Failed to reset password",
+	"生成密码失败":              "Failed to generate password",
+	"外部账户的密码由身份提供方管理":     "Passwords of external accounts are managed by the identity provider",
+	"外部账户请在身份提供方修改密码":     "External accounts must change their password at the identity provider",
+	"两步验证已启用":             "Two-factor authentication enabled",
+	"两步验证已启用，请妥善保存恢复码，之后将无法再次查看": "Two-factor authentication enabled. Store the recovery codes safely, they will not be shown again",
+	"两步验证已关闭":           "Two-factor authentication disabled",
+	"两步验证未启用":           "Two-factor authentication is not enabled",
+	"该账户未启用两步验证":        "Two-factor authentication is not enabled for this account",
+	"启用两步验证失败":          "Failed to enable two-factor authentication",
+	"关闭两步验证失败":          "Failed to disable two-factor authentication",
+	"请先生成两步验证密钥":        "Generate a two-factor secret first",
+	"管理员要求当前角色必须启用两步验证": "Your role requires two-factor authentication",
+	"需要提供密码和验证码":        "Password and verification code are required",
+	"缺少验证令牌或验证码":        "Missing verification token or code",
+	"缺少验证码":             "Missing verification code",
+	"验证码错误":             "Incorrect verification code",
+	"恢复码无效或已使用":         "Recovery code is invalid or already used",
+	"恢复码已重新生成，旧恢复码全部失效": "Recovery codes regenerated, all previous codes are invalid",
+	"生成恢复码失败":           "Failed to generate recovery codes",
+	"保存密钥失败":            "Failed to save secret",
+	"生成密钥失败":            "Failed to generate secret",
+
+	// 注册与邮箱
+	"无效的注册参数":               "Invalid registration parameters",
+	"用户名不能为空，邮箱格式必须正确":      "Username is required and the email must be valid",
+	"用户名或邮箱已存在":             "Username or email already exists",
+	"系统已关闭注册":               "Registration is closed",
+	"需要邀请码才能注册":             "An invite code is required to register",
+	"邀请码无效、已用完或已过期":         "Invite code is invalid, used up or expired",
+	"该邮箱域名不允许注册":            "Registration is not allowed for this email domain",
+	"注册成功，请查收验证邮件完成验证":      "Registered, please check your inbox to verify your email",
+	"注册成功，请等待管理员审核":         "Registered, please wait for administrator approval",
+	"请先验证邮箱":                "Please verify your email first",
+	"无效的邮箱":                 "Invalid email",
+	"邮箱格式不正确":               "Invalid email format",
+	"邮箱格式不正确或已被使用":          "Email is invalid or already in use",
+	"邮箱已被使用":                "Email is already in use",
+	"邮箱已被其他账户使用":            "Email is already used by another account",
+	"邮箱 %s 已被其他账户使用":        "Email %s is already used by another account",
+	"邮箱未改变":                 "Email is unchanged",
+	"邮箱已验证":                 "Email verified",
+	"验证邮箱失败":                "Failed to verify email",
+	"检查邮箱失败":                "Failed to check email",
+	"缺少验证令牌":                "Missing verification token",
+	"链接无效或已过期":              "The link is invalid or expired",
+	"发送验证邮件失败":              "Failed to send verification email",
+	"邮件发送过于频繁，请稍后再试":        "Emails are being sent too frequently, please try again later",
+	"验证邮件已发送到新邮箱，验证后生效":     "A verification email was sent to the new address, the change takes effect once verified",
+	"如果该邮箱已注册且尚未验证，验证邮件已发送": "If the email is registered and not yet verified, a verification email has been sent",
+	"如果该邮箱已注册，重置密码的邮件已发送":   "If the email is registered, a password reset email has been sent",
+	"外部账户的邮箱由身份提供方管理":       "Emails of external accounts are managed by the identity provider",
+
+	// 单点登录与 LDAP
+	"未启用单点登录":           "Single sign-on is not enabled",
+	"未启用 LDAP":          "LDAP is not enabled",
+	"缺少授权码":             "Missing authorization code",
+	"无效的跳转地址":           "Invalid redirect URL",
+	"生成登录状态失败":          "Failed to generate login state",
+	"连接身份提供方失败":         "Failed to connect to the identity provider",
+	"换取令牌失败":            "Failed to exchange token",
+	"身份提供方拒绝登录":         "The identity provider rejected the login",
+	"ID Token 缺少 sub":   "ID token is missing sub",
+	"身份提供方未返回邮箱，无法创建账户": "The identity provider did not return an email, cannot create an account",
+	"邮箱未经身份提供方验证，无法关联已有账户": "The email is not verified by the identity provider, cannot link an existing account",
+	"该邮箱的账户已关联其他单点登录身份":    "The account with this email is already linked to another single sign-on identity",
+	"关联账户失败":           "Failed to link account",
+	"同步角色失败":           "Failed to sync role",
+	"无法生成唯一的用户名":       "Failed to generate a unique username",
+	"LDAP 同步失败":        "LDAP sync failed",
+	"用户名 %s 已被本地账户占用":  "Username %s is taken by a local account",
+	"创建用户 %s 失败":       "Failed to create user %s",
+	"更新用户 %s 失败":       "Failed to update user %s",
+	"连接 LDAP 服务器失败":    "Failed to connect to the LDAP server",
+	"LDAP StartTLS 失败": "LDAP StartTLS failed",
+	"LDAP 服务账号绑定失败":    "LDAP service account bind failed",
+	"LDAP 搜索失败":        "LDAP search failed",
+	"LDAP 绑定失败":        "LDAP bind failed",
+	"LDAP 中存在多个同名用户":   "Multiple LDAP users share this username",
+	"获取 OIDC 发现文档失败":   "Failed to fetch the OIDC discovery document",
+	"获取 JWKS 失败":       "Failed to fetch JWKS",
+	"ID Token 校验失败":    "ID token validation failed",
+
+	// 用户、角色与审核
+	"用户不存在":       "User not found",
+	"用户ID不能为空":    "User ID is required",
+	"缺少用户ID":      "Missing user ID",
+	"请提供用户ID":     "Please provide a user ID",
+	"无效的用户ID":     "Invalid user ID",
+	"查询用户失败":      "Failed to query user",
+	"检查用户失败":      "Failed to check user",
+	"创建用户失败":      "Failed to create user",
+	"修改用户失败":      "Failed to update user",
+	"获取用户列表失败":    "Failed to list users",
+	"用户已启用":       "User enabled",
+	"用户已禁用":       "User disabled",
+	"启用用户失败":      "Failed to enable user",
+	"禁用用户失败":      "Failed to disable user",
+	"不能禁用自己":      "You cannot disable yourself",
+	"不能修改自己的角色":   "You cannot change your own role",
+	"不能移除最后一个管理员": "Cannot remove the last administrator",
+	"无效的存储配额":     "Invalid storage quota",
+	"无效的角色":       "Invalid role",
+	"修改角色失败":      "Failed to change role",
+	"角色不存在":       "Role not found",
+	"角色已存在":       "Role already exists",
+	"角色已删除":       "Role deleted",
+	"创建角色失败":      "Failed to create role",
+	"删除角色失败":      "Failed to delete role",
+	"获取角色列表失败":    "Failed to list roles",
+	"获取权限列表失败":    "Failed to list permissions",
+	"内置角色不能删除":    "Built-in roles cannot be deleted",
+	"内置管理员角色拥有全部权限，不能修改": "The built-in admin role has all permissions and cannot be modified",
+	"不支持修改角色名称":          "Renaming roles is not supported",
+	"包含不存在的角色":           "Contains a role that does not exist",
+	"仍有 %d 个用户使用该角色":     "%d users still have this role",
+	"未知的权限: %s":          "Unknown permission: %s",
+	"角色名称不能为空、不超过32个字符且不能包含空格或逗号": "Role name must be 1 to 32 characters without spaces or commas",
+	"审核用户失败":      "Failed to review user",
+	"已通过审核":       "Approved",
+	"已拒绝注册申请":     "Registration rejected",
+	"拒绝注册申请失败":    "Failed to reject registration",
+	"该用户不在待审核列表中": "The user is not pending approval",
+	"邀请码不存在":      "Invite code not found",
+	"邀请码已作废":      "Invite code revoked",
+	"生成邀请码失败":     "Failed to generate invite code",
+	"获取邀请码失败":     "Failed to list invite codes",
+	"删除邀请码失败":     "Failed to delete invite code",
+	"无效的使用次数或有效期": "Invalid usage count or expiration",
+	"未启用防暴力破解":    "Brute-force protection is not enabled",
+	"获取锁定列表失败":    "Failed to list lockouts",
+	"已解除锁定":       "Lockout cleared",
+	"解除锁定失败":      "Failed to clear lockout",
+	"查询审计日志失败":    "Failed to query audit logs",
+	"无效的 actorId": "Invalid actorId",
+	"无效的开始时间":     "Invalid start time",
+	"无效的结束时间":     "Invalid end time",
+
+	// 文件与目录
+	"文件不存在":                "File not found",
+	"原文件不存在":               "Source file not found",
+	"无效的文件":                "Invalid file",
+	"无效的文件ID":              "Invalid file ID",
+	"请提供文件路径":              "Please provide a file path",
+	"请提供新文件名":              "Please provide a new file name",
+	"文件名不合法":               "Invalid file name",
+	"文件名不能为空":              "File name cannot be empty",
+	"文件名过长":                "File name is too long",
+	"文件名包含非法字符: %s":        "File name contains an illegal character: %s",
+	"不允许上传隐藏文件":            "Uploading hidden files is not allowed",
+	"不支持的文件类型":             "Unsupported file type",
+	"不允许上传 %s 类型的文件":       "Uploading %s files is not allowed",
+	"文件大小超过限制，最大允许 %d MB":  "File is too large, the maximum is %d MB",
+	"不允许上传没有扩展名的文件":        "Uploading files without an extension is not allowed",
+	"文件内容（%s）与扩展名 %s 不符":   "File content (%s) does not match the extension %s",
+	"路径不合法":                "Invalid path",
+	"路径不能为空":               "Path cannot be empty",
+	"检测到路径遍历攻击":            "Path traversal detected",
+	"不允许使用非Unix风格的绝对路径":    "Non-Unix absolute paths are not allowed",
+	"父路径不合法":               "Invalid parent path",
+	"文件上传成功":               "File uploaded",
+	"保存文件失败":               "Failed to save file",
+	"保存文件信息失败":             "Failed to save file information",
+	"打开文件失败":               "Failed to open file",
+	"读取文件失败":               "Failed to read file",
+	"获取文件失败":               "Failed to get file",
+	"获取文件列表失败":             "Failed to list files",
+	"搜索文件失败":               "Failed to search files",
+	"生成文件名失败":              "Failed to generate file name",
+	"文件重命名成功":              "File renamed",
+	"目标文件名已存在":             "A file with the target name already exists",
+	"重命名失败":                "Failed to rename",
+	"删除文件失败":               "Failed to delete file",
+	"删除文件记录失败":             "Failed to delete file record",
+	"删除物理文件失败":             "Failed to delete file from storage",
+	"病毒扫描失败，请稍后重试":         "Virus scan failed, please try again later",
+	"文件包含恶意内容（%s），已被隔离":    "File contains malicious content (%s) and has been quarantined",
+	"文件已被隔离":               "File has been quarantined",
+	"文件已被隔离，不能分享":          "Quarantined files cannot be shared",
+	"获取隔离文件列表失败":           "Failed to list quarantined files",
+	"恢复隔离文件失败":             "Failed to restore quarantined file",
+	"原位置已存在同名文件":           "A file with the same name already exists at the original location",
+	"删除隔离文件失败":             "Failed to delete quarantined file",
+	"更新文件记录失败":             "Failed to update file record",
+	"文件已恢复":                "File restored",
+	"文件已删除":                "File deleted",
+	"统计加密文件失败":             "Failed to inspect encrypted files",
+	"未配置静态加密的主密钥":          "No master key is configured for encryption at rest",
+	"重新包装数据密钥失败":           "Failed to rewrap data keys",
+	"删除数据库记录失败":            "Failed to delete database record",
+	"没有权限访问此文件":            "You are not allowed to access this file",
+	"用户ID和搜索关键词不能为空":       "User ID and search keyword are required",
+	"用户ID和文件类型不能为空":        "User ID and file type are required",
+	"目录不存在":                "Directory not found",
+	"源目录不存在":               "Source directory not found",
+	"原目录不存在":               "Source directory not found",
+	"父目录不存在":               "Parent directory not found",
+	"目录已存在":                "Directory already exists",
+	"目录名不合法":               "Invalid directory name",
+	"无效的目录ID":              "Invalid directory ID",
+	"无效的父目录ID":             "Invalid parent directory ID",
+	"请提供目录路径":              "Please provide a directory path",
+	"请提供新目录名":              "Please provide a new directory name",
+	"指定路径不是目录":             "The path is not a directory",
+	"目录创建成功":               "Directory created",
+	"创建目录失败":               "Failed to create directory",
+	"保存目录记录失败":             "Failed to save directory record",
+	"删除目录记录失败":             "Failed to delete directory record",
+	"目录重命名成功":              "Directory renamed",
+	"目标目录名已存在":             "A directory with the target name already exists",
+	"获取目录列表失败":             "Failed to list directories",
+	"获取子目录列表失败":            "Failed to list subdirectories",
+	"搜索目录失败":               "Failed to search directories",
+	"没有权限删除此目录":            "You are not allowed to delete this directory",
+	"缺少必要参数 userId 或 path": "Missing required parameter userId or path",
+	"目录映射成功":               "Directory mapped",
+	"映射目录失败":               "Failed to map directory",
+	"保存映射目录记录失败":           "Failed to save mapped directory record",
+	"目录认证失败":               "Directory authentication failed",
+
+	// 回收站
+	"文件已移至回收站":   "File moved to the recycle bin",
+	"目录已移至回收站":   "Directory moved to the recycle bin",
+	"移动文件到回收站失败": "Failed to move file to the recycle bin",
+	"移动目录到回收站失败": "Failed to move directory to the recycle bin",
+	"创建回收站目录失败":  "Failed to create recycle bin directory",
+	"创建回收站记录失败":  "Failed to create recycle bin record",
+	"获取回收站列表失败":  "Failed to list the recycle bin",
+	"回收站项目不存在":   "Recycle bin item not found",
+	"无效的项目ID":    "Invalid item ID",
+	"文件恢复成功":     "File restored",
+	"恢复文件失败":     "Failed to restore file",
+	"恢复文件记录失败":   "Failed to restore file record",
+	"文件已永久删除":    "File permanently deleted",
+	"删除回收站记录失败":  "Failed to delete recycle bin record",
+	"回收站已清空":     "Recycle bin emptied",
+	"清空回收站记录失败":  "Failed to empty the recycle bin",
+
+	// 分享
+	"分享不存在":           "Share not found",
+	"分享已过期":           "Share has expired",
+	"分享创建成功":          "Share created",
+	"创建分享失败":          "Failed to create share",
+	"生成分享ID失败":        "Failed to generate share ID",
+	"分享已取消":           "Share cancelled",
+	"取消分享失败":          "Failed to cancel share",
+	"获取分享列表失败":        "Failed to list shares",
+	"没有权限分享该文件":       "You are not allowed to share this file",
+	"没有权限分享该目录":       "You are not allowed to share this directory",
+	"没有权限取消该分享":       "You are not allowed to cancel this share",
+	"必须且仅选择文件或目录其中之一": "Select exactly one file or directory",
+	"无效的有效期":          "Invalid expiration",
+	"暂未支持目录分享下载":      "Downloading shared directories is not supported yet",
+	"分享必须设置访问密码":      "Shares must have an access password",
+	"不允许创建永久分享":       "Permanent shares are not allowed",
+	"分享有效期不能超过 %d 天":  "Shares cannot be valid for more than %d days",
+
+	// 图床
+	"仅支持上传图片":   "Only images can be uploaded",
+	"未找到上传的图片":  "No uploaded image found",
+	"该文件不是图片":   "The file is not an image",
+	"图片不存在或已删除": "Image not found or already deleted",
+	"图片已删除":     "Image deleted",
+	"无效的上传密钥":   "Invalid upload key",
+	"无效的删除链接":   "Invalid deletion link",
+	"生成删除令牌失败":  "Failed to generate deletion token",
+	"相册路径不合法":   "Invalid album path",
+	"密钥已生成，请妥善保存，之后将无法再次查看": "Key generated. Store it safely, it will not be shown again",
+	"密钥已吊销":  "Key revoked",
+	"吊销密钥失败": "Failed to revoke key",
+}
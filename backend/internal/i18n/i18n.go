@@ -0,0 +1,110 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的语言（BCP 47 标签）
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+)
+
+// Source 源语言：代码中的消息都以简体中文书写，无需翻译
+const Source = ZhCN
+
+// Supported 支持的全部语言
+var Supported = []string{ZhCN, EnUS}
+
+// ContextKey 上下文中保存用户首选语言的键，由认证中间件根据用户设置写入
+const ContextKey = "locale"
+
+// defaultLocale 用户未设置首选语言且请求未携带 Accept-Language 时使用的语言
+var defaultLocale atomic.Value
+
+func init() {
+	defaultLocale.Store(Source)
+}
+
+// SetDefault 设置默认语言，不支持的语言忽略并返回 false
+func SetDefault(locale string) bool {
+	if l := Normalize(locale); l != "" {
+		defaultLocale.Store(l)
+		return true
+	}
+	return false
+}
+
+// Default 返回默认语言
+func Default() string {
+	return defaultLocale.Load().(string)
+}
+
+// Normalize 将语言标签规范化为支持的语言，如 en、en_GB、EN-us 均对应 en-US，
+// zh、zh-Hans、zh-TW 均对应 zh-CN；不支持的语言返回空字符串
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	primary, _, _ := strings.Cut(tag, "-")
+	switch primary {
+	case "zh":
+		return ZhCN
+	case "en":
+		return EnUS
+	}
+	return ""
+}
+
+// Match 按 Accept-Language 请求头中的权重选出最合适的支持语言，没有可用语言时返回空字符串
+func Match(acceptLanguage string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if q <= 0 {
+			continue
+		}
+		if l := Normalize(tag); l != "" {
+			candidates = append(candidates, candidate{l, q})
+		}
+	}
+	// 稳定排序保证权重相同时以请求头中的顺序为准
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].locale
+}
+
+// SetLocale 将用户的首选语言写入上下文，为空或不支持时不做处理
+func SetLocale(c *gin.Context, locale string) {
+	if l := Normalize(locale); l != "" {
+		c.Set(ContextKey, l)
+	}
+}
+
+// FromContext 返回当前请求使用的语言：用户首选语言优先，其次是 Accept-Language，最后是默认语言
+func FromContext(c *gin.Context) string {
+	if l := c.GetString(ContextKey); l != "" {
+		return l
+	}
+	if l := Match(c.GetHeader("Accept-Language")); l != "" {
+		return l
+	}
+	return Default()
+}
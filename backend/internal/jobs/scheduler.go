@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/metrics"
+)
+
+// job 定时任务
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Scheduler 简单的定时任务调度器，每个任务在独立的 goroutine 中按固定间隔执行
+type Scheduler struct {
+	jobs   []job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler 创建调度器
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel}
+}
+
+// Every 注册按固定间隔执行的任务，间隔不大于 0 的任务会被忽略。
+// 调度器停止时 ctx 被取消，任务应在处理完当前项目后尽快返回，已完成的部分下次执行时不会重复处理
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	if interval <= 0 {
+		return
+	}
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+// Start 启动所有任务，任务在启动后先执行一次
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+}
+
+// Stop 停止调度，通知正在执行的任务中止并等待其结束；ctx 到期时不再等待并返回错误
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// loop 循环执行单个任务
+func (s *Scheduler) loop(j job) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		s.runOnce(j)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce 执行一次任务，任务 panic 不影响调度器
+func (s *Scheduler) runOnce(j job) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("定时任务 %s 异常: %v", j.name, r)
+			metrics.ObserveJob(j.name, time.Since(start), fmt.Errorf("panic: %v", r))
+		}
+	}()
+	err := j.run(s.ctx)
+	if errors.Is(err, context.Canceled) {
+		logger.Info("定时任务 %s 因服务停止而中止，剩余部分将在下次启动后继续", j.name)
+		return
+	}
+	metrics.ObserveJob(j.name, time.Since(start), err)
+	if err != nil {
+		logger.Error("定时任务 %s 执行失败: %v", j.name, err)
+		return
+	}
+	logger.Debug("定时任务 %s 执行完成，耗时 %s", j.name, time.Since(start))
+}
@@ -0,0 +1,225 @@
+package ldapauth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/huanhq99/H-Cloud/internal/config"
+)
+
+var (
+	// ErrInvalidCredentials 用户不存在或密码错误
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	// ErrAmbiguousUser 过滤器匹配到多个用户
+	ErrAmbiguousUser = errors.New("LDAP 中存在多个同名用户")
+)
+
+// syncPageSize 同步时分页搜索的每页条数，Active Directory 默认单次最多返回 1000 条
+const syncPageSize = 500
+
+// Conn LDAP 连接上用到的操作，*ldap.Conn 满足该接口；测试时可替换为内存实现
+type Conn interface {
+	Bind(username, password string) error
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// Dialer 建立 LDAP 连接
+type Dialer func() (Conn, error)
+
+// Entry 目录中的用户
+type Entry struct {
+	DN       string
+	Username string
+	Email    string
+	Groups   []string
+}
+
+// Client LDAP 认证客户端
+type Client struct {
+	Config config.LDAPConfig
+	Dial   Dialer
+}
+
+// NewClient 创建 LDAP 客户端，使用配置中的地址建立真实连接
+func NewClient(cfg config.LDAPConfig) *Client {
+	c := &Client{Config: cfg}
+	c.Dial = c.dialURL
+	return c
+}
+
+// dialURL 按配置连接 LDAP 服务器，需要时启用 StartTLS
+func (c *Client) dialURL() (Conn, error) {
+	timeout := time.Duration(c.Config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	host := ""
+	if i := strings.Index(c.Config.URL, "://"); i >= 0 {
+		host, _, _ = net.SplitHostPort(strings.TrimSuffix(c.Config.URL[i+3:], "/"))
+	}
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: c.Config.InsecureSkipVerify}
+
+	conn, err := ldap.DialURL(c.Config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("连接 LDAP 服务器失败: %w", err)
+	}
+	conn.SetTimeout(timeout)
+	if c.Config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS 失败: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// serviceConn 建立连接并以服务账号绑定
+func (c *Client) serviceConn() (Conn, error) {
+	conn, err := c.Dial()
+	if err != nil {
+		return nil, err
+	}
+	if c.Config.BindDN != "" {
+		if err := conn.Bind(c.Config.BindDN, c.Config.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP 服务账号绑定失败: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// Authenticate 按用户名或邮箱查找用户，并以其 DN 和密码绑定验证
+func (c *Client) Authenticate(username, password string) (*Entry, error) {
+	// 空密码会被服务器当作匿名绑定而"成功"，必须拒绝
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	conn, err := c.serviceConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entries, err := c.search(conn, c.loginFilter(username), 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	if len(entries) > 1 {
+		return nil, ErrAmbiguousUser
+	}
+
+	if err := conn.Bind(entries[0].DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("LDAP 绑定失败: %w", err)
+	}
+	return &entries[0], nil
+}
+
+// loginFilter 登录时查找用户的过滤器：user_filter 匹配用户名，或者在 user_filter 的范围内按邮箱属性匹配
+func (c *Client) loginFilter(identifier string) string {
+	escaped := ldap.EscapeFilter(identifier)
+	filter := strings.ReplaceAll(c.Config.UserFilter, "%s", escaped)
+	if c.Config.EmailAttr == "" || !strings.Contains(identifier, "@") {
+		return filter
+	}
+	scope := strings.ReplaceAll(c.Config.UserFilter, "%s", "*")
+	return fmt.Sprintf("(|%s(&%s(%s=%s)))", filter, scope, c.Config.EmailAttr, escaped)
+}
+
+// ListUsers 分页列出同步范围内的全部用户
+func (c *Client) ListUsers() ([]Entry, error) {
+	conn, err := c.serviceConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	filter := c.Config.SyncFilter
+	if filter == "" {
+		filter = strings.ReplaceAll(c.Config.UserFilter, "%s", "*")
+	}
+	return c.search(conn, filter, 0)
+}
+
+// MapGroups 按组映射确定角色和配额，未匹配时返回默认角色且配额为 0
+func (c *Client) MapGroups(groups []string) (role string, quota int64) {
+	for _, m := range c.Config.GroupMappings {
+		for _, g := range groups {
+			if strings.EqualFold(g, m.Group) || strings.EqualFold(groupCN(g), m.Group) {
+				return m.Role, m.Quota
+			}
+		}
+	}
+	if c.Config.DefaultRole != "" {
+		return c.Config.DefaultRole, 0
+	}
+	return "user", 0
+}
+
+// search 执行搜索并转换为 Entry；sizeLimit 为 0 时分页取回全部结果，不受服务器单次返回条数的限制
+func (c *Client) search(conn Conn, filter string, sizeLimit int) ([]Entry, error) {
+	attrs := []string{c.Config.UsernameAttr, c.Config.EmailAttr}
+	if c.Config.GroupAttr != "" {
+		attrs = append(attrs, c.Config.GroupAttr)
+	}
+	req := ldap.NewSearchRequest(c.Config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		sizeLimit, 0, false, filter, attrs, nil)
+	var result *ldap.SearchResult
+	var err error
+	if sizeLimit == 0 {
+		result, err = conn.SearchWithPaging(req, syncPageSize)
+	} else {
+		result, err = conn.Search(req)
+	}
+	if err != nil && !(sizeLimit > 0 && ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded)) {
+		return nil, fmt.Errorf("LDAP 搜索失败: %w", err)
+	}
+	if result == nil {
+		return nil, nil
+	}
+
+	entries := make([]Entry, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		entry := Entry{
+			DN:       e.DN,
+			Username: e.GetAttributeValue(c.Config.UsernameAttr),
+			Email:    strings.ToLower(e.GetAttributeValue(c.Config.EmailAttr)),
+		}
+		if c.Config.GroupAttr != "" {
+			entry.Groups = e.GetAttributeValues(c.Config.GroupAttr)
+		}
+		if entry.Username == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// groupCN 从组 DN 中取出 CN，如 cn=admins,ou=groups,dc=example,dc=com 返回 admins
+func groupCN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return ""
+	}
+	for _, attr := range parsed.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") {
+			return attr.Value
+		}
+	}
+	return ""
+}
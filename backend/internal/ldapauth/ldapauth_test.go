@@ -0,0 +1,284 @@
+package ldapauth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/huanhq99/H-Cloud/internal/config"
+)
+
+const serviceDN = "cn=svc,dc=example,dc=com"
+
+// fakeUser 内存目录中的用户
+type fakeUser struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// fakeDirectory 内存目录，按 uid 或 mail 匹配过滤器中出现的值
+type fakeDirectory struct {
+	users   []fakeUser
+	bindErr error // 不为空时所有绑定都返回该错误
+
+	filters []string // 收到的搜索过滤器
+	paged   []uint32 // 分页搜索的每页条数
+	binds   []string // 绑定过的 DN
+	closed  int
+}
+
+// dial 作为 Client.Dial 使用
+func (d *fakeDirectory) dial() (Conn, error) {
+	return &fakeConn{dir: d}, nil
+}
+
+type fakeConn struct {
+	dir *fakeDirectory
+}
+
+func (c *fakeConn) Bind(username, password string) error {
+	c.dir.binds = append(c.dir.binds, username)
+	if c.dir.bindErr != nil {
+		return c.dir.bindErr
+	}
+	if username == serviceDN && password == "svc-pass" {
+		return nil
+	}
+	for _, u := range c.dir.users {
+		if u.dn == username && u.password == password {
+			return nil
+		}
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *fakeConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.dir.filters = append(c.dir.filters, req.Filter)
+	result := &ldap.SearchResult{}
+	for _, u := range c.dir.users {
+		if c.matches(req.Filter, u) {
+			result.Entries = append(result.Entries, ldap.NewEntry(u.dn, u.attrs))
+		}
+	}
+	return result, nil
+}
+
+func (c *fakeConn) SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	c.dir.paged = append(c.dir.paged, pagingSize)
+	return c.Search(req)
+}
+
+func (c *fakeConn) Close() error {
+	c.dir.closed++
+	return nil
+}
+
+// matches 通配过滤器匹配全部用户，否则匹配 (uid=x) 或 (mail=x) 出现在过滤器中的用户
+func (c *fakeConn) matches(filter string, u fakeUser) bool {
+	if strings.Contains(filter, "(uid=*)") && !strings.Contains(filter, "(mail=") {
+		return true
+	}
+	for _, attr := range []string{"uid", "mail"} {
+		for _, v := range u.attrs[attr] {
+			if strings.Contains(filter, "("+attr+"="+ldap.EscapeFilter(v)+")") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newTestClient(dir *fakeDirectory) *Client {
+	c := NewClient(config.LDAPConfig{
+		BindDN:       serviceDN,
+		BindPassword: "svc-pass",
+		BaseDN:       "dc=example,dc=com",
+		UserFilter:   "(&(objectClass=person)(uid=%s))",
+		UsernameAttr: "uid",
+		EmailAttr:    "mail",
+		GroupAttr:    "memberOf",
+		GroupMappings: []config.GroupMapping{
+			{Group: "cn=admins,ou=groups,dc=example,dc=com", Role: "admin", Quota: 100},
+			{Group: "staff", Role: "user", Quota: 10},
+		},
+		DefaultRole: "guest",
+	})
+	c.Dial = dir.dial
+	return c
+}
+
+func testDirectory() *fakeDirectory {
+	return &fakeDirectory{users: []fakeUser{
+		{
+			dn:       "uid=alice,ou=people,dc=example,dc=com",
+			password: "alice-pass",
+			attrs: map[string][]string{
+				"uid":      {"alice"},
+				"mail":     {"Alice@Example.com"},
+				"memberOf": {"cn=staff,ou=groups,dc=example,dc=com", "cn=admins,ou=groups,dc=example,dc=com"},
+			},
+		},
+		{
+			dn:       "uid=bob,ou=people,dc=example,dc=com",
+			password: "bob-pass",
+			attrs:    map[string][]string{"uid": {"bob"}},
+		},
+	}}
+}
+
+func TestAuthenticate(t *testing.T) {
+	dir := testDirectory()
+	c := newTestClient(dir)
+
+	entry, err := c.Authenticate("alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if entry.DN != "uid=alice,ou=people,dc=example,dc=com" || entry.Username != "alice" {
+		t.Errorf("用户不正确: %+v", entry)
+	}
+	if entry.Email != "alice@example.com" {
+		t.Errorf("邮箱应转为小写，实际为 %q", entry.Email)
+	}
+	if len(entry.Groups) != 2 {
+		t.Errorf("组不正确: %v", entry.Groups)
+	}
+	if len(dir.binds) != 2 || dir.binds[0] != serviceDN || dir.binds[1] != entry.DN {
+		t.Errorf("应先以服务账号绑定再以用户 DN 绑定，实际为 %v", dir.binds)
+	}
+	if dir.closed != 1 {
+		t.Errorf("连接应关闭一次，实际为 %d", dir.closed)
+	}
+}
+
+func TestAuthenticateByEmail(t *testing.T) {
+	dir := testDirectory()
+	c := newTestClient(dir)
+
+	entry, err := c.Authenticate("Alice@Example.com", "alice-pass")
+	if err != nil {
+		t.Fatalf("邮箱登录失败: %v", err)
+	}
+	if entry.Username != "alice" {
+		t.Errorf("用户不正确: %+v", entry)
+	}
+	want := "(|(&(objectClass=person)(uid=Alice@Example.com))(&(&(objectClass=person)(uid=*))(mail=Alice@Example.com)))"
+	if dir.filters[0] != want {
+		t.Errorf("过滤器不正确:\n got %s\nwant %s", dir.filters[0], want)
+	}
+
+	// 不含 @ 的用户名只按 user_filter 匹配
+	if _, err := c.Authenticate("bob", "bob-pass"); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if got := dir.filters[1]; got != "(&(objectClass=person)(uid=bob))" {
+		t.Errorf("过滤器不正确: %s", got)
+	}
+}
+
+func TestAuthenticateEscapesFilter(t *testing.T) {
+	dir := testDirectory()
+	c := newTestClient(dir)
+
+	if _, err := c.Authenticate("*)(uid=*", "x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("应返回 ErrInvalidCredentials，实际为 %v", err)
+	}
+	if strings.Contains(dir.filters[0], "(uid=*)") {
+		t.Errorf("用户名未转义: %s", dir.filters[0])
+	}
+}
+
+func TestAuthenticateFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		bindErr  error
+		want     error
+	}{
+		{name: "密码错误", username: "alice", password: "wrong", want: ErrInvalidCredentials},
+		{name: "空密码", username: "alice", password: "", want: ErrInvalidCredentials},
+		{name: "用户不存在", username: "carol", password: "x", want: ErrInvalidCredentials},
+		{name: "服务账号绑定失败", username: "alice", password: "alice-pass",
+			bindErr: ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testDirectory()
+			dir.bindErr = tt.bindErr
+			c := newTestClient(dir)
+
+			entry, err := c.Authenticate(tt.username, tt.password)
+			if entry != nil || err == nil {
+				t.Fatalf("应登录失败，实际为 %+v, %v", entry, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("错误应为 %v，实际为 %v", tt.want, err)
+			}
+			// 服务账号绑定失败是配置问题，不能当作用户密码错误
+			if tt.bindErr != nil && errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("服务账号绑定失败不应返回 ErrInvalidCredentials: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAmbiguous(t *testing.T) {
+	dir := testDirectory()
+	dir.users = append(dir.users, fakeUser{
+		dn:       "uid=alice,ou=contractors,dc=example,dc=com",
+		password: "alice-pass",
+		attrs:    map[string][]string{"uid": {"alice"}},
+	})
+	c := newTestClient(dir)
+
+	if _, err := c.Authenticate("alice", "alice-pass"); !errors.Is(err, ErrAmbiguousUser) {
+		t.Fatalf("应返回 ErrAmbiguousUser，实际为 %v", err)
+	}
+}
+
+func TestListUsersUsesPaging(t *testing.T) {
+	dir := testDirectory()
+	dir.users = append(dir.users, fakeUser{dn: "cn=nouid,dc=example,dc=com", attrs: map[string][]string{"cn": {"nouid"}}})
+	c := newTestClient(dir)
+
+	entries, err := c.ListUsers()
+	if err != nil {
+		t.Fatalf("列出用户失败: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("应跳过没有用户名属性的条目，实际返回 %d 个", len(entries))
+	}
+	if len(dir.paged) != 1 || dir.paged[0] != syncPageSize {
+		t.Errorf("应使用分页搜索，实际为 %v", dir.paged)
+	}
+	if dir.filters[0] != "(&(objectClass=person)(uid=*))" {
+		t.Errorf("未配置 sync_filter 时应由 user_filter 推导，实际为 %s", dir.filters[0])
+	}
+}
+
+func TestMapGroups(t *testing.T) {
+	c := newTestClient(testDirectory())
+
+	tests := []struct {
+		name   string
+		groups []string
+		role   string
+		quota  int64
+	}{
+		{name: "按完整 DN 匹配", groups: []string{"CN=Admins,OU=Groups,DC=example,DC=com"}, role: "admin", quota: 100},
+		{name: "按 CN 匹配", groups: []string{"cn=staff,ou=groups,dc=example,dc=com"}, role: "user", quota: 10},
+		{name: "取第一个匹配的映射", groups: []string{"cn=staff,dc=x", "cn=admins,ou=groups,dc=example,dc=com"}, role: "admin", quota: 100},
+		{name: "未匹配时使用默认角色", groups: []string{"cn=other,dc=x"}, role: "guest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, quota := c.MapGroups(tt.groups)
+			if role != tt.role || quota != tt.quota {
+				t.Errorf("得到 %s/%d，期望 %s/%d", role, quota, tt.role, tt.quota)
+			}
+		})
+	}
+}
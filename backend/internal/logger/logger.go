@@ -1,14 +1,15 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
-
-	"github.com/gin-gonic/gin"
 )
 
 // LogLevel 日志级别
@@ -22,76 +23,146 @@ const (
 	FATAL
 )
 
-var levelNames = map[LogLevel]string{
-	DEBUG: "DEBUG",
-	INFO:  "INFO",
-	WARN:  "WARN",
-	ERROR: "ERROR",
-	FATAL: "FATAL",
+var slogLevels = map[LogLevel]slog.Level{
+	DEBUG: slog.LevelDebug,
+	INFO:  slog.LevelInfo,
+	WARN:  slog.LevelWarn,
+	ERROR: slog.LevelError,
+	FATAL: slog.LevelError + 4,
+}
+
+// ParseLevel 解析配置中的日志级别，无法识别时使用 INFO
+func ParseLevel(s string) LogLevel {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return DEBUG
+	case "warn", "warning":
+		return WARN
+	case "error":
+		return ERROR
+	default:
+		return INFO
+	}
+}
+
+// Options 日志配置
+type Options struct {
+	Level      string // debug、info、warn、error
+	Format     string // json 或 text
+	File       string // 日志文件，为空时输出到标准输出
+	MaxSize    int    // 单个日志文件最大大小（MB），超过后轮转
+	MaxBackups int    // 保留的旧日志文件数量，0 表示不限
+	MaxAge     int    // 旧日志文件保留天数，0 表示不限
 }
 
 // Logger 日志记录器
 type Logger struct {
-	level  LogLevel
-	logger *log.Logger
+	handler slog.Handler
+	closer  io.Closer
 }
 
-var defaultLogger *Logger
+var defaultLogger atomic.Pointer[Logger]
 
-// Init 初始化日志系统
-func Init(logLevel LogLevel, logFile string) error {
-	var output *os.File
-	var err error
+// level 当前日志级别，可在运行时修改
+var level slog.LevelVar
 
-	if logFile != "" {
-		// 确保日志目录存在
-		logDir := filepath.Dir(logFile)
-		if err := os.MkdirAll(logDir, 0755); err != nil {
-			return fmt.Errorf("创建日志目录失败: %v", err)
-		}
-
-		output, err = os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+// Init 初始化日志系统，可重复调用以应用新配置
+func Init(opts Options) error {
+	var out io.Writer = os.Stdout
+	var closer io.Closer
+	if opts.File != "" {
+		f, err := openRotatingFile(opts.File, opts.MaxSize, opts.MaxBackups, opts.MaxAge)
 		if err != nil {
-			return fmt.Errorf("打开日志文件失败: %v", err)
+			return err
 		}
+		out, closer = f, f
+	}
+
+	SetLevel(opts.Level)
+	handlerOpts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       &level,
+		ReplaceAttr: replaceAttr,
+	}
+	var handler slog.Handler
+	if strings.ToLower(opts.Format) == "text" {
+		handler = slog.NewTextHandler(out, handlerOpts)
 	} else {
-		output = os.Stdout
+		handler = slog.NewJSONHandler(out, handlerOpts)
 	}
 
-	defaultLogger = &Logger{
-		level:  logLevel,
-		logger: log.New(output, "", 0),
+	old := defaultLogger.Swap(&Logger{handler: handler, closer: closer})
+	if old != nil && old.closer != nil {
+		old.closer.Close()
 	}
+	return nil
+}
 
+// SetLevel 修改日志级别，立即生效
+func SetLevel(s string) {
+	level.Set(slogLevels[ParseLevel(s)])
+}
+
+// Close 关闭日志文件，之后的日志按默认配置输出到标准输出；服务退出前调用
+func Close() error {
+	old := defaultLogger.Swap(nil)
+	if old != nil && old.closer != nil {
+		return old.closer.Close()
+	}
 	return nil
 }
 
+// replaceAttr 调整输出格式：FATAL 级别显示名称，源码位置只保留文件名和行号
+func replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Key {
+	case slog.LevelKey:
+		if lvl, ok := a.Value.Any().(slog.Level); ok && lvl >= slogLevels[FATAL] {
+			return slog.String(slog.LevelKey, "FATAL")
+		}
+	case slog.SourceKey:
+		if src, ok := a.Value.Any().(*slog.Source); ok {
+			file := src.File
+			if i := strings.LastIndex(file, "/"); i >= 0 {
+				if j := strings.LastIndex(file[:i], "/"); j >= 0 {
+					file = file[j+1:]
+				}
+			}
+			return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", file, src.Line))
+		}
+	}
+	return a
+}
+
 // GetLogger 获取默认日志记录器
 func GetLogger() *Logger {
-	if defaultLogger == nil {
-		// 如果没有初始化，使用默认配置
-		Init(INFO, "")
+	if l := defaultLogger.Load(); l != nil {
+		return l
 	}
-	return defaultLogger
+	// 如果没有初始化，使用默认配置
+	Init(Options{})
+	return defaultLogger.Load()
 }
 
-// formatMessage 格式化日志消息
-func (l *Logger) formatMessage(level LogLevel, message string) string {
-	now := time.Now().Format("2006-01-02 15:04:05")
-	_, file, line, _ := runtime.Caller(3)
-	filename := filepath.Base(file)
-	return fmt.Sprintf("[%s] %s %s:%d - %s", now, levelNames[level], filename, line, message)
+// Slog 返回底层的 slog 记录器，供需要 slog 接口的第三方库使用
+func Slog() *slog.Logger {
+	return slog.New(GetLogger().handler)
 }
 
-// log 记录日志
-func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
-	if level < l.level {
+// log 记录日志，skip 为调用栈中需要跳过的层数，用于定位实际调用位置
+func (l *Logger) log(skip int, level LogLevel, attrs []any, format string, args ...interface{}) {
+	slvl := slogLevels[level]
+	if !l.handler.Enabled(context.Background(), slvl) {
 		return
 	}
-
-	message := fmt.Sprintf(format, args...)
-	formatted := l.formatMessage(level, message)
-	l.logger.Println(formatted)
+	msg := format
+	if len(args) > 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	var pcs [1]uintptr
+	runtime.Callers(skip, pcs[:])
+	r := slog.NewRecord(time.Now(), slvl, msg, pcs[0])
+	r.Add(attrs...)
+	l.handler.Handle(context.Background(), r)
 
 	if level == FATAL {
 		os.Exit(1)
@@ -100,111 +171,83 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 
 // Debug 调试日志
 func (l *Logger) Debug(format string, args ...interface{}) {
-	l.log(DEBUG, format, args...)
+	l.log(3, DEBUG, nil, format, args...)
 }
 
 // Info 信息日志
 func (l *Logger) Info(format string, args ...interface{}) {
-	l.log(INFO, format, args...)
+	l.log(3, INFO, nil, format, args...)
 }
 
 // Warn 警告日志
 func (l *Logger) Warn(format string, args ...interface{}) {
-	l.log(WARN, format, args...)
+	l.log(3, WARN, nil, format, args...)
 }
 
 // Error 错误日志
 func (l *Logger) Error(format string, args ...interface{}) {
-	l.log(ERROR, format, args...)
+	l.log(3, ERROR, nil, format, args...)
 }
 
 // Fatal 致命错误日志
 func (l *Logger) Fatal(format string, args ...interface{}) {
-	l.log(FATAL, format, args...)
+	l.log(3, FATAL, nil, format, args...)
 }
 
 // 全局日志函数
 func Debug(format string, args ...interface{}) {
-	GetLogger().Debug(format, args...)
+	GetLogger().log(3, DEBUG, nil, format, args...)
 }
 
 func Info(format string, args ...interface{}) {
-	GetLogger().Info(format, args...)
+	GetLogger().log(3, INFO, nil, format, args...)
 }
 
 func Warn(format string, args ...interface{}) {
-	GetLogger().Warn(format, args...)
+	GetLogger().log(3, WARN, nil, format, args...)
 }
 
 func Error(format string, args ...interface{}) {
-	GetLogger().Error(format, args...)
+	GetLogger().log(3, ERROR, nil, format, args...)
 }
 
 func Fatal(format string, args ...interface{}) {
-	GetLogger().Fatal(format, args...)
+	GetLogger().log(3, FATAL, nil, format, args...)
 }
 
-// LogRequest 记录请求日志
-func LogRequest(ctx *gin.Context, message string, args ...interface{}) {
-	requestID := ctx.GetHeader("X-Request-ID")
-	if requestID == "" {
-		requestID = "unknown"
-	}
-
-	userAgent := ctx.GetHeader("User-Agent")
-	clientIP := ctx.ClientIP()
-	method := ctx.Request.Method
-	path := ctx.Request.URL.Path
-
-	logMessage := fmt.Sprintf("[%s] %s %s %s %s - %s", 
-		requestID, clientIP, method, path, userAgent, fmt.Sprintf(message, args...))
-	
-	GetLogger().Info(logMessage)
+// Entry 附带固定字段的日志记录器，字段以键值对形式给出
+type Entry struct {
+	attrs []any
 }
 
-// LogError 记录错误日志
-func LogError(ctx *gin.Context, err error, message string, args ...interface{}) {
-	requestID := ctx.GetHeader("X-Request-ID")
-	if requestID == "" {
-		requestID = "unknown"
-	}
-
-	method := ctx.Request.Method
-	path := ctx.Request.URL.Path
+// With 创建附带字段的日志记录器
+func With(args ...any) *Entry {
+	return &Entry{attrs: args}
+}
 
-	logMessage := fmt.Sprintf("[%s] %s %s - %s - Error: %v", 
-		requestID, method, path, fmt.Sprintf(message, args...), err)
-	
-	GetLogger().Error(logMessage)
+// With 追加字段
+func (e *Entry) With(args ...any) *Entry {
+	attrs := make([]any, 0, len(e.attrs)+len(args))
+	attrs = append(attrs, e.attrs...)
+	return &Entry{attrs: append(attrs, args...)}
 }
 
-// LogFileOperation 记录文件操作日志
-func LogFileOperation(ctx *gin.Context, operation, filename, path string, size int64) {
-	requestID := ctx.GetHeader("X-Request-ID")
-	if requestID == "" {
-		requestID = "unknown"
-	}
+// Debug 调试日志
+func (e *Entry) Debug(format string, args ...interface{}) {
+	GetLogger().log(3, DEBUG, e.attrs, format, args...)
+}
 
-	clientIP := ctx.ClientIP()
-	
-	logMessage := fmt.Sprintf("[%s] %s - %s: %s (path: %s, size: %d bytes)", 
-		requestID, clientIP, operation, filename, path, size)
-	
-	GetLogger().Info(logMessage)
+// Info 信息日志
+func (e *Entry) Info(format string, args ...interface{}) {
+	GetLogger().log(3, INFO, e.attrs, format, args...)
 }
 
-// LogSecurityEvent 记录安全事件日志
-func LogSecurityEvent(ctx *gin.Context, event, details string) {
-	requestID := ctx.GetHeader("X-Request-ID")
-	if requestID == "" {
-		requestID = "unknown"
-	}
+// Warn 警告日志
+func (e *Entry) Warn(format string, args ...interface{}) {
+	GetLogger().log(3, WARN, e.attrs, format, args...)
+}
 
-	clientIP := ctx.ClientIP()
-	userAgent := ctx.GetHeader("User-Agent")
-	
-	logMessage := fmt.Sprintf("[SECURITY] [%s] %s - %s: %s (UA: %s)", 
-		requestID, clientIP, event, details, userAgent)
-	
-	GetLogger().Warn(logMessage)
-}
\ No newline at end of file
+// Error 错误日志
+func (e *Entry) Error(format string, args ...interface{}) {
+	GetLogger().log(3, ERROR, e.attrs, format, args...)
+}
@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDKey 上下文中保存请求 ID 的键
+const RequestIDKey = "requestID"
+
+// RequestIDHeader 传递请求 ID 的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID 请求 ID 中间件。
+// 沿用反向代理传入的合法请求 ID，否则生成新的，并写入响应头，便于按 ID 关联客户端报错和服务端日志
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID 只接受长度合理、由字母数字和 "-_." 组成的 ID，防止日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// GetRequestID 获取当前请求的 ID
+func GetRequestID(c *gin.Context) string {
+	if id := c.GetString(RequestIDKey); id != "" {
+		return id
+	}
+	return c.GetHeader(RequestIDHeader)
+}
+
+// Request 返回附带请求 ID 和用户 ID 的日志记录器，处理函数中的日志都应通过它记录
+func Request(c *gin.Context) *Entry {
+	attrs := []any{"request_id", GetRequestID(c)}
+	if id := c.GetUint("userID"); id != 0 {
+		attrs = append(attrs, "user_id", id)
+	}
+	return &Entry{attrs: attrs}
+}
+
+// AccessLog 访问日志中间件，记录每个请求的方法、路径、状态码、耗时、响应大小和用户。
+// 5xx 按错误级别、4xx 按警告级别记录；skipPaths 中的路径（如健康检查）成功时不记录
+func AccessLog(skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, p := range skipPaths {
+		skip[p] = true
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		status := c.Writer.Status()
+		if skip[path] && status < http.StatusBadRequest {
+			return
+		}
+		// 只记录路由模板，原始路径中的参数可能是删除令牌、分享 ID 等凭据；未匹配的路由才记录原始路径
+		route := c.FullPath()
+		if route == "" {
+			route = path
+		}
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		attrs := []any{
+			"request_id", GetRequestID(c),
+			"method", c.Request.Method,
+			"route", route,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", size,
+			"ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+		}
+		if id := c.GetUint("userID"); id != 0 {
+			attrs = append(attrs, "user_id", id)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		level := INFO
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = ERROR
+		case status >= http.StatusBadRequest:
+			level = WARN
+		}
+		GetLogger().log(2, level, attrs, "%s %s %d", c.Request.Method, route, status)
+	}
+}
+
+// Recovery 捕获处理函数中的 panic，记录堆栈后调用 handle 输出错误响应
+func Recovery(handle gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				Request(c).With("stack", string(debug.Stack())).Error("请求处理发生 panic: %v", err)
+				if !c.Writer.Written() {
+					handle(c)
+				}
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
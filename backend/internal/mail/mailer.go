@@ -0,0 +1,129 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+)
+
+// 邮件模板名称
+const (
+	TemplateVerifyEmail     = "verify_email"
+	TemplatePasswordReset   = "password_reset"
+	TemplateAccountApproved = "account_approved"
+	TemplateFileQuarantined = "file_quarantined"
+)
+
+//go:embed templates/*.txt templates/*.html
+var templateFS embed.FS
+
+// Mailer 使用模板渲染并发送邮件
+type Mailer struct {
+	Transport Transport
+	From      string
+	BaseURL   string // 邮件中链接使用的站点地址
+
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// New 根据配置创建邮件发送器；baseURL 为未配置 mail.base_url 时使用的站点地址
+func New(cfg config.MailConfig, baseURL string) (*Mailer, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.ParseFS(templateFS, "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BaseURL != "" {
+		baseURL = cfg.BaseURL
+	}
+	return &Mailer{
+		Transport: transport,
+		From:      cfg.From,
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		text:      text,
+		html:      html,
+	}, nil
+}
+
+// Link 生成站点内链接
+func (m *Mailer) Link(path string) string {
+	return m.BaseURL + path
+}
+
+// Render 渲染模板，返回待发送的邮件
+func (m *Mailer) Render(to, name string, data interface{}) (*Message, error) {
+	var subject, text, html bytes.Buffer
+	if err := m.text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return nil, fmt.Errorf("渲染邮件模板 %s 失败: %w", name, err)
+	}
+	if err := m.text.ExecuteTemplate(&text, name+".text", data); err != nil {
+		return nil, fmt.Errorf("渲染邮件模板 %s 失败: %w", name, err)
+	}
+	if m.html.Lookup(name+".html") != nil {
+		if err := m.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+			return nil, fmt.Errorf("渲染邮件模板 %s 失败: %w", name, err)
+		}
+	}
+	return &Message{
+		From:    m.From,
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// Send 渲染模板并立即发送
+func (m *Mailer) Send(to, name string, data interface{}) error {
+	msg, err := m.Render(to, name, data)
+	if err != nil {
+		return err
+	}
+	return m.Transport.Send(msg)
+}
+
+// SendAsync 在后台发送，失败只记录日志；
+// 用于不应让请求等待邮件服务器、也不应通过响应时间暴露账户是否存在的场景
+func (m *Mailer) SendAsync(to, name string, data interface{}) {
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+		if err := m.Send(to, name, data); err != nil {
+			logger.Error("发送邮件 %s 到 %s 失败: %v", name, to, err)
+		}
+	}()
+}
+
+// pending 正在后台发送的邮件
+var pending sync.WaitGroup
+
+// Wait 等待后台发送的邮件全部完成，ctx 到期时不再等待并返回错误；服务停止时调用，避免丢失邮件
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
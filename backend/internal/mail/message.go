@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Message 待发送的邮件
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string // 纯文本正文
+	HTML    string // HTML 正文，可为空
+}
+
+// Bytes 生成符合 RFC 5322 的邮件内容，有 HTML 正文时使用 multipart/alternative
+func (m *Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("发件人地址无效: %w", err)
+	}
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return nil, fmt.Errorf("收件人地址无效: %w", err)
+	}
+
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.BEncoding.Encode("UTF-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header("Content-Type", `text/plain; charset="UTF-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQP(&buf, m.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	boundary := "hc-" + randomToken(12)
+	header("Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, boundary))
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ typ, body string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=\"UTF-8\"\r\n", part.typ)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQP(&buf, part.body); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+// Recipient 收件人的纯地址部分，用于 SMTP RCPT TO
+func (m *Message) Recipient() (string, error) {
+	addr, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return "", err
+	}
+	return addr.Address, nil
+}
+
+// Sender 发件人的纯地址部分，用于 SMTP MAIL FROM
+func (m *Message) Sender() (string, error) {
+	addr, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return "", err
+	}
+	return addr.Address, nil
+}
+
+// writeQP 以 quoted-printable 编码写入正文，统一使用 CRLF 换行
+func writeQP(buf *bytes.Buffer, body string) error {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+	w := quotedprintable.NewWriter(buf)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// messageID 生成 Message-ID，域名取自发件人地址
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), randomToken(8), domain)
+}
+
+// randomToken 生成 n 字节的随机十六进制字符串
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidAddress 校验邮箱地址格式，只接受不带显示名的纯地址
+func ValidAddress(addr string) bool {
+	parsed, err := mail.ParseAddress(addr)
+	return err == nil && parsed.Address == addr && parsed.Name == ""
+}
@@ -0,0 +1,142 @@
+package mail
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+)
+
+// Transport 邮件发送方式
+type Transport interface {
+	Send(msg *Message) error
+}
+
+// NewTransport 根据配置创建发送方式
+func NewTransport(cfg config.MailConfig) (Transport, error) {
+	switch cfg.Transport {
+	case "smtp":
+		if cfg.SMTP.Host == "" {
+			return nil, fmt.Errorf("未配置 SMTP 服务器")
+		}
+		return &SMTPTransport{Config: cfg.SMTP}, nil
+	case "file":
+		if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+			return nil, err
+		}
+		return &FileTransport{Dir: cfg.Dir}, nil
+	case "log", "":
+		return LogTransport{}, nil
+	default:
+		return nil, fmt.Errorf("未知的邮件发送方式: %s", cfg.Transport)
+	}
+}
+
+// SMTPTransport 通过 SMTP 服务器发送
+type SMTPTransport struct {
+	Config config.SMTPConfig
+}
+
+// Send 连接 SMTP 服务器发送邮件，支持 STARTTLS 和隐式 TLS
+func (t *SMTPTransport) Send(msg *Message) error {
+	from, err := msg.Sender()
+	if err != nil {
+		return err
+	}
+	to, err := msg.Recipient()
+	if err != nil {
+		return err
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	cfg := t.Config
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if cfg.Encryption == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if cfg.Encryption == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP 服务器不支持 STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS 失败: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// FileTransport 把邮件写入目录（.eml 文件），用于测试和开发
+type FileTransport struct {
+	Dir string
+}
+
+// Send 写入一个 .eml 文件
+func (t *FileTransport) Send(msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), randomToken(4))
+	return os.WriteFile(filepath.Join(t.Dir, name), data, 0o600)
+}
+
+// LogTransport 只把邮件内容写入日志，未配置邮件服务时使用
+type LogTransport struct{}
+
+// Send 记录邮件内容
+func (LogTransport) Send(msg *Message) error {
+	logger.Info("[MAIL] 收件人: %s 主题: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
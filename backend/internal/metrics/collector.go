@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"github.com/huanhq99/H-Cloud/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+func desc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+}
+
+var (
+	storageTotalDesc = desc("storage_total_bytes", "存储所在文件系统的总容量")
+	storageUsedDesc  = desc("storage_used_bytes", "存储所在文件系统的已用容量")
+	storageFreeDesc  = desc("storage_free_bytes", "存储所在文件系统的可用容量")
+	userUsedDesc     = desc("user_storage_used_bytes", "用户已用存储空间", "user")
+	userQuotaDesc    = desc("user_storage_quota_bytes", "用户存储配额", "user")
+	recycleItemsDesc = desc("recycle_bin_items", "回收站中的项目数")
+	recycleBytesDesc = desc("recycle_bin_bytes", "回收站中项目的总大小")
+	sharesDesc       = desc("shares_active", "未过期的分享数")
+	scrapeErrorDesc  = desc("app_scrape_errors", "本次采集中失败的查询数")
+)
+
+// appCollector 在每次采集时从数据库和存储读取业务指标
+type appCollector struct {
+	db *gorm.DB
+}
+
+// Describe 实现 prometheus.Collector
+func (c *appCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		storageTotalDesc, storageUsedDesc, storageFreeDesc,
+		userUsedDesc, userQuotaDesc, recycleItemsDesc, recycleBytesDesc,
+		sharesDesc, scrapeErrorDesc,
+	} {
+		ch <- d
+	}
+}
+
+// Collect 实现 prometheus.Collector；单项查询失败时跳过该指标并记录日志，不影响其余指标
+func (c *appCollector) Collect(ch chan<- prometheus.Metric) {
+	failed := 0
+	fail := func(what string, err error) {
+		failed++
+		logger.Warn("采集%s指标失败: %v", what, err)
+	}
+
+	if total, used, free, err := storage.GetSystemStorageInfo(); err != nil {
+		fail("存储容量", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(storageTotalDesc, prometheus.GaugeValue, float64(total))
+		ch <- prometheus.MustNewConstMetric(storageUsedDesc, prometheus.GaugeValue, float64(used))
+		ch <- prometheus.MustNewConstMetric(storageFreeDesc, prometheus.GaugeValue, float64(free))
+	}
+
+	var users []model.User
+	if err := c.db.Select("username", "storage_used", "storage_quota").Find(&users).Error; err != nil {
+		fail("用户配额", err)
+	} else {
+		for _, u := range users {
+			ch <- prometheus.MustNewConstMetric(userUsedDesc, prometheus.GaugeValue, float64(u.StorageUsed), u.Username)
+			ch <- prometheus.MustNewConstMetric(userQuotaDesc, prometheus.GaugeValue, float64(u.StorageQuota), u.Username)
+		}
+	}
+
+	var recycle struct {
+		Items int64
+		Bytes int64
+	}
+	if err := c.db.Model(&model.RecycleBin{}).Select("COUNT(*) AS items, COALESCE(SUM(size), 0) AS bytes").Scan(&recycle).Error; err != nil {
+		fail("回收站", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(recycleItemsDesc, prometheus.GaugeValue, float64(recycle.Items))
+		ch <- prometheus.MustNewConstMetric(recycleBytesDesc, prometheus.GaugeValue, float64(recycle.Bytes))
+	}
+
+	var shares int64
+	if err := c.db.Model(&model.Share{}).Where("no_expire = ? OR expire_at > ?", true, time.Now()).Count(&shares).Error; err != nil {
+		fail("分享", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(sharesDesc, prometheus.GaugeValue, float64(shares))
+	}
+
+	ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, float64(failed))
+}
+
+// RegisterDB 注册依赖数据库的指标：业务指标和连接池状态
+func RegisterDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := Registry.Register(&appCollector{db: db}); err != nil {
+		return err
+	}
+	return Registry.Register(collectors.NewDBStatsCollector(sqlDB, db.Dialector.Name()))
+}
+
+// errorLog 将指标输出过程中的错误写入应用日志
+type errorLog struct{}
+
+func (errorLog) Println(v ...interface{}) {
+	logger.Warn("输出指标失败: %s", fmt.Sprint(v...))
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace 所有指标名称的前缀
+const namespace = "hcloud"
+
+// 传输方向
+const (
+	Upload   = "upload"
+	Download = "download"
+)
+
+// Registry 应用指标注册表，不使用 Prometheus 的全局注册表，避免依赖库注册的指标混入
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP 请求数，按方法、路由和状态码统计",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP 请求处理耗时（秒）",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route"})
+
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "正在处理的 HTTP 请求数",
+	})
+
+	transferBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_bytes_total",
+		Help:      "成功上传、下载的文件字节数",
+	}, []string{"direction"})
+
+	transfersInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "transfers_in_flight",
+		Help:      "正在进行的上传、下载数",
+	}, []string{"direction"})
+
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "runs_total",
+		Help:      "定时任务执行次数，按任务和结果（success、failure）统计",
+	}, []string{"job", "result"})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "duration_seconds",
+		Help:      "定时任务执行耗时（秒）",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"job"})
+
+	jobLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "定时任务最近一次成功执行的时间（Unix 时间戳）",
+	}, []string{"job"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, httpInFlight, transferBytes, transfersInFlight,
+		jobRuns, jobDuration, jobLastSuccess,
+	)
+	for _, d := range []string{Upload, Download} {
+		transferBytes.WithLabelValues(d)
+		transfersInFlight.WithLabelValues(d)
+	}
+}
+
+// Middleware 统计 HTTP 请求数和耗时。
+// 路由取注册时的模板（如 /api/files/download/:id），未匹配的请求统一记为 unmatched，避免标签数量无限增长
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// activeTransfers 正在进行的传输数，服务停止时用于等待传输完成
+var activeTransfers atomic.Int64
+
+// ActiveTransfers 返回正在进行的上传和下载总数
+func ActiveTransfers() int64 {
+	return activeTransfers.Load()
+}
+
+// Transfer 统计文件传输字节数和正在进行的传输数，挂在上传和下载接口上；字节数只统计成功的请求。
+// 上传按实际读取的请求体计算，下载按写出的响应体计算
+func Transfer(direction string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		activeTransfers.Add(1)
+		transfersInFlight.WithLabelValues(direction).Inc()
+		defer func() {
+			activeTransfers.Add(-1)
+			transfersInFlight.WithLabelValues(direction).Dec()
+		}()
+
+		var body *countingReader
+		if direction == Upload && c.Request.Body != nil {
+			body = &countingReader{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+		var n int64
+		if body != nil {
+			n = body.n.Load()
+		} else if size := c.Writer.Size(); size > 0 {
+			n = int64(size)
+		}
+		transferBytes.WithLabelValues(direction).Add(float64(n))
+	}
+}
+
+// countingReader 记录已读取字节数的请求体
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// ObserveJob 记录一次定时任务的执行结果
+func ObserveJob(name string, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	} else {
+		jobLastSuccess.WithLabelValues(name).SetToCurrentTime()
+	}
+	jobRuns.WithLabelValues(name, result).Inc()
+	jobDuration.WithLabelValues(name).Observe(d.Seconds())
+}
+
+// Handler 以 Prometheus 文本格式输出全部指标
+func Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{ErrorLog: errorLog{}})
+	return gin.WrapH(h)
+}
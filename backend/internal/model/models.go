@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,22 +13,35 @@ type User struct {
 	Username     string `gorm:"uniqueIndex;not null"`
 	Password     string `gorm:"not null"`
 	Email        string `gorm:"uniqueIndex;not null"`
-	Role         string `gorm:"default:user"` // admin 或 user
+	Role         string `gorm:"default:user"`        // 角色名称，对应 Role.Name
 	StorageQuota int64  `gorm:"default:10737418240"` // 默认10GB，单位字节
 	StorageUsed  int64  `gorm:"default:0"`
 	LastLogin    time.Time
+	TOTPSecret   string // 两步验证密钥（Base32），启用前为待激活密钥
+	TOTPEnabled  bool   `gorm:"default:false"`
+	TOTPLastStep int64  `gorm:"default:0"`                 // 最近一次通过验证的时间步，防止验证码重放
+	AuthSource   string `gorm:"default:local"`             // 账户来源：local、oidc、ldap
+	OIDCSubject  string `gorm:"column:oidc_subject;index"` // 关联的 OIDC 用户标识（sub）
+	LDAPDN       string `gorm:"column:ldap_dn;index"`      // 关联的 LDAP 条目 DN
+	Disabled     bool   `gorm:"default:false"`             // 禁用后无法登录，已有会话和令牌失效
+
+	MustChangePassword bool       `gorm:"default:false"` // 下次登录后必须先修改密码（如初始管理员）
+	EmailVerifiedAt    *time.Time // 邮箱验证时间，nil 表示未验证
+	PendingApproval    bool       `gorm:"default:false;index"` // 等待管理员审核，审核通过前无法登录
+	InviteCodeID       *uint      // 注册时使用的邀请码
+	Locale             string     `gorm:"size:16"` // 首选语言（如 en-US），为空时按 Accept-Language 选择
 }
 
 // Directory 目录模型
 type Directory struct {
-    gorm.Model
-    Name        string `gorm:"not null"`
-    Path        string `gorm:"not null;uniqueIndex:idx_user_path"`
-    UserID      uint   `gorm:"index;uniqueIndex:idx_user_path"`
-    ParentID    *uint  `gorm:"index"` // 父目录ID，根目录为nil
-    IsMapping   bool   `gorm:"default:false"` // 是否为映射目录
-    MappingPath string // 映射到本地的路径
-    Files       []File `gorm:"foreignKey:DirectoryID"`
+	gorm.Model
+	Name        string `gorm:"not null"`
+	Path        string `gorm:"not null;uniqueIndex:idx_user_path"`
+	UserID      uint   `gorm:"index;uniqueIndex:idx_user_path"`
+	ParentID    *uint  `gorm:"index"`         // 父目录ID，根目录为nil
+	IsMapping   bool   `gorm:"default:false"` // 是否为映射目录
+	MappingPath string // 映射到本地的路径
+	Files       []File `gorm:"foreignKey:DirectoryID"`
 }
 
 // File 文件模型
@@ -42,32 +56,181 @@ type File struct {
 	DirectoryID uint   `gorm:"index"`
 	IsMapping   bool   `gorm:"default:false"` // 是否为映射文件
 	MappingPath string // 映射到本地的路径
+	DeleteToken string `gorm:"index"` // 图床删除链接令牌，仅通过图床接口上传的文件才有
+
+	// 病毒扫描
+	ScannedAt      *time.Time // 最近一次扫描时间，未扫描时为空
+	Quarantined    bool       `gorm:"default:false;index"` // 检出恶意内容，已移入隔离区
+	QuarantinePath string     // 隔离区中的文件名，Path 保留原位置以便恢复
+	Signature      string     // 检出的病毒名称
 }
 
 // Share 分享模型
 type Share struct {
-    gorm.Model
-    UUID        string    `gorm:"uniqueIndex;not null"` // 分享的唯一标识
-    UserID      uint      `gorm:"index"`
-    FileID      *uint     // 分享的文件ID，如果是目录则为nil
-    DirectoryID *uint     // 分享的目录ID，如果是文件则为nil
-    ExpireAt    time.Time // 过期时间
-    Password    string    // 访问密码，可为空
-    ViewCount   int       `gorm:"default:0"` // 查看次数
-    IsPublic    bool      `gorm:"default:true"` // 是否公开分享
-    NoExpire    bool      `gorm:"default:false"` // 永久有效
+	gorm.Model
+	UUID        string    `gorm:"uniqueIndex;not null"` // 分享的唯一标识
+	UserID      uint      `gorm:"index"`
+	FileID      *uint     // 分享的文件ID，如果是目录则为nil
+	DirectoryID *uint     // 分享的目录ID，如果是文件则为nil
+	ExpireAt    time.Time // 过期时间
+	Password    string    // 访问密码，可为空
+	ViewCount   int       `gorm:"default:0"`     // 查看次数
+	IsPublic    bool      `gorm:"default:true"`  // 是否公开分享
+	NoExpire    bool      `gorm:"default:false"` // 永久有效
 }
 
 // RecycleBin 回收站模型
 type RecycleBin struct {
-    gorm.Model
-    UserID       uint      `gorm:"index;not null"`
-    OriginalName string    `gorm:"not null"`           // 原始文件/目录名
-    OriginalPath string    `gorm:"not null"`           // 原始路径
-    StoragePath  string    `gorm:"not null"`           // 存储路径
-    Size         int64     `gorm:"not null"`           // 文件大小（字节）
-    ContentType  string                                // MIME类型
-    ItemType     string    `gorm:"not null"`           // 类型：file 或 directory
-    DeletedAt    time.Time `gorm:"not null"`           // 删除时间
-    ExpireAt     time.Time `gorm:"not null"`           // 过期时间（30天后自动清理）
-}
\ No newline at end of file
+	gorm.Model
+	UserID       uint      `gorm:"index;not null"`
+	OriginalName string    `gorm:"not null"` // 原始文件/目录名
+	OriginalPath string    `gorm:"not null"` // 原始路径
+	StoragePath  string    `gorm:"not null"` // 存储路径
+	Size         int64     `gorm:"not null"` // 文件大小（字节）
+	ContentType  string    // MIME类型
+	ItemType     string    `gorm:"not null"` // 类型：file 或 directory
+	DeletedAt    time.Time `gorm:"not null"` // 删除时间
+	ExpireAt     time.Time `gorm:"not null"` // 过期时间（30天后自动清理）
+}
+
+// ImageTraffic 图床每日流量统计
+type ImageTraffic struct {
+	gorm.Model
+	Day      string `gorm:"size:10;not null;uniqueIndex:idx_traffic_key"` // 统计日期 YYYY-MM-DD
+	Scope    string `gorm:"size:16;not null;uniqueIndex:idx_traffic_key"` // 统计维度：file 或 owner
+	TargetID uint   `gorm:"not null;uniqueIndex:idx_traffic_key"`         // 文件ID或所有者用户ID
+	Bytes    int64  `gorm:"default:0"`                                    // 当日已发送字节数
+}
+
+// ImageAPIKey 图床上传密钥（每个用户一个，供 PicGo/ShareX 等客户端使用）
+type ImageAPIKey struct {
+	gorm.Model
+	UserID     uint   `gorm:"uniqueIndex;not null"`
+	KeyHash    string `gorm:"uniqueIndex;not null"` // 密钥的 SHA-256 哈希
+	KeyPrefix  string // 密钥前缀，便于用户辨认
+	LastUsedAt *time.Time
+}
+
+// PersonalAccessToken 个人访问令牌，供脚本和 CI 使用
+type PersonalAccessToken struct {
+	gorm.Model
+	UserID      uint       `gorm:"index;not null"`
+	Name        string     `gorm:"not null"`
+	TokenHash   string     `gorm:"uniqueIndex;not null"` // 令牌的 SHA-256 哈希
+	TokenPrefix string     // 令牌前缀，便于用户辨认
+	Scopes      string     // 权限范围，空格分隔：files:read files:write shares:manage admin
+	ExpiresAt   *time.Time // 过期时间，nil 表示永不过期
+	LastUsedAt  *time.Time
+}
+
+// Session 登录会话，每个会话对应一个可轮换的刷新令牌
+type Session struct {
+	gorm.Model
+	UserID            uint       `gorm:"index;not null"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null"` // 当前刷新令牌的哈希
+	PreviousTokenHash string     `gorm:"index"`                // 上一个刷新令牌的哈希，用于发现重放
+	Device            string     // 登录设备（User-Agent）
+	IP                string     // 最近一次访问的IP
+	LastSeenAt        time.Time  // 最近活跃时间
+	ExpiresAt         time.Time  `gorm:"index"` // 刷新令牌过期时间
+	RevokedAt         *time.Time // 注销时间，nil 表示有效
+	ImpersonatorID    *uint      `gorm:"index"` // 管理员代登录时为管理员的用户ID
+}
+
+// RecoveryCode 两步验证恢复码（一次性）
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"index;not null"`
+	CodeHash string     `gorm:"not null"` // 恢复码的 SHA-256 哈希
+	UsedAt   *time.Time // 使用时间，nil 表示未使用
+}
+
+// Setting 可由管理员在运行时修改的系统设置
+type Setting struct {
+	gorm.Model
+	Key   string `gorm:"uniqueIndex;not null"`
+	Value string
+}
+
+// FailedAttempt 登录或分享密码的失败计数（数据库存储的防暴力破解记录）
+type FailedAttempt struct {
+	gorm.Model
+	Key           string `gorm:"uniqueIndex;not null"` // 如 ip:login:1.2.3.4、account:login:alice
+	Failures      int    `gorm:"default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time `gorm:"index"`
+}
+
+// Role 角色，用户通过 User.Role 关联角色名称
+type Role struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	System      bool         `gorm:"default:false"` // 内置角色，不可删除
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+}
+
+// Permission 权限点
+type Permission struct {
+	gorm.Model
+	Code        string `gorm:"uniqueIndex;not null"` // 如 admin.access、users.manage
+	Description string
+}
+
+// EmailToken 邮件中发送的一次性令牌（邮箱验证、密码重置）
+type EmailToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"index;not null"`
+	Purpose   string     `gorm:"size:16;index;not null"` // verify 或 reset
+	Email     string     // 发送到的邮箱；修改邮箱时为待验证的新邮箱
+	TokenHash string     `gorm:"uniqueIndex;not null"` // 令牌的 SHA-256 哈希
+	ExpiresAt time.Time  `gorm:"index"`
+	UsedAt    *time.Time // 使用时间，nil 表示未使用
+}
+
+// InviteCode 注册邀请码
+type InviteCode struct {
+	gorm.Model
+	Code         string     `gorm:"uniqueIndex;not null"`
+	Note         string     // 备注，如发给谁
+	CreatedBy    uint       `gorm:"index"`
+	Role         string     // 使用邀请码注册的用户角色，为空时使用 user
+	StorageQuota *int64     // 使用邀请码注册的用户存储配额，为空时使用默认配额
+	MaxUses      int        `gorm:"default:1"` // 最多可使用次数，0 表示不限
+	Uses         int        `gorm:"default:0"`
+	ExpiresAt    *time.Time `gorm:"index"` // 过期时间，nil 表示永不过期
+}
+
+// ErrAuditImmutable 审计日志只能追加，不能修改或删除
+var ErrAuditImmutable = errors.New("审计日志不可修改")
+
+// AuditLog 安全审计日志，只追加不修改
+type AuditLog struct {
+	ID             uint      `gorm:"primarykey"`
+	CreatedAt      time.Time `gorm:"index"`
+	RequestID      string    `gorm:"index"`
+	Action         string    `gorm:"index;not null"` // 操作名称，如 auth.login、file.upload
+	Result         string    `gorm:"size:16;index"`  // success、failure 或 denied
+	Status         int       // HTTP 响应状态码
+	ActorID        *uint     `gorm:"index"` // 操作者，未登录时为空
+	ActorName      string    `gorm:"index"` // 操作者用户名，登录失败时为尝试的用户名
+	ImpersonatorID *uint     // 代登录时为管理员的用户ID
+	IP             string    `gorm:"column:ip;index"`
+	UserAgent      string
+	Method         string `gorm:"size:8"`
+	Path           string // 路由，如 /api/files/download/:id
+	TargetType     string `gorm:"size:32;index"`
+	TargetID       string `gorm:"index"`
+	TargetName     string
+	Details        string
+}
+
+// BeforeUpdate 禁止修改审计日志
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// BeforeDelete 禁止删除审计日志
+func (AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
@@ -0,0 +1,241 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/audit"
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/response"
+	"gorm.io/gorm"
+)
+
+// Policy 锁定策略
+type Policy struct {
+	Threshold   int           // 达到该失败次数后开始锁定
+	BaseLockout time.Duration // 首次锁定时长，之后每次失败翻倍
+	MaxLockout  time.Duration // 锁定时长上限
+}
+
+// lockout 计算第 failures 次失败后的锁定时长，未达到阈值时返回 0
+func (p Policy) lockout(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+	exp := failures - p.Threshold
+	if exp > 30 {
+		exp = 30
+	}
+	// 先以浮点数比较上限，避免翻倍后超出 time.Duration 的范围变成负数
+	d := float64(p.BaseLockout) * math.Pow(2, float64(exp))
+	if p.MaxLockout > 0 && d > float64(p.MaxLockout) {
+		return p.MaxLockout
+	}
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// Limiter 基于失败次数的防暴力破解限制器，分别按 IP 和账户计数
+type Limiter struct {
+	Store      Store
+	IP         Policy
+	Account    Policy
+	ResetAfter time.Duration // 空闲多久后清零计数
+
+	mu         sync.Mutex // 保证同一进程内计数的读改写不丢失，同时保护上面的策略
+	lastPurged time.Time
+}
+
+// New 根据配置创建限制器，未启用时返回 nil
+func New(cfg config.RateLimitConfig, db *gorm.DB) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	var store Store = NewMemoryStore()
+	if cfg.Store == "database" {
+		store = NewDBStore(db)
+	}
+	l := &Limiter{Store: store}
+	l.Configure(cfg)
+	return l
+}
+
+// Configure 更新锁定策略和计数清零时间，配置文件修改后调用；已有的计数和锁定保持不变
+func (l *Limiter) Configure(cfg config.RateLimitConfig) {
+	if l == nil {
+		return
+	}
+	policy := func(p config.LockoutPolicy) Policy {
+		return Policy{
+			Threshold:   p.Threshold,
+			BaseLockout: time.Duration(p.BaseLockout) * time.Second,
+			MaxLockout:  time.Duration(p.MaxLockout) * time.Second,
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.IP = policy(cfg.IP)
+	l.Account = policy(cfg.Account)
+	l.ResetAfter = time.Duration(cfg.ResetAfter) * time.Second
+}
+
+// policies 返回当前的 IP 和账户锁定策略
+func (l *Limiter) policies() (ip, account Policy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.IP, l.Account
+}
+
+// IPKey 按 IP 计数的键
+func IPKey(scope, ip string) string {
+	return "ip:" + scope + ":" + ip
+}
+
+// AccountKey 按账户计数的键
+func AccountKey(scope, account string) string {
+	return "account:" + scope + ":" + account
+}
+
+// Check 返回键的剩余锁定时间，未锁定时返回 0
+func (l *Limiter) Check(key string) time.Duration {
+	e, err := l.Store.Get(key)
+	if err != nil || e == nil {
+		return 0
+	}
+	if now := time.Now(); e.Locked(now) {
+		return e.LockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// Fail 记录一次失败，返回本次触发的锁定时长（未锁定为 0）
+func (l *Limiter) Fail(key string, policy Policy) (time.Duration, int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	// 顺带清理过期计数，避免内存存储无限增长
+	if l.ResetAfter > 0 && now.Sub(l.lastPurged) > l.ResetAfter {
+		l.lastPurged = now
+		if err := l.Store.Purge(now.Add(-l.ResetAfter)); err != nil {
+			logger.Warn("清理失败计数失败: %v", err)
+		}
+	}
+
+	e, err := l.Store.Get(key)
+	if err != nil {
+		return 0, 0, err
+	}
+	if e == nil || (l.ResetAfter > 0 && now.Sub(lastActivity(e)) > l.ResetAfter) {
+		e = &Entry{Key: key}
+	}
+	e.Failures++
+	e.LastFailureAt = now
+	lock := policy.lockout(e.Failures)
+	if lock > 0 {
+		until := now.Add(lock)
+		e.LockedUntil = &until
+	}
+	return lock, e.Failures, l.Store.Put(e)
+}
+
+// Reset 清除键的计数和锁定
+func (l *Limiter) Reset(key string) error {
+	return l.Store.Delete(key)
+}
+
+// ResetAll 清除全部计数和锁定
+func (l *Limiter) ResetAll() error {
+	return l.Store.DeleteAll()
+}
+
+// Locks 列出当前的锁定
+func (l *Limiter) Locks() ([]Entry, error) {
+	return l.Store.Locked(time.Now())
+}
+
+// Middleware 防暴力破解中间件：请求前检查 IP 和账户是否被锁定，
+// 请求返回 401/403 时记为一次失败，成功时清除该账户的计数；
+// account 用于从请求中取出账户标识，返回空字符串时只按 IP 计数
+func (l *Limiter) Middleware(scope string, account func(ctx *gin.Context) string) gin.HandlerFunc {
+	return l.middleware(scope, account, false)
+}
+
+// Throttle 按请求次数限制的中间件：无论成功与否每次请求都计数，使用与 Middleware 相同的锁定策略；
+// 用于注册、发送邮件等成功的请求本身就会被滥用的接口
+func (l *Limiter) Throttle(scope string, account func(ctx *gin.Context) string) gin.HandlerFunc {
+	return l.middleware(scope, account, true)
+}
+
+// middleware countAll 为 true 时每次请求都计数，否则只计 401/403
+func (l *Limiter) middleware(scope string, account func(ctx *gin.Context) string, countAll bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if l == nil {
+			ctx.Next()
+			return
+		}
+
+		ipKey := IPKey(scope, ctx.ClientIP())
+		acctKey := ""
+		if account != nil {
+			if name := account(ctx); name != "" {
+				acctKey = AccountKey(scope, name)
+			}
+		}
+
+		wait := l.Check(ipKey)
+		if acctKey != "" {
+			if w := l.Check(acctKey); w > wait {
+				wait = w
+			}
+		}
+		if wait > 0 {
+			seconds := int(math.Ceil(wait.Seconds()))
+			ctx.Header("Retry-After", strconv.Itoa(seconds))
+			response.Error(ctx, response.ErrTooManyRequests, fmt.Sprintf("尝试次数过多，请在 %d 秒后重试", seconds))
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+
+		switch status := ctx.Writer.Status(); {
+		case countAll || status == http.StatusUnauthorized || status == http.StatusForbidden:
+			ipPolicy, accountPolicy := l.policies()
+			l.recordFailure(ctx, ipKey, ipPolicy, countAll)
+			if acctKey != "" {
+				l.recordFailure(ctx, acctKey, accountPolicy, countAll)
+			}
+		case status >= 200 && status < 300 && acctKey != "":
+			l.Reset(acctKey)
+		}
+	}
+}
+
+// recordFailure 记录失败（countAll 时为一次请求），触发锁定时写审计日志
+func (l *Limiter) recordFailure(ctx *gin.Context, key string, policy Policy, countAll bool) {
+	lock, failures, err := l.Fail(key, policy)
+	if err != nil {
+		logger.Error("记录失败次数失败: %v", err)
+		return
+	}
+	if lock > 0 {
+		details := fmt.Sprintf("%s 连续失败 %d 次，锁定 %s", key, failures, lock)
+		if countAll {
+			details = fmt.Sprintf("%s 请求 %d 次，锁定 %s", key, failures, lock)
+		}
+		audit.Record(ctx, audit.Entry{
+			Action:  "security.lockout",
+			Result:  audit.ResultDenied,
+			Details: details,
+		})
+	}
+}
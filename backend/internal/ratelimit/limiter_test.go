@@ -0,0 +1,247 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/response"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestPolicyLockout(t *testing.T) {
+	p := Policy{Threshold: 3, BaseLockout: time.Minute, MaxLockout: 5 * time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 5 * time.Minute},
+		{1000, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.lockout(tt.failures); got != tt.want {
+			t.Errorf("第 %d 次失败: 锁定时长应为 %s，实际为 %s", tt.failures, tt.want, got)
+		}
+	}
+
+	if got := (Policy{BaseLockout: time.Minute}).lockout(100); got != 0 {
+		t.Errorf("阈值为 0 时不应锁定，实际为 %s", got)
+	}
+	if got := (Policy{Threshold: 1, BaseLockout: time.Second}).lockout(1000); got <= 0 {
+		t.Errorf("未设置上限时锁定时长不应溢出，实际为 %s", got)
+	}
+}
+
+func TestLimiterFail(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		l := &Limiter{Store: store, ResetAfter: time.Hour}
+		policy := Policy{Threshold: 3, BaseLockout: time.Minute, MaxLockout: time.Hour}
+		key := AccountKey("login", "alice")
+
+		for i := 1; i <= 2; i++ {
+			lock, failures, err := l.Fail(key, policy)
+			if err != nil || lock != 0 || failures != i {
+				t.Fatalf("第 %d 次失败: %s, %d, %v", i, lock, failures, err)
+			}
+		}
+		if wait := l.Check(key); wait != 0 {
+			t.Fatalf("未达到阈值时不应锁定，剩余 %s", wait)
+		}
+
+		lock, _, _ := l.Fail(key, policy)
+		if lock != time.Minute {
+			t.Fatalf("达到阈值时应锁定 1 分钟，实际为 %s", lock)
+		}
+		if wait := l.Check(key); wait <= 0 || wait > time.Minute {
+			t.Errorf("剩余锁定时间不正确: %s", wait)
+		}
+		if lock, _, _ := l.Fail(key, policy); lock != 2*time.Minute {
+			t.Errorf("之后每次失败锁定时长应翻倍，实际为 %s", lock)
+		}
+		if locks, err := l.Locks(); err != nil || len(locks) != 1 || locks[0].Key != key {
+			t.Errorf("锁定列表不正确: %+v, %v", locks, err)
+		}
+
+		if err := l.Reset(key); err != nil {
+			t.Fatalf("清除失败: %v", err)
+		}
+		if wait := l.Check(key); wait != 0 {
+			t.Errorf("清除后不应锁定，剩余 %s", wait)
+		}
+		if _, failures, _ := l.Fail(key, policy); failures != 1 {
+			t.Errorf("清除后应重新计数，实际为 %d", failures)
+		}
+	})
+}
+
+func TestLimiterResetAfterIdle(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		l := &Limiter{Store: store, ResetAfter: 10 * time.Minute}
+		policy := Policy{Threshold: 3, BaseLockout: time.Minute}
+		key := IPKey("login", "192.0.2.1")
+
+		store.Put(&Entry{Key: key, Failures: 2, LastFailureAt: time.Now().Add(-time.Hour)})
+		lock, failures, err := l.Fail(key, policy)
+		if err != nil || lock != 0 || failures != 1 {
+			t.Errorf("空闲超过 reset_after 后应重新计数: %s, %d, %v", lock, failures, err)
+		}
+	})
+}
+
+// newTestRouter 返回的接口按查询参数 status 返回状态码，账户取自查询参数 user
+func newTestRouter(l *Limiter, countAll bool) (*gin.Engine, *int) {
+	calls := new(int)
+	account := func(ctx *gin.Context) string { return ctx.Query("user") }
+	mw := l.Middleware("login", account)
+	if countAll {
+		mw = l.Throttle("login", account)
+	}
+	r := gin.New()
+	r.POST("/login", mw, func(ctx *gin.Context) {
+		*calls++
+		status, _ := strconv.Atoi(ctx.Query("status"))
+		ctx.Status(status)
+	})
+	return r, calls
+}
+
+// request 以固定客户端 IP 发送请求
+func request(r *gin.Engine, user string, status int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login?user="+user+"&status="+strconv.Itoa(status), nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// expectLocked 检查请求被锁定拦截
+func expectLocked(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	var resp response.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != response.ErrTooManyRequests {
+		t.Fatalf("应返回 %d，实际为 %d %s", response.ErrTooManyRequests, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("缺少 Retry-After 响应头")
+	}
+}
+
+func newTestLimiter(store Store) *Limiter {
+	return &Limiter{
+		Store:      store,
+		IP:         Policy{Threshold: 5, BaseLockout: time.Minute, MaxLockout: time.Hour},
+		Account:    Policy{Threshold: 3, BaseLockout: time.Minute, MaxLockout: time.Hour},
+		ResetAfter: time.Hour,
+	}
+}
+
+func TestMiddlewareLocksAccount(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		r, calls := newTestRouter(newTestLimiter(store), false)
+
+		for i := 0; i < 3; i++ {
+			if w := request(r, "alice", http.StatusUnauthorized); w.Code != http.StatusUnauthorized {
+				t.Fatalf("第 %d 次请求应到达处理函数，实际为 %d", i+1, w.Code)
+			}
+		}
+		expectLocked(t, request(r, "alice", http.StatusOK))
+		if *calls != 3 {
+			t.Errorf("锁定后请求不应到达处理函数，共调用 %d 次", *calls)
+		}
+		// 账户锁定不影响同一 IP 的其他账户
+		if w := request(r, "bob", http.StatusOK); w.Code != http.StatusOK {
+			t.Errorf("其他账户不应被锁定，实际为 %d", w.Code)
+		}
+	})
+}
+
+func TestMiddlewareLocksIP(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		r, _ := newTestRouter(newTestLimiter(store), false)
+
+		// 403 同样计为失败；每个账户未达到阈值，但 IP 累计达到阈值
+		for i, status := range []int{401, 403, 401, 403, 401} {
+			request(r, "user"+strconv.Itoa(i), status)
+		}
+		expectLocked(t, request(r, "carol", http.StatusOK))
+	})
+}
+
+func TestMiddlewareCountsOnlyAuthFailures(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		r, calls := newTestRouter(newTestLimiter(store), false)
+
+		for _, status := range []int{200, 201, 204, 400, 404, 422, 500, 200, 400, 404} {
+			request(r, "alice", status)
+		}
+		if w := request(r, "alice", http.StatusOK); w.Code != http.StatusOK {
+			t.Fatalf("2xx 和其他错误不应计为失败，实际为 %d %s", w.Code, w.Body.String())
+		}
+		if *calls != 11 {
+			t.Errorf("所有请求都应到达处理函数，共调用 %d 次", *calls)
+		}
+	})
+}
+
+func TestMiddlewareResetsAccountOnSuccess(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		l := newTestLimiter(store)
+		l.IP.Threshold = 100
+		r, _ := newTestRouter(l, false)
+
+		request(r, "alice", http.StatusUnauthorized)
+		request(r, "alice", http.StatusUnauthorized)
+		request(r, "alice", http.StatusOK)
+		if e, _ := store.Get(AccountKey("login", "alice")); e != nil {
+			t.Fatalf("成功后应清除账户计数: %+v", e)
+		}
+		request(r, "alice", http.StatusUnauthorized)
+		request(r, "alice", http.StatusUnauthorized)
+		if w := request(r, "alice", http.StatusOK); w.Code != http.StatusOK {
+			t.Errorf("计数清除后不应锁定，实际为 %d", w.Code)
+		}
+		// IP 计数不因成功清除
+		if e, _ := store.Get(IPKey("login", "192.0.2.1")); e == nil || e.Failures != 4 {
+			t.Errorf("IP 计数不正确: %+v", e)
+		}
+	})
+}
+
+func TestThrottleCountsAllRequests(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		r, calls := newTestRouter(newTestLimiter(store), true)
+
+		for i := 0; i < 3; i++ {
+			if w := request(r, "alice", http.StatusOK); w.Code != http.StatusOK {
+				t.Fatalf("第 %d 次请求应成功，实际为 %d", i+1, w.Code)
+			}
+		}
+		expectLocked(t, request(r, "alice", http.StatusOK))
+		if *calls != 3 {
+			t.Errorf("锁定后请求不应到达处理函数，共调用 %d 次", *calls)
+		}
+	})
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	r, calls := newTestRouter(l, false)
+	for i := 0; i < 10; i++ {
+		request(r, "alice", http.StatusUnauthorized)
+	}
+	if *calls != 10 {
+		t.Errorf("未启用时不应限制，共调用 %d 次", *calls)
+	}
+}
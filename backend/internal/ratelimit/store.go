@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Entry 某个键（IP 或账户）的失败计数
+type Entry struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil"`
+}
+
+// Locked 判断在指定时间是否处于锁定状态
+func (e *Entry) Locked(now time.Time) bool {
+	return e.LockedUntil != nil && now.Before(*e.LockedUntil)
+}
+
+// Store 失败计数存储
+type Store interface {
+	Get(key string) (*Entry, error) // 不存在时返回 nil, nil
+	Put(entry *Entry) error
+	Delete(key string) error
+	DeleteAll() error
+	Locked(now time.Time) ([]Entry, error) // 当前处于锁定状态的记录
+	Purge(before time.Time) error          // 清理在此之前已失效的记录
+}
+
+// MemoryStore 进程内存储，适合单实例部署
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+func (s *MemoryStore) Get(key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (s *MemoryStore) Put(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.Key] = *entry
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) DeleteAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]Entry)
+	return nil
+}
+
+func (s *MemoryStore) Locked(now time.Time) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Entry, 0)
+	for _, e := range s.entries {
+		if e.Locked(now) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LockedUntil.After(*out[j].LockedUntil) })
+	return out, nil
+}
+
+func (s *MemoryStore) Purge(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, e := range s.entries {
+		if lastActivity(&e).Before(before) {
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
+
+// DBStore 数据库存储，多实例部署时共享计数
+type DBStore struct {
+	DB *gorm.DB
+}
+
+// NewDBStore 创建数据库存储
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{DB: db}
+}
+
+func (s *DBStore) Get(key string) (*Entry, error) {
+	var rec model.FailedAttempt
+	if err := s.DB.Where("`key` = ?", key).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &Entry{Key: rec.Key, Failures: rec.Failures, LastFailureAt: rec.LastFailureAt, LockedUntil: rec.LockedUntil}, nil
+}
+
+func (s *DBStore) Put(entry *Entry) error {
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"failures", "last_failure_at", "locked_until", "updated_at"}),
+	}).Create(&model.FailedAttempt{
+		Key:           entry.Key,
+		Failures:      entry.Failures,
+		LastFailureAt: entry.LastFailureAt,
+		LockedUntil:   entry.LockedUntil,
+	}).Error
+}
+
+func (s *DBStore) Delete(key string) error {
+	return s.DB.Unscoped().Where("`key` = ?", key).Delete(&model.FailedAttempt{}).Error
+}
+
+func (s *DBStore) DeleteAll() error {
+	return s.DB.Unscoped().Where("1 = 1").Delete(&model.FailedAttempt{}).Error
+}
+
+func (s *DBStore) Locked(now time.Time) ([]Entry, error) {
+	var recs []model.FailedAttempt
+	if err := s.DB.Where("locked_until > ?", now).Order("locked_until desc").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]Entry, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, Entry{Key: rec.Key, Failures: rec.Failures, LastFailureAt: rec.LastFailureAt, LockedUntil: rec.LockedUntil})
+	}
+	return out, nil
+}
+
+func (s *DBStore) Purge(before time.Time) error {
+	return s.DB.Unscoped().
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).
+		Delete(&model.FailedAttempt{}).Error
+}
+
+// lastActivity 最后一次失败或锁定结束时间，取较晚者
+func lastActivity(e *Entry) time.Time {
+	if e.LockedUntil != nil && e.LockedUntil.After(e.LastFailureAt) {
+		return *e.LockedUntil
+	}
+	return e.LastFailureAt
+}
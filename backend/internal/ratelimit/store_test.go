@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// newTestDBStore 在临时目录中创建 SQLite 数据库存储
+func newTestDBStore(t *testing.T) *DBStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&model.FailedAttempt{}); err != nil {
+		t.Fatalf("迁移数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return NewDBStore(db)
+}
+
+// forEachStore 分别使用内存存储和数据库存储运行测试
+func forEachStore(t *testing.T, fn func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) { fn(t, NewMemoryStore()) })
+	t.Run("database", func(t *testing.T) { fn(t, newTestDBStore(t)) })
+}
+
+func TestStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		now := time.Now().Truncate(time.Second)
+		if e, err := store.Get("ip:login:1.2.3.4"); err != nil || e != nil {
+			t.Fatalf("不存在的键应返回 nil, nil，实际为 %+v, %v", e, err)
+		}
+
+		locked := now.Add(time.Minute)
+		if err := store.Put(&Entry{Key: "ip:login:1.2.3.4", Failures: 1, LastFailureAt: now}); err != nil {
+			t.Fatalf("写入失败: %v", err)
+		}
+		// 再次写入同一个键时更新已有记录
+		if err := store.Put(&Entry{Key: "ip:login:1.2.3.4", Failures: 3, LastFailureAt: now, LockedUntil: &locked}); err != nil {
+			t.Fatalf("更新失败: %v", err)
+		}
+		if err := store.Put(&Entry{Key: "account:login:alice", Failures: 1, LastFailureAt: now.Add(-time.Hour)}); err != nil {
+			t.Fatalf("写入失败: %v", err)
+		}
+
+		e, err := store.Get("ip:login:1.2.3.4")
+		if err != nil || e == nil {
+			t.Fatalf("读取失败: %v", err)
+		}
+		if e.Failures != 3 || e.LockedUntil == nil || !e.LockedUntil.Equal(locked) || !e.Locked(now) {
+			t.Errorf("记录不正确: %+v", e)
+		}
+
+		locks, err := store.Locked(now)
+		if err != nil || len(locks) != 1 || locks[0].Key != "ip:login:1.2.3.4" {
+			t.Errorf("锁定列表不正确: %+v, %v", locks, err)
+		}
+		if locks, _ := store.Locked(locked.Add(time.Second)); len(locks) != 0 {
+			t.Errorf("锁定到期后不应列出: %+v", locks)
+		}
+
+		// 只清理最后活动早于指定时间的记录
+		if err := store.Purge(now.Add(-time.Minute)); err != nil {
+			t.Fatalf("清理失败: %v", err)
+		}
+		if e, _ := store.Get("account:login:alice"); e != nil {
+			t.Errorf("过期记录应被清理: %+v", e)
+		}
+		if e, _ := store.Get("ip:login:1.2.3.4"); e == nil {
+			t.Error("仍在锁定的记录不应被清理")
+		}
+
+		if err := store.Delete("ip:login:1.2.3.4"); err != nil {
+			t.Fatalf("删除失败: %v", err)
+		}
+		if e, _ := store.Get("ip:login:1.2.3.4"); e != nil {
+			t.Errorf("删除后仍能读取: %+v", e)
+		}
+
+		store.Put(&Entry{Key: "a", Failures: 1, LastFailureAt: now})
+		store.Put(&Entry{Key: "b", Failures: 1, LastFailureAt: now})
+		if err := store.DeleteAll(); err != nil {
+			t.Fatalf("全部删除失败: %v", err)
+		}
+		for _, key := range []string{"a", "b"} {
+			if e, _ := store.Get(key); e != nil {
+				t.Errorf("全部删除后仍能读取 %s", key)
+			}
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package rbac
+
+import (
+	"crypto/rand"
+	"math/big"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// legacyDefaultPassword 旧版本内置的管理员默认密码，仍在使用的账户会被要求修改
+const legacyDefaultPassword = "password"
+
+// 初始密码使用的字符，去掉了容易混淆的字符
+const passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+
+// Bootstrap 首次启动时创建初始管理员。
+// 初始密码取自配置 admin.password，未配置时随机生成并写入日志；
+// 无论哪种方式，管理员首次登录后都必须先修改密码
+func Bootstrap(db *gorm.DB, cfg *config.Config) error {
+	if err := flagLegacyPasswords(db); err != nil {
+		return err
+	}
+
+	count, err := HolderCount(db, PermAdminAccess)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	username, email := cfg.Admin.Username, cfg.Admin.Email
+	var exist int64
+	if err := db.Model(&model.User{}).Where("username = ? OR email = ?", username, email).Count(&exist).Error; err != nil {
+		return err
+	}
+	if exist > 0 {
+		logger.Warn("没有可用的管理员账户，但用户名 %s 或邮箱 %s 已被占用，跳过创建初始管理员", username, email)
+		return nil
+	}
+
+	password, generated := cfg.Admin.Password, false
+	if password == "" {
+		if password, err = RandomPassword(16); err != nil {
+			return err
+		}
+		generated = true
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	admin := &model.User{
+		Username:           username,
+		Password:           string(hashed),
+		Email:              email,
+		Role:               RoleAdmin,
+		MustChangePassword: true,
+		EmailVerifiedAt:    &now,
+	}
+	if err := db.Create(admin).Error; err != nil {
+		return err
+	}
+	if generated {
+		logger.Warn("已创建初始管理员 %s，初始密码: %s （首次登录后必须修改）", username, password)
+	} else {
+		logger.Warn("已创建初始管理员 %s，使用配置中的初始密码（首次登录后必须修改）", username)
+	}
+	return nil
+}
+
+// flagLegacyPasswords 仍在使用旧默认密码的本地管理员账户，下次登录时强制修改密码
+func flagLegacyPasswords(db *gorm.DB) error {
+	var admins []model.User
+	err := db.Where("role = ? AND auth_source = ? AND must_change_password = ?", RoleAdmin, "local", false).Find(&admins).Error
+	if err != nil {
+		return err
+	}
+	for _, u := range admins {
+		if bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(legacyDefaultPassword)) != nil {
+			continue
+		}
+		if err := db.Model(&u).Update("must_change_password", true).Error; err != nil {
+			return err
+		}
+		logger.Warn("管理员 %s 仍在使用默认密码，下次登录时必须修改", u.Username)
+	}
+	return nil
+}
+
+// RandomPassword 生成指定长度的随机密码
+func RandomPassword(n int) (string, error) {
+	buf := make([]byte, n)
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range buf {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = passwordAlphabet[idx.Int64()]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,164 @@
+package rbac
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 内置权限
+const (
+	PermAdminAccess    = "admin.access"    // 登录管理后台
+	PermUsersManage    = "users.manage"    // 管理用户
+	PermRolesManage    = "roles.manage"    // 管理角色和权限
+	PermSecurityManage = "security.manage" // 管理安全设置（两步验证要求、登录锁定等）
+	PermSystemManage   = "system.manage"   // 系统维护（目录同步等）
+	PermAuditRead      = "audit.read"      // 查看和导出审计日志
+)
+
+// 内置角色
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Permissions 所有内置权限及说明
+var Permissions = []model.Permission{
+	{Code: PermAdminAccess, Description: "登录管理后台"},
+	{Code: PermUsersManage, Description: "管理用户"},
+	{Code: PermRolesManage, Description: "管理角色和权限"},
+	{Code: PermSecurityManage, Description: "管理安全设置"},
+	{Code: PermSystemManage, Description: "系统维护"},
+	{Code: PermAuditRead, Description: "查看审计日志"},
+}
+
+// Seed 初始化内置权限和角色；内置管理员角色每次启动都会补齐全部内置权限
+func Seed(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range Permissions {
+			perm := p
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "code"}},
+				DoUpdates: clause.AssignmentColumns([]string{"description", "updated_at"}),
+			}).Create(&perm).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var perms []model.Permission
+		if err := tx.Find(&perms).Error; err != nil {
+			return err
+		}
+
+		admin, err := ensureRole(tx, RoleAdmin, "系统管理员")
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(admin).Association("Permissions").Replace(perms); err != nil {
+			return err
+		}
+		_, err = ensureRole(tx, RoleUser, "普通用户")
+		return err
+	})
+}
+
+// ensureRole 确保内置角色存在
+func ensureRole(tx *gorm.DB, name, description string) (*model.Role, error) {
+	role := model.Role{Name: name}
+	err := tx.Where(model.Role{Name: name}).
+		Attrs(model.Role{Description: description, System: true}).
+		FirstOrCreate(&role).Error
+	return &role, err
+}
+
+// HolderCount 统计拥有指定权限的有效用户数量
+func HolderCount(db *gorm.DB, perm string) (int64, error) {
+	var count int64
+	err := db.Model(&model.User{}).
+		Where("disabled = ?", false).
+		Where("role IN (?)", db.Table("roles").
+			Select("roles.name").
+			Joins("JOIN role_permissions ON role_permissions.role_id = roles.id").
+			Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+			Where("permissions.code = ? AND roles.deleted_at IS NULL", perm)).
+		Count(&count).Error
+	return count, err
+}
+
+// Authorizer 按角色检查权限，角色的权限集合在内存中缓存一段时间
+type Authorizer struct {
+	DB  *gorm.DB
+	TTL time.Duration
+
+	mu       sync.RWMutex
+	cache    map[string]map[string]bool
+	loadedAt time.Time
+}
+
+// NewAuthorizer 创建权限检查器
+func NewAuthorizer(db *gorm.DB) *Authorizer {
+	return &Authorizer{DB: db, TTL: time.Minute}
+}
+
+// Has 判断角色是否拥有指定权限
+func (a *Authorizer) Has(role, perm string) bool {
+	perms, err := a.rolePermissions()
+	if err != nil {
+		return false
+	}
+	return perms[role][perm]
+}
+
+// Permissions 返回角色拥有的全部权限（按名称排序）
+func (a *Authorizer) Permissions(role string) []string {
+	perms, err := a.rolePermissions()
+	if err != nil {
+		return []string{}
+	}
+	out := make([]string, 0, len(perms[role]))
+	for code := range perms[role] {
+		out = append(out, code)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Invalidate 清除缓存，角色或权限变更后调用
+func (a *Authorizer) Invalidate() {
+	a.mu.Lock()
+	a.cache = nil
+	a.mu.Unlock()
+}
+
+// rolePermissions 返回角色到权限集合的映射，缓存过期时从数据库重新加载
+func (a *Authorizer) rolePermissions() (map[string]map[string]bool, error) {
+	a.mu.RLock()
+	cache, loadedAt := a.cache, a.loadedAt
+	a.mu.RUnlock()
+	if cache != nil && time.Since(loadedAt) < a.TTL {
+		return cache, nil
+	}
+
+	var roles []model.Role
+	if err := a.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	cache = make(map[string]map[string]bool, len(roles))
+	for _, role := range roles {
+		set := make(map[string]bool, len(role.Permissions))
+		for _, p := range role.Permissions {
+			set[p.Code] = true
+		}
+		cache[role.Name] = set
+	}
+
+	a.mu.Lock()
+	a.cache, a.loadedAt = cache, time.Now()
+	a.mu.Unlock()
+	return cache, nil
+}
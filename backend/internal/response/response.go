@@ -2,14 +2,21 @@ package response
 
 import (
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huanhq99/H-Cloud/internal/i18n"
+	"github.com/huanhq99/H-Cloud/internal/logger"
 )
 
 // ErrorCode 错误代码
 type ErrorCode int
 
+// CodeSuccess 成功
+const CodeSuccess ErrorCode = 0
+
 const (
 	// 通用错误
 	ErrInvalidRequest ErrorCode = 40000 // 无效请求
@@ -18,6 +25,8 @@ const (
 	ErrNotFound       ErrorCode = 40400 // 资源不存在
 	ErrConflict       ErrorCode = 40900 // 资源冲突
 	ErrInternalServer ErrorCode = 50000 // 服务器内部错误
+	ErrNotImplemented ErrorCode = 50100 // 功能未实现
+	ErrBadGateway     ErrorCode = 50200 // 上游服务错误
 
 	// 文件相关错误
 	ErrFileInvalid     ErrorCode = 41001 // 无效文件
@@ -27,26 +36,57 @@ const (
 	ErrPathInvalid     ErrorCode = 41005 // 路径不合法
 	ErrFileNotFound    ErrorCode = 41006 // 文件不存在
 	ErrFileExists      ErrorCode = 41007 // 文件已存在
+	ErrFileInfected    ErrorCode = 41008 // 文件包含恶意内容，已隔离
+	ErrFileQuarantined ErrorCode = 41009 // 文件已被隔离
 
 	// 目录相关错误
-	ErrDirInvalid   ErrorCode = 42001 // 无效目录
-	ErrDirNotFound  ErrorCode = 42002 // 目录不存在
-	ErrDirExists    ErrorCode = 42003 // 目录已存在
-	ErrDirNotEmpty  ErrorCode = 42004 // 目录不为空
+	ErrDirInvalid  ErrorCode = 42001 // 无效目录
+	ErrDirNotFound ErrorCode = 42002 // 目录不存在
+	ErrDirExists   ErrorCode = 42003 // 目录已存在
+	ErrDirNotEmpty ErrorCode = 42004 // 目录不为空
 
 	// 存储相关错误
 	ErrStorageFull    ErrorCode = 43001 // 存储空间不足
 	ErrStorageFailure ErrorCode = 43002 // 存储操作失败
+
+	// 认证与账户相关错误
+	ErrInvalidCredentials  ErrorCode = 44001 // 用户名或密码错误
+	ErrTokenInvalid        ErrorCode = 44002 // 令牌无效或已过期
+	ErrVerificationFailed  ErrorCode = 44003 // 验证码或恢复码错误
+	ErrPasswordIncorrect   ErrorCode = 44004 // 密码错误
+	ErrMustChangePassword  ErrorCode = 44005 // 需要先修改密码
+	ErrAccountDisabled     ErrorCode = 44006 // 账户已禁用
+	ErrEmailNotVerified    ErrorCode = 44007 // 邮箱未验证
+	ErrPendingApproval     ErrorCode = 44008 // 账户等待审核
+	ErrRegistrationClosed  ErrorCode = 44009 // 注册已关闭或受限
+	ErrUserExists          ErrorCode = 44010 // 用户名或邮箱已存在
+	ErrUserNotFound        ErrorCode = 44011 // 用户不存在
+	ErrTwoFactorRequired   ErrorCode = 44012 // 需要启用两步验证
+	ErrInsufficientScope   ErrorCode = 44013 // 访问令牌权限不足
+	ErrPermissionDenied    ErrorCode = 44014 // 角色权限不足
+	ErrImpersonationDenied ErrorCode = 44015 // 代登录会话不能执行该操作
+
+	// 分享相关错误
+	ErrShareNotFound ErrorCode = 45001 // 分享不存在
+	ErrShareExpired  ErrorCode = 45002 // 分享已过期
+
+	// 频率限制相关错误
+	ErrTooManyRequests ErrorCode = 46001 // 请求过于频繁
 )
 
-// ErrorMessage 错误消息映射
+// ErrorMessages 错误消息映射（简体中文），其他语言缺少翻译时也使用这里的消息
 var ErrorMessages = map[ErrorCode]string{
-	ErrInvalidRequest: "无效的请求参数",
-	ErrUnauthorized:   "未授权访问",
-	ErrForbidden:      "禁止访问",
-	ErrNotFound:       "资源不存在",
-	ErrConflict:       "资源冲突",
-	ErrInternalServer: "服务器内部错误",
+	CodeSuccess: "操作成功",
+
+	ErrInvalidRequest:  "无效的请求参数",
+	ErrUnauthorized:    "未授权访问",
+	ErrForbidden:       "禁止访问",
+	ErrNotFound:        "资源不存在",
+	ErrConflict:        "资源冲突",
+	ErrTooManyRequests: "请求过于频繁，请稍后重试",
+	ErrInternalServer:  "服务器内部错误",
+	ErrNotImplemented:  "功能暂未支持",
+	ErrBadGateway:      "上游服务错误",
 
 	ErrFileInvalid:     "无效的文件",
 	ErrFileTooBig:      "文件大小超过限制",
@@ -55,14 +95,149 @@ var ErrorMessages = map[ErrorCode]string{
 	ErrPathInvalid:     "路径不合法",
 	ErrFileNotFound:    "文件不存在",
 	ErrFileExists:      "文件已存在",
+	ErrFileInfected:    "文件包含恶意内容",
+	ErrFileQuarantined: "文件已被隔离",
 
-	ErrDirInvalid:   "无效的目录",
-	ErrDirNotFound:  "目录不存在",
-	ErrDirExists:    "目录已存在",
-	ErrDirNotEmpty:  "目录不为空",
+	ErrDirInvalid:  "无效的目录",
+	ErrDirNotFound: "目录不存在",
+	ErrDirExists:   "目录已存在",
+	ErrDirNotEmpty: "目录不为空",
 
 	ErrStorageFull:    "存储空间不足",
 	ErrStorageFailure: "存储操作失败",
+
+	ErrInvalidCredentials:  "用户名或密码错误",
+	ErrTokenInvalid:        "令牌无效或已过期",
+	ErrVerificationFailed:  "验证码错误",
+	ErrPasswordIncorrect:   "密码错误",
+	ErrMustChangePassword:  "请先修改密码",
+	ErrAccountDisabled:     "账户已被禁用",
+	ErrEmailNotVerified:    "邮箱尚未验证",
+	ErrPendingApproval:     "账户正在等待管理员审核",
+	ErrRegistrationClosed:  "系统已关闭注册",
+	ErrUserExists:          "用户名或邮箱已存在",
+	ErrUserNotFound:        "用户不存在",
+	ErrTwoFactorRequired:   "必须启用两步验证",
+	ErrInsufficientScope:   "访问令牌缺少权限",
+	ErrPermissionDenied:    "权限不足",
+	ErrImpersonationDenied: "代登录会话不能执行该操作",
+
+	ErrShareNotFound: "分享不存在",
+	ErrShareExpired:  "分享已过期",
+}
+
+// localizedMessages 其他语言的错误消息，缺少的条目回退到 ErrorMessages
+var localizedMessages = map[string]map[ErrorCode]string{
+	i18n.EnUS: {
+		CodeSuccess: "Success",
+
+		ErrInvalidRequest:  "Invalid request parameters",
+		ErrUnauthorized:    "Unauthorized",
+		ErrForbidden:       "Forbidden",
+		ErrNotFound:        "Resource not found",
+		ErrConflict:        "Resource conflict",
+		ErrTooManyRequests: "Too many requests, please try again later",
+		ErrInternalServer:  "Internal server error",
+		ErrNotImplemented:  "Not supported yet",
+		ErrBadGateway:      "Upstream service error",
+
+		ErrFileInvalid:     "Invalid file",
+		ErrFileTooBig:      "File size exceeds the limit",
+		ErrFileTypeInvalid: "Unsupported file type",
+		ErrFileNameInvalid: "Invalid file name",
+		ErrPathInvalid:     "Invalid path",
+		ErrFileNotFound:    "File not found",
+		ErrFileExists:      "File already exists",
+		ErrFileInfected:    "File contains malicious content",
+		ErrFileQuarantined: "File has been quarantined",
+
+		ErrDirInvalid:  "Invalid directory",
+		ErrDirNotFound: "Directory not found",
+		ErrDirExists:   "Directory already exists",
+		ErrDirNotEmpty: "Directory is not empty",
+
+		ErrStorageFull:    "Insufficient storage space",
+		ErrStorageFailure: "Storage operation failed",
+
+		ErrInvalidCredentials:  "Invalid username or password",
+		ErrTokenInvalid:        "Token is invalid or expired",
+		ErrVerificationFailed:  "Incorrect verification code",
+		ErrPasswordIncorrect:   "Incorrect password",
+		ErrMustChangePassword:  "Please change your password first",
+		ErrAccountDisabled:     "Account is disabled",
+		ErrEmailNotVerified:    "Email is not verified",
+		ErrPendingApproval:     "Account is pending administrator approval",
+		ErrRegistrationClosed:  "Registration is closed",
+		ErrUserExists:          "Username or email already exists",
+		ErrUserNotFound:        "User not found",
+		ErrTwoFactorRequired:   "Two-factor authentication is required",
+		ErrInsufficientScope:   "Access token is missing a required scope",
+		ErrPermissionDenied:    "Permission denied",
+		ErrImpersonationDenied: "This operation is not allowed in an impersonation session",
+
+		ErrShareNotFound: "Share not found",
+		ErrShareExpired:  "Share has expired",
+	},
+}
+
+// Message 返回错误代码在指定语言下的默认消息，缺少翻译时使用中文消息
+func Message(locale string, code ErrorCode) string {
+	if m, ok := localizedMessages[locale][code]; ok {
+		return m
+	}
+	return ErrorMessages[code]
+}
+
+// localize 将消息翻译为当前请求的语言，并写入 Content-Language 响应头。
+// 自定义消息没有对应翻译时保留原始消息中的细节（如等待秒数、权限名称）：
+// "前缀: 详情" 形式的消息只翻译前缀，其余消息附在错误代码的默认消息之后
+func localize(ctx *gin.Context, code ErrorCode, message string) string {
+	locale := i18n.FromContext(ctx)
+	ctx.Header("Content-Language", locale)
+	ctx.Header("Vary", "Accept-Language")
+	if message == "" {
+		return Message(locale, code)
+	}
+	if s, ok := i18n.Translate(locale, message); ok {
+		return s
+	}
+	if prefix, detail, ok := strings.Cut(message, ": "); ok {
+		if p, ok := i18n.Translate(locale, prefix); ok {
+			return p + ": " + detail
+		}
+	}
+	return Message(locale, code) + " (" + message + ")"
+}
+
+// httpStatuses 错误代码对应的 HTTP 状态码；未列出的通用错误取代码前三位，文件和目录错误为 400
+var httpStatuses = map[ErrorCode]int{
+	ErrTooManyRequests: http.StatusTooManyRequests,
+
+	ErrFileNotFound:    http.StatusNotFound,
+	ErrFileInfected:    http.StatusUnprocessableEntity,
+	ErrFileQuarantined: http.StatusForbidden,
+	ErrDirNotFound:     http.StatusNotFound,
+	ErrStorageFull:     http.StatusForbidden,
+	ErrStorageFailure:  http.StatusInternalServerError,
+
+	ErrInvalidCredentials:  http.StatusUnauthorized,
+	ErrTokenInvalid:        http.StatusUnauthorized,
+	ErrVerificationFailed:  http.StatusUnauthorized,
+	ErrPasswordIncorrect:   http.StatusForbidden,
+	ErrMustChangePassword:  http.StatusForbidden,
+	ErrAccountDisabled:     http.StatusForbidden,
+	ErrEmailNotVerified:    http.StatusForbidden,
+	ErrPendingApproval:     http.StatusForbidden,
+	ErrRegistrationClosed:  http.StatusForbidden,
+	ErrUserExists:          http.StatusBadRequest,
+	ErrUserNotFound:        http.StatusNotFound,
+	ErrTwoFactorRequired:   http.StatusForbidden,
+	ErrInsufficientScope:   http.StatusForbidden,
+	ErrPermissionDenied:    http.StatusForbidden,
+	ErrImpersonationDenied: http.StatusForbidden,
+
+	ErrShareNotFound: http.StatusNotFound,
+	ErrShareExpired:  http.StatusBadRequest,
 }
 
 // Response 统一响应结构
@@ -75,45 +250,86 @@ type Response struct {
 	RequestID string      `json:"requestId,omitempty"`
 }
 
+// FormatHeader 客户端指定响应格式的请求头，取值为 envelope 或 legacy
+const FormatHeader = "X-Response-Format"
+
+// legacyDefault 是否默认使用旧的响应格式
+var legacyDefault atomic.Bool
+
+// SetLegacy 设置默认响应格式。
+// 旧格式中成功时直接返回数据，失败时返回 {"error": 消息, ...附加数据}，供尚未迁移的客户端使用
+func SetLegacy(enabled bool) {
+	legacyDefault.Store(enabled)
+}
+
+// isLegacy 判断当前请求是否使用旧的响应格式，请求头优先于配置
+func isLegacy(ctx *gin.Context) bool {
+	switch strings.ToLower(ctx.GetHeader(FormatHeader)) {
+	case "legacy":
+		return true
+	case "envelope":
+		return false
+	}
+	return legacyDefault.Load()
+}
+
 // Success 成功响应
 func Success(ctx *gin.Context, data interface{}) {
+	SuccessWithStatus(ctx, http.StatusOK, data)
+}
+
+// SuccessWithStatus 使用指定状态码（如 201、202）的成功响应。
+// data 中的 message 字段同时作为响应消息
+func SuccessWithStatus(ctx *gin.Context, status int, data interface{}) {
+	message := localize(ctx, CodeSuccess, "")
+	if h, ok := data.(gin.H); ok {
+		if m, ok := h["message"].(string); ok && m != "" {
+			message = localize(ctx, CodeSuccess, m)
+			h["message"] = message
+		}
+	}
+	if isLegacy(ctx) {
+		if data == nil {
+			data = gin.H{}
+		}
+		ctx.JSON(status, data)
+		return
+	}
 	response := Response{
 		Success:   true,
-		Code:      0,
-		Message:   "操作成功",
+		Code:      CodeSuccess,
+		Message:   message,
 		Data:      data,
 		Timestamp: time.Now().Unix(),
 		RequestID: getRequestID(ctx),
 	}
-	ctx.JSON(http.StatusOK, response)
+	ctx.JSON(status, response)
 }
 
 // Error 错误响应
 func Error(ctx *gin.Context, code ErrorCode, customMessage ...string) {
-	message := ErrorMessages[code]
-	if len(customMessage) > 0 && customMessage[0] != "" {
-		message = customMessage[0]
-	}
-
-	httpStatus := getHTTPStatus(code)
-	response := Response{
-		Success:   false,
-		Code:      code,
-		Message:   message,
-		Timestamp: time.Now().Unix(),
-		RequestID: getRequestID(ctx),
-	}
-	ctx.JSON(httpStatus, response)
+	ErrorWithData(ctx, code, nil, customMessage...)
 }
 
 // ErrorWithData 带数据的错误响应
 func ErrorWithData(ctx *gin.Context, code ErrorCode, data interface{}, customMessage ...string) {
-	message := ErrorMessages[code]
-	if len(customMessage) > 0 && customMessage[0] != "" {
+	message := ""
+	if len(customMessage) > 0 {
 		message = customMessage[0]
 	}
+	message = localize(ctx, code, message)
 
 	httpStatus := getHTTPStatus(code)
+	if isLegacy(ctx) {
+		body := gin.H{"error": message}
+		if h, ok := data.(gin.H); ok {
+			for k, v := range h {
+				body[k] = v
+			}
+		}
+		ctx.JSON(httpStatus, body)
+		return
+	}
 	response := Response{
 		Success:   false,
 		Code:      code,
@@ -127,23 +343,16 @@ func ErrorWithData(ctx *gin.Context, code ErrorCode, data interface{}, customMes
 
 // getHTTPStatus 根据错误代码获取HTTP状态码
 func getHTTPStatus(code ErrorCode) int {
+	if status, ok := httpStatuses[code]; ok {
+		return status
+	}
 	switch {
 	case code >= 40000 && code < 41000:
+		return int(code) / 100
+	case code >= 41000 && code < 43000:
 		return http.StatusBadRequest
-	case code >= 41000 && code < 42000:
-		return http.StatusBadRequest
-	case code >= 42000 && code < 43000:
-		return http.StatusBadRequest
-	case code >= 43000 && code < 44000:
-		return http.StatusInternalServerError
-	case code == ErrUnauthorized:
-		return http.StatusUnauthorized
-	case code == ErrForbidden:
-		return http.StatusForbidden
-	case code == ErrNotFound:
-		return http.StatusNotFound
-	case code == ErrConflict:
-		return http.StatusConflict
+	case code >= 50000 && code < 60000:
+		return int(code) / 100
 	default:
 		return http.StatusInternalServerError
 	}
@@ -151,11 +360,5 @@ func getHTTPStatus(code ErrorCode) int {
 
 // getRequestID 获取请求ID
 func getRequestID(ctx *gin.Context) string {
-	if requestID := ctx.GetHeader("X-Request-ID"); requestID != "" {
-		return requestID
-	}
-	if requestID := ctx.GetString("requestId"); requestID != "" {
-		return requestID
-	}
-	return ""
-}
\ No newline at end of file
+	return logger.GetRequestID(ctx)
+}
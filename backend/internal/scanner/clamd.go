@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize INSTREAM 每个数据块的大小
+const clamdChunkSize = 64 * 1024
+
+// Clamd 通过 INSTREAM 协议把文件内容发送给 clamd 扫描，clamd 不需要能访问存储目录
+type Clamd struct {
+	Network string // tcp 或 unix
+	Address string
+	Timeout time.Duration // 单次扫描的超时时间，0 表示只受 ctx 限制
+}
+
+// NewClamd 创建 clamd 扫描器，address 形如 tcp://127.0.0.1:3310、unix:///run/clamav/clamd.ctl 或 127.0.0.1:3310
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	network, addr := "tcp", address
+	if rest, ok := strings.CutPrefix(address, "unix://"); ok {
+		network, addr = "unix", rest
+	} else if rest, ok := strings.CutPrefix(address, "tcp://"); ok {
+		addr = rest
+	} else if strings.Contains(address, "://") {
+		return nil, fmt.Errorf("clamd 地址 %s 格式不正确，应为 tcp://主机:端口 或 unix://套接字路径", address)
+	}
+	if addr == "" {
+		return nil, errors.New("未配置 clamd 地址")
+	}
+	return &Clamd{Network: network, Address: addr, Timeout: timeout}, nil
+}
+
+// Name 返回 clamd
+func (c *Clamd) Name() string {
+	return BackendClamd
+}
+
+// Scan 以 INSTREAM 命令发送内容：每块前加 4 字节大端长度，以长度为 0 的块结束；
+// clamd 返回 "stream: OK"、"stream: <病毒名> FOUND" 或 "... ERROR"
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return Result{}, c.wrap(err)
+	}
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := w.Write(size[:]); err != nil {
+				return c.writeFailed(conn, err)
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return c.writeFailed(conn, err)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return Result{}, fmt.Errorf("读取待扫描的文件失败: %w", rerr)
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := w.Write(size[:]); err != nil {
+		return c.writeFailed(conn, err)
+	}
+	if err := w.Flush(); err != nil {
+		return c.writeFailed(conn, err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, c.wrap(err)
+	}
+	return parseReply(reply)
+}
+
+// writeFailed clamd 拒绝继续接收（如超过 StreamMaxLength）时会先返回原因再断开连接，尽量读出原因
+func (c *Clamd) writeFailed(conn net.Conn, err error) (Result, error) {
+	if reply, rerr := readReply(conn); rerr == nil && reply != "" {
+		return parseReply(reply)
+	}
+	return Result{}, c.wrap(err)
+}
+
+// Ping 检查 clamd 是否可用
+func (c *Clamd) Ping(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return c.wrap(err)
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return c.wrap(err)
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd 返回了意外的响应: %s", reply)
+	}
+	return nil
+}
+
+// dial 连接 clamd 并设置整个会话的截止时间
+func (c *Clamd) dial(ctx context.Context) (net.Conn, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return nil, c.wrap(err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+func (c *Clamd) wrap(err error) error {
+	return fmt.Errorf("clamd（%s）: %w", c.Address, err)
+}
+
+// readReply 读取以 \0 结尾的响应
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// parseReply 解析 INSTREAM 的扫描结果
+func parseReply(reply string) (Result, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		// 如文件超过 StreamMaxLength 时返回 "INSTREAM size limit exceeded. ERROR"
+		return Result{}, fmt.Errorf("clamd 扫描失败: %s", reply)
+	}
+}
@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+)
+
+// 扫描后端
+const (
+	BackendNone  = "none"  // 不扫描
+	BackendClamd = "clamd" // ClamAV 守护进程
+)
+
+// Result 扫描结果
+type Result struct {
+	Infected  bool
+	Signature string // 检出的病毒名称，如 Eicar-Test-Signature
+}
+
+// Scanner 扫描文件内容中的恶意软件
+type Scanner interface {
+	// Scan 读取 r 的全部内容并返回扫描结果；无法完成扫描时返回错误
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+	// Name 扫描器名称，用于日志
+	Name() string
+}
+
+// New 根据配置创建扫描器，未启用时返回不做任何检查的扫描器
+func New(cfg config.ScanConfig) (Scanner, error) {
+	switch cfg.Backend {
+	case BackendNone, "":
+		return Noop{}, nil
+	case BackendClamd:
+		return NewClamd(cfg.Clamd.Address, time.Duration(cfg.Timeout)*time.Second)
+	default:
+		return nil, fmt.Errorf("不支持的扫描后端: %s", cfg.Backend)
+	}
+}
+
+// Noop 不做任何检查，所有文件都视为安全
+type Noop struct{}
+
+// Scan 不读取内容，直接返回未感染
+func (Noop) Scan(context.Context, io.Reader) (Result, error) {
+	return Result{}, nil
+}
+
+// Name 返回 none
+func (Noop) Name() string {
+	return BackendNone
+}
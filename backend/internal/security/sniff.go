@@ -0,0 +1,109 @@
+package security
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// 文件内容与扩展名不符时的处理方式
+const (
+	MismatchReject = "reject" // 拒绝上传
+	MismatchActive = "active" // 仅当内容是浏览器可能执行的类型时拒绝
+	MismatchAllow  = "allow"  // 允许上传，按检测到的类型保存
+)
+
+// sniffLen 用于识别文件类型的头部字节数，与 mimetype 的默认读取上限一致
+const sniffLen = 3072
+
+// SniffContentType 读取文件头部识别实际类型，返回的 Reader 仍从文件开头读取，上传时无需先写入临时文件。
+// 空文件返回空字符串
+func SniffContentType(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	rest := io.MultiReader(bytes.NewReader(head), r)
+	if n == 0 {
+		return "", rest, nil
+	}
+	return mimetype.Detect(head).String(), rest, nil
+}
+
+// CheckContent 按策略检查识别出的文件类型：MIME 类型须允许上传，与扩展名不符时按 content_mismatch 处理。
+// 通过后 result.ContentType 更新为识别出的类型；contentType 为空（空文件）时保留按扩展名得到的类型
+func (p *UploadPolicy) CheckContent(result *FileValidationResult, contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	if err := p.CheckMIMEType(contentType); err != nil {
+		return err
+	}
+	if !contentMatches(result.Extension, contentType, p.ActiveContentTypes) {
+		active := IsActiveContent(contentType, p.ActiveContentTypes)
+		if p.ContentMismatch == MismatchReject || (p.ContentMismatch == MismatchActive && active) {
+			return denied("文件内容（%s）与扩展名 %s 不符", mediaTypeOf(contentType), result.Extension)
+		}
+	}
+	result.ContentType = contentType
+	return nil
+}
+
+// IsActiveContent 判断内容类型是否属于浏览器可能执行的类型，这类文件只能以附件形式下载
+func IsActiveContent(contentType string, activeTypes []string) bool {
+	return matchMIME(normalizeList(activeTypes), mediaTypeOf(contentType))
+}
+
+// contentMatches 判断识别出的类型与扩展名是否相符。
+// 无法识别的二进制内容、没有扩展名以及系统不认识的扩展名只要内容不可执行都视为相符，避免误拒
+func contentMatches(ext, contentType string, activeTypes []string) bool {
+	detected := mimetype.Lookup(mediaTypeOf(contentType))
+	if ext == "" || detected == nil || detected.Is("application/octet-stream") {
+		return true
+	}
+	expected := mediaTypeOf(mime.TypeByExtension(ext))
+
+	// 沿层级向上比较，如 docx 也是 zip；text/plain 是所有文本类型的上级，单独判断
+	for m := detected; m != nil && !m.Is("text/plain") && !m.Is("application/octet-stream"); m = m.Parent() {
+		if m.Extension() == ext || (expected != "" && m.Is(expected)) {
+			return true
+		}
+	}
+	active := IsActiveContent(contentType, activeTypes)
+	if isText(detected) && !active {
+		return expected == "" || isTextType(expected)
+	}
+	return expected == "" && !active
+}
+
+// isText 判断识别出的类型是否为文本
+func isText(m *mimetype.MIME) bool {
+	for ; m != nil; m = m.Parent() {
+		if m.Is("text/plain") {
+			return true
+		}
+	}
+	return false
+}
+
+// isTextType 判断按扩展名得到的类型是否为文本格式
+func isTextType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") ||
+		mediaType == "application/json" || mediaType == "application/xml" ||
+		mediaType == "application/yaml" || mediaType == "application/x-yaml"
+}
+
+// mediaTypeOf 去掉 charset 等参数，返回小写的 type/subtype
+func mediaTypeOf(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
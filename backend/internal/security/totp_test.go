@@ -0,0 +1,120 @@
+package security
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中 SHA1 测试向量的密钥 "12345678901234567890"（Base32 编码）
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 附录 B 的 SHA1 测试向量，验证码取 8 位结果的后 6 位
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("T=%d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("T=%d: 验证码应为 %s，实际为 %s", v.unix, v.code, code)
+		}
+	}
+
+	// 密钥大小写和填充不影响结果
+	code, err := TOTPCode(strings.ToLower(rfc6238Secret)+"====", time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Errorf("应忽略大小写和填充，实际为 %s, %v", code, err)
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	// 第一个向量紧挨 Unix 纪元，早两个窗口会落在负时间上，不参与窗口测试
+	for _, v := range rfc6238Vectors[1:] {
+		issued := time.Unix(v.unix, 0)
+		step := totpStep(issued)
+		tests := []struct {
+			name   string
+			offset time.Duration
+			ok     bool
+		}{
+			{name: "当前时间窗口", ok: true},
+			{name: "晚一个时间窗口", offset: TOTPPeriod * time.Second, ok: true},
+			{name: "早一个时间窗口", offset: -TOTPPeriod * time.Second, ok: true},
+			{name: "晚两个时间窗口", offset: 2 * TOTPPeriod * time.Second},
+			{name: "早两个时间窗口", offset: -2 * TOTPPeriod * time.Second},
+		}
+		for _, tt := range tests {
+			got, ok := ValidateTOTP(rfc6238Secret, v.code, issued.Add(tt.offset), 0)
+			if ok != tt.ok {
+				t.Errorf("T=%d %s: 结果应为 %v", v.unix, tt.name, tt.ok)
+				continue
+			}
+			if ok && got != step {
+				t.Errorf("T=%d %s: 应返回验证码所在的步数 %d，实际为 %d", v.unix, tt.name, step, got)
+			}
+		}
+	}
+}
+
+func TestValidateTOTPReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := totpStep(now)
+	code, _ := TOTPCode(rfc6238Secret, now)
+
+	if _, ok := ValidateTOTP(rfc6238Secret, code, now, step-1); !ok {
+		t.Error("上次使用的步数之后的验证码应通过")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, code, now, step); ok {
+		t.Error("同一时间窗口内已使用的验证码应被拒绝")
+	}
+	// 已使用下一个窗口的验证码后，上一个窗口的验证码也不能再用
+	if _, ok := ValidateTOTP(rfc6238Secret, code, now, step+1); ok {
+		t.Error("早于上次使用步数的验证码应被拒绝")
+	}
+}
+
+func TestValidateTOTPInvalidInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{name: "错误的验证码", secret: rfc6238Secret, code: "123456"},
+		{name: "位数不足", secret: rfc6238Secret, code: "28708"},
+		{name: "8 位验证码", secret: rfc6238Secret, code: "94287082"},
+		{name: "无效的密钥", secret: "not base32!", code: "287082"},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, now, 0); ok {
+			t.Errorf("%s: 应被拒绝", tt.name)
+		}
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, " 287082 ", now, 0); !ok {
+		t.Error("应忽略首尾空白")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("160 位密钥编码后应为 32 个字符，实际为 %d", len(secret))
+	}
+	if _, err := TOTPCode(secret, time.Now()); err != nil {
+		t.Errorf("生成的密钥无法使用: %v", err)
+	}
+}
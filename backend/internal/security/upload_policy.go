@@ -0,0 +1,210 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+)
+
+var (
+	// ErrFileTooLarge 文件超过大小上限
+	ErrFileTooLarge = errors.New("文件大小超过限制")
+	// ErrFileTypeDenied 文件扩展名或 MIME 类型不允许上传
+	ErrFileTypeDenied = errors.New("不允许上传该类型的文件")
+)
+
+// policyError 违反上传策略的错误，消息面向用户，可用 errors.Is 判断类别
+type policyError struct {
+	kind error
+	msg  string
+}
+
+func (e *policyError) Error() string { return e.msg }
+func (e *policyError) Unwrap() error { return e.kind }
+
+func denied(format string, args ...any) error {
+	return &policyError{kind: ErrFileTypeDenied, msg: fmt.Sprintf(format, args...)}
+}
+
+// OtherCategory 不属于任何分类的文件
+const OtherCategory = "other"
+
+// UploadCategory 文件分类
+type UploadCategory struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions"`
+	MaxSize    int64    `json:"maxSize"` // 字节，0 表示不限制
+}
+
+// UploadPolicy 某个用户实际适用的上传策略，大小单位为字节，0 表示不限制
+type UploadPolicy struct {
+	MaxSize            int64            `json:"maxSize"`        // 全局上限
+	DefaultMaxSize     int64            `json:"defaultMaxSize"` // 不属于任何分类的文件的上限
+	Categories         []UploadCategory `json:"categories"`
+	AllowedExtensions  []string         `json:"allowedExtensions"` // 为空表示不限制
+	DeniedExtensions   []string         `json:"deniedExtensions"`
+	AllowedMIMETypes   []string         `json:"allowedMimeTypes"` // 为空表示不限制
+	DeniedMIMETypes    []string         `json:"deniedMimeTypes"`
+	ContentMismatch    string           `json:"contentMismatch"`    // 内容与扩展名不符时的处理方式
+	ActiveContentTypes []string         `json:"activeContentTypes"` // 总是以附件形式下载的类型
+}
+
+const mb = 1024 * 1024
+
+// NewUploadPolicy 根据配置生成指定角色和用户的上传策略，匹配的第一项调整（overrides）会被应用
+func NewUploadPolicy(cfg config.UploadConfig, role, username string) *UploadPolicy {
+	p := &UploadPolicy{
+		MaxSize:            cfg.MaxSizeMB * mb,
+		DefaultMaxSize:     cfg.DefaultMaxSizeMB * mb,
+		AllowedExtensions:  normalizeExtensions(cfg.AllowedExtensions),
+		DeniedExtensions:   normalizeExtensions(cfg.DeniedExtensions),
+		AllowedMIMETypes:   normalizeList(cfg.AllowedMIMETypes),
+		DeniedMIMETypes:    normalizeList(cfg.DeniedMIMETypes),
+		ContentMismatch:    cfg.ContentMismatch,
+		ActiveContentTypes: normalizeList(cfg.ActiveContentTypes),
+	}
+	for name, c := range cfg.Categories {
+		p.Categories = append(p.Categories, UploadCategory{
+			Name:       name,
+			Extensions: normalizeExtensions(c.Extensions),
+			MaxSize:    c.MaxSizeMB * mb,
+		})
+	}
+	sort.Slice(p.Categories, func(i, j int) bool { return p.Categories[i].Name < p.Categories[j].Name })
+
+	for _, o := range cfg.Overrides {
+		if !slices.Contains(o.Roles, role) && !slices.Contains(o.Users, username) {
+			continue
+		}
+		if o.MaxSizeMB != nil {
+			p.MaxSize = *o.MaxSizeMB * mb
+		}
+		for i, c := range p.Categories {
+			if size, ok := o.Categories[c.Name]; ok {
+				p.Categories[i].MaxSize = size * mb
+			}
+		}
+		allow := normalizeExtensions(o.AllowExtensions)
+		p.DeniedExtensions = slices.DeleteFunc(p.DeniedExtensions, func(ext string) bool { return slices.Contains(allow, ext) })
+		if len(p.AllowedExtensions) > 0 {
+			p.AllowedExtensions = append(p.AllowedExtensions, allow...)
+		}
+		p.DeniedExtensions = append(p.DeniedExtensions, normalizeExtensions(o.DenyExtensions)...)
+		break
+	}
+	return p
+}
+
+// Largest 返回该策略允许上传的最大文件大小，0 表示不限制；用于在解析请求体之前拒绝过大的请求
+func (p *UploadPolicy) Largest() int64 {
+	largest := p.DefaultMaxSize
+	for _, c := range p.Categories {
+		if c.MaxSize == 0 {
+			largest = 0
+			break
+		}
+		if largest != 0 && c.MaxSize > largest {
+			largest = c.MaxSize
+		}
+	}
+	if p.MaxSize > 0 && (largest == 0 || p.MaxSize < largest) {
+		largest = p.MaxSize
+	}
+	return largest
+}
+
+// Check 按策略检查文件名对应的类型和文件大小
+func (p *UploadPolicy) Check(filename string, size int64) FileValidationResult {
+	ext := strings.ToLower(filepath.Ext(filename))
+	result := FileValidationResult{Extension: ext, FileType: OtherCategory}
+	fail := func(err error) FileValidationResult {
+		result.Error = err
+		return result
+	}
+
+	if slices.Contains(p.DeniedExtensions, ext) {
+		return fail(denied("不允许上传 %s 类型的文件", ext))
+	}
+	if len(p.AllowedExtensions) > 0 && !slices.Contains(p.AllowedExtensions, ext) {
+		if ext == "" {
+			return fail(denied("不允许上传没有扩展名的文件"))
+		}
+		return fail(denied("不允许上传 %s 类型的文件", ext))
+	}
+
+	result.ContentType = mime.TypeByExtension(ext)
+	if result.ContentType == "" {
+		result.ContentType = "application/octet-stream"
+	}
+	if err := p.CheckMIMEType(result.ContentType); err != nil {
+		return fail(err)
+	}
+
+	maxSize := p.DefaultMaxSize
+	for _, c := range p.Categories {
+		if slices.Contains(c.Extensions, ext) {
+			result.FileType, maxSize = c.Name, c.MaxSize
+			break
+		}
+	}
+	if p.MaxSize > 0 && (maxSize == 0 || p.MaxSize < maxSize) {
+		maxSize = p.MaxSize
+	}
+	if maxSize > 0 && size > maxSize {
+		return fail(&policyError{kind: ErrFileTooLarge, msg: fmt.Sprintf("文件大小超过限制，最大允许 %d MB", maxSize/mb)})
+	}
+
+	result.IsValid = true
+	return result
+}
+
+// CheckMIMEType 检查 MIME 类型是否允许上传
+func (p *UploadPolicy) CheckMIMEType(contentType string) error {
+	mediaType := mediaTypeOf(contentType)
+	if matchMIME(p.DeniedMIMETypes, mediaType) ||
+		(len(p.AllowedMIMETypes) > 0 && !matchMIME(p.AllowedMIMETypes, mediaType)) {
+		return denied("不允许上传 %s 类型的文件", mediaType)
+	}
+	return nil
+}
+
+// matchMIME 判断 MIME 类型是否匹配列表中的任一项，支持 image/* 形式
+func matchMIME(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeExtensions 扩展名统一为小写并以点开头
+func normalizeExtensions(list []string) []string {
+	out := make([]string, 0, len(list))
+	for _, ext := range normalizeList(list) {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		out = append(out, ext)
+	}
+	return out
+}
+
+func normalizeList(list []string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/encryption"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+)
+
+// File 打开的存储文件，加密保存的文件读取时透明解密；Size 为明文大小，支持随机读取以便按范围下载
+type File interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	Size() int64
+	ModTime() time.Time
+}
+
+var (
+	// keys 静态加密的主密钥，未配置时为 nil，此时不识别加密文件
+	keys *encryption.Keyring
+	// encryptNew 新保存的文件是否加密
+	encryptNew bool
+	// chunkSize 加密分块大小（字节）
+	chunkSize int
+)
+
+// initEncryption 加载主密钥；未启用加密但仍配置了密钥时，已加密的文件照常可读
+func initEncryption(cfg config.EncryptionConfig) error {
+	k, err := encryption.NewKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	keys, encryptNew, chunkSize = k, cfg.Enabled && k != nil, cfg.ChunkSize*1024
+	if cfg.KeyFile != "" {
+		if info, err := os.Stat(cfg.KeyFile); err == nil && info.Mode().Perm()&0077 != 0 {
+			logger.Warn("主密钥文件 %s 的权限为 %v，建议改为 0600", cfg.KeyFile, info.Mode().Perm())
+		}
+	}
+	if encryptNew {
+		logger.Info("新保存的文件将加密存储，当前主密钥 %s", k.Primary())
+	}
+	return nil
+}
+
+// writeContent 将 reader 的内容写入 file，启用加密时写入加密后的内容
+func writeContent(file io.Writer, reader io.Reader) error {
+	if !encryptNew {
+		_, err := io.Copy(file, reader)
+		return err
+	}
+	w, err := keys.NewWriter(file, chunkSize)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// openFile 打开存储中的文件，加密文件返回解密后的视图
+func openFile(fullPath string) (File, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if keys == nil || !isEncrypted(f) {
+		return &plainFile{File: f, info: info}, nil
+	}
+	r, err := keys.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &encryptedFile{SectionReader: io.NewSectionReader(r, 0, r.Size()), file: f, modTime: info.ModTime()}, nil
+}
+
+// isEncrypted 读取文件开头判断是否为加密文件
+func isEncrypted(f io.ReaderAt) bool {
+	head := make([]byte, encryption.HeaderSize)
+	n, _ := f.ReadAt(head, 0)
+	return encryption.IsEncrypted(head[:n])
+}
+
+// plainFile 未加密的文件
+type plainFile struct {
+	*os.File
+	info fs.FileInfo
+}
+
+func (f *plainFile) Size() int64        { return f.info.Size() }
+func (f *plainFile) ModTime() time.Time { return f.info.ModTime() }
+
+// encryptedFile 加密文件的解密视图
+type encryptedFile struct {
+	*io.SectionReader
+	file    *os.File
+	modTime time.Time
+}
+
+func (f *encryptedFile) Close() error       { return f.file.Close() }
+func (f *encryptedFile) ModTime() time.Time { return f.modTime }
+
+// plainInfo 列目录时将加密文件的大小换算为明文大小
+func plainInfo(fullPath string, info fs.FileInfo) fs.FileInfo {
+	if keys == nil || !info.Mode().IsRegular() {
+		return info
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return info
+	}
+	defer f.Close()
+	if !isEncrypted(f) {
+		return info
+	}
+	size, err := encryption.PlainSize(f, info.Size())
+	if err != nil {
+		return info
+	}
+	return sizedInfo{FileInfo: info, size: size}
+}
+
+type sizedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i sizedInfo) Size() int64 { return i.size }
+
+// KeyStats 存储目录（含回收站）、隔离区和缩略图缓存中文件的加密情况
+type KeyStats struct {
+	Enabled    bool           `json:"enabled"`
+	PrimaryKey string         `json:"primaryKey"`
+	Keys       []string       `json:"keys"`      // 已配置的主密钥标识，第一个为当前主密钥
+	Files      map[string]int `json:"files"`     // 各主密钥包装的文件数
+	Plaintext  int            `json:"plaintext"` // 未加密的文件数
+	Rewrapped  int            `json:"rewrapped"` // 本次重新包装的文件数
+	Failed     []string       `json:"failed"`    // 主密钥未配置或文件头损坏的文件
+}
+
+// ErrNoMasterKey 未配置主密钥
+var ErrNoMasterKey = errors.New("未配置静态加密的主密钥")
+
+// RewrapKeys 统计文件的加密情况；rewrap 为 true 时把旧主密钥包装的数据密钥改用当前主密钥包装。
+// 只重写每个文件开头的文件头，不重新加密文件内容；全部完成后即可从配置中移除旧主密钥
+func RewrapKeys(ctx context.Context, rewrap bool) (*KeyStats, error) {
+	if rewrap && keys == nil {
+		return nil, ErrNoMasterKey
+	}
+	stats := &KeyStats{Enabled: encryptNew, Keys: []string{}, Files: map[string]int{}, Failed: []string{}}
+	if keys != nil {
+		stats.PrimaryKey = keys.Primary().String()
+		for _, id := range keys.IDs() {
+			stats.Keys = append(stats.Keys, id.String())
+		}
+	}
+
+	visit := func(root string) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root && errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			flag := os.O_RDONLY
+			if rewrap {
+				flag = os.O_RDWR
+			}
+			f, err := os.OpenFile(path, flag, 0)
+			if err != nil {
+				stats.Failed = append(stats.Failed, path)
+				return nil
+			}
+			defer f.Close()
+			if !isEncrypted(f) {
+				stats.Plaintext++
+				return nil
+			}
+			id, err := encryption.KeyOf(f)
+			if err == nil && rewrap {
+				var changed bool
+				if changed, err = keys.Rewrap(f); err == nil {
+					id = keys.Primary()
+					if changed {
+						stats.Rewrapped++
+						err = f.Sync()
+					}
+				}
+			}
+			if err != nil {
+				logger.Warn("无法处理加密文件 %s: %v", path, err)
+				stats.Failed = append(stats.Failed, path)
+				return nil
+			}
+			stats.Files[id.String()]++
+			return nil
+		})
+	}
+	if err := visit(StoragePath); err != nil {
+		return stats, err
+	}
+	// 缩略图缓存同样以主密钥包装，轮换后不重新包装会在移除旧主密钥后无法读取
+	for _, root := range []string{QuarantinePath, ThumbnailPath} {
+		if root == "" {
+			continue
+		}
+		if err := visit(root); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
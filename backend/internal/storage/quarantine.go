@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// QuarantinePath 隔离区目录，检出恶意内容的文件移到这里，只有管理员可以恢复或删除
+var QuarantinePath string
+
+// ErrRestoreConflict 恢复隔离文件时原位置已存在同名文件
+var ErrRestoreConflict = errors.New("原位置已存在同名文件")
+
+// QuarantineFile 将存储中的文件移入隔离区，返回隔离区中的文件名
+func QuarantineFile(filePath string) (string, error) {
+	if QuarantinePath == "" {
+		return "", errors.New("未配置隔离区目录")
+	}
+	if err := os.MkdirAll(QuarantinePath, 0700); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(filePath))
+	if err := moveFile(filepath.Join(StoragePath, filePath), filepath.Join(QuarantinePath, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// RestoreQuarantined 将隔离区中的文件移回存储目录的原位置，原位置已有文件时返回错误
+func RestoreQuarantined(name, filePath string) error {
+	target := filepath.Join(StoragePath, filePath)
+	if _, err := os.Stat(target); err == nil {
+		return ErrRestoreConflict
+	}
+	if err := ensureDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	return moveFile(filepath.Join(QuarantinePath, filepath.Base(name)), target)
+}
+
+// DeleteQuarantined 删除隔离区中的文件
+func DeleteQuarantined(name string) error {
+	err := os.Remove(filepath.Join(QuarantinePath, filepath.Base(name)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// moveFile 移动文件；隔离区和存储目录通常位于不同的卷，无法重命名时复制后删除原文件
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -11,8 +11,12 @@ import (
 	"syscall"
 
 	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
 )
 
+// ErrNotExist 存储中没有该文件
+var ErrNotExist = errors.New("文件不存在")
+
 var (
 	// StoragePath 存储路径
 	StoragePath string
@@ -24,16 +28,21 @@ var (
 func InitStorage(cfg *config.Config) error {
 	StoragePath = cfg.Storage.Path
 	MappedPath = cfg.Storage.MappedPath
+	QuarantinePath = cfg.Scan.QuarantinePath
+	ThumbnailPath = cfg.ImageHost.ThumbnailPath
+	if err := initEncryption(cfg.Encryption); err != nil {
+		return fmt.Errorf("加载静态加密主密钥失败: %w", err)
+	}
 
 	// 确保存储目录存在（如果路径不是绝对路径或者是可写的）
 	if err := ensureDir(StoragePath); err != nil {
 		// 如果无法创建目录（比如权限问题），只记录警告但不返回错误
-		fmt.Printf("警告: 无法创建存储目录 %s: %v\n", StoragePath, err)
+		logger.Warn("无法创建存储目录 %s: %v", StoragePath, err)
 	}
 
 	// 确保映射目录存在
 	if err := ensureDir(MappedPath); err != nil {
-		fmt.Printf("警告: 无法创建映射目录 %s: %v\n", MappedPath, err)
+		logger.Warn("无法创建映射目录 %s: %v", MappedPath, err)
 	}
 
 	return nil
@@ -87,8 +96,8 @@ func SaveFile(userID uint, dirPath string, filename string, fileSize int64, read
 	}
 	defer file.Close()
 
-	// 写入文件内容
-	if _, err := io.Copy(file, reader); err != nil {
+	// 写入文件内容，启用静态加密时写入密文
+	if err := writeContent(file, reader); err != nil {
 		os.Remove(filePath) // 如果写入失败，删除文件
 		return "", err
 	}
@@ -102,18 +111,18 @@ func SaveFile(userID uint, dirPath string, filename string, fileSize int64, read
 	return relPath, nil
 }
 
-// GetFile 获取文件
-func GetFile(userID uint, filePath string) (*os.File, error) {
+// GetFile 获取文件，加密保存的文件返回解密后的内容
+func GetFile(userID uint, filePath string) (File, error) {
 	// 构建完整的文件路径，直接使用存储路径
 	fullPath := filepath.Join(StoragePath, filePath)
 
 	// 检查文件是否存在
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return nil, errors.New("文件不存在")
+		return nil, ErrNotExist
 	}
 
 	// 打开文件
-	return os.Open(fullPath)
+	return openFile(fullPath)
 }
 
 // DeleteFile 删除文件
@@ -207,7 +216,7 @@ func ListDirectory(userID uint, dirPath string) ([]fs.FileInfo, error) {
 		if err != nil {
 			continue
 		}
-		fileInfos = append(fileInfos, info)
+		fileInfos = append(fileInfos, plainInfo(filepath.Join(fullPath, entry.Name()), info))
 	}
 	
 	return fileInfos, nil
@@ -227,4 +236,16 @@ func GetSystemStorageInfo() (total int64, used int64, free int64, err error) {
 	used = total - free
 
 	return total, used, free, nil
-}
\ No newline at end of file
+}
+// CheckWritable 在存储目录中创建并删除一个临时文件，检查存储是否可写
+func CheckWritable() error {
+	f, err := os.CreateTemp(StoragePath, ".probe-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, werr := f.Write([]byte("ok"))
+	cerr := f.Close()
+	rerr := os.Remove(name)
+	return errors.Join(werr, cerr, rerr)
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ThumbnailPath 缩略图缓存目录，为空时不缓存
+var ThumbnailPath string
+
+// GetThumbnail 读取缓存的缩略图，未缓存时返回 ErrNotExist
+func GetThumbnail(name string) (File, error) {
+	if ThumbnailPath == "" {
+		return nil, ErrNotExist
+	}
+	f, err := openFile(filepath.Join(ThumbnailPath, filepath.Base(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	return f, err
+}
+
+// SaveThumbnail 缓存生成的缩略图，启用静态加密时同样加密保存。
+// 先写入临时文件再重命名，并发生成同一缩略图时不会读到写了一半的文件
+func SaveThumbnail(name string, data []byte) error {
+	if ThumbnailPath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(ThumbnailPath, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(ThumbnailPath, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if err := writeContent(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(ThumbnailPath, filepath.Base(name)))
+}
+
+// DeleteThumbnails 删除 prefix 开头的缓存缩略图
+func DeleteThumbnails(prefix string) error {
+	if ThumbnailPath == "" {
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Join(ThumbnailPath, filepath.Base(prefix)+"*"))
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := os.Remove(m); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeThumbnails 删除生成时间早于 maxAge 的缓存缩略图，已删除或已修改的图片留下的缓存由此清理
+func PurgeThumbnails(ctx context.Context, maxAge time.Duration) error {
+	if ThumbnailPath == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(ThumbnailPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	before := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().After(before) {
+			continue
+		}
+		os.Remove(filepath.Join(ThumbnailPath, e.Name()))
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huanhq99/H-Cloud/internal/config"
+	"github.com/huanhq99/H-Cloud/internal/logger"
+)
+
+// 客户端证书认证方式
+const (
+	ClientAuthNone     = "none"     // 不请求客户端证书
+	ClientAuthOptional = "optional" // 客户端提供证书时校验，不提供也允许连接
+	ClientAuthRequire  = "require"  // 必须提供由 client_ca_file 签发的证书
+)
+
+// Manager 管理服务端证书和客户端 CA，文件在磁盘上更新后由 Reload 重新加载，新连接立即使用新证书
+type Manager struct {
+	cfg config.TLSConfig
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	stamp     string // 证书文件的修改时间和大小，用于判断文件是否变化
+	failed    string // 最近一次加载失败时的文件状态，文件再次变化前不重复加载和报错
+}
+
+// NewManager 加载证书并创建管理器，证书无法加载时返回错误
+func NewManager(cfg config.TLSConfig) (*Manager, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("启用 TLS 时必须配置 cert_file 和 key_file")
+	}
+	if cfg.ClientAuth != ClientAuthNone && cfg.ClientAuth != "" && cfg.ClientCAFile == "" {
+		return nil, errors.New("启用客户端证书认证时必须配置 client_ca_file")
+	}
+	m := &Manager{cfg: cfg}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TLSConfig 返回服务端 TLS 配置
+func (m *Manager) TLSConfig() (*tls.Config, error) {
+	minVersion, err := parseVersion(m.cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{
+		MinVersion: minVersion,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			m.mu.RLock()
+			defer m.mu.RUnlock()
+			return m.cert, nil
+		},
+	}
+	// 显式声明 ALPN 协议，按连接生成的配置也能协商 HTTP/2
+	if m.cfg.HTTP2 {
+		tc.NextProtos = []string{"h2", "http/1.1"}
+	} else {
+		tc.NextProtos = []string{"http/1.1"}
+	}
+
+	switch m.cfg.ClientAuth {
+	case ClientAuthNone, "":
+		return tc, nil
+	case ClientAuthOptional:
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("不支持的客户端证书认证方式: %s", m.cfg.ClientAuth)
+	}
+	// 每个连接取当前的客户端 CA，CA 文件更新后无需重启
+	tc.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := tc.Clone()
+		c.GetConfigForClient = nil
+		m.mu.RLock()
+		c.ClientCAs = m.clientCAs
+		m.mu.RUnlock()
+		return c, nil
+	}
+	return tc, nil
+}
+
+// Reload 证书、私钥或客户端 CA 文件有变化时重新加载；加载失败时继续使用原证书并返回错误。
+// 按修改时间轮询而不是监听文件事件，Kubernetes Secret 等通过替换符号链接更新的挂载方式也能生效
+func (m *Manager) Reload(ctx context.Context) error {
+	stamp, err := m.fileStamp()
+	if err != nil {
+		return err
+	}
+	m.mu.RLock()
+	unchanged := stamp == m.stamp || stamp == m.failed
+	m.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	if err := m.load(); err != nil {
+		m.mu.Lock()
+		m.failed = stamp
+		m.mu.Unlock()
+		return fmt.Errorf("%w，继续使用原证书", err)
+	}
+	return nil
+}
+
+// load 读取证书和客户端 CA
+func (m *Manager) load() error {
+	stamp, err := m.fileStamp()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(m.cfg.CertFile, m.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("加载 TLS 证书失败: %w", err)
+	}
+	var pool *x509.CertPool
+	if m.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(m.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("读取客户端 CA 证书失败: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("客户端 CA 文件 %s 中没有有效的证书", m.cfg.ClientCAFile)
+		}
+	}
+
+	m.mu.Lock()
+	m.cert, m.clientCAs, m.stamp, m.failed = &cert, pool, stamp, ""
+	m.mu.Unlock()
+	if cert.Leaf != nil {
+		logger.Info("已加载 TLS 证书 %s，有效期至 %s", cert.Leaf.Subject.CommonName, cert.Leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// fileStamp 汇总相关文件的修改时间和大小
+func (m *Manager) fileStamp() (string, error) {
+	var b strings.Builder
+	for _, path := range []string{m.cfg.CertFile, m.cfg.KeyFile, m.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("读取证书文件失败: %w", err)
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+	return b.String(), nil
+}
+
+// parseVersion 解析最低 TLS 版本，只允许 1.2 和 1.3
+func parseVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("不支持的最低 TLS 版本: %s（可选 1.2、1.3）", v)
+	}
+}
+
+// RedirectHandler 将 HTTP 请求永久重定向到 HTTPS，httpsPort 为 443 时地址中省略端口
+func RedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 地址
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -73,7 +73,8 @@ update_version() {
 # 函数：构建 Docker 镜像
 build_docker_image() {
     local version=$1
-    local build_date=$(date +"%Y-%m-%d")
+    local build_date=$(date -u +"%Y-%m-%dT%H:%M:%SZ")
+    local commit=$(git rev-parse --short HEAD 2>/dev/null || echo "")
     
     print_info "开始构建 AMD64 Docker 镜像..."
     
@@ -81,6 +82,7 @@ build_docker_image() {
     docker build --platform linux/amd64 \
         --build-arg VERSION="$version" \
         --build-arg BUILD_DATE="$build_date" \
+        --build-arg COMMIT="$commit" \
         -f amd64-dockerfile \
         -t "${DOCKER_USERNAME}/${DOCKER_REPO}:${version}" \
         -t "${DOCKER_USERNAME}/${DOCKER_REPO}:latest" \